@@ -0,0 +1,94 @@
+package mdserve
+
+import (
+    "html"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var abbrDef = regexp.MustCompile(`^ {0,3}\*\[([^\]\n]+)\]:[ \t]*(.*?)\s*$`)
+
+// Remove "*[HTML]: HyperText Markup Language" definitions from the source
+// and return them
+func extractAbbreviations(src string) (string, map[string]string) {
+    abbrs := map[string]string{}
+    if !strings.Contains(src, "*[") {
+        return src, abbrs
+    }
+    src = mapProse(src, func(text string) string {
+        m := abbrDef.FindStringSubmatch(text)
+        if m == nil {
+            return text
+        }
+        abbrs[strings.TrimSpace(m[1])] = m[2]
+        return ""
+    })
+    return src, abbrs
+}
+
+var elementTag = regexp.MustCompile(`<(/?)([a-zA-Z0-9]+)[^>]*>`)
+
+// Wrap the defined abbreviations in <abbr title="..."> wherever they appear
+// as whole words in the text of a page, except in code and existing <abbr>
+func applyAbbreviations(page string, abbrs map[string]string) string {
+    if len(abbrs) == 0 {
+        return page
+    }
+    keys := make([]string, 0, len(abbrs))
+    for k := range abbrs {
+        keys = append(keys, k)
+    }
+    sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+    titles := map[string]string{}
+    var alts []string
+    for _, k := range keys {
+        escaped := html.EscapeString(k)
+        titles[escaped] = html.EscapeString(abbrs[k])
+        alt := regexp.QuoteMeta(escaped)
+        if isWordByte(escaped[0]) {
+            alt = `\b` + alt
+        }
+        if isWordByte(escaped[len(escaped)-1]) {
+            alt += `\b`
+        }
+        alts = append(alts, alt)
+    }
+    pattern := regexp.MustCompile(strings.Join(alts, "|"))
+    wrap := func(text string) string {
+        return pattern.ReplaceAllStringFunc(text, func(m string) string {
+            return `<abbr title="` + titles[m] + `">` + m + `</abbr>`
+        })
+    }
+
+    var out strings.Builder
+    skip := 0 // Depth inside code, pre, abbr, script or style
+    last := 0
+    for _, loc := range elementTag.FindAllStringSubmatchIndex(page, -1) {
+        text := page[last:loc[0]]
+        if skip == 0 {
+            text = wrap(text)
+        }
+        out.WriteString(text)
+        out.WriteString(page[loc[0]:loc[1]])
+        last = loc[1]
+        switch strings.ToLower(page[loc[4]:loc[5]]) {
+        case "code", "pre", "abbr", "script", "style":
+            if loc[3] > loc[2] {
+                skip--
+            } else {
+                skip++
+            }
+        }
+    }
+    if skip == 0 {
+        out.WriteString(wrap(page[last:]))
+    } else {
+        out.WriteString(page[last:])
+    }
+    return out.String()
+}
+
+func isWordByte(c byte) bool {
+    return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,173 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "fmt"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+)
+
+const rotatedStamp = "2006-01-02T15-04-05.000"
+
+// AccessLogOptions control the format and rotation of an AccessLog
+type AccessLogOptions struct {
+    Format  string        // "combined" (default), the Apache/nginx format, or "json"
+    MaxSize int64         // Rotate once the file holds this many bytes, 0 never
+    MaxAge  time.Duration // Rotate once the file has been written to this long, 0 never
+    Keep    int           // Rotated files kept, oldest removed first; 0 keeps all
+}
+
+// An AccessLog writes a line per request to a file. Rotated files get the
+// time of rotation appended to their name, as in
+// access.log.2006-01-02T15-04-05.000. Add it with Server.Use(log.Middleware).
+type AccessLog struct {
+    opts   AccessLogOptions
+    path   string
+    mu     sync.Mutex
+    f      *os.File
+    size   int64
+    opened time.Time
+}
+
+// OpenAccessLog opens (creating if needed) an access log, appending to it
+func OpenAccessLog(path string, opts AccessLogOptions) (*AccessLog, error) {
+    if opts.Format == "" {
+        opts.Format = "combined"
+    }
+    if opts.Format != "combined" && opts.Format != "json" {
+        return nil, fmt.Errorf("unknown access log format %q", opts.Format)
+    }
+    l := &AccessLog{opts: opts, path: path}
+    if err := l.open(); err != nil {
+        return nil, err
+    }
+    return l, nil
+}
+
+func (l *AccessLog) open() error {
+    f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+    if err != nil {
+        return fmt.Errorf("could not open access log %s: %v", l.path, err)
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return err
+    }
+    l.f, l.size, l.opened = f, info.Size(), time.Now()
+    return nil
+}
+
+// Middleware logs each request once its response is written
+func (l *AccessLog) Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        sw := &statusWriter{ResponseWriter: w}
+        next.ServeHTTP(sw, r)
+        if sw.status == 0 {
+            sw.status = http.StatusOK
+        }
+        l.write(l.entry(r, sw, start))
+    })
+}
+
+// Format one request's line
+func (l *AccessLog) entry(r *http.Request, sw *statusWriter, start time.Time) []byte {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        host = r.RemoteAddr
+    }
+    user, _, _ := r.BasicAuth()
+    if l.opts.Format == "json" {
+        line, _ := json.Marshal(struct {
+            Time      time.Time `json:"time"`
+            Remote    string    `json:"remote"`
+            User      string    `json:"user,omitempty"`
+            Method    string    `json:"method"`
+            URI       string    `json:"uri"`
+            Proto     string    `json:"proto"`
+            Status    int       `json:"status"`
+            Bytes     int64     `json:"bytes"`
+            Duration  float64   `json:"duration_ms"`
+            Referer   string    `json:"referer,omitempty"`
+            UserAgent string    `json:"user_agent,omitempty"`
+            RequestID string    `json:"request_id,omitempty"`
+        }{start, host, user, r.Method, r.RequestURI, r.Proto, sw.status, sw.written,
+            float64(time.Since(start).Microseconds()) / 1000, r.Referer(), r.UserAgent(), RequestID(r)})
+        return append(line, '\n')
+    }
+    // The request ID follows the combined fields, as log parsers allow
+    return []byte(fmt.Sprintf("%s - %s [%s] %q %d %d %q %q %s\n",
+        host, orDash(user), start.Format("02/Jan/2006:15:04:05 -0700"),
+        r.Method+" "+r.RequestURI+" "+r.Proto, sw.status, sw.written, orDash(r.Referer()), orDash(r.UserAgent()), orDash(RequestID(r))))
+}
+
+func orDash(s string) string {
+    if s == "" {
+        return "-"
+    }
+    return s
+}
+
+// Append a line, rotating the file first when it is full or old enough
+func (l *AccessLog) write(line []byte) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f == nil {
+        return
+    }
+    full := l.opts.MaxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.opts.MaxSize
+    old := l.opts.MaxAge > 0 && time.Since(l.opened) >= l.opts.MaxAge
+    if full || old {
+        if err := l.rotate(); err != nil {
+            log.Printf("Access log: %v", err)
+        }
+        if l.f == nil {
+            return
+        }
+    }
+    n, _ := l.f.Write(line)
+    l.size += int64(n)
+}
+
+// Move the current file aside, start a new one and remove the oldest
+// rotated files beyond opts.Keep
+func (l *AccessLog) rotate() error {
+    l.f.Close()
+    rotated := l.path + "." + time.Now().Format(rotatedStamp)
+    renameErr := os.Rename(l.path, rotated)
+    if err := l.open(); err != nil {
+        l.f = nil
+        return err
+    }
+    if renameErr != nil {
+        return renameErr
+    }
+    if l.opts.Keep > 0 {
+        old, _ := filepath.Glob(l.path + ".*")
+        sort.Strings(old) // The stamps sort by time
+        for len(old) > l.opts.Keep {
+            os.Remove(old[0])
+            old = old[1:]
+        }
+    }
+    return nil
+}
+
+// Close the log file; later requests are not logged
+func (l *AccessLog) Close() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f == nil {
+        return nil
+    }
+    err := l.f.Close()
+    l.f = nil
+    return err
+}
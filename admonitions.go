@@ -0,0 +1,123 @@
+package mdserve
+
+import (
+    "fmt"
+    "html"
+    "regexp"
+    "strings"
+)
+
+// MkDocs admonition types mapped onto the alert colours
+var admonitionStyles = map[string]string{
+    "note": "note", "abstract": "note", "summary": "note", "info": "note", "example": "note", "quote": "note",
+    "tip": "tip", "hint": "tip", "success": "tip", "check": "tip", "done": "tip", "question": "tip", "help": "tip", "faq": "tip",
+    "important": "important",
+    "warning": "warning", "caution": "warning", "attention": "warning",
+    "danger": "caution", "error": "caution", "failure": "caution", "fail": "caution", "missing": "caution", "bug": "caution",
+}
+
+var admonitionStart = regexp.MustCompile(`^(!!!|\?\?\?\+?) +([\w-]+)(?: +"(.*)")?\s*$`)
+
+// Replace MkDocs-style admonitions with placeholders:
+//
+//     !!! note "Title"
+//         Indented body.
+//
+// "???" makes the block collapsible (closed), "???+" collapsible but open.
+// An empty title ("") drops the title line of a "!!!" block.
+func (s *Server) expandAdmonitions(src string, placeholders map[string]string, opts markdownOptions) string {
+    lines := strings.SplitAfter(src, "\n")
+    var out strings.Builder
+    fence := ""
+    for i := 0; i < len(lines); i++ {
+        line := lines[i]
+        trimmed := strings.TrimLeft(line, " \t")
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) {
+                fence = ""
+            }
+            out.WriteString(line)
+            continue
+        }
+        if fence = fenceMarker(trimmed); fence != "" {
+            out.WriteString(line)
+            continue
+        }
+
+        m := admonitionStart.FindStringSubmatch(strings.TrimRight(line, "\n"))
+        if m == nil {
+            out.WriteString(line)
+            continue
+        }
+        kind := strings.ToLower(m[2])
+        style, ok := admonitionStyles[kind]
+        if !ok {
+            style = "note"
+        }
+        title := m[3]
+        if !strings.Contains(line, `"`) || (title == "" && m[1] != "!!!") {
+            title = strings.ToUpper(kind[:1]) + kind[1:]
+        }
+
+        // The body is every following indented line, including blank lines
+        // between indented ones
+        var body strings.Builder
+        for i+1 < len(lines) {
+            next := lines[i+1]
+            if strings.TrimSpace(next) == "" {
+                j := i + 1
+                for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+                    j++
+                }
+                if j == len(lines) || unindent(lines[j]) == "" {
+                    break
+                }
+                for ; i+1 < j; i++ {
+                    body.WriteString("\n")
+                }
+                continue
+            }
+            text := unindent(next)
+            if text == "" {
+                break
+            }
+            body.WriteString(text)
+            i++
+        }
+
+        content := string(s.renderMarkdown([]byte(body.String()), opts))
+        class := fmt.Sprintf("alert alert-%s admonition admonition-%s", style, kind)
+        var b strings.Builder
+        switch {
+        case m[1] == "!!!":
+            fmt.Fprintf(&b, "<div class=\"%s\">\n", class)
+            if title != "" {
+                fmt.Fprintf(&b, "<p class=\"alert-title\">%s</p>\n", html.EscapeString(title))
+            }
+            b.WriteString(content + "</div>\n")
+        default:
+            open := ""
+            if m[1] == "???+" {
+                open = " open"
+            }
+            fmt.Fprintf(&b, "<details class=\"%s\"%s>\n<summary class=\"alert-title\">%s</summary>\n%s</details>\n",
+                class, open, html.EscapeString(title), content)
+        }
+        key := fmt.Sprintf("MDSERVEADMONITION%dX", len(placeholders))
+        placeholders[key] = b.String()
+        out.WriteString("\n" + key + "\n\n")
+    }
+    return out.String()
+}
+
+// Strip one level of indentation (four spaces or a tab); "" if the line
+// is not indented
+func unindent(line string) string {
+    switch {
+    case strings.HasPrefix(line, "    "):
+        return line[4:]
+    case strings.HasPrefix(line, "\t"):
+        return line[1:]
+    }
+    return ""
+}
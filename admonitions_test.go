@@ -0,0 +1,44 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestAdmonitions(t *testing.T) {
+    s := New()
+    tests := []struct {
+        name    string
+        src     string
+        want    []string // In the page
+        notWant []string
+    }{
+        {"note", "!!! note\n    Read this.\n", []string{`<div class="alert alert-note admonition admonition-note">`, `<p class="alert-title">Note</p>`, "<p>Read this.</p>"}, []string{"!!!"}},
+        {"custom title", "!!! tip \"Pro tip\"\n    Text.\n", []string{"admonition-tip", "Pro tip</p>"}, nil},
+        {"no title", "!!! warning \"\"\n    Text.\n", []string{"admonition-warning"}, []string{"alert-title"}},
+        {"collapsible", "??? info\n    Hidden.\n", []string{`<details class="alert`, "<summary", "Hidden."}, []string{" open>"}},
+        {"collapsible open", "???+ info\n    Shown.\n", []string{" open>"}, nil},
+        {"blank lines inside", "!!! note\n    One.\n\n    Two.\n\nAfter.\n", []string{"<p>One.</p>", "<p>Two.</p>\n</div>", "<p>After.</p>"}, nil},
+        {"in fenced code", "```\n!!! note\n    Code.\n```\n", []string{"!!! note"}, []string{"admonition-note"}},
+        {"shortcode inside", "!!! tip\n    Press {{< kbd \"Ctrl+C\" >}} to stop.\n", []string{"admonition-tip", "<kbd>Ctrl</kbd>"}, []string{"MDSERVE"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            // Placeholders come out in map order, so render a few times
+            for i := 0; i < 20 && !strings.Contains(page, "MDSERVE"); i++ {
+                page = string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            }
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,65 @@
+package mdserve
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// GitHub alert kinds with their titles and icons
+var alertKinds = map[string][2]string{
+    "NOTE":      {"Note", "ℹ️"},
+    "TIP":       {"Tip", "💡"},
+    "IMPORTANT": {"Important", "📣"},
+    "WARNING":   {"Warning", "⚠️"},
+    "CAUTION":   {"Caution", "🛑"},
+}
+
+var alertStart = regexp.MustCompile(`^ {0,3}> ?\[!(\w+)\]\s*$`)
+var quoteLine = regexp.MustCompile(`^ {0,3}> ?`)
+
+// Replace "> [!NOTE]" blockquotes with placeholders for styled alert boxes.
+// Like on GitHub, an alert runs until the first line not starting with ">".
+func (s *Server) expandAlerts(src string, placeholders map[string]string, opts markdownOptions) string {
+    lines := strings.SplitAfter(src, "\n")
+    var out strings.Builder
+    fence := ""
+    for i := 0; i < len(lines); i++ {
+        line := lines[i]
+        trimmed := strings.TrimLeft(line, " \t")
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) {
+                fence = ""
+            }
+            out.WriteString(line)
+            continue
+        }
+        if fence = fenceMarker(trimmed); fence != "" {
+            out.WriteString(line)
+            continue
+        }
+
+        m := alertStart.FindStringSubmatch(strings.TrimRight(line, "\n"))
+        if m == nil {
+            out.WriteString(line)
+            continue
+        }
+        kind := strings.ToUpper(m[1])
+        info, ok := alertKinds[kind]
+        if !ok {
+            out.WriteString(line)
+            continue
+        }
+
+        var body strings.Builder
+        for i+1 < len(lines) && quoteLine.MatchString(lines[i+1]) {
+            i++
+            body.WriteString(quoteLine.ReplaceAllString(lines[i], ""))
+        }
+        key := fmt.Sprintf("MDSERVEALERT%dX", len(placeholders))
+        placeholders[key] = fmt.Sprintf("<div class=\"alert alert-%s\">\n<p class=\"alert-title\">%s %s</p>\n%s</div>\n",
+            strings.ToLower(kind), info[1], info[0], s.renderMarkdown([]byte(body.String()), opts))
+        out.WriteString("\n" + key + "\n\n")
+    }
+    return out.String()
+}
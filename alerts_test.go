@@ -0,0 +1,44 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestAlerts(t *testing.T) {
+    s := New()
+    tests := []struct {
+        name    string
+        src     string
+        want    []string // In the page
+        notWant []string
+    }{
+        {"note", "> [!NOTE]\n> Read this.\n", []string{`<div class="alert alert-note">`, "Note</p>", "<p>Read this.</p>"}, []string{"[!NOTE]", "<blockquote>"}},
+        {"lower case kind", "> [!warning]\n> Careful.\n", []string{`alert-warning`, "Warning</p>"}, nil},
+        {"markdown inside", "> [!TIP]\n> Use **bold**.\n>\n> - item\n", []string{"alert-tip", "<strong>bold</strong>", "<li>item</li>"}, nil},
+        {"ends at the first unquoted line", "> [!CAUTION]\n> Inside.\n\nOutside.\n", []string{"alert-caution", "<p>Outside.</p>\n"}, nil},
+        {"unknown kind", "> [!FOO]\n> Text.\n", []string{"<blockquote>"}, []string{"alert-"}},
+        {"plain blockquote", "> Quote.\n", []string{"<blockquote>"}, []string{"alert-"}},
+        {"in fenced code", "```\n> [!NOTE]\n> Code.\n```\n", []string{"[!NOTE]"}, []string{"alert-note"}},
+        {"shortcode inside", "> [!NOTE]\n> Press {{< kbd \"Ctrl+C\" >}} to stop.\n", []string{"alert-note", "<kbd>Ctrl</kbd>"}, []string{"MDSERVE"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            // Placeholders come out in map order, so render a few times
+            for i := 0; i < 20 && !strings.Contains(page, "MDSERVE"); i++ {
+                page = string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            }
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,71 @@
+package mdserve
+
+import (
+    "bytes"
+    "fmt"
+    "html"
+    "html/template"
+    "strings"
+)
+
+// Analytics adds a tracking snippet to the head of every page, built-in and
+// custom templates alike. Set the site of one of the privacy-friendly
+// services, or Snippet for anything else.
+type Analytics struct {
+    Plausible       string // Site domain as registered with Plausible
+    PlausibleServer string // Self-hosted Plausible, default https://plausible.io
+    Matomo          string // Matomo server URL
+    MatomoSiteID    string
+    Snippet         string // Custom HTML
+    Counter         bool   // Count views server-side too, for readers who block scripts
+}
+
+// The HTML to add to pages, "" for none
+func (a Analytics) snippet() string {
+    var b strings.Builder
+    if a.Plausible != "" {
+        server := a.PlausibleServer
+        if server == "" {
+            server = "https://plausible.io"
+        }
+        fmt.Fprintf(&b, `<script defer data-domain="%s" src="%s/js/script.js"></script>`+"\n",
+            html.EscapeString(a.Plausible), html.EscapeString(strings.TrimSuffix(server, "/")))
+    }
+    if a.Matomo != "" {
+        fmt.Fprintf(&b, `<script>
+var _paq = window._paq = window._paq || [];
+_paq.push(['trackPageView']);
+_paq.push(['enableLinkTracking']);
+(function() {
+    var u = '%s/';
+    _paq.push(['setTrackerUrl', u + 'matomo.php']);
+    _paq.push(['setSiteId', '%s']);
+    var g = document.createElement('script');
+    g.async = true;
+    g.src = u + 'matomo.js';
+    document.head.appendChild(g);
+})();
+</script>
+`, template.JSEscapeString(strings.TrimSuffix(a.Matomo, "/")), template.JSEscapeString(a.MatomoSiteID))
+    }
+    if a.Snippet != "" {
+        b.WriteString(a.Snippet + "\n")
+    }
+    return b.String()
+}
+
+// Insert the analytics snippet before </head>, or at the top of pages
+// without a head
+func (s *Server) injectAnalytics(page []byte) []byte {
+    if s.analytics == "" || s.lite {
+        return page
+    }
+    i := bytes.Index(bytes.ToLower(page), []byte("</head>"))
+    if i < 0 {
+        return append([]byte(s.analytics), page...)
+    }
+    out := make([]byte, 0, len(page)+len(s.analytics))
+    out = append(out, page[:i]...)
+    out = append(out, s.analytics...)
+    return append(out, page[i:]...)
+}
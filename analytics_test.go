@@ -0,0 +1,59 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestAnalytics(t *testing.T) {
+    files := map[string]string{"doc.md": "# Doc\n"}
+    tests := []struct {
+        name    string
+        a       Analytics
+        lite    bool
+        want    []string // In the head
+        notWant []string
+    }{
+        {"plausible", Analytics{Plausible: "docs.example.com"}, false, []string{`<script defer data-domain="docs.example.com" src="https://plausible.io/js/script.js"></script>`}, nil},
+        {"self-hosted plausible", Analytics{Plausible: "docs.example.com", PlausibleServer: "https://stats.example.com/"}, false, []string{`src="https://stats.example.com/js/script.js"`}, []string{"plausible.io"}},
+        {"matomo", Analytics{Matomo: "https://matomo.example.com/", MatomoSiteID: "7"}, false, []string{"var u = 'https://matomo.example.com/';", "_paq.push(['setSiteId', '7']);"}, nil},
+        {"custom snippet", Analytics{Snippet: `<script src="/track.js"></script>`}, false, []string{`<script src="/track.js"></script>`}, nil},
+        {"escaped", Analytics{Plausible: `x"><script>`, Matomo: "https://m.example.com/'); alert('"}, false, []string{`data-domain="x&#34;&gt;&lt;script&gt;"`, `\'); alert(\'`}, []string{`x"><script>`}},
+        {"none", Analytics{}, false, nil, []string{"plausible", "_paq"}},
+        {"lite mode", Analytics{Plausible: "docs.example.com"}, true, nil, []string{"plausible"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            opts := []Option{WithAnalytics(tt.a)}
+            if tt.lite {
+                opts = append(opts, WithLite())
+            }
+            s, _ := newTestServer(t, files, opts...)
+            for _, target := range []string{"/doc.md", "/", "/search?q=doc"} {
+                page := doRequest(s, "GET", target, nil, true).Body.String()
+                head, _, ok := strings.Cut(page, "</head>")
+                if !ok {
+                    t.Fatalf("no head in %q", page)
+                }
+                for _, want := range tt.want {
+                    if !strings.Contains(head, want) {
+                        t.Errorf("%s: %q missing from %q", target, want, head)
+                    }
+                }
+                for _, unwanted := range tt.notWant {
+                    if strings.Contains(page, unwanted) {
+                        t.Errorf("%s: %q in %q", target, unwanted, page)
+                    }
+                }
+            }
+        })
+    }
+}
+
+func TestAnalyticsCounter(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{"doc.md": "# Doc\n"}, WithAnalytics(Analytics{Plausible: "docs.example.com", Counter: true}))
+    doRequest(s, "GET", "/doc.md", nil, true)
+    if page := doRequest(s, "GET", "/stats", nil, true).Body.String(); !strings.Contains(page, `<tr><td><a href="/doc.md">Doc</a></td><td>1</td></tr>`) {
+        t.Errorf("view not counted: %q", page)
+    }
+}
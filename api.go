@@ -0,0 +1,35 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+)
+
+// One document in /api/files
+type fileInfo struct {
+    Path  string `json:"path"` // URL path without the leading slash
+    Name  string `json:"name"` // Path relative to the mount root
+    Title string `json:"title"`
+}
+
+// Serve /api/files, every document of the site with its title, for the
+// quick-open palette and other frontends
+func (s *Server) filesHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+    files := []fileInfo{}
+    for _, doc := range docs {
+        f := fileInfo{Path: doc.Path, Name: doc.Name}
+        if content, err := ioutil.ReadFile(doc.File); err == nil {
+            fm, body := parseFrontMatter(content)
+            f.Title = documentTitle(fm, string(body))
+        }
+        files = append(files, f)
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(files)
+}
@@ -0,0 +1,44 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestFilesAPI(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "guide/setup.md": "---\ntitle: Setting up\n---\n# Ignored\n",
+        "notes.md":       "Intro.\n\n# Notes heading\n",
+        "untitled.md":    "No heading.\n",
+        "draft.md":       "---\ndraft: true\n---\n# Draft\n",
+        "image.png":      "png",
+    })
+    w := doRequest(s, "GET", "/api/files", nil, true)
+    if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+        t.Errorf("got Content-Type %q", ct)
+    }
+    var got []fileInfo
+    if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+        t.Fatal(err)
+    }
+    want := []fileInfo{
+        {Path: "guide/setup.md", Name: "guide/setup.md", Title: "Setting up"},
+        {Path: "notes.md", Name: "notes.md", Title: "Notes heading"},
+        {Path: "untitled.md", Name: "untitled.md"},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got %+v, want %+v", got, want)
+    }
+
+    if page := doRequest(s, "GET", "/notes.md", nil, true).Body.String(); !strings.Contains(page, "fetch(root + 'api/files')") {
+        t.Error("the palette doesn't fetch the list")
+    }
+
+    // An empty site lists nothing rather than null
+    s, _ = newTestServer(t, map[string]string{"doc.txt": "text"})
+    if body := strings.TrimSpace(doRequest(s, "GET", "/api/files", nil, true).Body.String()); body != "[]" {
+        t.Errorf("got %q for an empty site", body)
+    }
+}
@@ -0,0 +1,510 @@
+package mdserve
+
+import (
+    "fmt"
+    "html"
+    "regexp"
+    "strings"
+)
+
+// AsciiDoc renders the commonly used subset of AsciiDoc: titles and
+// sections, paragraphs, lists and checklists, description lists, listing,
+// literal, quote, example and sidebar blocks, admonitions, tables, images,
+// links, cross references and inline formatting. Register a RendererFunc
+// that runs asciidoctor with RegisterFormat for full coverage.
+var AsciiDoc = RendererFunc(func(src []byte) []byte {
+    c := &adocConverter{attrs: map[string]string{}}
+    text := strings.ReplaceAll(string(src), "\r\n", "\n")
+    return []byte(c.blocks(strings.Split(text, "\n")))
+})
+
+type adocConverter struct {
+    attrs map[string]string // Document attributes from ":name: value" lines
+}
+
+var (
+    adocHeading   = regexp.MustCompile(`^(={1,6}) +(.+?)\s*$`)
+    adocAttrEntry = regexp.MustCompile(`^:([\w-]+!?):\s*(.*)$`)
+    adocBlockAttr = regexp.MustCompile(`^\[([^\[\]]*)\]\s*$`)
+    adocAnchor    = regexp.MustCompile(`^\[\[([\w:.-]+)(?:,[^\]]*)?\]\]\s*$`)
+    adocListItem  = regexp.MustCompile(`^\s*(\*{1,5}|-|\.{1,5}) +(.*)$`)
+    adocDescItem  = regexp.MustCompile(`^(.+?)(::{1,3}|;;)(?: +(.*))?$`)
+    adocAdmonPara = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION): +(.*)$`)
+    adocImage     = regexp.MustCompile(`^image::([^\[\s]+)\[([^\]]*)\]\s*$`)
+)
+
+// Delimiters of the block kinds that enclose other lines
+var adocDelimiters = map[string]string{
+    "----": "listing", "....": "literal", "____": "quote", "====": "example",
+    "****": "sidebar", "++++": "pass", "|===": "table", "////": "comment",
+}
+
+// Convert a sequence of block-level lines to HTML
+func (c *adocConverter) blocks(lines []string) string {
+    var out strings.Builder
+    var attrs []string // Pending [style,...] line for the next block
+    var anchor, title string
+    for i := 0; i < len(lines); i++ {
+        line := lines[i]
+        trimmed := strings.TrimSpace(line)
+        switch {
+        case trimmed == "":
+            continue
+        case strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "////"):
+            continue
+        case adocAttrEntry.MatchString(line):
+            m := adocAttrEntry.FindStringSubmatch(line)
+            if strings.HasSuffix(m[1], "!") {
+                delete(c.attrs, strings.TrimSuffix(m[1], "!"))
+            } else {
+                c.attrs[m[1]] = m[2]
+            }
+            continue
+        case adocAnchor.MatchString(line):
+            anchor = adocAnchor.FindStringSubmatch(line)[1]
+            continue
+        case adocBlockAttr.MatchString(line):
+            attrs = splitAdocAttrs(adocBlockAttr.FindStringSubmatch(line)[1])
+            if len(attrs) > 0 && strings.HasPrefix(attrs[0], "#") {
+                anchor = attrs[0][1:]
+            }
+            continue
+        case len(line) > 1 && line[0] == '.' && line[1] != '.' && line[1] != ' ':
+            title = line[1:]
+            continue
+        }
+
+        style := ""
+        if len(attrs) > 0 {
+            style = attrs[0]
+        }
+        idAttr := ""
+        if anchor != "" {
+            idAttr = ` id="` + html.EscapeString(anchor) + `"`
+        }
+        titleHTML := ""
+        if title != "" {
+            titleHTML = `<div class="title">` + c.inline(title) + "</div>\n"
+        }
+
+        if kind, ok := adocDelimiters[trimmed]; ok {
+            end := i + 1
+            for end < len(lines) && strings.TrimSpace(lines[end]) != trimmed {
+                end++
+            }
+            inner := lines[i+1 : min(end, len(lines))]
+            i = end
+            out.WriteString(c.delimited(kind, style, attrs, idAttr, titleHTML, inner))
+        } else if m := adocHeading.FindStringSubmatch(line); m != nil {
+            level := len(m[1])
+            id := anchor
+            if id == "" {
+                id = adocID(m[2])
+            }
+            fmt.Fprintf(&out, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), c.inline(m[2]), level)
+        } else if m := adocImage.FindStringSubmatch(line); m != nil {
+            fmt.Fprintf(&out, "<figure%s><img src=\"%s\" alt=\"%s\">", idAttr, html.EscapeString(m[1]), html.EscapeString(strings.Split(m[2], ",")[0]))
+            if title != "" {
+                fmt.Fprintf(&out, "<figcaption>%s</figcaption>", c.inline(title))
+            }
+            out.WriteString("</figure>\n")
+        } else if trimmed == "'''" || trimmed == "---" || trimmed == "***" {
+            out.WriteString("<hr>\n")
+        } else if trimmed == "<<<" {
+            continue
+        } else if adocListItem.MatchString(line) {
+            end := i
+            for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+                end++
+            }
+            out.WriteString(titleHTML + c.list(lines[i:end]))
+            i = end - 1
+        } else if m := adocDescItem.FindStringSubmatch(line); m != nil && !strings.Contains(m[1], "://") {
+            end := i
+            for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+                end++
+            }
+            out.WriteString(titleHTML + c.descList(lines[i:end]))
+            i = end - 1
+        } else if strings.HasPrefix(line, " ") || style == "literal" {
+            // Literal paragraph
+            end := i
+            var para []string
+            for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+                para = append(para, lines[end])
+                end++
+            }
+            i = end - 1
+            fmt.Fprintf(&out, "%s<pre%s>%s</pre>\n", titleHTML, idAttr, html.EscapeString(dedent(para)))
+        } else {
+            end := i
+            var para []string
+            for end < len(lines) && strings.TrimSpace(lines[end]) != "" && !adocBlockStart(lines[end], end > i) {
+                para = append(para, lines[end])
+                end++
+            }
+            i = end - 1
+            text := strings.Join(para, "\n")
+            if m := adocAdmonPara.FindStringSubmatch(text); m != nil {
+                out.WriteString(adocAdmonition(m[1], idAttr, "<p>"+c.inline(m[2])+"</p>\n"))
+            } else if isAdmonition(style) {
+                out.WriteString(adocAdmonition(style, idAttr, "<p>"+c.inline(text)+"</p>\n"))
+            } else {
+                fmt.Fprintf(&out, "%s<p%s>%s</p>\n", titleHTML, idAttr, c.inline(text))
+            }
+        }
+        attrs, anchor, title = nil, "", ""
+    }
+    return out.String()
+}
+
+// Split a block attribute list on the commas outside quotes
+func splitAdocAttrs(list string) []string {
+    var attrs []string
+    quoted := false
+    start := 0
+    for i, c := range list {
+        switch {
+        case c == '"':
+            quoted = !quoted
+        case c == ',' && !quoted:
+            attrs = append(attrs, strings.TrimSpace(list[start:i]))
+            start = i + 1
+        }
+    }
+    return append(attrs, strings.TrimSpace(list[start:]))
+}
+
+// Whether a line inside a paragraph starts a new block instead
+func adocBlockStart(line string, inside bool) bool {
+    trimmed := strings.TrimSpace(line)
+    if _, ok := adocDelimiters[trimmed]; ok {
+        return true
+    }
+    return inside && (adocHeading.MatchString(line) || adocBlockAttr.MatchString(line) || adocListItem.MatchString(line))
+}
+
+func isAdmonition(style string) bool {
+    _, ok := alertKinds[style]
+    return ok
+}
+
+// Admonitions share the look of GitHub alerts
+func adocAdmonition(kind, idAttr, body string) string {
+    info := alertKinds[kind]
+    return fmt.Sprintf("<div class=\"alert alert-%s\"%s>\n<p class=\"alert-title\">%s %s</p>\n%s</div>\n",
+        strings.ToLower(kind), idAttr, info[1], info[0], body)
+}
+
+// Render a delimited block
+func (c *adocConverter) delimited(kind, style string, attrs []string, idAttr, titleHTML string, inner []string) string {
+    switch kind {
+    case "comment":
+        return ""
+    case "pass":
+        return strings.Join(inner, "\n") + "\n"
+    case "listing", "literal":
+        class := ""
+        if style == "source" && len(attrs) > 1 {
+            class = ` class="language-` + html.EscapeString(attrs[1]) + `"`
+        } else if kind == "listing" && len(attrs) == 1 && style != "source" && style != "listing" && style != "" {
+            class = ` class="language-` + html.EscapeString(style) + `"`
+        }
+        return fmt.Sprintf("%s<pre%s><code%s>%s\n</code></pre>\n", titleHTML, idAttr, class, html.EscapeString(strings.Join(inner, "\n")))
+    case "quote":
+        cite := ""
+        if style == "quote" && len(attrs) > 1 {
+            cite = "<footer>— " + c.inline(strings.Join(attrs[1:], ", ")) + "</footer>\n"
+        }
+        return fmt.Sprintf("%s<blockquote%s>\n%s%s</blockquote>\n", titleHTML, idAttr, c.blocks(inner), cite)
+    case "example":
+        if isAdmonition(style) {
+            return adocAdmonition(style, idAttr, c.blocks(inner))
+        }
+        return fmt.Sprintf("<div class=\"example\"%s>\n%s%s</div>\n", idAttr, titleHTML, c.blocks(inner))
+    case "sidebar":
+        return fmt.Sprintf("<aside class=\"sidebar\"%s>\n%s%s</aside>\n", idAttr, titleHTML, c.blocks(inner))
+    case "table":
+        return c.table(attrs, idAttr, titleHTML, inner)
+    }
+    return ""
+}
+
+// Render a |=== table; the first row is the header when the options say so
+// or when it is on a line of its own followed by a blank line
+func (c *adocConverter) table(attrs []string, idAttr, titleHTML string, lines []string) string {
+    header := false
+    for _, a := range attrs {
+        if strings.Contains(a, "header") && !strings.Contains(a, "noheader") {
+            header = true
+        }
+    }
+    if len(lines) > 1 && strings.HasPrefix(strings.TrimSpace(lines[0]), "|") && strings.TrimSpace(lines[1]) == "" {
+        header = true
+    }
+
+    // Cells run from one "|" to the next, across lines
+    var cells []string
+    cols := 0
+    firstRowCells := 0
+    for n, line := range lines {
+        trimmed := strings.TrimSpace(line)
+        if trimmed == "" {
+            if firstRowCells == 0 {
+                firstRowCells = len(cells)
+            }
+            continue
+        }
+        if !strings.HasPrefix(trimmed, "|") {
+            if len(cells) > 0 {
+                cells[len(cells)-1] += "\n" + trimmed
+            }
+            continue
+        }
+        parts := strings.Split(trimmed[1:], "|")
+        for _, p := range parts {
+            cells = append(cells, strings.TrimSpace(p))
+        }
+        if n == 0 {
+            cols = len(parts)
+        }
+    }
+    for _, a := range attrs {
+        if strings.HasPrefix(a, "cols=") {
+            spec := strings.Trim(strings.TrimPrefix(a, "cols="), `"'`)
+            cols = len(strings.Split(spec, ","))
+        }
+    }
+    if cols == 0 {
+        cols = 1
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "%s<table%s>\n", titleHTML, idAttr)
+    for r := 0; r*cols < len(cells); r++ {
+        row := cells[r*cols : min((r+1)*cols, len(cells))]
+        tag := "td"
+        if r == 0 && header {
+            tag = "th"
+            b.WriteString("<thead>\n")
+        } else if r == 0 || (r == 1 && header) {
+            b.WriteString("<tbody>\n")
+        }
+        b.WriteString("<tr>")
+        for _, cell := range row {
+            fmt.Fprintf(&b, "<%s>%s</%s>", tag, c.inline(cell), tag)
+        }
+        b.WriteString("</tr>\n")
+        if r == 0 && header {
+            b.WriteString("</thead>\n")
+        }
+    }
+    if len(cells) > 0 && !(header && len(cells) <= cols) {
+        b.WriteString("</tbody>\n")
+    }
+    b.WriteString("</table>\n")
+    return b.String()
+}
+
+// Render nested * or . lists; deeper markers nest
+func (c *adocConverter) list(lines []string) string {
+    var b strings.Builder
+    var stack []string // Open list tags, one per depth
+    for _, line := range lines {
+        m := adocListItem.FindStringSubmatch(line)
+        if m == nil {
+            if strings.TrimSpace(line) != "+" {
+                b.WriteString(" " + c.inline(strings.TrimSpace(line)))
+            }
+            continue
+        }
+        depth, tag := len(m[1]), "ul"
+        if m[1][0] == '.' {
+            tag = "ol"
+        }
+        if m[1] == "-" {
+            depth = 1
+        }
+        for len(stack) > depth {
+            b.WriteString("</li>\n</" + stack[len(stack)-1] + ">")
+            stack = stack[:len(stack)-1]
+        }
+        if len(stack) == depth {
+            b.WriteString("</li>\n")
+        }
+        for len(stack) < depth {
+            b.WriteString("\n<" + tag + ">\n")
+            stack = append(stack, tag)
+        }
+        text := m[2]
+        // Checklists keep their "[ ]" so they become checkboxes like in markdown
+        if strings.HasPrefix(text, "[ ] ") || strings.HasPrefix(text, "[x] ") || strings.HasPrefix(text, "[*] ") {
+            box := "[x] "
+            if text[1] == ' ' {
+                box = "[ ] "
+            }
+            b.WriteString("<li>" + box + c.inline(text[4:]))
+            continue
+        }
+        b.WriteString("<li>" + c.inline(text))
+    }
+    for len(stack) > 0 {
+        b.WriteString("</li>\n</" + stack[len(stack)-1] + ">\n")
+        stack = stack[:len(stack)-1]
+    }
+    return strings.TrimPrefix(b.String(), "\n")
+}
+
+// Render "term:: definition" lines as a description list
+func (c *adocConverter) descList(lines []string) string {
+    var b strings.Builder
+    b.WriteString("<dl>\n")
+    open := false
+    for _, line := range lines {
+        if m := adocDescItem.FindStringSubmatch(line); m != nil {
+            if open {
+                b.WriteString("</dd>\n")
+                open = false
+            }
+            b.WriteString("<dt>" + c.inline(m[1]) + "</dt>\n")
+            if m[3] != "" {
+                b.WriteString("<dd>" + c.inline(m[3]))
+                open = true
+            }
+            continue
+        }
+        if !open {
+            b.WriteString("<dd>")
+            open = true
+        } else {
+            b.WriteString(" ")
+        }
+        b.WriteString(c.inline(strings.TrimSpace(line)))
+    }
+    if open {
+        b.WriteString("</dd>\n")
+    }
+    b.WriteString("</dl>\n")
+    return b.String()
+}
+
+// Like html.EscapeString but leaves ' alone, as its escape contains the
+// "#" of #highlight# markup
+var adocEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+var (
+    adocPassInline = regexp.MustCompile(`\+\+\+(.+?)\+\+\+|\+([^+\s][^+]*?)\+`)
+    adocMono       = regexp.MustCompile("`([^`]+)`")
+    adocStrongU    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+    adocStrong     = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*?[^*\s])?)\*($|[^\w*])`)
+    adocEmphU      = regexp.MustCompile(`__(.+?)__`)
+    adocEmph       = regexp.MustCompile(`(^|[^\w_])_([^_\s](?:[^_]*?[^_\s])?)_($|[^\w_])`)
+    adocMark       = regexp.MustCompile(`(^|[^\w#])#([^#\s](?:[^#]*?[^#\s])?)#($|[^\w#])`)
+    adocLinkMacro  = regexp.MustCompile(`(?:link:|(https?://))([^\s\[]+)\[([^\]]*)\]`)
+    adocBareURL    = regexp.MustCompile(`(^|[\s(])(https?://[^\s<\[]+[^\s<\[.,;:!?)])`)
+    adocXref       = regexp.MustCompile(`&lt;&lt;([\w:.#/-]+)(?:,\s*([^&]+?))?&gt;&gt;|xref:([^\s\[]+)\[([^\]]*)\]`)
+    adocInlineImg  = regexp.MustCompile(`image:([^\s\[:][^\s\[]*)\[([^\]]*)\]`)
+    adocAttrRef    = regexp.MustCompile(`\{([\w-]+)\}`)
+    adocHardBreak  = regexp.MustCompile(` \+\n`)
+)
+
+// Apply inline formatting to text, escaping it first
+func (c *adocConverter) inline(text string) string {
+    text = adocAttrRef.ReplaceAllStringFunc(text, func(m string) string {
+        if v, ok := c.attrs[m[1:len(m)-1]]; ok {
+            return v
+        }
+        return m
+    })
+
+    // Keep passthroughs and code spans away from the other substitutions
+    var saved []string
+    save := func(s string) string {
+        saved = append(saved, s)
+        return fmt.Sprintf("\x00%d\x00", len(saved)-1)
+    }
+    text = adocPassInline.ReplaceAllStringFunc(text, func(m string) string {
+        sub := adocPassInline.FindStringSubmatch(m)
+        if sub[1] != "" {
+            return save(sub[1])
+        }
+        return save(html.EscapeString(sub[2]))
+    })
+    text = adocMono.ReplaceAllStringFunc(text, func(m string) string {
+        return save("<code>" + html.EscapeString(m[1:len(m)-1]) + "</code>")
+    })
+
+    text = adocEscaper.Replace(text)
+    text = adocInlineImg.ReplaceAllStringFunc(text, func(m string) string {
+        sub := adocInlineImg.FindStringSubmatch(m)
+        return save(`<img src="` + sub[1] + `" alt="` + strings.Split(sub[2], ",")[0] + `">`)
+    })
+    text = adocLinkMacro.ReplaceAllStringFunc(text, func(m string) string {
+        sub := adocLinkMacro.FindStringSubmatch(m)
+        target := sub[1] + sub[2]
+        label := sub[3]
+        if label == "" {
+            label = target
+        }
+        return save(`<a href="` + target + `">`) + label + "</a>"
+    })
+    text = adocBareURL.ReplaceAllStringFunc(text, func(m string) string {
+        sub := adocBareURL.FindStringSubmatch(m)
+        return sub[1] + save(`<a href="`+sub[2]+`">`+sub[2]+`</a>`)
+    })
+    text = adocXref.ReplaceAllStringFunc(text, func(m string) string {
+        sub := adocXref.FindStringSubmatch(m)
+        if sub[3] != "" {
+            // xref:other.adoc#section[label]
+            label := sub[4]
+            if label == "" {
+                label = sub[3]
+            }
+            return save(`<a href="`+sub[3]+`">`) + label + "</a>"
+        }
+        target, label := sub[1], sub[2]
+        if label == "" {
+            label = target
+        }
+        if !strings.Contains(target, "#") && !strings.Contains(target, ".adoc") {
+            target = "#" + target
+        }
+        return save(`<a href="`+target+`">`) + label + "</a>"
+    })
+
+    text = adocStrongU.ReplaceAllString(text, "<strong>$1</strong>")
+    text = adocStrong.ReplaceAllString(text, "$1<strong>$2</strong>$3")
+    text = adocEmphU.ReplaceAllString(text, "<em>$1</em>")
+    text = adocEmph.ReplaceAllString(text, "$1<em>$2</em>$3")
+    text = adocMark.ReplaceAllString(text, "$1<mark>$2</mark>$3")
+    text = adocHardBreak.ReplaceAllString(text, "<br>\n")
+
+    for i := len(saved) - 1; i >= 0; i-- {
+        text = strings.ReplaceAll(text, fmt.Sprintf("\x00%d\x00", i), saved[i])
+    }
+    return text
+}
+
+var adocIDStrip = regexp.MustCompile(`[^\w]+`)
+
+// Section ids the way asciidoctor makes them: "_" plus the lower-case title
+// with runs of other characters turned into "_"
+func adocID(title string) string {
+    plain := htmlTag.ReplaceAllString(title, "")
+    return "_" + strings.Trim(adocIDStrip.ReplaceAllString(strings.ToLower(plain), "_"), "_")
+}
+
+// Remove the indentation common to all lines
+func dedent(lines []string) string {
+    indent := -1
+    for _, l := range lines {
+        n := len(l) - len(strings.TrimLeft(l, " "))
+        if indent < 0 || n < indent {
+            indent = n
+        }
+    }
+    for i, l := range lines {
+        lines[i] = l[indent:]
+    }
+    return strings.Join(lines, "\n")
+}
@@ -0,0 +1,52 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestAsciiDoc(t *testing.T) {
+    tests := []struct {
+        name string
+        src  string
+        want string
+    }{
+        {"sections and inline", "= Title\n\n== Section One\n\nSome *bold* and _em_ and `code`.\n", "<h1 id=\"_title\">Title</h1>\n<h2 id=\"_section_one\">Section One</h2>\n<p>Some <strong>bold</strong> and <em>em</em> and <code>code</code>.</p>\n"},
+        {"nested list", "* one\n* two\n** nested\n", "<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul>\n</li>\n</ul>\n"},
+        {"ordered list", ". first\n. second\n", "<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"},
+        {"description list", "Term:: Definition\n", "<dl>\n<dt>Term</dt>\n<dd>Definition</dd>\n</dl>\n"},
+        {"source block", "[source,go]\n----\nfunc main() {}\n----\n", "<pre><code class=\"language-go\">func main() {}\n</code></pre>\n"},
+        {"admonition paragraph", "NOTE: Watch out.\n", "<div class=\"alert alert-note\">\n<p class=\"alert-title\">ℹ️ Note</p>\n<p>Watch out.</p>\n</div>\n"},
+        {"admonition block", "[WARNING]\n====\nCareful.\n====\n", "<div class=\"alert alert-warning\">\n<p class=\"alert-title\">⚠️ Warning</p>\n<p>Careful.</p>\n</div>\n"},
+        {"table", "|===\n|A |B\n\n|1 |2\n|===\n", "<table>\n<thead>\n<tr><th>A</th><th>B</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td>2</td></tr>\n</tbody>\n</table>\n"},
+        {"image", "image::pic.png[A picture]\n", "<figure><img src=\"pic.png\" alt=\"A picture\"></figure>\n"},
+        {"links", "See https://example.com[Example], <<_one>> and xref:other.adoc#x[Other].\n", "<p>See <a href=\"https://example.com\">Example</a>, <a href=\"#_one\">_one</a> and <a href=\"other.adoc#x\">Other</a>.</p>\n"},
+        {"attributes", ":product: mdserve\n\nUse {product}.\n", "<p>Use mdserve.</p>\n"},
+        {"html escaped", "<script>alert(1)</script>\n", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+        {"quote", "____\nA quote.\n____\n", "<blockquote>\n<p>A quote.</p>\n</blockquote>\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := string(AsciiDoc.Render([]byte(tt.src))); got != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+// AsciiDoc documents are pages like markdown ones
+func TestAsciiDocPages(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{"guide.adoc": "= Guide\n\n* [x] done\n", "notes.txt": "plain"})
+    page := doRequest(s, "GET", "/guide.adoc", nil, true).Body.String()
+    for _, want := range []string{`<h1 id="_guide">Guide</h1>`, `<a href="#_guide">Guide</a>`, `<input type="checkbox" class="task-checkbox" checked disabled> done`} {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing from the page", want)
+        }
+    }
+    if !s.isDocument("guide.ADOC") || s.isDocument("notes.txt") {
+        t.Error("wrong documents")
+    }
+    if index := doRequest(s, "GET", "/", nil, true).Body.String(); !strings.Contains(index, "guide.adoc") {
+        t.Error("guide.adoc not listed")
+    }
+}
@@ -0,0 +1,115 @@
+package mdserve
+
+import (
+    "fmt"
+    "mime"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// How an attachment type is listed and served
+type attachmentType struct {
+    Icon   string
+    Inline bool // Shown in the browser rather than downloaded
+}
+
+// Attachments keyed by extension: PDFs open in the browser's viewer,
+// office documents and archives are downloaded
+var attachmentTypes = map[string]attachmentType{
+    ".pdf":  {"📕", true},
+    ".doc":  {"📝", false},
+    ".docx": {"📝", false},
+    ".odt":  {"📝", false},
+    ".rtf":  {"📝", false},
+    ".xls":  {"📊", false},
+    ".xlsx": {"📊", false},
+    ".ods":  {"📊", false},
+    ".ppt":  {"📽️", false},
+    ".pptx": {"📽️", false},
+    ".odp":  {"📽️", false},
+    ".zip":  {"📦", false},
+    ".tar":  {"📦", false},
+    ".gz":   {"📦", false},
+    ".7z":   {"📦", false},
+}
+
+// A non-document file listed on an index page
+type Attachment struct {
+    Path string // URL path without the leading slash
+    Name string // Path relative to the mount root
+    Icon string
+}
+
+func attachmentFor(file string) (attachmentType, bool) {
+    t, ok := attachmentTypes[strings.ToLower(filepath.Ext(file))]
+    return t, ok
+}
+
+// List the attachments under a mount, leaving out hidden and ignored files
+func (s *Server) listAttachments(m *Mount) ([]Attachment, error) {
+    var list []Attachment
+    err := filepath.Walk(m.Root, func(file string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if hidden(m.Root, file, info) || s.ignored(m.Root, file, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        t, ok := attachmentFor(file)
+        if info.IsDir() || !ok {
+            return nil
+        }
+        rel, err := filepath.Rel(m.Root, file)
+        if err != nil {
+            return err
+        }
+        rel = filepath.ToSlash(rel)
+        list = append(list, Attachment{
+            Path: strings.TrimPrefix(path.Join(m.Prefix, rel), "/"),
+            Name: rel,
+            Icon: t.Icon,
+        })
+        return nil
+    })
+    return list, err
+}
+
+// Serve a file with a Content-Disposition matching its type, or as a
+// download when asked with ?download (or ?download=1). Reports false for
+// files that are neither attachments nor explicitly downloaded.
+func serveAttachment(w http.ResponseWriter, r *http.Request, file string) bool {
+    download := wantsDownload(r)
+    disposition := "attachment"
+    if t, ok := attachmentFor(file); ok && t.Inline && !download {
+        disposition = "inline"
+    } else if !ok && !download {
+        return false
+    }
+    w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": filepath.Base(file)}))
+    serveAsset(w, r, file)
+    return true
+}
+
+func wantsDownload(r *http.Request) bool {
+    if !r.URL.Query().Has("download") {
+        return false
+    }
+    v, err := strconv.ParseBool(r.URL.Query().Get("download"))
+    return v || err != nil
+}
+
+// Serve a file as-is. http.ServeFile answers Range requests; the ETag lets
+// clients resume with If-Range even across servers with skewed clocks.
+func serveAsset(w http.ResponseWriter, r *http.Request, file string) {
+    if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+        w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+    }
+    http.ServeFile(w, r, file)
+}
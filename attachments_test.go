@@ -0,0 +1,49 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestAttachments(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "readme.md":        "# Home\n",
+        "docs/spec.pdf":    "%PDF-1.4",
+        "docs/budget.xlsx": "sheet",
+        ".private/x.pdf":   "hidden",
+        "notes.txt":        "text",
+    })
+
+    index := doRequest(s, "GET", "/", nil, true).Body.String()
+    for _, want := range []string{`📕 <a href="/docs/spec.pdf">docs/spec.pdf</a>`, `<a href="/docs/spec.pdf?download"`, `📊 <a href="/docs/budget.xlsx">`} {
+        if !strings.Contains(index, want) {
+            t.Errorf("%q missing from the index", want)
+        }
+    }
+    for _, unwanted := range []string{"x.pdf", "notes.txt</a> <a"} {
+        if strings.Contains(index, unwanted) {
+            t.Errorf("%q listed", unwanted)
+        }
+    }
+
+    tests := []struct {
+        target      string
+        disposition string
+    }{
+        {"/docs/spec.pdf", `inline; filename=spec.pdf`},
+        {"/docs/spec.pdf?download", `attachment; filename=spec.pdf`},
+        {"/docs/spec.pdf?download=0", `inline; filename=spec.pdf`},
+        {"/docs/budget.xlsx", `attachment; filename=budget.xlsx`},
+        {"/notes.txt", ""},
+        {"/notes.txt?download=1", `attachment; filename=notes.txt`},
+    }
+    for _, tt := range tests {
+        w := doRequest(s, "GET", tt.target, nil, true)
+        if got := w.Header().Get("Content-Disposition"); got != tt.disposition {
+            t.Errorf("%s: got Content-Disposition %q, want %q", tt.target, got, tt.disposition)
+        }
+        if tt.disposition != "" && w.Header().Get("ETag") == "" {
+            t.Errorf("%s: no ETag", tt.target)
+        }
+    }
+}
@@ -0,0 +1,76 @@
+package mdserve
+
+import (
+    "container/list"
+    "sync"
+    "time"
+)
+
+// A rendered page, valid while the file keeps its size and mtime
+type cachedPage struct {
+    key     string
+    modTime time.Time
+    size    int64
+    page    *renderedPage
+}
+
+// Least-recently-used cache of rendered pages
+type pageCache struct {
+    mu      sync.Mutex
+    max     int
+    order   *list.List // Front is most recently used
+    entries map[string]*list.Element
+}
+
+func newPageCache(max int) *pageCache {
+    return &pageCache{max: max, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// Look up a page, ignoring entries whose file changed since rendering
+func (c *pageCache) get(key string, modTime time.Time, size int64) *renderedPage {
+    if c == nil {
+        return nil
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    el, ok := c.entries[key]
+    if !ok {
+        return nil
+    }
+    entry := el.Value.(*cachedPage)
+    if !entry.modTime.Equal(modTime) || entry.size != size {
+        c.order.Remove(el)
+        delete(c.entries, key)
+        return nil
+    }
+    c.order.MoveToFront(el)
+    return entry.page
+}
+
+func (c *pageCache) put(key string, modTime time.Time, size int64, page *renderedPage) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if el, ok := c.entries[key]; ok {
+        c.order.Remove(el)
+    }
+    c.entries[key] = c.order.PushFront(&cachedPage{key: key, modTime: modTime, size: size, page: page})
+    for c.order.Len() > c.max {
+        oldest := c.order.Back()
+        c.order.Remove(oldest)
+        delete(c.entries, oldest.Value.(*cachedPage).key)
+    }
+}
+
+// Drop every page
+func (c *pageCache) clear() {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.order.Init()
+    c.entries = map[string]*list.Element{}
+}
@@ -0,0 +1,77 @@
+package mdserve
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "log"
+    "os"
+    "time"
+)
+
+// A CacheBackend keeps rendered pages and search index entries outside
+// the process, so replicas behind a load balancer render each document
+// once between them. Implementations must be safe for concurrent use.
+type CacheBackend interface {
+    Get(key string) ([]byte, error) // nil, nil when missing
+    Set(key string, value []byte, ttl time.Duration) error // ttl 0 keeps it
+    Close() error
+}
+
+// Whether a document's entries go to the cache backend: not for
+// documents decrypted from .gpg files, whose text must not leave the
+// process
+func (s *Server) sharesCache(file string) bool {
+    return s.cacheBackend != nil && !decrypted(file)
+}
+
+func decrypted(file string) bool {
+    _, err := os.Stat(file + ".gpg")
+    return err == nil
+}
+
+// The backend key of a document's entry of kind. The file's size and
+// modification time, and the render settings, are part of it, so a
+// changed file or differently configured replica misses.
+func (s *Server) backendKey(kind, file string, info os.FileInfo) string {
+    sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%v", s.defaultSite.Name, file, info.ModTime().UnixNano(), info.Size(), s.markdown)))
+    return "mdserve:" + kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// Read a document's entry of kind from the backend into v
+func (s *Server) fromBackend(kind, file string, info os.FileInfo, v interface{}) bool {
+    if !s.sharesCache(file) {
+        return false
+    }
+    data, err := s.cacheBackend.Get(s.backendKey(kind, file, info))
+    if err != nil {
+        s.backendFailed(err)
+        return false
+    }
+    return data != nil && json.Unmarshal(data, v) == nil
+}
+
+// Store a document's entry of kind in the backend for Config.CacheTTL
+func (s *Server) toBackend(kind, file string, info os.FileInfo, v interface{}) {
+    if !s.sharesCache(file) {
+        return
+    }
+    data, err := json.Marshal(v)
+    if err != nil {
+        return
+    }
+    if err := s.cacheBackend.Set(s.backendKey(kind, file, info), data, s.cacheTTL); err != nil {
+        s.backendFailed(err)
+    }
+}
+
+// Log a backend failure, at most once a minute so an unreachable backend
+// doesn't flood the log. Requests go on without it.
+func (s *Server) backendFailed(err error) {
+    now := time.Now().Unix()
+    last := s.backendLogged.Load()
+    if now-last >= 60 && s.backendLogged.CompareAndSwap(last, now) {
+        log.Printf("Cache backend: %v", err)
+    }
+}
@@ -0,0 +1,84 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "fmt"
+    "html"
+    "net/http"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// The asciinema player release pages load unless Config.CastPlayer names
+// a self-hosted copy
+const castPlayerCDN = "https://cdn.jsdelivr.net/npm/asciinema-player@3.8.0/dist/bundle/asciinema-player"
+
+// The player files served from Config.CastPlayer, in place of any
+// documents of the same name
+var castPlayerFiles = map[string]bool{"/asciinema-player.min.js": true, "/asciinema-player.css": true}
+
+// Where pages load the player from, without the .min.js or .css
+func (s *Server) castPlayerURL() string {
+    if s.castPlayer != "" {
+        return s.basePath + "/asciinema-player"
+    }
+    return castPlayerCDN
+}
+
+// Serve the self-hosted player's script and style sheet
+func (s *Server) castPlayerHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    serveAsset(w, r, filepath.Join(s.castPlayer, r.URL.Path[1:]))
+}
+
+// A paragraph holding nothing but a link to a .cast recording
+var castLink = regexp.MustCompile(`<p><a href="([^"]+\.cast)"[^>]*>([^<]*)</a></p>`)
+
+// Markup for an asciinema player; the page script loads the player and
+// until then (or in lite mode) the link to the recording remains
+func castPlayer(src, label string, opts map[string]interface{}) string {
+    if label == "" {
+        label = path.Base(src)
+    }
+    data, _ := json.Marshal(opts)
+    return fmt.Sprintf(`<div class="cast-player" data-opts="%s"><a href="%s">▶ %s</a></div>`+"\n",
+        html.EscapeString(string(data)), src, label)
+}
+
+// Render a ```asciinema block naming a recording, with player options:
+//
+//     ```asciinema autoplay loop speed=2
+//     demos/install.cast
+//     ```
+func renderCast(opts []string, body string) (string, error) {
+    src := strings.TrimSpace(body)
+    if src == "" || strings.ContainsAny(src, "\n\"<>") {
+        return "", fmt.Errorf("expected the path of a .cast file")
+    }
+    settings := map[string]interface{}{}
+    for _, opt := range opts {
+        key, val, ok := strings.Cut(opt, "=")
+        if !ok {
+            settings[key] = true
+        } else if n, err := strconv.ParseFloat(val, 64); err == nil {
+            settings[key] = n
+        } else {
+            settings[key] = val
+        }
+    }
+    return castPlayer(html.EscapeString(src), "", settings), nil
+}
+
+// Turn standalone links to .cast files into players
+func embedCasts(page string) string {
+    if !strings.Contains(page, ".cast\"") {
+        return page
+    }
+    return castLink.ReplaceAllStringFunc(page, func(m string) string {
+        sub := castLink.FindStringSubmatch(m)
+        return castPlayer(sub[1], sub[2], nil)
+    })
+}
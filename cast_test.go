@@ -0,0 +1,40 @@
+package mdserve
+
+import (
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestCastPlayerSource(t *testing.T) {
+    player := t.TempDir()
+    os.WriteFile(filepath.Join(player, "asciinema-player.min.js"), []byte("// player"), 0644)
+    os.WriteFile(filepath.Join(player, "asciinema-player.css"), []byte("/* player */"), 0644)
+    os.WriteFile(filepath.Join(player, "other.js"), []byte("// other"), 0644)
+    tests := []struct {
+        name   string
+        opts   []Option
+        loaded string // Where the page loads the player from
+        served int    // Status of /asciinema-player.min.js
+    }{
+        {"jsDelivr", nil, castPlayerCDN, http.StatusNotFound},
+        {"self-hosted", []Option{WithCastPlayer(player)}, `"/asciinema-player"`, http.StatusOK},
+        {"self-hosted under a base path", []Option{WithCastPlayer(player), WithBasePath("/docs")}, `"/docs/asciinema-player"`, http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, map[string]string{"demo.md": "[Demo](demo.cast)\n"}, tt.opts...)
+            if page := doRequest(s, "GET", "/demo.md", nil, true).Body.String(); !strings.Contains(page, tt.loaded) {
+                t.Errorf("page does not load the player from %s", tt.loaded)
+            }
+            if w := doRequest(s, "GET", "/asciinema-player.min.js", nil, true); w.Code != tt.served {
+                t.Errorf("got %d for the player script, want %d", w.Code, tt.served)
+            }
+            if w := doRequest(s, "GET", "/other.js", nil, true); w.Code != http.StatusNotFound {
+                t.Errorf("got %d for another file of the player directory", w.Code)
+            }
+        })
+    }
+}
@@ -0,0 +1,229 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "fmt"
+    "html"
+    "math"
+    "strings"
+)
+
+// Chart described in a ```chart block. Both this flat form and Chart.js
+// configs ({"type", "data": {"labels", "datasets"}, "options"}) are accepted.
+type chartSpec struct {
+    Type     string         `json:"type"` // bar (default), line or pie
+    Title    string         `json:"title"`
+    Labels   []string       `json:"labels"`
+    Datasets []chartDataset `json:"datasets"`
+    Data     *struct {
+        Labels   []string       `json:"labels"`
+        Datasets []chartDataset `json:"datasets"`
+    } `json:"data"`
+    Options struct {
+        Plugins struct {
+            Title struct {
+                Text string `json:"text"`
+            } `json:"title"`
+        } `json:"plugins"`
+    } `json:"options"`
+}
+
+type chartDataset struct {
+    Label string    `json:"label"`
+    Data  []float64 `json:"data"`
+}
+
+var chartColors = []string{"#4e79a7", "#f28e2b", "#59a14f", "#e15759", "#76b7b2", "#edc948", "#b07aa1", "#9c755f"}
+
+const (
+    chartWidth  = 640
+    chartHeight = 320
+    chartLeft   = 50 // Room for the value axis
+    chartRight  = 20
+    chartTop    = 36 // Room for the title and legend
+    chartBottom = 40 // Room for the labels
+)
+
+// Render a ```chart block as an inline SVG, so charts need no scripts
+func renderChart(opts []string, body string) (string, error) {
+    var spec chartSpec
+    if err := json.Unmarshal([]byte(body), &spec); err != nil {
+        return "", fmt.Errorf("invalid chart JSON: %v", err)
+    }
+    if spec.Data != nil {
+        spec.Labels, spec.Datasets = spec.Data.Labels, spec.Data.Datasets
+    }
+    if spec.Title == "" {
+        spec.Title = spec.Options.Plugins.Title.Text
+    }
+    if len(spec.Datasets) == 0 {
+        return "", fmt.Errorf("chart has no datasets")
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, `<figure class="chart"><svg viewBox="0 0 %d %d" role="img" aria-label="%s" font-size="12" fill="currentColor">`,
+        chartWidth, chartHeight, html.EscapeString(spec.Title))
+    if spec.Title != "" {
+        fmt.Fprintf(&b, `<text x="%d" y="16" text-anchor="middle" font-weight="bold" font-size="14">%s</text>`, chartWidth/2, html.EscapeString(spec.Title))
+    }
+    switch spec.Type {
+    case "pie", "doughnut":
+        pieChart(&b, spec)
+    case "line":
+        axisChart(&b, spec, true)
+    case "", "bar":
+        axisChart(&b, spec, false)
+    default:
+        return "", fmt.Errorf("unknown chart type %q", spec.Type)
+    }
+    b.WriteString("</svg></figure>\n")
+    return b.String(), nil
+}
+
+// Bar or line chart with a value axis
+func axisChart(b *strings.Builder, spec chartSpec, line bool) {
+    points := len(spec.Labels)
+    lo, hi := 0.0, 0.0
+    for _, ds := range spec.Datasets {
+        points = max(points, len(ds.Data))
+        for _, v := range ds.Data {
+            lo, hi = math.Min(lo, v), math.Max(hi, v)
+        }
+    }
+    if points == 0 {
+        return
+    }
+    step := niceStep((hi - lo) / 5)
+    lo, hi = math.Floor(lo/step)*step, math.Ceil(hi/step)*step
+    if hi == lo {
+        hi = lo + step
+    }
+
+    plotW := float64(chartWidth - chartLeft - chartRight)
+    plotH := float64(chartHeight - chartTop - chartBottom)
+    y := func(v float64) float64 { return float64(chartTop) + plotH*(hi-v)/(hi-lo) }
+    slot := plotW / float64(points)
+
+    // Grid lines and value labels
+    for v := lo; v <= hi+step/2; v += step {
+        fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="currentColor" stroke-opacity="0.15"/>`,
+            chartLeft, chartWidth-chartRight, y(v), y(v))
+        fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`,
+            chartLeft-6, y(v), formatTick(v))
+    }
+    for i, label := range spec.Labels {
+        fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+            float64(chartLeft)+slot*(float64(i)+0.5), chartHeight-chartBottom+18, html.EscapeString(label))
+    }
+
+    for d, ds := range spec.Datasets {
+        color := chartColors[d%len(chartColors)]
+        if line {
+            var pts []string
+            for i, v := range ds.Data {
+                x := float64(chartLeft) + slot*(float64(i)+0.5)
+                pts = append(pts, fmt.Sprintf("%.1f,%.1f", x, y(v)))
+                fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s</title></circle>`, x, y(v), color, chartTooltip(spec, ds, i))
+            }
+            fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(pts, " "), color)
+            continue
+        }
+        barW := slot * 0.8 / float64(len(spec.Datasets))
+        for i, v := range ds.Data {
+            x := float64(chartLeft) + slot*float64(i) + slot*0.1 + barW*float64(d)
+            top, bottom := y(math.Max(v, 0)), y(math.Min(v, 0))
+            fmt.Fprintf(b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s</title></rect>`,
+                x, top, barW, bottom-top, color, chartTooltip(spec, ds, i))
+        }
+    }
+    fmt.Fprintf(b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="currentColor"/>`, chartLeft, chartWidth-chartRight, y(0), y(0))
+
+    if len(spec.Datasets) > 1 || spec.Datasets[0].Label != "" {
+        var names []string
+        for _, ds := range spec.Datasets {
+            names = append(names, ds.Label)
+        }
+        chartLegend(b, names)
+    }
+}
+
+// Pie chart of the first dataset
+func pieChart(b *strings.Builder, spec chartSpec) {
+    ds := spec.Datasets[0]
+    total := 0.0
+    for _, v := range ds.Data {
+        total += math.Max(v, 0)
+    }
+    if total == 0 {
+        return
+    }
+    cx, cy := float64(chartWidth)/2, float64(chartTop+chartHeight)/2
+    r := float64(chartHeight-chartTop)/2 - 10
+    angle := -math.Pi / 2
+    for i, v := range ds.Data {
+        if v <= 0 {
+            continue
+        }
+        color := chartColors[i%len(chartColors)]
+        sweep := 2 * math.Pi * v / total
+        if sweep >= 2*math.Pi-1e-9 {
+            fmt.Fprintf(b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"><title>%s</title></circle>`, cx, cy, r, color, chartTooltip(spec, ds, i))
+            break
+        }
+        large := 0
+        if sweep > math.Pi {
+            large = 1
+        }
+        x1, y1 := cx+r*math.Cos(angle), cy+r*math.Sin(angle)
+        angle += sweep
+        x2, y2 := cx+r*math.Cos(angle), cy+r*math.Sin(angle)
+        fmt.Fprintf(b, `<path d="M%.1f,%.1f L%.1f,%.1f A%.1f,%.1f 0 %d 1 %.1f,%.1f Z" fill="%s"><title>%s</title></path>`,
+            cx, cy, x1, y1, r, r, large, x2, y2, color, chartTooltip(spec, ds, i))
+    }
+    chartLegend(b, spec.Labels)
+}
+
+// One legend entry per name along the top right
+func chartLegend(b *strings.Builder, names []string) {
+    if len(names) > len(chartColors) {
+        return
+    }
+    x, y := chartWidth-chartRight, chartTop-12
+    for i := len(names) - 1; i >= 0; i-- {
+        x -= 7*len([]rune(names[i])) + 24
+        fmt.Fprintf(b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/><text x="%d" y="%d">%s</text>`,
+            x, y, chartColors[i], x+14, y+9, html.EscapeString(names[i]))
+    }
+}
+
+func chartTooltip(spec chartSpec, ds chartDataset, i int) string {
+    label := ""
+    if i < len(spec.Labels) {
+        label = spec.Labels[i]
+    }
+    parts := []string{}
+    for _, p := range []string{ds.Label, label} {
+        if p != "" {
+            parts = append(parts, p)
+        }
+    }
+    return html.EscapeString(strings.Join(append(parts, formatTick(ds.Data[i])), ": "))
+}
+
+// Round a raw axis step up to 1, 2 or 5 times a power of ten
+func niceStep(raw float64) float64 {
+    if raw <= 0 {
+        return 1
+    }
+    mag := math.Pow(10, math.Floor(math.Log10(raw)))
+    for _, m := range []float64{1, 2, 5, 10} {
+        if raw <= m*mag {
+            return m * mag
+        }
+    }
+    return 10 * mag
+}
+
+func formatTick(v float64) string {
+    return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
@@ -0,0 +1,48 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestChartFences(t *testing.T) {
+    s := New()
+    tests := []struct {
+        name    string
+        src     string
+        want    []string // In the page
+        notWant []string
+    }{
+        {"bar", `{"title": "Stars", "labels": ["a", "b"], "datasets": [{"data": [3, 5]}]}`, []string{`<figure class="chart"><svg`, `aria-label="Stars"`, "<rect", "<title>a: 3</title>", "<title>b: 5</title>"}, []string{"<polyline", "<script"}},
+        {"line with legend", `{"type": "line", "labels": ["a", "b"], "datasets": [{"label": "2024", "data": [1, 2]}, {"label": "2025", "data": [2, 4]}]}`, []string{"<polyline", "<title>2025: b: 4</title>", ">2024</text>"}, nil},
+        {"pie", `{"type": "pie", "labels": ["x", "y"], "datasets": [{"data": [1, 3]}]}`, []string{"<path d=", "<title>y: 3</title>"}, nil},
+        {"chart.js config", `{"type": "bar", "data": {"labels": ["q1"], "datasets": [{"label": "sales", "data": [7]}]}, "options": {"plugins": {"title": {"text": "Sales"}}}}`, []string{`aria-label="Sales"`, "<title>sales: q1: 7</title>"}, nil},
+        {"escaped", `{"title": "<b>", "labels": ["<i>"], "datasets": [{"data": [1]}]}`, []string{"&lt;b&gt;", "&lt;i&gt;"}, []string{"<b>", "<i>"}},
+        {"invalid JSON", `{"labels": [`, []string{`<p class="sc-error">chart: invalid chart JSON`}, []string{"<svg"}},
+        {"no datasets", `{"labels": ["a"]}`, []string{"chart has no datasets"}, []string{"<svg"}},
+        {"unknown type", `{"type": "radar", "datasets": [{"data": [1]}]}`, []string{`unknown chart type &#34;radar&#34;`}, []string{"<svg"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte("```chart\n"+tt.src+"\n```\n"), s.markdown))
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+}
+
+func TestNiceStep(t *testing.T) {
+    for raw, want := range map[float64]float64{0: 1, 0.3: 0.5, 1: 1, 1.5: 2, 3: 5, 7: 10, 42: 50, 180: 200} {
+        if got := niceStep(raw); got != want {
+            t.Errorf("niceStep(%v) = %v, want %v", raw, got, want)
+        }
+    }
+}
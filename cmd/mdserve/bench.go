@@ -0,0 +1,228 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math/rand"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+    "github.com/awkto/mdserve"
+)
+
+// Words for the synthetic corpus
+var benchWords = strings.Fields(`the server renders markdown files into pages with a table of contents
+and themes so that teams can keep their documentation next to the code while readers browse
+it with links images tables lists tasks shortcodes alerts and code blocks in many languages`)
+
+// mdserve bench [-files n] [-size 50kb] [-dir d] [-url http://host:port] [-requests n] [-concurrency n]
+//
+// Generates a synthetic corpus (unless dir already holds one) and requests
+// random pages from it, reporting render latencies. Without -url the corpus
+// is served in-process.
+func runBench(args []string) error {
+    fs := flag.NewFlagSet("bench", flag.ExitOnError)
+    files := fs.Int("files", 1000, "number of documents to generate")
+    size := fs.String("size", "10kb", "approximate `size` of each document, e.g. 50kb or 1mb")
+    dir := fs.String("dir", "bench-corpus", "corpus `dir`; reused when it already exists")
+    target := fs.String("url", "", "base `url` of a running instance serving dir (default: serve it in-process)")
+    auth := fs.String("auth", "", "`user:password` for -url")
+    requests := fs.Int("requests", 2000, "total number of requests")
+    concurrency := fs.Int("concurrency", 8, "concurrent clients")
+    cacheSize := fs.Int("cache", 256, "page cache size for the in-process server")
+    fs.Parse(args)
+
+    bytes, err := parseSize(*size)
+    if err != nil {
+        return err
+    }
+    paths, err := benchCorpus(*dir, *files, bytes)
+    if err != nil {
+        return err
+    }
+    if len(paths) == 0 {
+        return fmt.Errorf("no documents in %s", *dir)
+    }
+
+    user, password := "", ""
+    base := strings.TrimRight(*target, "/")
+    if base == "" {
+        user, password = "admin", "bench"
+        srv := mdserve.New(
+            mdserve.WithMounts(mdserve.Mount{Prefix: "/", Root: *dir}),
+            mdserve.WithAuth(user, password),
+            mdserve.WithCache(*cacheSize),
+        )
+        ts := httptest.NewServer(srv)
+        defer ts.Close()
+        base = ts.URL
+    } else if *auth != "" {
+        parts := strings.SplitN(*auth, ":", 2)
+        if len(parts) != 2 {
+            return fmt.Errorf("-auth must be user:password")
+        }
+        user, password = parts[0], parts[1]
+    }
+
+    fmt.Printf("%d documents, %d requests against %s with %d clients\n", len(paths), *requests, base, *concurrency)
+    latencies, failures, elapsed := benchRequests(base, user, password, paths, *requests, *concurrency)
+    if len(latencies) == 0 {
+        return fmt.Errorf("all %d requests failed", failures)
+    }
+
+    sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+    percentile := func(p float64) time.Duration {
+        return latencies[int(p*float64(len(latencies)-1))]
+    }
+    fmt.Printf("p50 %v  p95 %v  p99 %v  max %v\n",
+        percentile(0.50), percentile(0.95), percentile(0.99), latencies[len(latencies)-1])
+    fmt.Printf("%.0f req/s, %d failed\n", float64(len(latencies))/elapsed.Seconds(), failures)
+    return nil
+}
+
+// Parse sizes such as 512, 50kb or 1mb
+func parseSize(s string) (int, error) {
+    s = strings.ToLower(strings.TrimSpace(s))
+    unit := 1
+    switch {
+    case strings.HasSuffix(s, "kb"):
+        unit, s = 1024, strings.TrimSuffix(s, "kb")
+    case strings.HasSuffix(s, "mb"):
+        unit, s = 1024*1024, strings.TrimSuffix(s, "mb")
+    case strings.HasSuffix(s, "b"):
+        s = strings.TrimSuffix(s, "b")
+    }
+    n, err := strconv.Atoi(s)
+    if err != nil || n <= 0 {
+        return 0, fmt.Errorf("invalid size %q", s)
+    }
+    return n * unit, nil
+}
+
+// Generate the corpus unless dir exists, and return the URL paths of its documents
+func benchCorpus(dir string, files, size int) ([]string, error) {
+    if _, err := os.Stat(dir); os.IsNotExist(err) {
+        fmt.Printf("Generating %d documents in %s\n", files, dir)
+        rng := rand.New(rand.NewSource(1))
+        for i := 0; i < files; i++ {
+            file := filepath.Join(dir, fmt.Sprintf("section-%03d", i/100), fmt.Sprintf("doc-%05d.md", i))
+            if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+                return nil, err
+            }
+            if err := ioutil.WriteFile(file, benchDocument(rng, i, size), 0644); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    var paths []string
+    err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if !info.IsDir() && strings.HasSuffix(file, ".md") {
+            rel, _ := filepath.Rel(dir, file)
+            paths = append(paths, filepath.ToSlash(rel))
+        }
+        return nil
+    })
+    return paths, err
+}
+
+// A document of roughly size bytes mixing the usual markdown constructs
+func benchDocument(rng *rand.Rand, n, size int) []byte {
+    sentence := func(words int) string {
+        s := make([]string, words)
+        for i := range s {
+            s[i] = benchWords[rng.Intn(len(benchWords))]
+        }
+        return strings.ToUpper(s[0][:1]) + strings.Join(s, " ")[1:] + "."
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "---\ntitle: Document %d\ntags: [bench, section-%03d]\n---\n\n# Document %d\n\n", n, n/100, n)
+    for section := 1; b.Len() < size; section++ {
+        fmt.Fprintf(&b, "## Section %d\n\n", section)
+        switch section % 5 {
+        case 0:
+            b.WriteString("```go\nfunc main() {\n    fmt.Println(\"hello\")\n}\n```\n\n")
+        case 1:
+            for i := 0; i < 4; i++ {
+                fmt.Fprintf(&b, "- [%s] %s\n", map[bool]string{true: "x", false: " "}[rng.Intn(2) == 0], sentence(6))
+            }
+            b.WriteString("\n")
+        case 2:
+            b.WriteString("| Name | Value |\n|------|-------|\n")
+            for i := 0; i < 4; i++ {
+                fmt.Fprintf(&b, "| %s | %d |\n", benchWords[rng.Intn(len(benchWords))], rng.Intn(1000))
+            }
+            b.WriteString("\n")
+        case 3:
+            fmt.Fprintf(&b, "See [document %d](../section-%03d/doc-%05d.md) and **%s**.\n\n",
+                n+1, (n+1)/100, n+1, sentence(3))
+        }
+        for p := 0; p < 3; p++ {
+            for s := 0; s < 5; s++ {
+                b.WriteString(sentence(8+rng.Intn(10)) + " ")
+            }
+            b.WriteString("\n\n")
+        }
+    }
+    return []byte(b.String())
+}
+
+// Fire total requests for random documents from concurrency clients
+func benchRequests(base, user, password string, paths []string, total, concurrency int) ([]time.Duration, int, time.Duration) {
+    var (
+        mu        sync.Mutex
+        latencies []time.Duration
+        failures  int
+        wg        sync.WaitGroup
+    )
+    jobs := make(chan string)
+    client := &http.Client{Timeout: 30 * time.Second}
+
+    start := time.Now()
+    for c := 0; c < concurrency; c++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for path := range jobs {
+                req, _ := http.NewRequest("GET", base+"/"+path, nil)
+                if user != "" {
+                    req.SetBasicAuth(user, password)
+                }
+                t := time.Now()
+                resp, err := client.Do(req)
+                if err == nil {
+                    io.Copy(ioutil.Discard, resp.Body)
+                    resp.Body.Close()
+                }
+                elapsed := time.Since(t)
+
+                mu.Lock()
+                if err != nil || resp.StatusCode != http.StatusOK {
+                    failures++
+                } else {
+                    latencies = append(latencies, elapsed)
+                }
+                mu.Unlock()
+            }
+        }()
+    }
+    rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+    for i := 0; i < total; i++ {
+        jobs <- paths[rng.Intn(len(paths))]
+    }
+    close(jobs)
+    wg.Wait()
+    return latencies, failures, time.Since(start)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+    "github.com/awkto/mdserve"
+)
+
+// mdserve check links [-external] [-concurrency n] [-format text|json] [-drafts=false] [-mount /prefix=/dir ...] [dir]
+// mdserve check orphans [-format text|json] [-drafts=false] [-mount /prefix=/dir ...] [dir]
+// mdserve check spelling [-dict file,...] [-format text|json] [-drafts=false] [-mount /prefix=/dir ...] [dir]
+//
+// Reports links that do not resolve, documents no link leads to or
+// misspelled words, one per line, and fails when there are any, for use
+// in CI.
+func runCheck(args []string) error {
+    if len(args) == 0 || args[0] != "links" && args[0] != "orphans" && args[0] != "spelling" {
+        return fmt.Errorf("usage: mdserve check links|orphans|spelling [flags] [dir]")
+    }
+    fs := flag.NewFlagSet("check "+args[0], flag.ExitOnError)
+    external := fs.Bool("external", false, "also request http(s) links")
+    concurrency := fs.Int("concurrency", 8, "external requests in flight")
+    timeout := fs.Duration("timeout", 10*time.Second, "per external request")
+    dicts := fs.String("dict", "", "comma-separated word list `files`, one word per line, besides the built-in English words and each tree's .spelling")
+    format := fs.String("format", "text", "output format: text or json")
+    drafts := fs.Bool("drafts", true, "include documents marked draft: true")
+    var mounts mountFlag
+    fs.Var(&mounts, "mount", "check `/prefix=/dir` (repeatable)")
+    fs.Parse(args[1:])
+
+    if len(mounts) == 0 {
+        root := "."
+        if fs.NArg() > 0 {
+            root = fs.Arg(0)
+        }
+        mounts = mountFlag{{Prefix: "/", Root: root}}
+    }
+    opts := []mdserve.Option{mdserve.WithMounts(mounts...)}
+    if *drafts {
+        opts = append(opts, mdserve.WithDrafts())
+    }
+    srv := mdserve.New(opts...)
+
+    var found interface{}
+    var lines []string
+    what := "broken links"
+    switch args[0] {
+    case "spelling":
+        what = "misspelled words"
+        dict := mdserve.DefaultDictionary()
+        for _, file := range strings.Split(*dicts, ",") {
+            if file == "" {
+                continue
+            }
+            if err := dict.Load(file); err != nil {
+                return err
+            }
+        }
+        issues, err := srv.CheckSpelling(dict)
+        if err != nil {
+            return err
+        }
+        for _, i := range issues {
+            line := fmt.Sprintf("%s:%d: %s", i.Path, i.Line, i.Word)
+            if len(i.Suggestions) > 0 {
+                line += " (" + strings.Join(i.Suggestions, ", ") + ")"
+            }
+            lines = append(lines, line)
+        }
+        found = issues
+    case "orphans":
+        what = "orphaned documents"
+        orphans, err := srv.Orphans()
+        if err != nil {
+            return err
+        }
+        for _, o := range orphans {
+            lines = append(lines, fmt.Sprintf("%s: %s", o.Path, o.Reason))
+        }
+        found = orphans
+    default:
+        problems, err := srv.CheckLinks(mdserve.LinkCheckOptions{
+            External:    *external,
+            Concurrency: *concurrency,
+            Timeout:     *timeout,
+        })
+        if err != nil {
+            return err
+        }
+        for _, p := range problems {
+            lines = append(lines, fmt.Sprintf("%s: %s: %s", p.Path, p.Link, p.Reason))
+        }
+        found = problems
+    }
+
+    switch *format {
+    case "json":
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(found); err != nil {
+            return err
+        }
+    case "text":
+        for _, line := range lines {
+            fmt.Println(line)
+        }
+    default:
+        return fmt.Errorf("unknown format %q", *format)
+    }
+    if len(lines) > 0 {
+        return fmt.Errorf("%d %s", len(lines), what)
+    }
+    return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+    "log"
+    "os"
+)
+
+var logFileName string // Set by -log-file, reopened on SIGHUP
+var logFileOut *os.File
+
+// Send log messages to the end of file, closing the one used before
+func openLogFile(file string) error {
+    f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+    if err != nil {
+        return err
+    }
+    log.SetOutput(f)
+    if logFileOut != nil {
+        logFileOut.Close()
+    }
+    logFileName, logFileOut = file, f
+    return nil
+}
+
+// The command line without -daemon, for the detached copy to run
+func daemonArgs() []string {
+    var args []string
+    for _, arg := range os.Args[1:] {
+        switch arg {
+        case "-daemon", "--daemon", "-daemon=true", "--daemon=true":
+            continue
+        }
+        args = append(args, arg)
+    }
+    return args
+}
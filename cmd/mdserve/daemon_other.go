@@ -0,0 +1,34 @@
+//go:build !windows
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "syscall"
+)
+
+// Start the server again detached from the terminal, in a new session,
+// with its output going to logFile or nowhere
+func daemonize(logFile string) error {
+    exe, err := os.Executable()
+    if err != nil {
+        return err
+    }
+    cmd := exec.Command(exe, daemonArgs()...)
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+    if logFile != "" {
+        f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        cmd.Stdout, cmd.Stderr = f, f
+    }
+    if err := cmd.Start(); err != nil {
+        return err
+    }
+    fmt.Printf("Started in the background, process %d\n", cmd.Process.Pid)
+    return cmd.Process.Release()
+}
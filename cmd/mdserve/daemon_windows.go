@@ -0,0 +1,7 @@
+package main
+
+import "fmt"
+
+func daemonize(logFile string) error {
+    return fmt.Errorf("-daemon is not supported on Windows; install a service with mdserve service install")
+}
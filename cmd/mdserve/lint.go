@@ -0,0 +1,103 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "github.com/awkto/mdserve"
+)
+
+// mdserve lint [-config .markdownlint.json] [-enable rules] [-disable rules] [-format text|json] [-drafts=false] [-mount /prefix=/dir ...] [dir]
+//
+// Checks documents against markdownlint-style rules and fails when any
+// are broken. Rules are picked by a .markdownlint.json in dir, then the
+// -enable and -disable lists of IDs or names.
+func runLint(args []string) error {
+    fs := flag.NewFlagSet("lint", flag.ExitOnError)
+    configFile := fs.String("config", "", "markdownlint JSON `file` turning rules on or off (default dir/.markdownlint.json)")
+    enable := fs.String("enable", "", "comma-separated rules to turn on, e.g. MD001,no-bare-urls")
+    disable := fs.String("disable", "", "comma-separated rules to turn off")
+    format := fs.String("format", "text", "output format: text or json")
+    list := fs.Bool("rules", false, "list the rules and exit")
+    drafts := fs.Bool("drafts", true, "include documents marked draft: true")
+    var mounts mountFlag
+    fs.Var(&mounts, "mount", "lint `/prefix=/dir` (repeatable)")
+    fs.Parse(args)
+
+    if *list {
+        for _, r := range mdserve.LintRules {
+            fmt.Printf("%s/%s\t%s\n", r.ID, r.Name, r.Description)
+        }
+        return nil
+    }
+    root := "."
+    if len(mounts) == 0 {
+        if fs.NArg() > 0 {
+            root = fs.Arg(0)
+        }
+        mounts = mountFlag{{Prefix: "/", Root: root}}
+    }
+
+    rules := map[string]bool{}
+    file := *configFile
+    if file == "" {
+        file = filepath.Join(root, ".markdownlint.json")
+    }
+    if data, err := ioutil.ReadFile(file); err == nil {
+        // Rules may also be objects of options, which count as on
+        var raw map[string]interface{}
+        if err := json.Unmarshal(data, &raw); err != nil {
+            return fmt.Errorf("could not parse %s: %v", file, err)
+        }
+        for key, v := range raw {
+            on, isBool := v.(bool)
+            rules[key] = on || !isBool
+        }
+    } else if *configFile != "" {
+        return err
+    }
+    for _, key := range strings.Split(*enable, ",") {
+        if key != "" {
+            rules[key] = true
+        }
+    }
+    for _, key := range strings.Split(*disable, ",") {
+        if key != "" {
+            rules[key] = false
+        }
+    }
+
+    opts := []mdserve.Option{mdserve.WithMounts(mounts...)}
+    if *drafts {
+        opts = append(opts, mdserve.WithDrafts())
+    }
+    issues, err := mdserve.New(opts...).Lint(rules)
+    if err != nil {
+        return err
+    }
+    switch *format {
+    case "json":
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if issues == nil {
+            issues = []mdserve.LintIssue{}
+        }
+        if err := enc.Encode(issues); err != nil {
+            return err
+        }
+    case "text":
+        for _, i := range issues {
+            fmt.Printf("%s:%d %s %s\n", i.Path, i.Line, i.Rule, i.Message)
+        }
+    default:
+        return fmt.Errorf("unknown format %q", *format)
+    }
+    if len(issues) > 0 {
+        return fmt.Errorf("%d problems", len(issues))
+    }
+    return nil
+}
@@ -0,0 +1,727 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "io/ioutil"
+    "log"
+    "net"
+    "net/http"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "syscall"
+    "time"
+    "github.com/awkto/mdserve"
+)
+
+// Config file given with -config
+type config struct {
+    Theme   string                 `json:"theme"`
+    Favicon string                 `json:"favicon"` // Icon file served at /favicon.ico
+    Robots  string                 `json:"robots"`  // File served as /robots.txt
+    Cast    string                 `json:"cast_player"` // Directory with a copy of the asciinema player
+    Hosts   []hostConfig           `json:"hosts"`
+    Data    map[string]interface{} `json:"data"` // Variables for custom templates
+    Filters []filterConfig         `json:"filters"`
+    Ignore  []string               `json:"ignore"` // Globs added to -ignore
+    Tenants []tenantConfig         `json:"tenants"`
+
+    PlantUML struct {
+        Server   string   `json:"server"`
+        Command  []string `json:"command"`
+        CacheDir string   `json:"cache_dir"`
+    } `json:"plantuml"`
+
+    Analytics struct {
+        Plausible       string `json:"plausible"` // Site domain
+        PlausibleServer string `json:"plausible_server"`
+        Matomo          string `json:"matomo"` // Server URL
+        MatomoSiteID    string `json:"matomo_site_id"`
+        Snippet         string `json:"snippet"` // Custom HTML
+        Counter         bool   `json:"counter"` // Count views server-side too
+    } `json:"analytics"`
+}
+
+// One external filter command from the config file
+type filterConfig struct {
+    Command []string `json:"command"`
+    Stage   string   `json:"stage"`   // markdown or html
+    Match   string   `json:"match"`   // Glob, e.g. "*.md" or "specs/*.md"
+    Timeout string   `json:"timeout"` // Duration, e.g. "30s"
+}
+
+// One virtual host from the config file
+type hostConfig struct {
+    Host         string `json:"host"`
+    Root         string `json:"root"`
+    Index        string `json:"index"`
+    ReadOnly     bool   `json:"readonly"`
+    Theme        string `json:"theme"`
+    Username     string `json:"username"`
+    PasswordFile string `json:"password_file"`
+    Public       bool   `json:"public"`
+}
+
+// One tenant from the config file: a server of its own for a host name, a
+// path prefix or both, set up like the main one unless it says otherwise
+type tenantConfig struct {
+    Host              string                 `json:"host"`
+    Prefix            string                 `json:"prefix"` // e.g. /team-a
+    Root              string                 `json:"root"`
+    Index             string                 `json:"index"`
+    ReadOnly          bool                   `json:"readonly"`
+    Theme             string                 `json:"theme"`
+    Username          string                 `json:"username"`
+    PasswordFile      string                 `json:"password_file"`
+    Public            bool                   `json:"public"`
+    TOC               string                 `json:"toc"`
+    Lang              string                 `json:"lang"`
+    Stats             *bool                  `json:"stats"`
+    Drafts            *bool                  `json:"drafts"`
+    InteractiveTables *bool                  `json:"interactive_tables"`
+    NumberSections    *bool                  `json:"number_sections"`
+    CommonMark        *bool                  `json:"commonmark"`
+    Data              map[string]interface{} `json:"data"`
+
+    password string // Read from PasswordFile
+}
+
+// The configuration of a tenant's server: the main one's with the
+// tenant's tree, credentials and settings
+func tenantServerConfig(main mdserve.Config, t tenantConfig) mdserve.Config {
+    cfg := main
+    cfg.Mounts = []mdserve.Mount{{Prefix: "/", Root: t.Root, Index: t.Index, ReadOnly: t.ReadOnly}}
+    cfg.Hosts = nil
+    cfg.BasePath = t.Prefix
+    cfg.Name = t.Host + t.Prefix
+    cfg.Public = t.Public
+    if t.Username != "" {
+        cfg.Username = t.Username
+    }
+    if t.password != "" {
+        cfg.Password = t.password
+    }
+    if t.Theme != "" {
+        cfg.Theme = t.Theme
+    }
+    if t.TOC != "" {
+        cfg.TOCPosition = t.TOC
+    }
+    if t.Lang != "" {
+        cfg.Language = t.Lang
+    }
+    if t.Data != nil {
+        cfg.Data = t.Data
+    }
+    for _, toggle := range []struct {
+        set *bool
+        to  *bool
+    }{{t.Stats, &cfg.Stats}, {t.Drafts, &cfg.Drafts}, {t.InteractiveTables, &cfg.InteractiveTables}, {t.NumberSections, &cfg.NumberSections}, {t.CommonMark, &cfg.CommonMark}} {
+        if toggle.set != nil {
+            *toggle.to = *toggle.set
+        }
+    }
+    return cfg
+}
+
+// Load the config file into cfg, returning its tenants
+func loadConfig(filePath string, cfg *mdserve.Config) ([]tenantConfig, error) {
+    data, err := ioutil.ReadFile(filePath)
+    if err != nil {
+        return nil, fmt.Errorf("could not read config: %v", err)
+    }
+    var file config
+    if err := json.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("could not parse config %s: %v", filePath, err)
+    }
+
+    if file.Theme != "" {
+        cfg.Theme = file.Theme
+    }
+    if file.Favicon != "" {
+        cfg.Favicon = file.Favicon
+    }
+    if file.Robots != "" {
+        cfg.Robots = file.Robots
+    }
+    if file.Cast != "" {
+        cfg.CastPlayer = file.Cast
+    }
+    cfg.Data = file.Data
+    cfg.Ignore = append(cfg.Ignore, file.Ignore...)
+    cfg.PlantUML = mdserve.PlantUML(file.PlantUML)
+    cfg.Analytics = mdserve.Analytics(file.Analytics)
+    if cfg.Analytics.Matomo != "" && cfg.Analytics.MatomoSiteID == "" {
+        return nil, fmt.Errorf("analytics: matomo needs matomo_site_id")
+    }
+    for _, f := range file.Filters {
+        if len(f.Command) == 0 {
+            return nil, fmt.Errorf("config filter entries need a command")
+        }
+        if f.Stage != "" && f.Stage != "markdown" && f.Stage != "html" {
+            return nil, fmt.Errorf("filter %s: stage must be markdown or html", f.Command[0])
+        }
+        filter := mdserve.Filter{Command: f.Command, Stage: f.Stage, Match: f.Match}
+        if f.Timeout != "" {
+            if filter.Timeout, err = time.ParseDuration(f.Timeout); err != nil {
+                return nil, fmt.Errorf("filter %s: %v", f.Command[0], err)
+            }
+        }
+        cfg.Filters = append(cfg.Filters, filter)
+    }
+    for _, h := range file.Hosts {
+        if h.Host == "" || h.Root == "" {
+            return nil, fmt.Errorf("config host entries need both host and root")
+        }
+        if h.Theme != "" {
+            if _, ok := mdserve.Themes[h.Theme]; !ok {
+                return nil, fmt.Errorf("unknown theme %q for host %s", h.Theme, h.Host)
+            }
+        }
+        host := mdserve.Host{
+            Name:     h.Host,
+            Mounts:   []mdserve.Mount{{Prefix: "/", Root: h.Root, Index: h.Index, ReadOnly: h.ReadOnly}},
+            Theme:    h.Theme,
+            Username: h.Username,
+            Public:   h.Public,
+        }
+        if h.PasswordFile != "" {
+            if host.Password, err = readPasswordFromFile(h.PasswordFile); err != nil {
+                return nil, fmt.Errorf("host %s: %v", h.Host, err)
+            }
+        }
+        cfg.Hosts = append(cfg.Hosts, host)
+    }
+    for i, t := range file.Tenants {
+        if (t.Host == "" && t.Prefix == "") || t.Root == "" {
+            return nil, fmt.Errorf("config tenants need a root and a host or prefix")
+        }
+        name := t.Host + t.Prefix
+        if t.Prefix != "" && (!strings.HasPrefix(t.Prefix, "/") || strings.Trim(t.Prefix, "/") == "") {
+            return nil, fmt.Errorf("tenant %s: prefix must look like /name", name)
+        }
+        if _, ok := mdserve.Themes[t.Theme]; t.Theme != "" && !ok {
+            return nil, fmt.Errorf("unknown theme %q for tenant %s", t.Theme, name)
+        }
+        if _, ok := mdserve.Messages[t.Lang]; t.Lang != "" && !ok {
+            return nil, fmt.Errorf("unknown language %q for tenant %s", t.Lang, name)
+        }
+        if t.TOC != "" && t.TOC != "left" && t.TOC != "right" && t.TOC != "none" {
+            return nil, fmt.Errorf("unknown TOC position %q for tenant %s", t.TOC, name)
+        }
+        if t.PasswordFile != "" {
+            if file.Tenants[i].password, err = readPasswordFromFile(t.PasswordFile); err != nil {
+                return nil, fmt.Errorf("tenant %s: %v", name, err)
+            }
+        }
+    }
+    return file.Tenants, nil
+}
+
+// Repeatable -mount flag: /prefix=/dir[,readonly][,index=file.md]
+type mountFlag []mdserve.Mount
+
+func (f *mountFlag) String() string {
+    var parts []string
+    for _, m := range *f {
+        parts = append(parts, m.Prefix+"="+m.Root)
+    }
+    return strings.Join(parts, " ")
+}
+
+func (f *mountFlag) Set(value string) error {
+    spec := strings.Split(value, ",")
+    prefix, root, ok := strings.Cut(spec[0], "=")
+    if !ok || prefix == "" || root == "" {
+        return fmt.Errorf("mount must look like /prefix=/dir, got %q", value)
+    }
+    m := mdserve.Mount{Prefix: prefix, Root: root}
+    for _, opt := range spec[1:] {
+        key, val, _ := strings.Cut(opt, "=")
+        switch key {
+        case "readonly":
+            m.ReadOnly = true
+        case "index":
+            m.Index = val
+        default:
+            return fmt.Errorf("unknown mount option %q", key)
+        }
+    }
+    *f = append(*f, m)
+    return nil
+}
+
+// Repeatable string flag
+type listFlag []string
+
+func (f *listFlag) String() string {
+    return strings.Join(*f, " ")
+}
+
+func (f *listFlag) Set(value string) error {
+    *f = append(*f, value)
+    return nil
+}
+
+// Read the password from a file
+func readPasswordFromFile(filePath string) (string, error) {
+    file, err := os.Open(filePath)
+    if err != nil {
+        return "", fmt.Errorf("could not open password file: %v", err)
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    if scanner.Scan() {
+        return scanner.Text(), nil
+    }
+    return "", fmt.Errorf("password file is empty")
+}
+
+// The servers answering requests, the main one first, then one per
+// tenant; reloading replaces them all
+type deployment struct {
+    servers  []*mdserve.Server
+    watchers []io.Closer // Keep the search indexes current, nil when off
+    handler  http.Handler
+}
+
+// Watch every server's files
+func (d *deployment) watch() {
+    d.watchers = make([]io.Closer, len(d.servers))
+    for i, srv := range d.servers {
+        w, err := srv.Watch()
+        if err != nil {
+            log.Printf("Not watching for changes: %v", err)
+            continue
+        }
+        d.watchers[i] = w
+    }
+}
+
+func (d *deployment) stopWatching() {
+    for _, w := range d.watchers {
+        if w != nil {
+            w.Close()
+        }
+    }
+}
+
+var current atomic.Pointer[deployment] // For cleanup on shutdown
+var reloadMu sync.Mutex                // Held while building or tearing down deployments
+var decryptedRoots = map[string]bool{} // Roots whose GPG files are decrypted, under reloadMu
+var decryptors []*mdserve.Server       // Every server started, whose decrypted and saved plaintext goes on shutdown
+var stdinDir string                    // Holds what was piped in with "mdserve -"
+var accessLogFile *mdserve.AccessLog   // nil without -access-log
+var cacheBackend mdserve.CacheBackend  // nil without -cache-redis
+var pidFileName string                 // Removed on shutdown, "" without -pidfile
+
+// Copy standard input to a document in a new temporary directory,
+// appending whatever arrives after it is served until input ends
+func pipeStdin() (string, error) {
+    dir, err := os.MkdirTemp("", "mdserve-stdin-")
+    if err != nil {
+        return "", err
+    }
+    file := filepath.Join(dir, "stdin.md")
+    f, err := os.Create(file)
+    if err != nil {
+        os.RemoveAll(dir)
+        return "", err
+    }
+    stdinDir = dir
+    go func() {
+        defer f.Close()
+        if _, err := io.Copy(f, os.Stdin); err != nil {
+            log.Printf("Reading standard input: %v", err)
+        }
+    }()
+    return file, nil
+}
+
+// Delete decrypted files and close the state store, after any reload
+// under way
+func cleanup() {
+    reloadMu.Lock()
+    defer reloadMu.Unlock()
+    d := current.Load()
+    if d == nil {
+        return
+    }
+    log.Println("Shutting down, cleaning up markdown files...")
+    sdNotify("STOPPING=1")
+    d.stopWatching()
+    for _, srv := range decryptors {
+        srv.Cleanup()
+    }
+    d.servers[0].Store().Close() // Shared by all
+    if accessLogFile != nil {
+        accessLogFile.Close()
+    }
+    if cacheBackend != nil {
+        cacheBackend.Close()
+    }
+    if stdinDir != "" {
+        os.RemoveAll(stdinDir)
+    }
+    if pidFileName != "" {
+        os.Remove(pidFileName)
+    }
+}
+
+// Clean up and exit on SIGINT and SIGTERM; on SIGHUP reopen the log file,
+// so it can be rotated, and call reload
+func handleSignals(reload func()) {
+    c := make(chan os.Signal, 1)
+    signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+    go func() {
+        for sig := range c {
+            if sig != syscall.SIGHUP {
+                cleanup()
+                os.Exit(0)
+            }
+            if logFileName != "" {
+                if err := openLogFile(logFileName); err != nil {
+                    log.Printf("Could not reopen log file: %v", err)
+                }
+            }
+            reload()
+        }
+    }()
+}
+
+// Subcommands run instead of the server
+var commands = map[string]func(args []string) error{
+    "bench":   runBench,
+    "check":   runCheck,
+    "lint":    runLint,
+    "report":  runReport,
+    "service": runService,
+}
+
+func main() {
+    if len(os.Args) > 1 {
+        if run, ok := commands[os.Args[1]]; ok {
+            if err := run(os.Args[2:]); err != nil {
+                log.Fatalf("%s: %v", os.Args[1], err)
+            }
+            return
+        }
+    }
+    serverMain()
+}
+
+// Parse the server flags from os.Args and serve until killed
+func serverMain() {
+    var mounts mountFlag
+    flag.Var(&mounts, "mount", "serve `/prefix=/dir` (repeatable; options: ,readonly ,index=file.md)")
+    configFile := flag.String("config", "", "JSON config `file` with virtual hosts")
+    theme := flag.String("theme", "", "default page theme (light, dark, contrast, slides, plain)")
+    toc := flag.String("toc", "left", "table of contents position: left, right or none")
+    tocMin := flag.Int("toc-min-level", 1, "shallowest heading `level` listed in the table of contents")
+    tocMax := flag.Int("toc-max-level", 6, "deepest heading `level` listed in the table of contents")
+    numberSections := flag.Bool("number-sections", false, "number headings 1., 1.1, 1.2.3 in pages and the table of contents")
+    cacheSize := flag.Int("cache", 256, "number of rendered pages to keep in memory (0 disables)")
+    cacheRedis := flag.String("cache-redis", "", "share rendered pages and the search index with other replicas through Redis at `url`, redis://[:password@]host:port/db")
+    cacheTTL := flag.Duration("cache-ttl", 24*time.Hour, "how long -cache-redis keeps an entry, 0 until Redis evicts it")
+    templateDir := flag.String("templates", "", "`dir` with view.html, index.html or edit.html overriding the built-in templates")
+    stats := flag.Bool("stats", false, "count page views, listed at /stats (persisted with -state)")
+    drafts := flag.Bool("drafts", false, "show documents marked draft: true in indexes, search and views")
+    previewToken := flag.String("preview-token", "", "`token` that shows drafts to requests with ?preview=token")
+    smartPunctuation := flag.Bool("smart-punctuation", false, "render curly quotes, en and em dashes and fractions; frontmatter smart_punctuation overrides")
+    hardWraps := flag.Bool("hard-wraps", false, "render single newlines as line breaks, as GitLab and Obsidian do; frontmatter hard_wraps overrides")
+    commonMark := flag.Bool("commonmark", false, "render strict CommonMark: no tables, footnotes, shortcodes, alerts or other extensions")
+    debug := flag.Bool("debug", false, "underline links to missing files and headings in pages")
+    var ignore listFlag
+    flag.Var(&ignore, "ignore", "leave files or directories matching `pattern` out of indexes and search (repeatable; !name keeps a default such as node_modules)")
+    gitIgnore := flag.Bool("gitignore", false, "also hide what .gitignore files exclude, as .mdserveignore files always do")
+    maxDepth := flag.Int("max-depth", 20, "directory `levels` listed below each root, 0 for no limit")
+    maxFiles := flag.Int("max-files", 100000, "`documents` listed per root, 0 for no limit")
+    streamThreshold := flag.Int64("stream-threshold", 1<<20, "stream markdown files larger than this many `bytes` as they render, 0 never")
+    maxFileSize := flag.Int64("max-file-size", 50<<20, "documents larger than this many `bytes` get a download link instead of being rendered, 0 for no limit")
+    renderWorkers := flag.Int("render-workers", runtime.NumCPU(), "documents rendered at once; others queue, 0 for no limit")
+    renderTimeout := flag.Duration("render-timeout", 30*time.Second, "how long a request waits to render before getting a 503")
+    accessLog := flag.String("access-log", "", "append a line per request to `file`")
+    accessLogFormat := flag.String("access-log-format", "combined", "access log format: combined or json")
+    accessLogMaxSize := flag.Int64("access-log-max-size", 100<<20, "rotate the access log at this many `bytes`, 0 never")
+    accessLogMaxAge := flag.Duration("access-log-max-age", 24*time.Hour, "rotate the access log once it is this old, 0 never")
+    accessLogKeep := flag.Int("access-log-keep", 7, "rotated access logs kept, 0 for all")
+    daemon := flag.Bool("daemon", false, "detach and run in the background (not on Windows; see mdserve service)")
+    pidFile := flag.String("pidfile", "", "write the server's process ID to `file`, removed on exit")
+    logFile := flag.String("log-file", "", "append log messages to `file` instead of standard error; reopened on SIGHUP")
+    dev := flag.Bool("dev", false, "development mode: re-read -templates on every request, cache nothing and log each request")
+    openURL := flag.Bool("open", false, "open the server in the default browser once it is listening")
+    caseInsensitive := flag.Bool("case-insensitive", false, "resolve paths like /Readme.MD to the one file matching them regardless of case")
+    lang := flag.String("lang", "", "language of the page chrome: en, de, fr or es (default from the browser's Accept-Language)")
+    stateFile := flag.String("state", "", "bolt database `file` for server-side state (default in memory)")
+    lite := flag.Bool("lite", false, "minimal mode for tiny devices: no caches, indexing, watchers or scripts")
+    tables := flag.Bool("interactive-tables", false, "make every table sortable and filterable")
+    plantumlServer := flag.String("plantuml-server", "", "PlantUML server `url` for ```plantuml blocks")
+    plantumlCommand := flag.String("plantuml-command", "", "local PlantUML `command` for ```plantuml blocks, e.g. \"java -jar plantuml.jar\"")
+    favicon := flag.String("favicon", "", "icon `file` to serve at /favicon.ico instead of the built-in one")
+    castPlayer := flag.String("cast-player", "", "`directory` with asciinema-player.min.js and .css to serve instead of loading them from jsDelivr")
+    robots := flag.String("robots", "", "`file` to serve as /robots.txt instead of the generated one")
+    flag.Parse()
+
+    if *toc != "left" && *toc != "right" && *toc != "none" {
+        log.Fatalf("Unknown TOC position %q", *toc)
+    }
+    if *tocMin < 1 || *tocMax > 6 || *tocMin > *tocMax {
+        log.Fatalf("TOC levels must satisfy 1 <= -toc-min-level <= -toc-max-level <= 6")
+    }
+    if *logFile != "" {
+        if err := openLogFile(*logFile); err != nil {
+            log.Fatalf("Failed to open log file: %v", err)
+        }
+    }
+    if *daemon {
+        if err := daemonize(*logFile); err != nil {
+            log.Fatalf("Failed to start in the background: %v", err)
+        }
+        return
+    }
+
+    // mdserve README.md [port] previews just that document at /, and
+    // mdserve - what is piped in
+    args := flag.Args()
+    if len(args) > 0 && args[0] == "-" {
+        file, err := pipeStdin()
+        if err != nil {
+            log.Fatalf("Failed to read standard input: %v", err)
+        }
+        args[0] = file
+    }
+    if len(args) > 0 {
+        if info, err := os.Stat(args[0]); err == nil && info.Mode().IsRegular() {
+            mounts = append(mountFlag{{Prefix: "/", Root: args[0]}}, mounts...)
+            args = args[1:]
+        }
+    }
+
+    // The configuration from the flags, the config file and the password
+    // files, read again on SIGHUP
+    readConfig := func() (mdserve.Config, []tenantConfig, error) {
+        cfg := mdserve.Config{Mounts: mounts, TOCPosition: *toc, TOCMinLevel: *tocMin, TOCMaxLevel: *tocMax, NumberSections: *numberSections, Stats: *stats, Drafts: *drafts, PreviewToken: *previewToken, CaseInsensitive: *caseInsensitive, SmartPunctuation: *smartPunctuation, HardWraps: *hardWraps, CommonMark: *commonMark, Debug: *debug, Ignore: ignore, GitIgnore: *gitIgnore, MaxDepth: *maxDepth, MaxFiles: *maxFiles, StreamThreshold: *streamThreshold, MaxFileSize: *maxFileSize, RenderWorkers: *renderWorkers, RenderTimeout: *renderTimeout, Dev: *dev, Language: *lang, CacheSize: *cacheSize, CacheTTL: *cacheTTL, TemplateDir: *templateDir, Lite: *lite, InteractiveTables: *tables}
+        var tenants []tenantConfig
+        if *configFile != "" {
+            var err error
+            if tenants, err = loadConfig(*configFile, &cfg); err != nil {
+                return cfg, nil, fmt.Errorf("failed to load config: %v", err)
+            }
+        }
+        if *theme != "" {
+            cfg.Theme = *theme
+        }
+        if *favicon != "" {
+            cfg.Favicon = *favicon
+        }
+        if *robots != "" {
+            cfg.Robots = *robots
+        }
+        if *castPlayer != "" {
+            cfg.CastPlayer = *castPlayer
+        }
+        if *plantumlServer != "" {
+            cfg.PlantUML.Server = *plantumlServer
+        }
+        if *plantumlCommand != "" {
+            cfg.PlantUML.Command = strings.Fields(*plantumlCommand)
+        }
+        if _, ok := mdserve.Messages[cfg.Language]; cfg.Language != "" && !ok {
+            return cfg, nil, fmt.Errorf("unknown language %q", cfg.Language)
+        }
+        if _, ok := mdserve.Themes[cfg.Theme]; cfg.Theme != "" && !ok {
+            return cfg, nil, fmt.Errorf("unknown theme %q", cfg.Theme)
+        }
+        if cfg.Favicon != "" {
+            if _, err := os.Stat(cfg.Favicon); err != nil {
+                return cfg, nil, fmt.Errorf("favicon: %v", err)
+            }
+        }
+        if cfg.CastPlayer != "" {
+            if _, err := os.Stat(filepath.Join(cfg.CastPlayer, "asciinema-player.min.js")); err != nil {
+                return cfg, nil, fmt.Errorf("cast player: %v", err)
+            }
+        }
+
+        // Read password from file
+        var err error
+        cfg.Password, err = readPasswordFromFile(".secret.key")
+        if err != nil {
+            return cfg, nil, fmt.Errorf("failed to read password: %v", err)
+        }
+        return cfg, tenants, nil
+    }
+    cfg, tenants, err := readConfig()
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    var store mdserve.Store
+    if *stateFile != "" {
+        if store, err = mdserve.OpenBoltStore(*stateFile); err != nil {
+            log.Fatalf("Failed to open state: %v", err)
+        }
+    }
+    if *cacheRedis != "" {
+        if cacheBackend, err = mdserve.OpenRedisCache(*cacheRedis); err != nil {
+            log.Fatalf("Failed to open cache: %v", err)
+        }
+    }
+    if *accessLog != "" {
+        if accessLogFile, err = mdserve.OpenAccessLog(*accessLog, mdserve.AccessLogOptions{Format: *accessLogFormat, MaxSize: *accessLogMaxSize, MaxAge: *accessLogMaxAge, Keep: *accessLogKeep}); err != nil {
+            log.Fatalf("Failed to open access log: %v", err)
+        }
+    }
+
+    // Build the main server for cfg and one per tenant, all sharing the
+    // state store, access log and page cache. Reloading them, on SIGHUP
+    // or POST /admin/reload, replaces them with ones built from the
+    // config read again.
+    var reload func() error
+    start := func(cfg mdserve.Config, tenants []tenantConfig) (*deployment, error) {
+        configs := []mdserve.Config{cfg}
+        for _, t := range tenants {
+            configs = append(configs, tenantServerConfig(cfg, t))
+        }
+        var shared *mdserve.SharedCache
+        if len(tenants) > 0 && cfg.CacheSize > 0 {
+            shared = mdserve.NewSharedCache(cfg.CacheSize)
+        }
+        d := &deployment{}
+        var routes []mdserve.Tenant
+        for i, c := range configs {
+            c.Store, c.SharedCache, c.CacheBackend = store, shared, cacheBackend
+            srv := mdserve.New(mdserve.WithConfig(c))
+            if accessLogFile != nil {
+                srv.Use(accessLogFile.Middleware)
+            }
+            srv.OnReload(func() error { return reload() })
+
+            // Decrypt GPG files at startup, and again only for roots a
+            // reload adds. Servers a reload replaced may still have saved
+            // documents, so all of them are cleaned up.
+            decryptors = append(decryptors, srv)
+            fresh := false
+            for _, root := range srv.Roots() {
+                fresh = fresh || !decryptedRoots[root]
+            }
+            if fresh {
+                if err := srv.DecryptAll(); err != nil {
+                    return nil, fmt.Errorf("failed to decrypt files: %v", err)
+                }
+                for _, root := range srv.Roots() {
+                    decryptedRoots[root] = true
+                }
+            }
+            if store == nil {
+                store = srv.Store() // The in-memory store, kept across reloads
+            }
+            d.servers = append(d.servers, srv)
+            if i > 0 {
+                routes = append(routes, mdserve.Tenant{Host: tenants[i-1].Host, Prefix: tenants[i-1].Prefix, Server: srv})
+            }
+        }
+        d.handler = d.servers[0]
+        if len(routes) > 0 {
+            d.handler = mdserve.NewTenants(d.servers[0], routes...)
+        }
+        return d, nil
+    }
+    d, err := start(cfg, tenants)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if !*lite {
+        d.watch()
+    }
+
+    // One reload at a time; the deployment it replaces stops watching
+    // once requests go to the new one
+    reload = func() error {
+        reloadMu.Lock()
+        defer reloadMu.Unlock()
+        cfg, tenants, err := readConfig()
+        if err != nil {
+            return err
+        }
+        sdNotify("RELOADING=1")
+        defer sdNotify("READY=1")
+        next, err := start(cfg, tenants)
+        if err != nil {
+            return err
+        }
+        if !*lite {
+            next.watch()
+        }
+        current.Swap(next).stopWatching()
+        log.Printf("Reloaded configuration")
+        return nil
+    }
+
+    // Handle graceful exit for cleanup, and SIGHUP to reload
+    current.Store(d)
+    handleSignals(func() {
+        if err := current.Load().servers[0].Reload(); err != nil {
+            log.Printf("Reload failed, keeping the running configuration: %v", err)
+        }
+    })
+
+    port := "8080"
+    if len(args) > 0 {
+        port = args[0]
+    }
+
+    for _, srv := range d.servers {
+        for _, root := range srv.Roots() {
+            log.Printf("Serving %s", root)
+        }
+    }
+    // Under systemd socket activation the socket is passed in, so
+    // connections queue there while the server restarts
+    ln, err := systemdListener()
+    if err == nil && ln == nil {
+        ln, err = net.Listen("tcp", ":"+port)
+    }
+    if err != nil {
+        log.Fatal(err)
+    }
+    if addr, ok := ln.Addr().(*net.TCPAddr); ok {
+        port = strconv.Itoa(addr.Port)
+    }
+    url := "http://localhost:" + port
+    fmt.Printf("Serving on %s\n", url)
+    if *openURL {
+        if err := openBrowser(url); err != nil {
+            log.Printf("Could not open a browser: %v", err)
+        }
+    }
+    if *pidFile != "" {
+        if err := ioutil.WriteFile(*pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+            log.Fatalf("Failed to write pid file: %v", err)
+        }
+        pidFileName = *pidFile
+    }
+    go func() {
+        for i, w := range d.watchers {
+            if w != nil {
+                <-d.servers[i].Scanned()
+            }
+        }
+        if err := sdNotify("READY=1"); err != nil {
+            log.Printf("Could not notify systemd: %v", err)
+        }
+    }()
+    log.Fatal(http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        current.Load().handler.ServeHTTP(w, r)
+    })))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/awkto/mdserve"
+)
+
+// mdserve report [-format csv|json] [-state file] [-drafts=false] [-mount /prefix=/dir ...] [dir]
+func runReport(args []string) error {
+    fs := flag.NewFlagSet("report", flag.ExitOnError)
+    format := fs.String("format", "csv", "output format: csv or json")
+    drafts := fs.Bool("drafts", true, "include documents marked draft: true")
+    stateFile := fs.String("state", "", "the server's -state `file`, for view counts")
+    var mounts mountFlag
+    fs.Var(&mounts, "mount", "report on `/prefix=/dir` (repeatable)")
+    fs.Parse(args)
+
+    if len(mounts) == 0 {
+        root := "."
+        if fs.NArg() > 0 {
+            root = fs.Arg(0)
+        }
+        mounts = mountFlag{{Prefix: "/", Root: root}}
+    }
+    opts := []mdserve.Option{mdserve.WithMounts(mounts...)}
+    if *drafts {
+        opts = append(opts, mdserve.WithDrafts())
+    }
+    if *stateFile != "" {
+        store, err := mdserve.OpenBoltStore(*stateFile)
+        if err != nil {
+            return err
+        }
+        defer store.Close()
+        opts = append(opts, mdserve.WithStore(store))
+    }
+    docs, err := mdserve.New(opts...).Report()
+    if err != nil {
+        return err
+    }
+
+    switch *format {
+    case "json":
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        return enc.Encode(docs)
+    case "csv":
+        w := csv.NewWriter(os.Stdout)
+        w.Write([]string{"path", "title", "tags", "owner", "words", "mtime", "views", "broken_links"})
+        for _, d := range docs {
+            w.Write([]string{
+                d.Path,
+                d.Title,
+                strings.Join(d.Tags, ";"),
+                d.Owner,
+                strconv.Itoa(d.Words),
+                d.ModTime.Format(time.RFC3339),
+                strconv.Itoa(d.Views),
+                strings.Join(d.BrokenLinks, ";"),
+            })
+        }
+        w.Flush()
+        return w.Error()
+    default:
+        return fmt.Errorf("unknown format %q", *format)
+    }
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+    "fmt"
+    "os"
+)
+
+const serviceName = "mdserve"
+
+// mdserve service install|uninstall|start|stop [server flags...]
+//
+// install registers the current directory and the given server flags with
+// the platform's service manager (Windows services, launchd on macOS) so
+// the server starts automatically.
+func runService(args []string) error {
+    if len(args) == 0 {
+        return fmt.Errorf("usage: mdserve service install|uninstall|start|stop [server flags]")
+    }
+    switch args[0] {
+    case "install":
+        exe, err := os.Executable()
+        if err != nil {
+            return err
+        }
+        dir, err := os.Getwd()
+        if err != nil {
+            return err
+        }
+        return installService(exe, dir, args[1:])
+    case "uninstall":
+        return uninstallService()
+    case "start":
+        return startService()
+    case "stop":
+        return stopService()
+    case "run":
+        // Entry point used by the service manager, not meant for users
+        return runServiceHost(args[1:])
+    default:
+        return fmt.Errorf("unknown service action %q", args[0])
+    }
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+const launchdLabel = "com.github.awkto.mdserve"
+
+// Where the launchd agent definition lives
+func plistPath() (string, error) {
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return "", err
+    }
+    return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// Escape a value for the plist XML
+func plistString(s string) string {
+    var b strings.Builder
+    xml.EscapeText(&b, []byte(s))
+    return "<string>" + b.String() + "</string>"
+}
+
+// Write a launchd agent that starts at login and keeps the server running
+func installService(exe, dir string, args []string) error {
+    file, err := plistPath()
+    if err != nil {
+        return err
+    }
+    home, _ := os.UserHomeDir()
+    logFile := filepath.Join(home, "Library", "Logs", "mdserve.log")
+
+    var program []string
+    for _, arg := range append([]string{exe}, args...) {
+        program = append(program, "        "+plistString(arg))
+    }
+    plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    ` + plistString(launchdLabel) + `
+    <key>ProgramArguments</key>
+    <array>
+` + strings.Join(program, "\n") + `
+    </array>
+    <key>WorkingDirectory</key>
+    ` + plistString(dir) + `
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <true/>
+    <key>StandardOutPath</key>
+    ` + plistString(logFile) + `
+    <key>StandardErrorPath</key>
+    ` + plistString(logFile) + `
+</dict>
+</plist>
+`
+    if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+        return err
+    }
+    if err := ioutil.WriteFile(file, []byte(plist), 0644); err != nil {
+        return fmt.Errorf("could not write %s: %v", file, err)
+    }
+    if err := launchctl("load", "-w", file); err != nil {
+        return err
+    }
+    log.Printf("Installed launchd agent %s, logging to %s", file, logFile)
+    return nil
+}
+
+func uninstallService() error {
+    file, err := plistPath()
+    if err != nil {
+        return err
+    }
+    if err := launchctl("unload", "-w", file); err != nil {
+        log.Printf("launchctl unload: %v", err)
+    }
+    return os.Remove(file)
+}
+
+func startService() error {
+    return launchctl("start", launchdLabel)
+}
+
+func stopService() error {
+    return launchctl("stop", launchdLabel)
+}
+
+func runServiceHost(args []string) error {
+    return fmt.Errorf("launchd runs the server directly; use mdserve service install")
+}
+
+func launchctl(args ...string) error {
+    out, err := exec.Command("launchctl", args...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("launchctl %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
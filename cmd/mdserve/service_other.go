@@ -0,0 +1,30 @@
+//go:build !windows && !darwin
+
+package main
+
+import (
+    "fmt"
+    "runtime"
+)
+
+var errNoServiceManager = fmt.Errorf("service management is not supported on %s; use a systemd unit instead", runtime.GOOS)
+
+func installService(exe, dir string, args []string) error {
+    return errNoServiceManager
+}
+
+func uninstallService() error {
+    return errNoServiceManager
+}
+
+func startService() error {
+    return errNoServiceManager
+}
+
+func stopService() error {
+    return errNoServiceManager
+}
+
+func runServiceHost(args []string) error {
+    return errNoServiceManager
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "time"
+    "golang.org/x/sys/windows/svc"
+    "golang.org/x/sys/windows/svc/mgr"
+)
+
+// Register the server as an automatically started Windows service. The
+// service manager runs "mdserve service run <dir> <flags...>".
+func installService(exe, dir string, args []string) error {
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("could not connect to the service manager: %v", err)
+    }
+    defer m.Disconnect()
+
+    if s, err := m.OpenService(serviceName); err == nil {
+        s.Close()
+        return fmt.Errorf("service %s already exists", serviceName)
+    }
+    s, err := m.CreateService(serviceName, exe, mgr.Config{
+        DisplayName: "mdserve markdown server",
+        Description: "Serves markdown files from " + dir,
+        StartType:   mgr.StartAutomatic,
+    }, append([]string{"service", "run", dir}, args...)...)
+    if err != nil {
+        return fmt.Errorf("could not create service: %v", err)
+    }
+    defer s.Close()
+    log.Printf("Installed service %s serving %s", serviceName, dir)
+    return nil
+}
+
+func uninstallService() error {
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("could not connect to the service manager: %v", err)
+    }
+    defer m.Disconnect()
+    s, err := m.OpenService(serviceName)
+    if err != nil {
+        return fmt.Errorf("service %s is not installed", serviceName)
+    }
+    defer s.Close()
+    return s.Delete()
+}
+
+func startService() error {
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("could not connect to the service manager: %v", err)
+    }
+    defer m.Disconnect()
+    s, err := m.OpenService(serviceName)
+    if err != nil {
+        return fmt.Errorf("service %s is not installed", serviceName)
+    }
+    defer s.Close()
+    return s.Start()
+}
+
+func stopService() error {
+    m, err := mgr.Connect()
+    if err != nil {
+        return fmt.Errorf("could not connect to the service manager: %v", err)
+    }
+    defer m.Disconnect()
+    s, err := m.OpenService(serviceName)
+    if err != nil {
+        return fmt.Errorf("service %s is not installed", serviceName)
+    }
+    defer s.Close()
+    _, err = s.Control(svc.Stop)
+    return err
+}
+
+// Run under the service manager: serve from dir until asked to stop
+func runServiceHost(args []string) error {
+    if len(args) == 0 {
+        return fmt.Errorf("missing working directory")
+    }
+    if err := os.Chdir(args[0]); err != nil {
+        return err
+    }
+    os.Args = append([]string{os.Args[0]}, args[1:]...)
+    return svc.Run(serviceName, serviceHandler{})
+}
+
+type serviceHandler struct{}
+
+func (serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+    status <- svc.Status{State: svc.StartPending}
+    go serverMain()
+    status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+    for req := range requests {
+        switch req.Cmd {
+        case svc.Interrogate:
+            status <- req.CurrentStatus
+        case svc.Stop, svc.Shutdown:
+            status <- svc.Status{State: svc.StopPending, WaitHint: uint32(10 * time.Second / time.Millisecond)}
+            cleanup()
+            return false, 0
+        }
+    }
+    return false, 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "net"
+    "os"
+    "strconv"
+)
+
+// The listening socket systemd passed with socket activation, nil when
+// started otherwise. Passed sockets start at file descriptor 3.
+func systemdListener() (net.Listener, error) {
+    if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+        return nil, nil
+    }
+    if n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS")); n < 1 {
+        return nil, nil
+    }
+    os.Unsetenv("LISTEN_PID")
+    os.Unsetenv("LISTEN_FDS")
+    os.Unsetenv("LISTEN_FDNAMES")
+    f := os.NewFile(3, "systemd-socket")
+    defer f.Close()
+    return net.FileListener(f)
+}
+
+// Tell systemd about the server's state, such as READY=1, when it runs
+// the server as a Type=notify service
+func sdNotify(state string) error {
+    socket := os.Getenv("NOTIFY_SOCKET")
+    if socket == "" {
+        return nil
+    }
+    if socket[0] == '@' {
+        socket = "\x00" + socket[1:] // Abstract namespace
+    }
+    conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+    _, err = conn.Write([]byte(state))
+    return err
+}
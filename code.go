@@ -0,0 +1,135 @@
+package mdserve
+
+import (
+    "bytes"
+    "encoding/json"
+    "html/template"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "strings"
+    "unicode/utf8"
+)
+
+const maxCodeView = 1 << 20 // Larger files are served raw
+
+// One highlighted line of the code viewer, with the lines nested under it
+// when the file's structure folds
+type codeLine struct {
+    Number   int
+    HTML     template.HTML
+    Children []*codeLine
+}
+
+// Nest the lines of a file so the template can make them collapsible: by
+// indentation (YAML, JSON), under [section] headers (TOML, INI), or under
+// the file and hunk headers of a diff
+func foldLines(lines []*codeLine, texts []string, mode string) []*codeLine {
+    type open struct {
+        indent int
+        line   *codeLine
+    }
+    var root []*codeLine
+    var stack []open
+    var blanks []*codeLine // Blank lines go with the line that follows them
+    for i, l := range lines {
+        text := texts[i]
+        trimmed := strings.TrimLeft(text, " \t")
+        if trimmed == "" {
+            blanks = append(blanks, l)
+            continue
+        }
+        indent := len(text) - len(trimmed)
+        switch mode {
+        case "sections":
+            indent = 1
+            if strings.HasPrefix(text, "[") {
+                indent = 0
+            }
+        case "indent":
+            // YAML list items belong to the key above at the same indent
+            if strings.HasPrefix(trimmed, "- ") {
+                indent++
+            }
+        case "diff":
+            indent = 2
+            if strings.HasPrefix(text, "diff ") {
+                indent = 0
+            } else if strings.HasPrefix(text, "@@") {
+                indent = 1
+            }
+        }
+        for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+            stack = stack[:len(stack)-1]
+        }
+        if len(stack) > 0 {
+            top := stack[len(stack)-1].line
+            top.Children = append(append(top.Children, blanks...), l)
+        } else {
+            root = append(append(root, blanks...), l)
+        }
+        blanks = nil
+        stack = append(stack, open{indent, l})
+    }
+    root = append(root, blanks...)
+    return root
+}
+
+// Show a source or data file highlighted with line numbers, data files
+// with collapsible nodes; ?raw serves it as-is.
+// Returns false when the file is not viewable source code.
+func (s *Server) codeHandler(w http.ResponseWriter, r *http.Request, st *site, file, urlFile string) bool {
+    lang := languageFor(file)
+    if lang == nil || r.URL.Query().Has("raw") {
+        return false
+    }
+    info, err := os.Stat(file)
+    if err != nil || info.IsDir() || info.Size() > maxCodeView {
+        return false
+    }
+    content, err := ioutil.ReadFile(file)
+    if err != nil || !utf8.Valid(content) {
+        return false
+    }
+
+    text := string(content)
+    if lang.Name == "JSON" && strings.Count(strings.TrimSpace(text), "\n") == 0 {
+        // Spread minified JSON out so it can be folded
+        var pretty bytes.Buffer
+        if json.Indent(&pretty, content, "", "  ") == nil {
+            text = pretty.String()
+        }
+    }
+    var lines []*codeLine
+    for i, l := range highlight(text, lang) {
+        lines = append(lines, &codeLine{Number: i + 1, HTML: l})
+    }
+    count := len(lines)
+    if lang.Fold != "" {
+        lines = foldLines(lines, strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), lang.Fold)
+    }
+    theme := s.themeFor(st, nil)
+    data := struct {
+        Base     string
+        File     string
+        Language string
+        Theme    string
+        BaseCSS  template.CSS
+        ThemeCSS template.CSS
+        Lines    []*codeLine
+        Count    int
+        Data     map[string]interface{}
+    }{
+        Base:     s.basePath,
+        File:     urlFile,
+        Language: lang.Name,
+        Theme:    theme,
+        BaseCSS:  template.CSS(baseCSS),
+        ThemeCSS: template.CSS(Themes[theme]),
+        Lines:    lines,
+        Count:    count,
+        Data:     s.pageData(r, file),
+    }
+    s.executeTemplate(w, r, "code.html", data)
+    return true
+}
@@ -0,0 +1,169 @@
+package mdserve
+
+import (
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestCodeViewer(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "main.go":    "package main\n\n// Say hi\nfunc main() { println(\"<hi>\", 42) }\n",
+        "binary.txt": "\xff\xfe",
+        "image.xyz":  "unknown",
+    })
+    tests := []struct {
+        name        string
+        target      string
+        contentType string
+        want        []string
+    }{
+        {"highlighted", "/main.go", "text/html; charset=utf-8", []string{
+            "Go &middot; 4 lines",
+            `<span class="line" id="L3"><a class="line-number" href="#L3">3</a><code><span class="tok-c">// Say hi</span></code></span>`,
+            `<span class="tok-k">func</span> main() { println(<span class="tok-s">&#34;&lt;hi&gt;&#34;</span>, <span class="tok-n">42</span>) }`,
+            `href="/main.go?raw"`,
+        }},
+        {"raw", "/main.go?raw", "text/x-go; charset=utf-8", []string{"println(\"<hi>\", 42)"}},
+        {"not UTF-8", "/binary.txt", "text/plain; charset=utf-8", []string{"\xff\xfe"}},
+        {"unknown language", "/image.xyz", "", []string{"unknown"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := doRequest(s, "GET", tt.target, nil, true)
+            if ct := w.Header().Get("Content-Type"); tt.contentType != "" && ct != tt.contentType {
+                t.Errorf("got Content-Type %q, want %q", ct, tt.contentType)
+            }
+            for _, want := range tt.want {
+                if !strings.Contains(w.Body.String(), want) {
+                    t.Errorf("%q missing from %q", want, w.Body.String())
+                }
+            }
+        })
+    }
+}
+
+func TestHighlight(t *testing.T) {
+    tests := []struct {
+        file string
+        src  string
+        want []string // Per line
+    }{
+        {"a.py", "x = 'a#b'  # note\n", []string{`x = <span class="tok-s">&#39;a#b&#39;</span>  <span class="tok-c"># note</span>`}},
+        {"a.go", "/* one\ntwo */ x := `a\nb`", []string{`<span class="tok-c">/* one</span>`, `<span class="tok-c">two */</span> x := <span class="tok-s">`+"`a</span>", `<span class="tok-s">b`+"`</span>"}},
+        {"a.sh", "echo ${x#y}", []string{`<span class="tok-k">echo</span> ${x#y}`}},
+        {"Makefile", "ifeq (a,b)", []string{`<span class="tok-k">ifeq</span> (a,b)`}},
+        {"a.txt", "if x", []string{"if x"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.file, func(t *testing.T) {
+            lang := languageFor(tt.file)
+            if lang == nil {
+                t.Fatal("no language")
+            }
+            var got []string
+            for _, l := range highlight(tt.src, lang) {
+                got = append(got, string(l))
+            }
+            if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+    if languageFor("notes.md") != nil {
+        t.Error("markdown has a code language")
+    }
+}
+
+// The nesting of folded lines, as line numbers with their children in
+// parentheses
+func foldShape(lines []*codeLine) string {
+    var parts []string
+    for _, l := range lines {
+        part := strconv.Itoa(l.Number)
+        if len(l.Children) > 0 {
+            part += "(" + foldShape(l.Children) + ")"
+        }
+        parts = append(parts, part)
+    }
+    return strings.Join(parts, " ")
+}
+
+func TestFoldLines(t *testing.T) {
+    tests := []struct {
+        name string
+        mode string
+        src  string
+        want string
+    }{
+        {"json", "indent", "{\n  \"a\": {\n    \"b\": 1\n  },\n  \"c\": 2\n}", "1(2(3) 4 5) 6"},
+        {"yaml", "indent", "a:\n  b: 1\nlist:\n- x\n- y\n\nc: 3", "1(2) 3(4 5) 6 7"},
+        {"toml", "sections", "top = 1\n[server]\nport = 80\n[db]\nhost = \"x\"", "1 2(3) 4(5)"},
+        {"ini", "sections", "[a]\nx=1\n\ny=2", "1(2 3 4)"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            texts := strings.Split(tt.src, "\n")
+            var lines []*codeLine
+            for i := range texts {
+                lines = append(lines, &codeLine{Number: i + 1})
+            }
+            if got := foldShape(foldLines(lines, texts, tt.mode)); got != tt.want {
+                t.Errorf("got %s, want %s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestDataViewers(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "min.json":    `{"a":{"b":[1,true]}}`,
+        "config.yaml": "name: x\nport: 80\n",
+        "config.toml": "[server]\nport = 80\n",
+    })
+    tests := []struct {
+        target string
+        want   []string
+    }{
+        {"/min.json", []string{"JSON &middot; 8 lines", `<details open><summary><span class="line" id="L1">`, `<span class="tok-a">&#34;b&#34;</span>: [`, `<span class="tok-k">true</span>`}},
+        {"/config.yaml", []string{"YAML &middot; 2 lines", `<span class="tok-a">name</span>: x`}},
+        {"/config.toml", []string{"TOML &middot; 2 lines", `<details open><summary><span class="line" id="L1"><a class="line-number" href="#L1">1</a><code>[server]</code></span></summary>`, `<span class="tok-a">port</span> = <span class="tok-n">80</span>`}},
+        {"/min.json?raw", []string{`{"a":{"b":[1,true]}}`}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.target, func(t *testing.T) {
+            body := doRequest(s, "GET", tt.target, nil, true).Body.String()
+            for _, want := range tt.want {
+                if !strings.Contains(body, want) {
+                    t.Errorf("%q missing from %q", want, body)
+                }
+            }
+        })
+    }
+}
+
+func TestDiffViewer(t *testing.T) {
+    diff := "diff --git a/f b/f\nindex 1..2\n--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n-old\n+new\n same\n@@ -9 +9 @@\n-x\ndiff --git a/g b/g\n@@ -1 +1 @@\n+y\n"
+    texts := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+    var lines []*codeLine
+    for i := range texts {
+        lines = append(lines, &codeLine{Number: i + 1})
+    }
+    if got, want := foldShape(foldLines(lines, texts, "diff")), "1(2 3 4 5(6 7 8) 9(10)) 11(12(13))"; got != want {
+        t.Errorf("got %s, want %s", got, want)
+    }
+
+    for line, want := range map[string]string{"diff --git a/f b/f": "meta", "--- a/f": "meta", "+++ b/f": "meta", "@@ -1 +1 @@": "hunk", "+new": "ins", "-old": "del", " same": ""} {
+        if got := diffLineClass(line); got != want {
+            t.Errorf("%q: got %q, want %q", line, got, want)
+        }
+    }
+
+    s, _ := newTestServer(t, map[string]string{"fix.patch": diff})
+    body := doRequest(s, "GET", "/fix.patch", nil, true).Body.String()
+    for _, want := range []string{"Diff &middot;", `<span class="tok-hunk">@@ -1,2 +1,2 @@</span>`, `<span class="tok-del">-old</span>`, `<span class="tok-ins">+new</span>`, "<code> same</code>"} {
+        if !strings.Contains(body, want) {
+            t.Errorf("%q missing from %q", want, body)
+        }
+    }
+}
@@ -0,0 +1,112 @@
+package mdserve
+
+import (
+    "fmt"
+    "html"
+    "strconv"
+    "strings"
+)
+
+// Attributes after the language of a fence info string, written as
+// title="config.yaml" or {linenos=true, hl_lines="3-5"}; list values like
+// hl_lines=[1, "3-5"] come back without their brackets
+func fenceAttrs(info string) map[string]string {
+    attrs := map[string]string{}
+    s := info
+    for {
+        s = strings.TrimLeft(s, " \t{},")
+        eq := strings.IndexByte(s, '=')
+        if eq <= 0 {
+            return attrs
+        }
+        key := strings.TrimSpace(s[:eq])
+        if i := strings.LastIndexAny(key, " \t{},"); i >= 0 {
+            key = key[i+1:] // Skip bare words before the key
+        }
+        s = s[eq+1:]
+        var val string
+        if s != "" && (s[0] == '"' || s[0] == '\'') {
+            end := strings.IndexByte(s[1:], s[0])
+            if end < 0 {
+                end = len(s) - 1
+            }
+            val, s = s[1:end+1], s[min(end+2, len(s)):]
+        } else if s != "" && s[0] == '[' {
+            end := strings.IndexByte(s, ']')
+            if end < 0 {
+                end = len(s) - 1
+            }
+            val, s = s[1:end], s[end+1:]
+        } else {
+            end := strings.IndexAny(s, " \t,}")
+            if end < 0 {
+                end = len(s)
+            }
+            val, s = s[:end], s[end:]
+        }
+        attrs[strings.ToLower(key)] = val
+    }
+}
+
+// Line numbers picked by hl_lines, e.g. "3-5 8", "3-5,8" or [8, "3-5"]
+func lineSet(spec string) map[int]bool {
+    set := map[int]bool{}
+    for _, part := range strings.FieldsFunc(spec, func(r rune) bool { return strings.ContainsRune(` ,"'`, r) }) {
+        lo, hi, isRange := strings.Cut(part, "-")
+        from, err := strconv.Atoi(lo)
+        if err != nil {
+            continue
+        }
+        to := from
+        if isRange {
+            if to, err = strconv.Atoi(hi); err != nil {
+                continue
+            }
+        }
+        for n := from; n <= to && n-from < 10000; n++ {
+            set[n] = true
+        }
+    }
+    return set
+}
+
+// A fenced code block with attributes, as the markdown renderer would
+// write it plus a title bar. With linenos or hl_lines each line becomes a
+// block, numbered by a CSS counter so copying the code leaves the numbers
+// behind.
+func renderCodeBlock(lang string, attrs map[string]string, body string) string {
+    linenos := attrs["linenos"] != "" && attrs["linenos"] != "false"
+    highlighted := lineSet(attrs["hl_lines"])
+
+    var b strings.Builder
+    if linenos {
+        start, err := strconv.Atoi(attrs["linenostart"])
+        if err != nil {
+            start = 1
+        }
+        fmt.Fprintf(&b, `<div class="code-block linenos" style="--linenostart: %d">`, start-1)
+    } else {
+        b.WriteString(`<div class="code-block">`)
+    }
+    if title := attrs["title"]; title != "" {
+        fmt.Fprintf(&b, `<div class="code-title">%s</div>`, html.EscapeString(title))
+    }
+    b.WriteString("<pre><code")
+    if lang != "" {
+        fmt.Fprintf(&b, ` class="language-%s"`, html.EscapeString(lang))
+    }
+    b.WriteString(">")
+    if !linenos && len(highlighted) == 0 {
+        b.WriteString(html.EscapeString(body))
+    } else {
+        for i, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+            class := "line"
+            if highlighted[i+1] {
+                class += " hl"
+            }
+            fmt.Fprintf(&b, `<span class="%s">%s</span>`, class, html.EscapeString(line))
+        }
+    }
+    b.WriteString("</code></pre></div>\n")
+    return b.String()
+}
@@ -0,0 +1,119 @@
+package mdserve
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestFenceAttrs(t *testing.T) {
+    tests := []struct {
+        info string
+        want map[string]string
+    }{
+        {"", map[string]string{}},
+        {` title="config.yaml"`, map[string]string{"title": "config.yaml"}},
+        {` title='a b' Lang=x`, map[string]string{"title": "a b", "lang": "x"}},
+        {` {title="main.go", linenos=true}`, map[string]string{"title": "main.go", "linenos": "true"}},
+        {` bare title="x"`, map[string]string{"title": "x"}},
+        {` hl_lines=[1, "3-5"]`, map[string]string{"hl_lines": `1, "3-5"`}},
+        {` {linenos=true,hl_lines="2"}`, map[string]string{"linenos": "true", "hl_lines": "2"}},
+        {` title="unclosed`, map[string]string{"title": "unclosed"}},
+    }
+    for _, tt := range tests {
+        if got := fenceAttrs(tt.info); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("fenceAttrs(%q) = %q, want %q", tt.info, got, tt.want)
+        }
+    }
+}
+
+func TestLineSet(t *testing.T) {
+    tests := []struct {
+        spec string
+        want []int
+    }{
+        {"", nil},
+        {"3", []int{3}},
+        {"3-5 8", []int{3, 4, 5, 8}},
+        {"3-5,8", []int{3, 4, 5, 8}},
+        {`8, "3-4"`, []int{3, 4, 8}},
+        {"x 2 4-y", []int{2}},
+    }
+    for _, tt := range tests {
+        want := map[int]bool{}
+        for _, n := range tt.want {
+            want[n] = true
+        }
+        if got := lineSet(tt.spec); !reflect.DeepEqual(got, want) {
+            t.Errorf("lineSet(%q) = %v, want %v", tt.spec, got, want)
+        }
+    }
+}
+
+func TestCodeBlockLines(t *testing.T) {
+    s := New()
+    tests := []struct {
+        name    string
+        src     string
+        want    []string
+        notWant []string
+    }{
+        {"line numbers", "```py {linenos=true}\na\nb\n```\n", []string{`<div class="code-block linenos" style="--linenostart: 0"><pre><code class="language-py"><span class="line">a</span><span class="line">b</span></code></pre></div>`}, nil},
+        {"numbers start later", "```py linenos=true linenostart=10\na\n```\n", []string{`style="--linenostart: 9"`}, nil},
+        {"highlighted lines", "```go hl_lines=\"2-3\"\na\nb\nc\nd\n```\n", []string{`<div class="code-block"><pre><code class="language-go"><span class="line">a</span><span class="line hl">b</span><span class="line hl">c</span><span class="line">d</span>`}, []string{"linenos"}},
+        {"both", "```go {linenos=true, hl_lines=[1]}\na\n```\n", []string{`code-block linenos`, `<span class="line hl">a</span>`}, nil},
+        {"line numbers off", "```go linenos=false\na\n```\n", []string{`<pre><code class="language-go">a` + "\n</code>"}, []string{"linenos", `class="line`}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+}
+
+func TestCodeBlockTitles(t *testing.T) {
+    s, _ := newTestServer(t, nil)
+    tests := []struct {
+        name    string
+        src     string
+        want    []string
+        notWant []string
+    }{
+        {"title", "```go title=\"main.go\"\nx := 1\n```\n", []string{`<div class="code-block"><div class="code-title">main.go</div><pre><code class="language-go">x := 1` + "\n</code></pre></div>"}, nil},
+        {"braces", "```go {title=\"a<b>.go\"}\nx\n```\n", []string{`<div class="code-title">a&lt;b&gt;.go</div>`}, []string{"<b>"}},
+        {"no language", "``` title=\"notes\"\ntext\n```\n", []string{`<div class="code-title">notes</div><pre><code>text`}, []string{"language-"}},
+        {"code escaped", "```html title=\"x\"\n<p>&amp;</p>\n```\n", []string{"&lt;p&gt;&amp;amp;&lt;/p&gt;"}, nil},
+        {"plain fence", "```go\nx := 1\n```\n", []string{`<pre><code class="language-go">x := 1`}, []string{"code-block", "code-title"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+
+    // The copy buttons are added in the browser
+    s, _ = newTestServer(t, map[string]string{"doc.md": "```\ncode\n```\n"})
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); !strings.Contains(page, "button.className = 'copy-code';") {
+        t.Error("no copy button script")
+    }
+}
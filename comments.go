@@ -0,0 +1,100 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Bucket of review comments, one JSON list per document
+const commentsBucket = "comments"
+
+// A review comment on a document, or on one of its sections
+type Comment struct {
+    ID      string
+    Heading string // Id of the heading commented on, "" for the whole document
+    Author  string
+    Text    string
+    Time    time.Time
+}
+
+// Comments need to know who wrote them and a tree that takes changes
+func (s *Server) commentsEnabled(st *site, m *Mount) bool {
+    return !st.Public && !m.ReadOnly && !s.lite
+}
+
+func (s *Server) comments(st *site, urlFile string) ([]Comment, error) {
+    value, err := s.store.Get(commentsBucket, viewsKey(st, urlFile))
+    if err != nil || value == nil {
+        return nil, err
+    }
+    var list []Comment
+    err = json.Unmarshal(value, &list)
+    return list, err
+}
+
+// Add (POST text and heading) or delete (POST delete=id) a comment on the
+// document at /comments/<path>, then return to the page
+func (s *Server) commentsHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    m, _, urlFile := s.resolveRequest(st, strings.TrimPrefix(r.URL.Path, "/comments"))
+    if m == nil {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    if !s.commentsEnabled(st, m) {
+        http.Error(w, "Comments are off for this tree", http.StatusForbidden)
+        return
+    }
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+    author, _, _ := r.BasicAuth()
+
+    s.commentsMu.Lock()
+    defer s.commentsMu.Unlock()
+    list, err := s.comments(st, urlFile)
+    if err != nil {
+        http.Error(w, "Could not read comments", http.StatusInternalServerError)
+        return
+    }
+    anchor := "#comments"
+    if id := r.FormValue("delete"); id != "" {
+        for i, c := range list {
+            if c.ID == id && c.Author == author {
+                list = append(list[:i], list[i+1:]...)
+                break
+            }
+        }
+    } else {
+        text := strings.TrimSpace(r.FormValue("text"))
+        if text == "" {
+            http.Error(w, "Empty comment", http.StatusBadRequest)
+            return
+        }
+        c := Comment{
+            ID:      strconv.FormatInt(time.Now().UnixNano(), 36),
+            Heading: r.FormValue("heading"),
+            Author:  author,
+            Text:    text,
+            Time:    time.Now(),
+        }
+        list = append(list, c)
+        anchor = "#comment-" + c.ID
+    }
+
+    value, err := json.Marshal(list)
+    if err == nil {
+        err = s.store.Put(commentsBucket, viewsKey(st, urlFile), value)
+    }
+    if err != nil {
+        log.Printf("Comments: %v", err)
+        http.Error(w, "Could not save comment", http.StatusInternalServerError)
+        return
+    }
+    http.Redirect(w, r, s.basePath+"/"+urlFile+anchor, http.StatusSeeOther)
+}
@@ -0,0 +1,81 @@
+package mdserve
+
+import (
+    "net/http"
+    "net/url"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+func TestComments(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{"doc.md": "# Doc\n\n## Setup\n\nText.\n"})
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); !strings.Contains(page, "<p>No comments yet.</p>") || !strings.Contains(page, `<option value="setup">Setup</option>`) {
+        t.Fatalf("no comment form in %q", page)
+    }
+
+    w := doRequest(s, "POST", "/comments/doc.md", url.Values{"text": {"  Needs <b>an example</b>.  "}, "heading": {"setup"}}, true)
+    if w.Code != http.StatusSeeOther {
+        t.Fatalf("got %d, want %d", w.Code, http.StatusSeeOther)
+    }
+    m := regexp.MustCompile(`^/doc\.md#comment-(\w+)$`).FindStringSubmatch(w.Header().Get("Location"))
+    if m == nil {
+        t.Fatalf("redirected to %q", w.Header().Get("Location"))
+    }
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    for _, want := range []string{
+        `<div class="comment" id="comment-` + m[1] + `">`,
+        `<strong>admin</strong> on `,
+        `&middot; about <a href="#setup">Setup</a></p>`,
+        `<p class="comment-text">Needs &lt;b&gt;an example&lt;/b&gt;.</p>`,
+        `<button name="delete" value="` + m[1] + `">Delete</button>`,
+    } {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing from %q", want, page)
+        }
+    }
+
+    tests := []struct {
+        name   string
+        method string
+        form   url.Values
+        auth   bool
+        status int
+    }{
+        {"empty", "POST", url.Values{"text": {"  "}}, true, http.StatusBadRequest},
+        {"not a post", "GET", nil, true, http.StatusMethodNotAllowed},
+        {"signed out", "POST", url.Values{"text": {"Hi"}}, false, http.StatusUnauthorized},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if w := doRequest(s, tt.method, "/comments/doc.md", tt.form, tt.auth); w.Code != tt.status {
+                t.Errorf("got %d, want %d", w.Code, tt.status)
+            }
+        })
+    }
+
+    if w := doRequest(s, "POST", "/comments/doc.md", url.Values{"delete": {m[1]}}, true); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/doc.md#comments" {
+        t.Errorf("delete: got %d to %q", w.Code, w.Header().Get("Location"))
+    }
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); strings.Contains(page, "an example") {
+        t.Error("comment not deleted")
+    }
+}
+
+func TestCommentsOff(t *testing.T) {
+    files := map[string]string{"doc.md": "# Doc\n"}
+    public, _ := newTestServer(t, files, WithPublic())
+    lite, _ := newTestServer(t, files, WithLite())
+    _, root := newTestServer(t, files)
+    readOnly := New(WithMounts(Mount{Prefix: "/", Root: root, ReadOnly: true}), WithAuth("admin", "pw"))
+    for name, s := range map[string]*Server{"public": public, "lite": lite, "read-only": readOnly} {
+        t.Run(name, func(t *testing.T) {
+            if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); strings.Contains(page, `id="comments"`) {
+                t.Errorf("comments shown in %q", page)
+            }
+            if w := doRequest(s, "POST", "/comments/doc.md", url.Values{"text": {"Hi"}}, true); w.Code != http.StatusForbidden {
+                t.Errorf("got %d, want %d", w.Code, http.StatusForbidden)
+            }
+        })
+    }
+}
@@ -0,0 +1,322 @@
+package mdserve
+
+import (
+    "html/template"
+    "time"
+)
+
+// Config holds everything a Server needs. Build one with functional
+// options passed to New, or fill it directly and pass WithConfig.
+type Config struct {
+    Mounts      []Mount // Served for requests not matching any host; default "/" = "."
+    Hosts       []Host
+    Theme       string   // Default page theme, see Themes
+    Username    string   // Basic auth username, default admin
+    Password    string   // Basic auth password and gpg passphrase
+    BasePath    string   // URL path the handler is mounted under, e.g. "/docs"
+    TOCPosition string   // "left" (default), "right" or "none"
+    TOCMinLevel int      // Shallowest heading level in the TOC, default 1
+    TOCMaxLevel int      // Deepest heading level in the TOC, default 6
+    CacheSize   int      // Rendered pages kept in memory; 0 disables caching
+    Renderer    Renderer // Markdown to HTML converter, default gomarkdown
+    TemplateDir string   // Directory with view.html, index.html or edit.html overrides
+    Funcs       template.FuncMap
+    Data        map[string]interface{} // Site variables, available to templates as .Data
+    Filters     []Filter               // External commands applied to each document
+    PlantUML    PlantUML               // Renders ```plantuml blocks when set
+    Analytics   Analytics              // Tracking snippet added to every page
+    Language    string                 // Language of the page chrome, default from Accept-Language
+    Favicon     string                 // Icon file served at /favicon.ico, default built in
+    CastPlayer  string                 // Directory with asciinema-player.min.js and .css, served instead of loading them from jsDelivr
+    Robots      string                 // File served as /robots.txt, default generated per site
+    Store       Store                  // Server-side state, default in memory
+    Stats       bool                   // Count page views in the Store, listed at /stats
+    Drafts      bool                   // Show documents marked draft: true to everyone
+    Lite        bool                   // Minimal HTML without scripts, TOC, caches or background work
+    Ignore      []string               // Globs left out of listings and search besides DefaultIgnore; "!name" keeps a default
+
+    InteractiveTables bool   // Make every table sortable and filterable, not just {.sortable .filterable} ones
+    NumberSections    bool   // Number headings 1., 1.1, 1.2.3; frontmatter number_sections overrides
+    PreviewToken      string // ?preview=<token> shows drafts to whoever has the link
+    CaseInsensitive   bool   // Resolve /Readme.MD to README.md when that is the only match
+    SmartPunctuation  bool   // Curly quotes and dashes; frontmatter smart_punctuation overrides
+    HardWraps         bool   // Single newlines become line breaks; frontmatter hard_wraps overrides
+    CommonMark        bool   // Render plain CommonMark: no extensions, shortcodes, alerts or other additions
+    Debug             bool   // Mark links to missing files and headings in pages
+    GitIgnore         bool   // Apply .gitignore files as well as .mdserveignore
+    MaxDepth          int    // Directory levels listed below each mount root, 0 for no limit
+    MaxFiles          int    // Documents listed per mount, 0 for no limit
+    StreamThreshold   int64  // Markdown files larger than this many bytes are streamed, 0 never
+    MaxFileSize       int64  // Documents larger than this many bytes get a download link instead of rendering, 0 for no limit
+    RenderWorkers     int           // Documents rendered at once, 0 for no limit
+    RenderTimeout     time.Duration // How long a request waits to render before a 503, default 30s
+    Dev               bool          // Re-read override templates on every request, cache nothing and log each request
+    Public            bool          // Serve the mounts without authentication
+    SharedCache       *SharedCache  // Used instead of a cache of CacheSize pages of its own
+    Name              string        // Keeps stats and comments apart from other Servers sharing the Store
+    CacheBackend      CacheBackend  // Rendered pages and search entries shared with other replicas
+    CacheTTL          time.Duration // How long CacheBackend keeps an entry, 0 until evicted
+}
+
+// Option changes one setting of a Config
+type Option func(*Config)
+
+// WithConfig replaces the whole configuration; later options still apply on top
+func WithConfig(cfg Config) Option {
+    return func(c *Config) { *c = cfg }
+}
+
+// WithMounts adds directory trees served for the default host
+func WithMounts(mounts ...Mount) Option {
+    return func(c *Config) { c.Mounts = append(c.Mounts, mounts...) }
+}
+
+// WithHost adds a virtual host
+func WithHost(h Host) Option {
+    return func(c *Config) { c.Hosts = append(c.Hosts, h) }
+}
+
+// WithTheme sets the default page theme
+func WithTheme(name string) Option {
+    return func(c *Config) { c.Theme = name }
+}
+
+// WithAuth sets the basic auth credentials; the password is also the gpg passphrase
+func WithAuth(username, password string) Option {
+    return func(c *Config) {
+        c.Username = username
+        c.Password = password
+    }
+}
+
+// WithPublic serves the mounts without authentication
+func WithPublic() Option {
+    return func(c *Config) { c.Public = true }
+}
+
+// WithBasePath sets the URL path the handler is mounted under
+func WithBasePath(p string) Option {
+    return func(c *Config) { c.BasePath = p }
+}
+
+// WithTOCPosition places the table of contents "left", "right" or "none"
+func WithTOCPosition(pos string) Option {
+    return func(c *Config) { c.TOCPosition = pos }
+}
+
+// WithTOCLevels limits the table of contents to headings from level min to max
+func WithTOCLevels(min, max int) Option {
+    return func(c *Config) {
+        c.TOCMinLevel = min
+        c.TOCMaxLevel = max
+    }
+}
+
+// WithSectionNumbers numbers headings in pages and their TOC
+func WithSectionNumbers() Option {
+    return func(c *Config) { c.NumberSections = true }
+}
+
+// WithCache keeps up to entries rendered pages in memory
+func WithCache(entries int) Option {
+    return func(c *Config) { c.CacheSize = entries }
+}
+
+// WithRenderer replaces the markdown to HTML converter
+func WithRenderer(r Renderer) Option {
+    return func(c *Config) { c.Renderer = r }
+}
+
+// WithTemplateDir overrides built-in page templates with files from dir
+func WithTemplateDir(dir string) Option {
+    return func(c *Config) { c.TemplateDir = dir }
+}
+
+// WithFuncs adds functions usable from page templates
+func WithFuncs(funcs template.FuncMap) Option {
+    return func(c *Config) {
+        if c.Funcs == nil {
+            c.Funcs = template.FuncMap{}
+        }
+        for name, fn := range funcs {
+            c.Funcs[name] = fn
+        }
+    }
+}
+
+// WithData sets site variables available to templates as .Data
+func WithData(data map[string]interface{}) Option {
+    return func(c *Config) {
+        if c.Data == nil {
+            c.Data = map[string]interface{}{}
+        }
+        for k, v := range data {
+            c.Data[k] = v
+        }
+    }
+}
+
+// WithFilters adds external commands to the render pipeline
+func WithFilters(filters ...Filter) Option {
+    return func(c *Config) { c.Filters = append(c.Filters, filters...) }
+}
+
+// WithPlantUML renders ```plantuml blocks with a PlantUML server or command
+func WithPlantUML(p PlantUML) Option {
+    return func(c *Config) { c.PlantUML = p }
+}
+
+// WithFavicon serves the icon in file at /favicon.ico instead of the built-in one
+func WithFavicon(file string) Option {
+    return func(c *Config) { c.Favicon = file }
+}
+
+// WithCastPlayer serves the asciinema player from dir, a copy of its
+// release bundle, instead of loading it from jsDelivr
+func WithCastPlayer(dir string) Option {
+    return func(c *Config) { c.CastPlayer = dir }
+}
+
+// WithRobots serves file as /robots.txt instead of the generated one
+func WithRobots(file string) Option {
+    return func(c *Config) { c.Robots = file }
+}
+
+// WithAnalytics adds a Plausible, Matomo or custom tracking snippet to every page
+func WithAnalytics(a Analytics) Option {
+    return func(c *Config) { c.Analytics = a }
+}
+
+// WithDrafts shows documents marked draft: true in indexes, search and views
+func WithDrafts() Option {
+    return func(c *Config) { c.Drafts = true }
+}
+
+// WithPreviewToken lets requests with ?preview=token see drafts
+func WithPreviewToken(token string) Option {
+    return func(c *Config) { c.PreviewToken = token }
+}
+
+// WithCaseInsensitivePaths resolves paths that differ from exactly one file
+// only in case, for links written on case-insensitive file systems
+func WithCaseInsensitivePaths() Option {
+    return func(c *Config) { c.CaseInsensitive = true }
+}
+
+// WithSmartPunctuation turns straight quotes into curly ones and -- and ---
+// into en and em dashes
+func WithSmartPunctuation() Option {
+    return func(c *Config) { c.SmartPunctuation = true }
+}
+
+// WithHardWraps renders every newline inside a paragraph as a line break,
+// as GitLab and Obsidian do
+func WithHardWraps() Option {
+    return func(c *Config) { c.HardWraps = true }
+}
+
+// WithCommonMark renders documents as plain CommonMark, turning off the
+// markdown extensions and every addition mdserve makes on top of them
+func WithCommonMark() Option {
+    return func(c *Config) { c.CommonMark = true }
+}
+
+// WithDebug marks links that point at missing files or headings in
+// rendered pages with a dotted underline
+func WithDebug() Option {
+    return func(c *Config) { c.Debug = true }
+}
+
+// WithIgnore leaves files and directories matching the glob patterns out
+// of index pages, search and reports, besides those in DefaultIgnore.
+// Patterns match the name, or the path from the mount root when they
+// contain "/"; "!build" keeps a default.
+func WithIgnore(patterns ...string) Option {
+    return func(c *Config) { c.Ignore = append(c.Ignore, patterns...) }
+}
+
+// WithGitIgnore keeps what .gitignore files exclude out of listings,
+// search and serving, as .mdserveignore files always are
+func WithGitIgnore() Option {
+    return func(c *Config) { c.GitIgnore = true }
+}
+
+// WithIndexLimits stops listing a mount below maxDepth directory levels
+// or after maxFiles documents; 0 lifts a limit. Index pages say when a
+// listing was cut short.
+func WithIndexLimits(maxDepth, maxFiles int) Option {
+    return func(c *Config) { c.MaxDepth, c.MaxFiles = maxDepth, maxFiles }
+}
+
+// WithStreaming sends markdown documents larger than threshold bytes as
+// they render, a few sections at a time, instead of rendering the whole
+// page first. Their table of contents is built in the browser.
+func WithStreaming(threshold int64) Option {
+    return func(c *Config) { c.StreamThreshold = threshold }
+}
+
+// WithMaxFileSize leaves documents larger than max bytes unrendered:
+// opening one shows a page with a link to download the raw file
+func WithMaxFileSize(max int64) Option {
+    return func(c *Config) { c.MaxFileSize = max }
+}
+
+// WithRenderWorkers renders at most n documents at once, so a burst of
+// requests for large documents can't exhaust memory. Others wait up to
+// timeout (default 30s) for their turn, then get a 503.
+func WithRenderWorkers(n int, timeout time.Duration) Option {
+    return func(c *Config) { c.RenderWorkers, c.RenderTimeout = n, timeout }
+}
+
+// WithDev turns on development mode for template and theme authors:
+// override templates are read again on every request, rendered pages and
+// listings aren't cached, responses tell browsers not to cache them, and
+// every request is logged.
+func WithDev() Option {
+    return func(c *Config) { c.Dev = true }
+}
+
+// WithSharedCache keeps rendered pages in c, shared with other Servers,
+// instead of a cache of the server's own
+func WithSharedCache(c *SharedCache) Option {
+    return func(cfg *Config) { cfg.SharedCache = c }
+}
+
+// WithName keys the server's stats and comments by name, for Servers
+// sharing a Store
+func WithName(name string) Option {
+    return func(c *Config) { c.Name = name }
+}
+
+// WithCacheBackend shares rendered pages and search index entries with
+// other replicas through b, each kept for ttl (0 until b evicts it)
+func WithCacheBackend(b CacheBackend, ttl time.Duration) Option {
+    return func(c *Config) { c.CacheBackend, c.CacheTTL = b, ttl }
+}
+
+// WithLanguage fixes the language of the page chrome, a key of Messages
+func WithLanguage(lang string) Option {
+    return func(c *Config) { c.Language = lang }
+}
+
+// WithStats counts page views, listed at /stats and as popular pages on indexes
+func WithStats() Option {
+    return func(c *Config) { c.Stats = true }
+}
+
+// WithStore sets where server-side state is kept, e.g. OpenBoltStore
+func WithStore(st Store) Option {
+    return func(c *Config) { c.Store = st }
+}
+
+// WithInteractiveTables adds click-to-sort headers and a filter box to
+// every table
+func WithInteractiveTables() Option {
+    return func(c *Config) { c.InteractiveTables = true }
+}
+
+// WithLite trims the server down for tiny devices: no caches, indexing,
+// watchers, scripts or TOC sidebar
+func WithLite() Option {
+    return func(c *Config) { c.Lite = true }
+}
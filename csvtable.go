@@ -0,0 +1,83 @@
+package mdserve
+
+import (
+    "encoding/csv"
+    "fmt"
+    "html"
+    "strconv"
+    "strings"
+)
+
+// Render CSV data from a ```csv or ```tsv block as an HTML table. Words
+// after the language tune it: "header" or "noheader" override header
+// detection, "sortable" and "filterable" add those classes.
+func csvTable(data string, tabs bool, opts []string) (string, error) {
+    r := csv.NewReader(strings.NewReader(data))
+    r.FieldsPerRecord = -1
+    r.LazyQuotes = true
+    r.TrimLeadingSpace = true
+    if tabs {
+        r.Comma = '\t'
+    }
+    rows, err := r.ReadAll()
+    if err != nil {
+        return "", err
+    }
+    if len(rows) == 0 {
+        return "", fmt.Errorf("no rows")
+    }
+
+    header := looksLikeHeader(rows)
+    var classes []string
+    for _, opt := range opts {
+        switch opt {
+        case "header":
+            header = true
+        case "noheader":
+            header = false
+        case "sortable", "filterable":
+            classes = append(classes, opt)
+        }
+    }
+
+    var b strings.Builder
+    if len(classes) > 0 {
+        fmt.Fprintf(&b, "<table class=\"csv-table %s\">\n", strings.Join(classes, " "))
+    } else {
+        b.WriteString("<table class=\"csv-table\">\n")
+    }
+    if header {
+        b.WriteString("<thead>\n")
+        writeRow(&b, "th", rows[0])
+        b.WriteString("</thead>\n")
+        rows = rows[1:]
+    }
+    b.WriteString("<tbody>\n")
+    for _, row := range rows {
+        writeRow(&b, "td", row)
+    }
+    b.WriteString("</tbody>\n</table>\n")
+    return b.String(), nil
+}
+
+func writeRow(b *strings.Builder, tag string, cells []string) {
+    b.WriteString("<tr>")
+    for _, cell := range cells {
+        fmt.Fprintf(b, "<%s>%s</%s>", tag, html.EscapeString(cell), tag)
+    }
+    b.WriteString("</tr>\n")
+}
+
+// Treat the first row as a header when it has neither numbers nor blanks
+func looksLikeHeader(rows [][]string) bool {
+    if len(rows) < 2 {
+        return false
+    }
+    for _, cell := range rows[0] {
+        cell = strings.TrimSpace(cell)
+        if _, err := strconv.ParseFloat(strings.ReplaceAll(cell, ",", ""), 64); err == nil || cell == "" {
+            return false
+        }
+    }
+    return true
+}
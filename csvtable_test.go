@@ -0,0 +1,40 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestCSVFences(t *testing.T) {
+    s := New()
+    tests := []struct {
+        name    string
+        src     string
+        want    []string // In the page
+        notWant []string
+    }{
+        {"header detected", "```csv\nName,Stars\nmdserve,\"1,200\"\n```\n", []string{`<table class="csv-table">`, "<thead>\n<tr><th>Name</th><th>Stars</th></tr>", "<td>1,200</td>"}, []string{"<pre"}},
+        {"numbers in the first row", "```csv\n1,2\n3,4\n```\n", []string{"<tbody>\n<tr><td>1</td><td>2</td></tr>"}, []string{"<thead>"}},
+        {"header forced", "```csv header\n1,2\n3,4\n```\n", []string{"<th>1</th>"}, nil},
+        {"header turned off", "```csv noheader\na,b\nc,d\n```\n", []string{"<td>a</td>"}, []string{"<thead>"}},
+        {"tsv", "```tsv\nName\tNote\nx\ta, b\n```\n", []string{"<th>Note</th>", "<td>a, b</td>"}, nil},
+        {"classes", "```csv sortable filterable\na,b\nc,d\n```\n", []string{`<table class="csv-table sortable filterable">`}, nil},
+        {"cells escaped", "```csv\nName,Tag\nx,<b>\n```\n", []string{"<td>&lt;b&gt;</td>"}, []string{"<b>"}},
+        {"ragged rows", "```csv\nA,B,C\n1\n```\n", []string{"<tr><td>1</td></tr>"}, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+}
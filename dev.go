@@ -0,0 +1,53 @@
+package mdserve
+
+import (
+    "log"
+    "net/http"
+    "time"
+)
+
+// Development mode logs every request with its status and how long it
+// took, and keeps browsers from reusing stylesheets and pages, so changes
+// to templates and CSS show on the next reload
+func devMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        sw := &statusWriter{ResponseWriter: w}
+        w.Header().Set("Cache-Control", "no-store")
+        next.ServeHTTP(sw, r)
+        if sw.status == 0 {
+            sw.status = http.StatusOK
+        }
+        log.Printf("%s %s %d %d bytes %v [%s]", r.Method, r.URL.RequestURI(), sw.status, sw.written, time.Since(start).Round(time.Microsecond), RequestID(r))
+    })
+}
+
+// Remembers the status and size of a response
+type statusWriter struct {
+    http.ResponseWriter
+    status  int
+    written int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+    if w.status == 0 {
+        w.status = status
+    }
+    w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+    if w.status == 0 {
+        w.status = http.StatusOK
+    }
+    n, err := w.ResponseWriter.Write(p)
+    w.written += int64(n)
+    return n, err
+}
+
+// Streamed pages flush as they render
+func (w *statusWriter) Flush() {
+    if f, ok := w.ResponseWriter.(http.Flusher); ok {
+        f.Flush()
+    }
+}
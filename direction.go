@@ -0,0 +1,36 @@
+package mdserve
+
+import (
+    "unicode"
+)
+
+// Languages written right to left
+var rtlLanguages = map[string]bool{"ar": true, "he": true, "fa": true, "ur": true, "yi": true, "ps": true, "dv": true, "ku": true, "sd": true}
+
+// The writing direction of a document, "rtl" or "ltr": the dir frontmatter
+// key when set, else its language, else whichever direction most of the
+// letters at the start of its text are written in
+func textDirection(dir, lang string, text []byte) string {
+    if dir == "rtl" || dir == "ltr" {
+        return dir
+    }
+    if rtlLanguages[lang] {
+        return "rtl"
+    }
+    rtl, ltr := 0, 0
+    for i, r := range string(text) {
+        if i > 4000 {
+            break
+        }
+        switch {
+        case unicode.In(r, unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko):
+            rtl++
+        case unicode.IsLetter(r):
+            ltr++
+        }
+    }
+    if rtl > ltr {
+        return "rtl"
+    }
+    return "ltr"
+}
@@ -0,0 +1,51 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestTextDirection(t *testing.T) {
+    tests := []struct {
+        name string
+        dir  string
+        lang string
+        text string
+        want string
+    }{
+        {"english", "", "", "Hello there", "ltr"},
+        {"arabic text", "", "", "مرحبا بالعالم and more", "rtl"},
+        {"hebrew text", "", "", "שלום עולם", "rtl"},
+        {"mostly english", "", "", "Say שלום to everyone here", "ltr"},
+        {"language", "", "fa", "Hello", "rtl"},
+        {"frontmatter wins", "ltr", "ar", "مرحبا", "ltr"},
+        {"frontmatter rtl", "rtl", "", "Hello", "rtl"},
+        {"unknown frontmatter", "up", "", "Hello", "ltr"},
+        {"no letters", "", "", "123 !", "ltr"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := textDirection(tt.dir, tt.lang, []byte(tt.text)); got != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestRightToLeftPage(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "ar.md":     "# مرحبا\n\nنص عربي <b>bold</b>.\n",
+        "lang.md":   "---\nlang: he\n---\n# Title\n",
+        "en.md":     "# Hello\n",
+        "forced.md": "---\ndir: rtl\n---\n# Hello\n",
+    })
+    for target, dir := range map[string]string{"/ar.md": "rtl", "/lang.md": "rtl", "/en.md": "ltr", "/forced.md": "rtl"} {
+        page := doRequest(s, "GET", target, nil, true).Body.String()
+        if want := `<div class="layout toc-left" dir="` + dir + `">`; !strings.Contains(page, want) {
+            t.Errorf("%s: %q missing", target, want)
+        }
+        if !strings.Contains(page, "[dir=rtl] pre, [dir=rtl] .code-block { direction: ltr;") {
+            t.Errorf("%s: code isn't kept left to right", target)
+        }
+    }
+}
@@ -0,0 +1,50 @@
+package mdserve
+
+import (
+    "net/http"
+    "strconv"
+)
+
+// Cookie that keeps preview mode on while following links
+const previewCookie = "mdserve_preview"
+
+// Whether a document is marked draft: true in its frontmatter
+func isDraft(file string) bool {
+    draft, _ := strconv.ParseBool(readFrontMatter(file).Get("draft"))
+    return draft
+}
+
+// Whether drafts are shown: always with -drafts, otherwise for requests
+// carrying ?preview=<token> or the cookie it sets. r may be nil for work
+// outside a request.
+func (s *Server) showDrafts(r *http.Request) bool {
+    if s.drafts || r == nil || s.previewToken == "" {
+        return s.drafts
+    }
+    if r.URL.Query().Get("preview") == s.previewToken {
+        return true
+    }
+    c, err := r.Cookie(previewCookie)
+    return err == nil && c.Value == s.previewToken
+}
+
+// Remember a preview token given in the URL for the following pages
+func (s *Server) setPreviewCookie(w http.ResponseWriter, r *http.Request) {
+    if s.previewToken != "" && r.URL.Query().Get("preview") == s.previewToken {
+        http.SetCookie(w, &http.Cookie{Name: previewCookie, Value: s.previewToken, Path: s.basePath + "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+    }
+}
+
+// Leave out drafts unless the request may see them
+func (s *Server) dropDrafts(r *http.Request, entries []IndexEntry) []IndexEntry {
+    if s.showDrafts(r) {
+        return entries
+    }
+    var kept []IndexEntry
+    for _, e := range entries {
+        if !isDraft(e.File) {
+            kept = append(kept, e)
+        }
+    }
+    return kept
+}
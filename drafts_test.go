@@ -0,0 +1,44 @@
+package mdserve
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+)
+
+// Drafts stay hidden however they are asked for
+func TestDraftsHidden(t *testing.T) {
+    files := map[string]string{
+        "draft.md": "---\ndraft: true\ntitle: Secret plans\n---\nNot yet.\n",
+        "doc.md":   "# Doc\n\nPublished.\n",
+    }
+    tests := []struct {
+        name   string
+        target string
+        opts   []Option
+        status int
+    }{
+        {"view", "/draft.md", nil, http.StatusNotFound},
+        {"download", "/draft.md?download=1", nil, http.StatusNotFound},
+        {"raw download", "/draft.md?download", nil, http.StatusNotFound},
+        {"oembed", "/oembed?url=/draft.md", nil, http.StatusNotFound},
+        {"published view", "/doc.md", nil, http.StatusOK},
+        {"published download", "/doc.md?download=1", nil, http.StatusOK},
+        {"published oembed", "/oembed?url=/doc.md", nil, http.StatusOK},
+        {"view with preview link", "/draft.md?preview=tok", []Option{WithPreviewToken("tok")}, http.StatusOK},
+        {"download with preview link", "/draft.md?download=1&preview=tok", []Option{WithPreviewToken("tok")}, http.StatusOK},
+        {"oembed with drafts shown", "/oembed?url=/draft.md", []Option{WithDrafts()}, http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, files, tt.opts...)
+            w := doRequest(s, "GET", tt.target, nil, true)
+            if w.Code != tt.status {
+                t.Errorf("got %d, want %d", w.Code, tt.status)
+            }
+            if tt.status == http.StatusNotFound && strings.Contains(w.Body.String(), "Secret plans") {
+                t.Error("draft content leaked")
+            }
+        })
+    }
+}
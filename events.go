@@ -0,0 +1,189 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "path"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Events kept for clients reconnecting with Last-Event-ID, and buffered
+// per client; a client further behind misses events
+const (
+    eventHistory = 256
+    eventBuffer  = 64
+)
+
+// How often an idle feed sends a comment, so proxies keep it open
+const eventKeepAlive = 30 * time.Second
+
+// A change in the served files, as sent by /api/events. Type is created,
+// changed or removed for a document, indexed once its search index entry
+// is current, or scanned once the index is up to date after startup.
+type changeEvent struct {
+    ID   int64     `json:"-"`
+    Type string    `json:"type"`
+    File string    `json:"-"`
+    Path string    `json:"path,omitempty"` // URL path without the leading slash
+    Time time.Time `json:"time"`
+}
+
+// Hands events from the watcher to the clients of /api/events
+type eventHub struct {
+    mu      sync.Mutex
+    last    int64
+    history []changeEvent
+    clients map[chan changeEvent]bool
+}
+
+// Send an event about file, "" for none, to every client
+func (h *eventHub) publish(typ, file string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.last++
+    ev := changeEvent{ID: h.last, Type: typ, File: file, Time: time.Now()}
+    h.history = append(h.history, ev)
+    if len(h.history) > eventHistory {
+        h.history = h.history[len(h.history)-eventHistory:]
+    }
+    for c := range h.clients {
+        select {
+        case c <- ev:
+        default: // Too far behind
+        }
+    }
+}
+
+// Start receiving events, getting those after lastID first
+func (h *eventHub) subscribe(lastID int64) (chan changeEvent, []changeEvent) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    c := make(chan changeEvent, eventBuffer)
+    if h.clients == nil {
+        h.clients = map[chan changeEvent]bool{}
+    }
+    h.clients[c] = true
+    var missed []changeEvent
+    for _, ev := range h.history {
+        if lastID > 0 && ev.ID > lastID {
+            missed = append(missed, ev)
+        }
+    }
+    return c, missed
+}
+
+func (h *eventHub) unsubscribe(c chan changeEvent) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.clients[c] {
+        delete(h.clients, c)
+        close(c)
+    }
+}
+
+// End every feed, when the watcher stops; clients reconnect to whatever
+// serves next
+func (h *eventHub) closeAll() {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for c := range h.clients {
+        close(c)
+    }
+    h.clients = nil
+}
+
+// The URL path of a file under the first of the site's mounts holding it
+func (st *site) urlPathOf(file string) (string, bool) {
+    for _, m := range st.mounts {
+        rel, err := filepath.Rel(m.Root, file)
+        if err != nil || rel == ".." || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+            continue
+        }
+        return strings.TrimPrefix(path.Join(m.Prefix, filepath.ToSlash(rel)), "/"), true
+    }
+    return "", false
+}
+
+// Whether a document path is one a client asked for with ?path=: the
+// path itself, a directory ending in a slash, or a glob such as
+// guides/*.md. No filter gets everything.
+func matchesEventFilters(p string, filters []string) bool {
+    if len(filters) == 0 {
+        return true
+    }
+    for _, f := range filters {
+        f = strings.TrimPrefix(f, "/")
+        if f == p || f == "" || strings.HasSuffix(f, "/") && strings.HasPrefix(p, f) {
+            return true
+        }
+        if ok, _ := path.Match(f, p); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// /api/events streams changes to the site's documents as server-sent
+// events while the server watches its files. Repeat ?path= to only get
+// events for some documents.
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    flusher, ok := w.(http.Flusher)
+    if !ok || !s.trees.isWatched() {
+        http.Error(w, "Change feed not available", http.StatusNotFound)
+        return
+    }
+    filters := r.URL.Query()["path"]
+    drafts := s.showDrafts(r)
+    lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+    events, missed := s.events.subscribe(lastID)
+    defer s.events.unsubscribe(events)
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("X-Accel-Buffering", "no") // Don't let nginx hold events back
+    w.WriteHeader(http.StatusOK)
+    fmt.Fprint(w, "retry: 2000\n\n")
+    flusher.Flush()
+
+    send := func(ev changeEvent) {
+        if ev.File != "" {
+            p, ok := st.urlPathOf(ev.File)
+            if !ok || !matchesEventFilters(p, filters) {
+                return
+            }
+            if ev.Type != "removed" && !drafts && isDraft(ev.File) {
+                return
+            }
+            ev.Path = p
+        }
+        data, _ := json.Marshal(ev)
+        fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+    }
+    for _, ev := range missed {
+        send(ev)
+    }
+    flusher.Flush()
+
+    keepAlive := time.NewTicker(eventKeepAlive)
+    defer keepAlive.Stop()
+    for {
+        select {
+        case ev, ok := <-events:
+            if !ok {
+                return
+            }
+            send(ev)
+            flusher.Flush()
+        case <-keepAlive.C:
+            fmt.Fprint(w, ": keep-alive\n\n")
+            flusher.Flush()
+        case <-r.Context().Done():
+            return
+        }
+    }
+}
@@ -0,0 +1,24 @@
+package mdserve
+
+import (
+    "bytes"
+    _ "embed"
+    "net/http"
+    "time"
+)
+
+//go:embed favicon.ico
+var defaultFavicon []byte
+
+var faviconTime = time.Now() // Last-Modified of the built-in icon
+
+// Serve the configured favicon, or the built-in one
+func (s *Server) faviconHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    if s.favicon != "" {
+        serveAsset(w, r, s.favicon)
+        return
+    }
+    w.Header().Set("Content-Type", "image/x-icon")
+    http.ServeContent(w, r, "favicon.ico", faviconTime, bytes.NewReader(defaultFavicon))
+}
@@ -0,0 +1,82 @@
+package mdserve
+
+import (
+    "fmt"
+    "html"
+    "log"
+    "strings"
+)
+
+// Renders the content of a fenced code block in some language to HTML.
+// opts holds the words of the info string after the language.
+type fenceFunc func(opts []string, body string) (string, error)
+
+func (s *Server) registerBuiltinFences() {
+    s.fences = map[string]fenceFunc{
+        "csv":       func(opts []string, body string) (string, error) { return csvTable(body, false, opts) },
+        "tsv":       func(opts []string, body string) (string, error) { return csvTable(body, true, opts) },
+        "chart":     renderChart,
+        "chartjs":   renderChart,
+        "asciinema": renderCast,
+    }
+}
+
+// Replace fenced code blocks in a language with a registered renderer, or
+// with attributes such as title="main.go", by placeholders for their HTML. When rendering fails the error is shown above
+// the block, which stays as code.
+func (s *Server) expandFences(src string, placeholders map[string]string) string {
+    lines := strings.SplitAfter(src, "\n")
+    var out strings.Builder
+    for i := 0; i < len(lines); i++ {
+        line := lines[i]
+        trimmed := strings.TrimLeft(line, " \t")
+        fence := fenceMarker(trimmed)
+        if fence == "" {
+            out.WriteString(line)
+            continue
+        }
+        end := i + 1
+        for end < len(lines) && !strings.HasPrefix(strings.TrimLeft(lines[end], " \t"), fence) {
+            end++
+        }
+        block := strings.Join(lines[i+1:min(end, len(lines))], "")
+        closing := ""
+        if end < len(lines) {
+            closing = lines[end]
+        }
+        i = end
+
+        rest := strings.TrimSpace(strings.TrimPrefix(trimmed, fence))
+        info := strings.Fields(rest)
+        var fn fenceFunc
+        if len(info) > 0 {
+            fn = s.fences[strings.ToLower(info[0])]
+        }
+        if fn == nil {
+            // Plain code blocks only need rendering here for their attributes
+            lang := ""
+            if len(info) > 0 && !strings.ContainsAny(info[0], "={") {
+                lang, rest = info[0], strings.TrimPrefix(rest, info[0])
+            }
+            if attrs := fenceAttrs(rest); len(attrs) > 0 {
+                key := fmt.Sprintf("MDSERVEFENCE%dX", len(placeholders))
+                placeholders[key] = renderCodeBlock(lang, attrs, block)
+                out.WriteString("\n" + key + "\n\n")
+            } else {
+                out.WriteString(line + block + closing)
+            }
+            continue
+        }
+        rendered, err := fn(info[1:], block)
+        if err != nil {
+            log.Printf("%s block: %v", info[0], err)
+            out.WriteString(fmt.Sprintf("\n<p class=\"sc-error\">%s</p>\n\n", html.EscapeString(info[0]+": "+err.Error())))
+            out.WriteString(line + block + closing)
+            continue
+        }
+        key := fmt.Sprintf("MDSERVEFENCE%dX", len(placeholders))
+        placeholders[key] = rendered
+        out.WriteString("\n" + key + "\n\n")
+    }
+    return out.String()
+}
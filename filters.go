@@ -0,0 +1,121 @@
+package mdserve
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Filter pipes a document through an external command, e.g. a pandoc filter
+// or a macro expander. The command reads the content on stdin and writes the
+// replacement to stdout; MDSERVE_FILE holds the document's path.
+type Filter struct {
+    Command []string      // Program and arguments
+    Stage   string        // "markdown" (before rendering, default) or "html" (after)
+    Match   string        // Glob on the file name, or on the path under the mount root when it contains "/"; default all
+    Timeout time.Duration // Default 10s
+}
+
+const maxFilterCache = 512 // Cached filter outputs before the cache is reset
+
+// Outputs of filter runs keyed by a hash of command and input
+type filterCache struct {
+    mu      sync.Mutex
+    outputs map[string][]byte
+}
+
+func (c *filterCache) get(key string) ([]byte, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    out, ok := c.outputs[key]
+    return out, ok
+}
+
+func (c *filterCache) put(key string, out []byte) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.outputs == nil || len(c.outputs) >= maxFilterCache {
+        c.outputs = map[string][]byte{}
+    }
+    c.outputs[key] = out
+}
+
+// Hook each configured filter into the render pipeline
+func (s *Server) registerFilters(filters []Filter) {
+    for i := range filters {
+        f := filters[i]
+        if len(f.Command) == 0 {
+            continue
+        }
+        run := func(file string, content []byte) []byte {
+            if !f.matches(s.rootOf(file), file) {
+                return content
+            }
+            out, err := s.runFilter(f, file, content)
+            if err != nil {
+                log.Printf("Filter %s on %s: %v", f.Command[0], file, err)
+                return content
+            }
+            return out
+        }
+        if f.Stage == "html" {
+            s.AfterRender(run)
+        } else {
+            s.BeforeRender(run)
+        }
+    }
+}
+
+// Whether the filter applies to a file under root
+func (f Filter) matches(root, file string) bool {
+    if f.Match == "" {
+        return true
+    }
+    target := filepath.Base(file)
+    if rel, err := filepath.Rel(root, file); err == nil && rel != "." && strings.Contains(f.Match, "/") {
+        target = filepath.ToSlash(rel)
+    }
+    ok, _ := filepath.Match(f.Match, target)
+    return ok
+}
+
+// Run a filter command, reusing the output for identical input
+func (s *Server) runFilter(f Filter, file string, content []byte) ([]byte, error) {
+    h := sha256.New()
+    fmt.Fprintf(h, "%q\x00%s\x00", f.Command, file)
+    h.Write(content)
+    key := hex.EncodeToString(h.Sum(nil))
+    if out, ok := s.filterCache.get(key); ok {
+        return out, nil
+    }
+
+    timeout := f.Timeout
+    if timeout <= 0 {
+        timeout = 10 * time.Second
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    cmd := exec.CommandContext(ctx, f.Command[0], f.Command[1:]...)
+    cmd.Stdin = bytes.NewReader(content)
+    cmd.Env = append(os.Environ(), "MDSERVE_FILE="+file)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    out, err := cmd.Output()
+    if err != nil {
+        return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+    }
+    if !s.lite {
+        s.filterCache.put(key, out)
+    }
+    return out, nil
+}
@@ -0,0 +1,44 @@
+package mdserve
+
+import (
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestFilterMatches(t *testing.T) {
+    root := filepath.FromSlash("/srv/docs")
+    tests := []struct {
+        match string
+        file  string
+        want  bool
+    }{
+        {"", "a.md", true},
+        {"*.md", "a.md", true},
+        {"*.md", "specs/a.md", true},
+        {"*.md", "a.txt", false},
+        {"specs/*.md", "specs/a.md", true},
+        {"specs/*.md", "a.md", false},
+        {"specs/*.md", "other/specs/a.md", false},
+        {"docs/specs/*.md", "specs/a.md", false}, // Not above the root
+    }
+    for _, tt := range tests {
+        file := filepath.Join(root, filepath.FromSlash(tt.file))
+        if got := (Filter{Match: tt.match}).matches(root, file); got != tt.want {
+            t.Errorf("%q on %s: got %v, want %v", tt.match, tt.file, got, tt.want)
+        }
+    }
+}
+
+func TestFilterOnPath(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "specs/a.md": "hello\n",
+        "a.md":       "hello\n",
+    }, WithFilters(Filter{Command: []string{"sed", "s/hello/filtered/"}, Match: "specs/*.md"}))
+    if body := doRequest(s, "GET", "/specs/a.md", nil, true).Body.String(); !strings.Contains(body, "filtered") {
+        t.Errorf("filter not run on specs/a.md: %q", body)
+    }
+    if body := doRequest(s, "GET", "/a.md", nil, true).Body.String(); strings.Contains(body, "filtered") {
+        t.Errorf("filter run on a.md: %q", body)
+    }
+}
@@ -0,0 +1,60 @@
+package mdserve
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    footnoteRef  = regexp.MustCompile(`<sup class="footnote-ref" id="fnref:([^"]+)"><a href="#fn:[^"]+">(\d+)</a></sup>`)
+    footnoteItem = regexp.MustCompile(`(?s)<li id="fn:([^"]+)">(.*?)\s*<a class="footnote-return" href="#fnref:[^"]+">.*?</a></li>`)
+    blockTag     = regexp.MustCompile(`</?(p|div|ul|ol|li|pre|blockquote)>`)
+)
+
+// Give every footnote reference a unique id and a hover preview of the
+// note, and link each note back to all of its references
+func linkFootnotes(page string) string {
+    if !strings.Contains(page, `class="footnote-ref"`) {
+        return page
+    }
+
+    previews := map[string]string{}
+    for _, m := range footnoteItem.FindAllStringSubmatch(page, -1) {
+        previews[m[1]] = strings.Join(strings.Fields(blockTag.ReplaceAllString(m[2], " ")), " ")
+    }
+
+    refs := map[string]int{}
+    page = footnoteRef.ReplaceAllStringFunc(page, func(ref string) string {
+        m := footnoteRef.FindStringSubmatch(ref)
+        name, num := m[1], m[2]
+        refs[name]++
+        id := "fnref:" + name
+        if refs[name] > 1 {
+            id = fmt.Sprintf("fnref:%s:%d", name, refs[name])
+        }
+        preview := ""
+        if text, ok := previews[name]; ok {
+            preview = `<span class="footnote-preview" role="tooltip">` + text + `</span>`
+        }
+        return fmt.Sprintf(`<sup class="footnote-ref" id="%s"><a href="#fn:%s">%s</a>%s</sup>`, id, name, num, preview)
+    })
+
+    return footnoteItem.ReplaceAllStringFunc(page, func(item string) string {
+        m := footnoteItem.FindStringSubmatch(item)
+        name := m[1]
+        var back strings.Builder
+        for i := 1; i <= refs[name] || i == 1; i++ {
+            id, label := "fnref:"+name, "↩"
+            if i > 1 {
+                id, label = fmt.Sprintf("fnref:%s:%d", name, i), fmt.Sprintf("↩<sup>%d</sup>", i)
+            }
+            fmt.Fprintf(&back, ` <a class="footnote-return" href="#%s" aria-label="Back to reference">%s</a>`, id, label)
+        }
+        body := m[2]
+        if strings.HasSuffix(body, "</p>") {
+            return `<li id="fn:` + name + `">` + strings.TrimSuffix(body, "</p>") + back.String() + "</p></li>"
+        }
+        return `<li id="fn:` + name + `">` + body + back.String() + "</li>"
+    })
+}
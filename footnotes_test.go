@@ -0,0 +1,56 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestFootnotes(t *testing.T) {
+    s := New()
+    tests := []struct {
+        name string
+        src  string
+        want []string // In the page, in order
+    }{
+        {
+            "preview and backlink",
+            "Text[^a].\n\n[^a]: The *note*.\n",
+            []string{
+                `<sup class="footnote-ref" id="fnref:a"><a href="#fn:a">1</a><span class="footnote-preview" role="tooltip">The <em>note</em>.</span></sup>`,
+                `<li id="fn:a">The <em>note</em>. <a class="footnote-return" href="#fnref:a" aria-label="Back to reference">↩</a></li>`,
+            },
+        },
+        {
+            "repeated reference",
+            "One[^a], two[^a].\n\n[^a]: Note.\n",
+            []string{
+                `id="fnref:a"`,
+                `id="fnref:a:2"`,
+                `href="#fnref:a" aria-label="Back to reference">↩</a>`,
+                `href="#fnref:a:2" aria-label="Back to reference">↩<sup>2</sup></a>`,
+            },
+        },
+        {
+            "numbered in order of use",
+            "B[^b] then A[^a].\n\n[^a]: First defined.\n[^b]: Second defined.\n",
+            []string{`href="#fn:b">1</a>`, `href="#fn:a">2</a>`},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            rest := page
+            for _, want := range tt.want {
+                i := strings.Index(rest, want)
+                if i < 0 {
+                    t.Fatalf("%q missing, or out of order, in %q", want, page)
+                }
+                rest = rest[i+len(want):]
+            }
+        })
+    }
+
+    if page := string(s.renderMarkdown([]byte("No notes.\n"), s.markdown)); strings.Contains(page, "footnote") {
+        t.Errorf("footnote markup in %q", page)
+    }
+}
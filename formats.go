@@ -0,0 +1,33 @@
+package mdserve
+
+import (
+    "path/filepath"
+    "strings"
+)
+
+// RegisterFormat renders documents with the given file extension, such as
+// ".rst", with r. They are listed on index pages, get a table of contents
+// from their headings and go through the render hooks like markdown.
+func (s *Server) RegisterFormat(ext string, r Renderer) {
+    s.formatsMu.Lock()
+    defer s.formatsMu.Unlock()
+    s.formats[strings.ToLower(ext)] = r
+}
+
+func (s *Server) registerBuiltinFormats() {
+    s.RegisterFormat(".adoc", AsciiDoc)
+    s.RegisterFormat(".asciidoc", AsciiDoc)
+}
+
+// The renderer for a document in a registered format; nil for markdown
+// and for files that are not documents
+func (s *Server) formatFor(file string) Renderer {
+    s.formatsMu.RLock()
+    defer s.formatsMu.RUnlock()
+    return s.formats[strings.ToLower(filepath.Ext(file))]
+}
+
+// Whether a file is rendered as a page rather than served as-is
+func (s *Server) isDocument(file string) bool {
+    return strings.HasSuffix(file, ".md") || s.formatFor(file) != nil
+}
@@ -0,0 +1,91 @@
+package mdserve
+
+import (
+    "bufio"
+    "bytes"
+    "os"
+    "strings"
+)
+
+// Frontmatter keys of a document; scalars are stored as one-element lists
+type frontMatter map[string][]string
+
+// Split a leading "---" delimited frontmatter block off the markdown body.
+// Supports "key: value", inline lists "key: [a, b]" and "- item" block lists.
+func parseFrontMatter(content []byte) (frontMatter, []byte) {
+    fm := frontMatter{}
+    text := strings.ReplaceAll(string(content), "\r\n", "\n")
+    if !strings.HasPrefix(text, "---\n") {
+        return fm, content
+    }
+    end := strings.Index(text[4:], "\n---")
+    if end < 0 {
+        return fm, content
+    }
+    block := text[4 : 4+end]
+    body := strings.TrimPrefix(text[4+end+4:], "\n")
+
+    lastKey := ""
+    for _, line := range strings.Split(block, "\n") {
+        trimmed := strings.TrimSpace(line)
+        if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+            continue
+        }
+        if strings.HasPrefix(trimmed, "- ") && lastKey != "" {
+            fm[lastKey] = append(fm[lastKey], unquote(trimmed[2:]))
+            continue
+        }
+        key, val, ok := strings.Cut(trimmed, ":")
+        if !ok {
+            continue
+        }
+        key = strings.ToLower(strings.TrimSpace(key))
+        val = strings.TrimSpace(val)
+        lastKey = key
+        fm[key] = nil
+        if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+            for _, item := range strings.Split(val[1:len(val)-1], ",") {
+                if item = unquote(item); item != "" {
+                    fm[key] = append(fm[key], item)
+                }
+            }
+        } else if val != "" {
+            fm[key] = []string{unquote(val)}
+        }
+    }
+    return fm, []byte(body)
+}
+
+// The frontmatter of a file, read from its first lines only
+func readFrontMatter(file string) frontMatter {
+    f, err := os.Open(file)
+    if err != nil {
+        return frontMatter{}
+    }
+    defer f.Close()
+    var head bytes.Buffer
+    scanner := bufio.NewScanner(f)
+    for n := 0; n < 100 && scanner.Scan(); n++ {
+        head.Write(scanner.Bytes())
+        head.WriteByte('\n')
+    }
+    fm, _ := parseFrontMatter(head.Bytes())
+    return fm
+}
+
+// Strip surrounding whitespace and quotes from a frontmatter value
+func unquote(s string) string {
+    s = strings.TrimSpace(s)
+    if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+        return s[1 : len(s)-1]
+    }
+    return s
+}
+
+// First value of a key, or "" when unset
+func (fm frontMatter) Get(key string) string {
+    if len(fm[key]) == 0 {
+        return ""
+    }
+    return fm[key][0]
+}
@@ -0,0 +1,34 @@
+package mdserve
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+        want    frontMatter
+        body    string
+    }{
+        {"none", "# Title\n", frontMatter{}, "# Title\n"},
+        {"scalars", "---\ntitle: Hello\nOwner: \"ann\"\n---\nBody\n", frontMatter{"title": {"Hello"}, "owner": {"ann"}}, "Body\n"},
+        {"inline list", "---\ntags: [a, 'b', , c]\n---\n", frontMatter{"tags": {"a", "b", "c"}}, ""},
+        {"block list", "---\naliases:\n  - /old.md\n  - \"two.md\"\n---\nBody", frontMatter{"aliases": {"/old.md", "two.md"}}, "Body"},
+        {"comments and blanks", "---\n# note\n\ndraft: true\n---\n", frontMatter{"draft": {"true"}}, ""},
+        {"empty value", "---\ntitle:\n---\n", frontMatter{"title": nil}, ""},
+        {"value with colon", "---\nurl: https://example.com\n---\n", frontMatter{"url": {"https://example.com"}}, ""},
+        {"windows line endings", "---\r\ntitle: Hi\r\n---\r\nBody\r\n", frontMatter{"title": {"Hi"}}, "Body\n"},
+        {"unclosed", "---\ntitle: Hi\n", frontMatter{}, "---\ntitle: Hi\n"},
+        {"not at the start", "\n---\ntitle: Hi\n---\n", frontMatter{}, "\n---\ntitle: Hi\n---\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            fm, body := parseFrontMatter([]byte(tt.content))
+            if !reflect.DeepEqual(fm, tt.want) || string(body) != tt.body {
+                t.Errorf("got %q, %q; want %q, %q", fm, body, tt.want, tt.body)
+            }
+        })
+    }
+}
@@ -0,0 +1,69 @@
+package mdserve
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+// The last commit touching a file
+type commitInfo struct {
+    Author string
+    Date   time.Time
+}
+
+// Commit lookups are remembered this long, as committing doesn't touch the file
+const commitInfoTTL = time.Minute
+
+type cachedCommit struct {
+    info    *commitInfo
+    fetched time.Time
+}
+
+var (
+    commitsMu sync.Mutex
+    commits   = map[string]cachedCommit{}
+)
+
+// The last commit of file, or of the .gpg file it was decrypted from, when
+// it lives in a git repository; nil otherwise
+func lastCommit(file string) *commitInfo {
+    commitsMu.Lock()
+    cached, ok := commits[file]
+    commitsMu.Unlock()
+    if ok && time.Since(cached.fetched) < commitInfoTTL {
+        return cached.info
+    }
+
+    info := gitLog(file)
+    if info == nil {
+        if _, err := os.Stat(file + ".gpg"); err == nil {
+            info = gitLog(file + ".gpg")
+        }
+    }
+    commitsMu.Lock()
+    commits[file] = cachedCommit{info: info, fetched: time.Now()}
+    commitsMu.Unlock()
+    return info
+}
+
+func gitLog(file string) *commitInfo {
+    cmd := exec.Command("git", "log", "-1", "--format=%an%x00%aI", "--", filepath.Base(file))
+    cmd.Dir = filepath.Dir(file)
+    out, err := cmd.Output()
+    if err != nil {
+        return nil
+    }
+    author, date, ok := strings.Cut(strings.TrimSpace(string(out)), "\x00")
+    if !ok {
+        return nil
+    }
+    t, err := time.Parse(time.RFC3339, date)
+    if err != nil {
+        return nil
+    }
+    return &commitInfo{Author: author, Date: t}
+}
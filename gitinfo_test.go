@@ -0,0 +1,61 @@
+package mdserve
+
+import (
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestPageMeta(t *testing.T) {
+    s, root := newTestServer(t, map[string]string{"doc.md": "# Doc\n", "new.md": "# New\n"})
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git not installed")
+    }
+    git := func(args ...string) {
+        t.Helper()
+        cmd := exec.Command("git", args...)
+        cmd.Dir = root
+        cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Ann", "GIT_AUTHOR_EMAIL=ann@example.com", "GIT_AUTHOR_DATE=2024-02-01T09:00:00Z",
+            "GIT_COMMITTER_NAME=Ann", "GIT_COMMITTER_EMAIL=ann@example.com", "GIT_COMMITTER_DATE=2024-02-01T09:00:00Z")
+        if out, err := cmd.CombinedOutput(); err != nil {
+            t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+        }
+    }
+    git("init", "-q")
+    git("add", "doc.md")
+    git("commit", "-q", "-m", "Add doc")
+    modified := time.Date(2024, 3, 5, 10, 30, 0, 0, time.Local)
+    if err := os.Chtimes(filepath.Join(root, "doc.md"), modified, modified); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name    string
+        target  string
+        lite    bool
+        want    string
+        notWant string
+    }{
+        {"committed", "/doc.md", false, "Updated 5 Mar 2024 10:30 &middot; last commit by Ann on 1 Feb 2024</p>", ""},
+        {"not committed yet", "/new.md", false, "Updated ", "last commit"},
+        {"lite mode", "/doc.md", true, "Updated 5 Mar 2024 10:30</p>", "last commit"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := s
+            if tt.lite {
+                s = New(WithMounts(Mount{Prefix: "/", Root: root}), WithAuth("admin", "pw"), WithLite())
+            }
+            page := doRequest(s, "GET", tt.target, nil, true).Body.String()
+            if !strings.Contains(page, tt.want) {
+                t.Errorf("%q missing from %q", tt.want, page)
+            }
+            if tt.notWant != "" && strings.Contains(page, tt.notWant) {
+                t.Errorf("%q in %q", tt.notWant, page)
+            }
+        })
+    }
+}
@@ -1,5 +1,14 @@
-module markdown_server
+module github.com/awkto/mdserve
 
-go 1.19
+go 1.22
 
-require github.com/gomarkdown/markdown v0.0.0-20240930133441-72d49d9543d8 // indirect
+require (
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/gomarkdown/markdown v0.0.0-20240930133441-72d49d9543d8
+	go.etcd.io/bbolt v1.3.11
+)
+
+require (
+	golang.org/x/sys v0.4.0
+	golang.org/x/text v0.6.0
+)
@@ -0,0 +1,93 @@
+package mdserve
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// DecryptAll decrypts every .gpg file under each writable mounted root,
+// writing the plaintext next to it. Call it once at startup.
+func (s *Server) DecryptAll() error {
+    for _, st := range s.allSites() {
+        for _, m := range st.mounts {
+            if m.ReadOnly || m.single {
+                continue
+            }
+            if err := s.decryptAllGPGFiles(m.Root); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// Cleanup deletes the plaintext files DecryptAll wrote and those saved
+// encrypted through the server, and nothing else. Call it on shutdown.
+func (s *Server) Cleanup() {
+    s.decryptMu.Lock()
+    defer s.decryptMu.Unlock()
+    for _, file := range s.decrypted {
+        if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+            log.Printf("Failed to delete %s: %v", file, err)
+            continue
+        }
+        log.Printf("Deleted: %s", file)
+    }
+    s.decrypted = nil
+}
+
+// Decrypt all GPG files under root, remembering what was written
+func (s *Server) decryptAllGPGFiles(root string) error {
+    return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if strings.HasSuffix(path, ".gpg") {
+            outputFile := strings.TrimSuffix(path, ".gpg")
+            cmd := exec.Command("gpg", "--batch", "--yes", "--passphrase", s.password,
+                                "-o", outputFile, "-d", path)
+            if err := cmd.Run(); err != nil {
+                return fmt.Errorf("Failed to decrypt %s: %v", path, err)
+            }
+            s.trackPlaintext(outputFile)
+            log.Printf("Decrypted: %s", path)
+        }
+        return nil
+    })
+}
+
+// Remember a plaintext file for Cleanup to delete
+func (s *Server) trackPlaintext(file string) {
+    s.decryptMu.Lock()
+    defer s.decryptMu.Unlock()
+    for _, f := range s.decrypted {
+        if f == file {
+            return
+        }
+    }
+    s.decrypted = append(s.decrypted, file)
+}
+
+// Encrypt a document saved through the server next to it; its plaintext
+// goes on shutdown, as if it had been decrypted, even when there was no
+// .gpg file before
+func (s *Server) encryptSaved(file string) error {
+    if err := encryptFile(file, s.password); err != nil {
+        return err
+    }
+    s.trackPlaintext(file)
+    return nil
+}
+
+// Encrypt a file using GPG
+func encryptFile(file, passphrase string) error {
+    cmd := exec.Command("gpg", "--batch", "--yes", "--passphrase", passphrase, "-c", file)
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("GPG encryption failed: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,83 @@
+package mdserve
+
+import (
+    "net/http"
+    "net/url"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "testing"
+)
+
+func TestCleanupOnlyDeletesDecryptedFiles(t *testing.T) {
+    if _, err := exec.LookPath("gpg"); err != nil {
+        t.Skip("gpg not installed")
+    }
+    t.Setenv("GNUPGHOME", t.TempDir())
+    _, root := newTestServer(t, map[string]string{
+        "notes.md":      "# Plain notes",
+        "secret.md":     "# Secret",
+        "docs/guide.md": "# Guide",
+    })
+    readOnly := t.TempDir()
+    os.WriteFile(filepath.Join(readOnly, "kept.md"), []byte("# Kept"), 0644)
+    for _, file := range []string{filepath.Join(root, "secret.md"), filepath.Join(readOnly, "kept.md")} {
+        if err := encryptFile(file, "pw"); err != nil {
+            t.Fatal(err)
+        }
+        os.Remove(file)
+    }
+
+    s := New(WithMounts(Mount{Prefix: "/", Root: root}, Mount{Prefix: "/ro", Root: readOnly, ReadOnly: true}), WithAuth("admin", "pw"))
+    if err := s.DecryptAll(); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := os.Stat(filepath.Join(root, "secret.md")); err != nil {
+        t.Fatalf("not decrypted: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(readOnly, "kept.md")); err == nil {
+        t.Error("decrypted into a read-only mount")
+    }
+    s.Cleanup()
+
+    for file, want := range map[string]bool{
+        filepath.Join(root, "notes.md"):         true,
+        filepath.Join(root, "docs", "guide.md"): true,
+        filepath.Join(root, "secret.md"):        false,
+        filepath.Join(root, "secret.md.gpg"):    true,
+        filepath.Join(readOnly, "kept.md.gpg"):  true,
+    } {
+        if _, err := os.Stat(file); (err == nil) != want {
+            t.Errorf("%s exists: %v, want %v", file, err == nil, want)
+        }
+    }
+}
+
+// Documents saved through the server lose their plaintext on shutdown,
+// whether or not they were encrypted before
+func TestCleanupDeletesSavedFiles(t *testing.T) {
+    if _, err := exec.LookPath("gpg"); err != nil {
+        t.Skip("gpg not installed")
+    }
+    t.Setenv("GNUPGHOME", t.TempDir())
+    s, root := newTestServer(t, map[string]string{"todo.md": "- [ ] task\n", "notes.md": "# Notes"})
+    if w := doRequest(s, "POST", "/edit/new.md", url.Values{"content": {"# New"}}, true); w.Code != http.StatusSeeOther {
+        t.Fatalf("saving: got %d", w.Code)
+    }
+    if w := doRequest(s, "POST", "/edit/todo.md", url.Values{"task": {"0"}, "checked": {"true"}}, true); w.Code != http.StatusNoContent {
+        t.Fatalf("toggling: got %d", w.Code)
+    }
+    s.Cleanup()
+
+    for file, want := range map[string]bool{
+        "new.md":      false,
+        "new.md.gpg":  true,
+        "todo.md":     false,
+        "todo.md.gpg": true,
+        "notes.md":    true,
+    } {
+        if _, err := os.Stat(filepath.Join(root, file)); (err == nil) != want {
+            t.Errorf("%s exists: %v, want %v", file, err == nil, want)
+        }
+    }
+}
@@ -0,0 +1,299 @@
+package mdserve
+
+import (
+    "html/template"
+    "io"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "strconv"
+    "time"
+)
+
+// Render a markdown file, serving other files as-is
+func (s *Server) viewHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    m, file, urlFile := s.resolveRequest(st, r.URL.Path)
+    if _, err := os.Stat(file); m == nil || os.IsNotExist(err) {
+        // Moved files leave a _redirects rule or an alias behind
+        if to, status := s.redirectFor(st, r.URL.Path); to != "" {
+            http.Redirect(w, r, to, status)
+            return
+        }
+    }
+    // Directories only get an index page at the mount root
+    if info, err := os.Stat(file); m == nil || err == nil && info.IsDir() {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    // Before anything is sent, downloads included
+    if s.isDocument(file) && !s.showDrafts(r) && isDraft(file) {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+
+    // Attachments and downloads get a Content-Disposition, source code the
+    // code viewer; stylesheets, images and other assets, and partial
+    // requests resuming a download, are served as-is
+    if !s.isDocument(file) {
+        if !serveAttachment(w, r, file) && (r.Header.Get("Range") != "" || !s.codeHandler(w, r, st, file, urlFile)) {
+            serveAsset(w, r, file)
+        }
+        return
+    }
+
+    if wantsDownload(r) {
+        serveAttachment(w, r, file)
+        return
+    }
+    if info, err := os.Stat(file); err == nil && s.maxFileSize > 0 && info.Size() > s.maxFileSize {
+        s.largeHandler(w, r, st, file, urlFile, info.Size())
+        return
+    }
+
+    // Large documents are streamed: the page is sent as it is rendered
+    stream := s.streamed(file)
+    var page *renderedPage
+    var body []byte // Markdown left to render while streaming
+    var err error
+    if stream {
+        // The slot is held until the whole page is sent
+        if err = s.acquireRender(); err == nil {
+            defer s.releaseRender()
+            var content []byte
+            if content, err = ioutil.ReadFile(file); err == nil {
+                page = &renderedPage{}
+                page.FrontMatter, body = parseFrontMatter(content)
+                page.TOC = s.streamedHeadings(page.FrontMatter, body)
+            }
+        }
+    } else {
+        page, err = s.renderFile(file)
+    }
+    if err == errBusy {
+        w.Header().Set("Retry-After", "5")
+        http.Error(w, "Busy rendering other documents, try again shortly", http.StatusServiceUnavailable)
+        return
+    }
+    if os.IsNotExist(err) && path.Clean("/"+r.URL.Path) == m.Prefix {
+        s.indexHandler(w, r, st, m)
+        return
+    }
+    if err != nil {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+
+    fm := page.FrontMatter
+    draft, _ := strconv.ParseBool(fm.Get("draft"))
+    if draft && !s.showDrafts(r) {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    theme := s.themeFor(st, fm)
+
+    data := struct {
+        Base        string
+        File        string
+        Title       string
+        Theme       string
+        BaseCSS     template.CSS
+        ThemeCSS    template.CSS
+        BaseJS      template.JS
+        CSS         []string
+        Editable    bool
+        Draft       bool
+        Lang        string        // Language of the document, "" when unknown
+        Languages   []translation // Language versions, for the switcher
+        Dir         string        // Writing direction, rtl or ltr
+        TOCPosition string
+        TOC         template.HTML
+        HTMLContent template.HTML
+        Modified    time.Time
+        Commit      *commitInfo // nil outside git repositories
+        Commentable bool
+        Comments    []Comment
+        Headings    []tocEntry        // Sections that can be commented on
+        Sections    map[string]string // Heading text by id
+        Data        map[string]interface{}
+        Live        bool // Reload when the file changes, through /api/events
+    }{
+        Base:        s.basePath,
+        File:        urlFile,
+        Title:       fm.Get("title"),
+        Theme:       theme,
+        BaseCSS:     template.CSS(baseCSS),
+        ThemeCSS:    template.CSS(Themes[theme]),
+        BaseJS:      s.pageJS(),
+        CSS:         fm["css"],
+        Editable:    !m.ReadOnly && s.checkCredentials(st, r),
+        Draft:       draft,
+        Lang:        fm.Get("lang"),
+        TOCPosition: s.tocPositionFor(fm),
+        Data:        s.pageData(r, file),
+        Live:        s.trees.isWatched() && !s.lite,
+    }
+    content, toc := string(page.HTML), page.TOC
+    var numbers map[string]string // For the streamed chunks
+    if numbered, err := strconv.ParseBool(fm.Get("number_sections")); numbered || err != nil && s.numberSections {
+        numbers = sectionNumbers(toc)
+        content, toc = numberSections(content, toc)
+    }
+    if s.debug {
+        content = s.markBrokenLinks(content, st, file)
+    }
+    data.HTMLContent = template.HTML(s.processImages(content, file, urlFile))
+    if stream && data.TOCPosition != "none" && data.BaseJS != "" {
+        data.TOC = deferredTOC(fm, s.tocLevels[0], s.tocLevels[1])
+    } else if data.TOCPosition != "none" {
+        data.TOC = renderTOC(filterTOC(toc, fm, s.tocLevels[0], s.tocLevels[1]))
+    }
+    if info, err := os.Stat(file); err == nil {
+        data.Modified = info.ModTime()
+    }
+    if !s.lite {
+        data.Commit = lastCommit(file)
+    }
+
+    if !s.lite {
+        data.Languages = s.translations(m, file, urlFile)
+        for _, t := range data.Languages {
+            if t.Current {
+                data.Lang = t.Lang
+            }
+        }
+    }
+
+    if stream {
+        data.Dir = textDirection(fm.Get("dir"), data.Lang, body)
+    } else {
+        data.Dir = textDirection(fm.Get("dir"), data.Lang, htmlTag.ReplaceAll(page.HTML, nil))
+    }
+
+    if s.commentsEnabled(st, m) {
+        data.Commentable = true
+        data.Headings = toc
+        data.Sections = map[string]string{}
+        for _, h := range toc {
+            data.Sections[h.ID] = h.Text
+        }
+        if data.Comments, err = s.comments(st, urlFile); err != nil {
+            log.Printf("Comments: %v", err)
+        }
+    }
+
+    if s.stats {
+        s.countView(st, urlFile)
+    }
+
+    if stream {
+        data.HTMLContent = streamMarker
+        s.streamTemplate(w, r, "view.html", data, func(w io.Writer) {
+            s.streamMarkdown(w, st, file, urlFile, fm, body, numbers)
+        })
+        return
+    }
+    s.executeTemplate(w, r, "view.html", data)
+}
+
+// Explain that a document is over Config.MaxFileSize instead of spending
+// minutes rendering it, and link the raw file
+func (s *Server) largeHandler(w http.ResponseWriter, r *http.Request, st *site, file, urlFile string, size int64) {
+    theme := s.themeFor(st, nil)
+    data := struct {
+        Base     string
+        Theme    string
+        BaseCSS  template.CSS
+        ThemeCSS template.CSS
+        File     string
+        Name     string
+        Size     string
+        Limit    string
+        Data     map[string]interface{}
+    }{
+        Base:     s.basePath,
+        Theme:    theme,
+        BaseCSS:  template.CSS(baseCSS),
+        ThemeCSS: template.CSS(Themes[theme]),
+        File:     urlFile,
+        Name:     filepath.Base(file),
+        Size:     sizeText(size),
+        Limit:    sizeText(s.maxFileSize),
+        Data:     s.pageData(r, file),
+    }
+    w.WriteHeader(http.StatusRequestEntityTooLarge)
+    s.executeTemplate(w, r, "large.html", data)
+}
+
+// Edit a markdown file and save it back encrypted
+func (s *Server) editHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    if r.URL.Path == "/edit/" {
+        http.Error(w, "File not specified", http.StatusBadRequest)
+        return
+    }
+
+    m, file, urlFile := s.resolveRequest(st, r.URL.Path[len("/edit/"):])
+    if m == nil {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    if m.ReadOnly {
+        http.Error(w, "This tree is read-only", http.StatusForbidden)
+        return
+    }
+    // Public sites are public to read, not to change
+    if !s.checkCredentials(st, r) {
+        w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+        http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+        return
+    }
+
+    if r.Method == http.MethodPost && r.FormValue("task") != "" {
+        s.taskHandler(w, r, file)
+        return
+    }
+    if r.Method == http.MethodPost {
+        newContent := r.FormValue("content")
+        // Not in the middle of a task toggle's read-modify-write
+        s.writeMu.Lock()
+        defer s.writeMu.Unlock()
+        err := ioutil.WriteFile(file, []byte(newContent), 0644)
+        if err != nil {
+            http.Error(w, "Could not save file", http.StatusInternalServerError)
+            return
+        }
+
+        // Encrypt the file after saving
+        err = s.encryptSaved(file)
+        if err != nil {
+            log.Printf("Encryption error: %v", err)
+            http.Error(w, "Encryption failed", http.StatusInternalServerError)
+            return
+        }
+
+        http.Redirect(w, r, s.basePath+"/"+urlFile, http.StatusSeeOther)
+        return
+    }
+
+    content, err := ioutil.ReadFile(file)
+    if err != nil {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+
+    data := struct {
+        Base       string
+        File       string
+        RawContent string
+        Data       map[string]interface{}
+    }{
+        Base:       s.basePath,
+        File:       urlFile,
+        RawContent: string(content),
+        Data:       s.pageData(r, file),
+    }
+
+    s.executeTemplate(w, r, "edit.html", data)
+}
@@ -0,0 +1,87 @@
+package mdserve
+
+import (
+    "bytes"
+    "image"
+    "image/png"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestServingFilesAsTheyAre(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "index.md":          "# Home",
+        "sub/a.md":          "# A",
+        "sub/.hidden/h.txt": "hidden",
+        ".secret.key":       "pw",
+        ".mdserveignore":    "private\n",
+        "private/p.txt":     "private",
+        "node_modules/x.js": "js",
+        "pic.png":           "png",
+    })
+    tests := []struct {
+        path   string
+        status int
+    }{
+        {"/", http.StatusOK},
+        {"/sub/a.md", http.StatusOK},
+        {"/pic.png", http.StatusOK},
+        {"/sub/", http.StatusNotFound},
+        {"/sub", http.StatusNotFound},
+        {"/.secret.key", http.StatusNotFound},
+        {"/.mdserveignore", http.StatusNotFound},
+        {"/sub/.hidden/h.txt", http.StatusNotFound},
+        {"/private/p.txt", http.StatusNotFound},
+        {"/node_modules/x.js", http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        if w := doRequest(s, "GET", tt.path, nil, true); w.Code != tt.status {
+            t.Errorf("GET %s: got %d, want %d", tt.path, w.Code, tt.status)
+        }
+    }
+}
+
+// Every handler taking a file from the URL refuses hidden and ignored ones
+func TestHandlersRefuseConcealedFiles(t *testing.T) {
+    var pic bytes.Buffer
+    png.Encode(&pic, image.NewRGBA(image.Rect(0, 0, 300, 100)))
+    s, root := newTestServer(t, map[string]string{
+        "doc.md":              "# Doc",
+        "pic.png":             pic.String(),
+        ".hidden/x.png":       pic.String(),
+        ".hidden/doc.md":      "# Hidden",
+        "node_modules/y.png":  pic.String(),
+        "node_modules/doc.md": "# Ignored",
+        ".notes.md":           "# Hidden",
+    })
+    tests := []struct {
+        method string
+        target string
+        form   url.Values
+        status int
+    }{
+        {"GET", "/img/pic.png?w=100", nil, http.StatusOK},
+        {"GET", "/img/.hidden/x.png?w=100", nil, http.StatusNotFound},
+        {"GET", "/img/node_modules/y.png", nil, http.StatusNotFound},
+        {"GET", "/edit/doc.md", nil, http.StatusOK},
+        {"GET", "/edit/.hidden/doc.md", nil, http.StatusNotFound},
+        {"GET", "/edit/node_modules/doc.md", nil, http.StatusNotFound},
+        {"GET", "/edit/.notes.md", nil, http.StatusNotFound},
+        {"POST", "/edit/.hidden/new.md", url.Values{"content": {"# New"}}, http.StatusNotFound},
+        {"GET", "/oembed?url=/.notes.md", nil, http.StatusNotFound},
+        {"GET", "/oembed?url=/node_modules/doc.md", nil, http.StatusNotFound},
+        {"POST", "/comments/.notes.md", url.Values{"text": {"hi"}}, http.StatusNotFound},
+        {"GET", "/zip/.hidden", nil, http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        if w := doRequest(s, tt.method, tt.target, tt.form, true); w.Code != tt.status {
+            t.Errorf("%s %s: got %d, want %d", tt.method, tt.target, w.Code, tt.status)
+        }
+    }
+    if _, err := os.Stat(filepath.Join(root, ".hidden", "new.md")); err == nil {
+        t.Error("created a hidden document")
+    }
+}
@@ -0,0 +1,116 @@
+package mdserve
+
+import (
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Entries in each list of the content overview
+const healthListSize = 10
+
+// One document in the content overview
+type docHealth struct {
+    Path        string
+    Title       string // "" when the document has none
+    Name        string
+    Size        int64
+    Words       int
+    Updated     time.Time // Last commit, or modification time outside git
+    BrokenLinks int
+}
+
+// The state of a site's documents, for maintainers
+type contentHealth struct {
+    Documents   int
+    Words       int
+    BrokenLinks int
+    Largest     []docHealth
+    Stalest     []docHealth
+    Untitled    []docHealth
+    Broken      []docHealth // Documents with broken links, most first
+}
+
+// Summarise a site's documents: totals, the largest and least recently
+// updated, those without a title and those with broken links
+func (s *Server) contentHealth(st *site, r *http.Request) (*contentHealth, error) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        return nil, err
+    }
+    problems, err := s.siteLinkProblems(st, r, nil)
+    if err != nil {
+        return nil, err
+    }
+    broken := map[string]int{}
+    for _, p := range problems {
+        broken[p.Path]++
+    }
+
+    h := &contentHealth{BrokenLinks: len(problems)}
+    var all []docHealth
+    for _, doc := range docs {
+        info, err := os.Stat(doc.File)
+        if err != nil {
+            continue
+        }
+        content, err := ioutil.ReadFile(doc.File)
+        if err != nil {
+            continue
+        }
+        fm, body := parseFrontMatter(content)
+        d := docHealth{
+            Path:        doc.Path,
+            Title:       documentTitle(fm, string(body)),
+            Name:        doc.Name,
+            Size:        info.Size(),
+            Words:       len(strings.Fields(string(body))),
+            Updated:     info.ModTime(),
+            BrokenLinks: broken[doc.Path],
+        }
+        if !s.lite {
+            if c := lastCommit(doc.File); c != nil {
+                d.Updated = c.Date
+            }
+        }
+        h.Documents++
+        h.Words += d.Words
+        if d.Title == "" {
+            h.Untitled = append(h.Untitled, d)
+        }
+        if d.BrokenLinks > 0 {
+            h.Broken = append(h.Broken, d)
+        }
+        all = append(all, d)
+    }
+
+    top := func(less func(a, b docHealth) bool) []docHealth {
+        list := append([]docHealth(nil), all...)
+        sort.SliceStable(list, func(i, j int) bool { return less(list[i], list[j]) })
+        return list[:min(len(list), healthListSize)]
+    }
+    h.Largest = top(func(a, b docHealth) bool { return a.Size > b.Size })
+    h.Stalest = top(func(a, b docHealth) bool { return a.Updated.Before(b.Updated) })
+    sort.SliceStable(h.Broken, func(i, j int) bool { return h.Broken[i].BrokenLinks > h.Broken[j].BrokenLinks })
+    return h, nil
+}
+
+// The size for people: 812 B, 14.2 KB, 3.1 MB
+func (d docHealth) SizeText() string {
+    return sizeText(d.Size)
+}
+
+// A byte count for people: 812 B, 14.2 KB, 3.1 MB
+func sizeText(n int64) string {
+    switch {
+    case n < 1<<10:
+        return fmt.Sprintf("%d B", n)
+    case n < 1<<20:
+        return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+    }
+    return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,69 @@
+package mdserve
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestContentHealth(t *testing.T) {
+    s, root := newTestServer(t, map[string]string{
+        "big.md":   "# Big\n\n" + strings.Repeat("word ", 300) + "\n",
+        "old.md":   "---\ntitle: Old one\n---\nSee [gone](gone.md) and [away](#away).\n",
+        "notes.md": "Just some notes.\n",
+        "draft.md": "---\ndraft: true\n---\n# Draft\n",
+    })
+    for name, age := range map[string]time.Duration{"big.md": time.Hour, "old.md": 1000 * time.Hour, "notes.md": 10 * time.Hour} {
+        at := time.Now().Add(-age)
+        if err := os.Chtimes(filepath.Join(root, name), at, at); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    h, err := s.contentHealth(s.defaultSite, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    paths := func(docs []docHealth) string {
+        var p []string
+        for _, d := range docs {
+            p = append(p, d.Path)
+        }
+        return strings.Join(p, " ")
+    }
+    if h.Documents != 3 || h.Words != 2+300+4+3 || h.BrokenLinks != 2 {
+        t.Errorf("got %d documents, %d words, %d broken links", h.Documents, h.Words, h.BrokenLinks)
+    }
+    for name, tt := range map[string]struct{ got, want string }{
+        "largest":  {paths(h.Largest), "big.md old.md notes.md"},
+        "stalest":  {paths(h.Stalest), "old.md notes.md big.md"},
+        "untitled": {paths(h.Untitled), "notes.md"},
+        "broken":   {paths(h.Broken), "old.md"},
+    } {
+        if tt.got != tt.want {
+            t.Errorf("%s: got %q, want %q", name, tt.got, tt.want)
+        }
+    }
+
+    page := doRequest(s, "GET", "/stats", nil, true).Body.String()
+    for _, want := range []string{
+        "<p>3 documents, 309 words &middot; 2 broken links</p>",
+        `<tr><td><a href="/big.md">Big</a></td><td>1.5 KB</td><td>302</td></tr>`,
+        `<li><a href="/notes.md">notes.md</a></li>`,
+        `<tr><td><a href="/old.md">Old one</a></td><td>2</td></tr>`,
+    } {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing from %q", want, page)
+        }
+    }
+}
+
+func TestSizeText(t *testing.T) {
+    for n, want := range map[int64]string{0: "0 B", 812: "812 B", 1024: "1.0 KB", 14540: "14.2 KB", 3 << 20: "3.0 MB"} {
+        if got := sizeText(n); got != want {
+            t.Errorf("sizeText(%d) = %q, want %q", n, got, want)
+        }
+    }
+}
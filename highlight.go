@@ -0,0 +1,227 @@
+package mdserve
+
+import (
+    "html"
+    "html/template"
+    "path/filepath"
+    "strings"
+    "unicode"
+)
+
+// Lexical rules of a language, enough to colour keywords, strings,
+// numbers and comments
+type language struct {
+    Name         string
+    LineComment  []string
+    BlockComment [2]string
+    Quotes       string // Characters that delimit strings
+    Multiline    string // Quotes whose strings may span lines
+    Keywords     map[string]bool
+    Keys         string // For data files: the separator after a key, ":" or "="
+    Fold         string // Collapsible structure: "indent", "sections" or "diff"
+    LineClass    func(line string) string // Colours whole lines instead of tokens
+}
+
+func keywords(list string) map[string]bool {
+    set := map[string]bool{}
+    for _, w := range strings.Fields(list) {
+        set[w] = true
+    }
+    return set
+}
+
+var (
+    cLike      = keywords(`if else for while do switch case default break continue return goto struct union enum typedef
+        static const extern void int char short long float double unsigned signed sizeof class public private protected
+        virtual new delete this namespace using template typename try catch throw true false nullptr NULL bool auto`)
+    yamlWords  = keywords(`true false null yes no on off`)
+    shellWords = keywords(`if then else elif fi for while until do done case esac in function return local export
+        readonly set unset shift exit echo source alias true false`)
+)
+
+// Languages keyed by file extension or, for files like Makefile, base name
+var languages = map[string]*language{
+    ".go": {Name: "Go", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: "\"'`", Multiline: "`",
+        Keywords: keywords(`break case chan const continue default defer else fallthrough for func go goto if import
+            interface map package range return select struct switch type var true false nil iota`)},
+    ".py": {Name: "Python", LineComment: []string{"#"}, Quotes: `"'`,
+        Keywords: keywords(`and as assert async await break class continue def del elif else except finally for from
+            global if import in is lambda nonlocal not or pass raise return try while with yield True False None self`)},
+    ".sh":   {Name: "Shell", LineComment: []string{"#"}, Quotes: `"'`, Multiline: `"'`, Keywords: shellWords},
+    ".bash": {Name: "Shell", LineComment: []string{"#"}, Quotes: `"'`, Multiline: `"'`, Keywords: shellWords},
+    ".js": {Name: "JavaScript", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: "\"'`", Multiline: "`",
+        Keywords: keywords(`break case catch class const continue debugger default delete do else export extends
+            finally for function if import in instanceof let new return super switch this throw try typeof var void
+            while with yield async await of true false null undefined`)},
+    ".ts": {Name: "TypeScript", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: "\"'`", Multiline: "`",
+        Keywords: keywords(`break case catch class const continue default delete do else enum export extends finally
+            for function if implements import in instanceof interface let new private protected public readonly return
+            super switch this throw try type typeof var void while async await of true false null undefined`)},
+    ".rb": {Name: "Ruby", LineComment: []string{"#"}, Quotes: `"'`,
+        Keywords: keywords(`alias and begin break case class def defined? do else elsif end ensure false for if in
+            module next nil not or redo rescue retry return self super then true undef unless until when while yield`)},
+    ".rs": {Name: "Rust", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: `"`,
+        Keywords: keywords(`as break const continue crate else enum extern false fn for if impl in let loop match mod
+            move mut pub ref return self Self static struct super trait true type unsafe use where while async await dyn`)},
+    ".c":    {Name: "C", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: `"'`, Keywords: cLike},
+    ".h":    {Name: "C", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: `"'`, Keywords: cLike},
+    ".cpp":  {Name: "C++", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: `"'`, Keywords: cLike},
+    ".java": {Name: "Java", LineComment: []string{"//"}, BlockComment: [2]string{"/*", "*/"}, Quotes: `"'`, Keywords: cLike},
+    ".sql": {Name: "SQL", LineComment: []string{"--"}, BlockComment: [2]string{"/*", "*/"}, Quotes: `'"`,
+        Keywords: keywords(`select from where and or not insert into values update set delete create table drop alter
+            index join left right inner outer on group by order having limit as null is in like primary key references
+            SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER INDEX JOIN LEFT
+            RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AS NULL IS IN LIKE PRIMARY KEY REFERENCES`)},
+    ".yaml": {Name: "YAML", LineComment: []string{"#"}, Quotes: `"'`, Keywords: yamlWords, Keys: ":", Fold: "indent"},
+    ".yml":  {Name: "YAML", LineComment: []string{"#"}, Quotes: `"'`, Keywords: yamlWords, Keys: ":", Fold: "indent"},
+    ".json": {Name: "JSON", Quotes: `"`, Keywords: keywords(`true false null`), Keys: ":", Fold: "indent"},
+    ".toml": {Name: "TOML", LineComment: []string{"#"}, Quotes: `"'`, Keywords: keywords(`true false inf nan`), Keys: "=", Fold: "sections"},
+    ".ini":  {Name: "INI", LineComment: []string{";", "#"}, Quotes: `"`, Keywords: keywords(`true false`), Keys: "=", Fold: "sections"},
+    ".txt":   {Name: "Text"},
+    ".diff":  {Name: "Diff", LineClass: diffLineClass, Fold: "diff"},
+    ".patch": {Name: "Diff", LineClass: diffLineClass, Fold: "diff"},
+    "Makefile": {Name: "Makefile", LineComment: []string{"#"}, Quotes: `"'`,
+        Keywords: keywords(`ifeq ifneq ifdef ifndef else endif include define endef export override`)},
+    "Dockerfile": {Name: "Dockerfile", LineComment: []string{"#"}, Quotes: `"'`,
+        Keywords: keywords(`FROM RUN CMD LABEL EXPOSE ENV ADD COPY ENTRYPOINT VOLUME USER WORKDIR ARG ONBUILD
+            STOPSIGNAL HEALTHCHECK SHELL AS`)},
+}
+
+// The language of a source file, or nil
+func languageFor(file string) *language {
+    if lang, ok := languages[filepath.Base(file)]; ok {
+        return lang
+    }
+    return languages[strings.ToLower(filepath.Ext(file))]
+}
+
+// Highlight source code, returning one HTML fragment per line. Tokens that
+// span lines are split so every line stands on its own.
+func highlight(src string, lang *language) []template.HTML {
+    var lines []template.HTML
+    var line strings.Builder
+    emit := func(class, text string) {
+        for i, part := range strings.Split(text, "\n") {
+            if i > 0 {
+                lines = append(lines, template.HTML(line.String()))
+                line.Reset()
+            }
+            if part == "" {
+                continue
+            }
+            if class == "" {
+                line.WriteString(html.EscapeString(part))
+            } else {
+                line.WriteString(`<span class="tok-` + class + `">` + html.EscapeString(part) + "</span>")
+            }
+        }
+    }
+
+    src = strings.TrimSuffix(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+    if lang.LineClass != nil {
+        for _, l := range strings.Split(src, "\n") {
+            emit(lang.LineClass(l), l)
+            emit("", "\n")
+        }
+        return lines
+    }
+    for i := 0; i < len(src); {
+        rest := src[i:]
+        if lang.BlockComment[0] != "" && strings.HasPrefix(rest, lang.BlockComment[0]) {
+            end := strings.Index(rest[len(lang.BlockComment[0]):], lang.BlockComment[1])
+            n := len(rest)
+            if end >= 0 {
+                n = len(lang.BlockComment[0]) + end + len(lang.BlockComment[1])
+            }
+            emit("c", rest[:n])
+            i += n
+            continue
+        }
+        if hasAnyPrefix(rest, lang.LineComment) && (i == 0 || lang.LineComment[0] != "#" || !isIdentByte(src[i-1])) {
+            n := strings.IndexByte(rest, '\n')
+            if n < 0 {
+                n = len(rest)
+            }
+            emit("c", rest[:n])
+            i += n
+            continue
+        }
+        c := rest[0]
+        if strings.IndexByte(lang.Quotes, c) >= 0 {
+            n := 1
+            for n < len(rest) && rest[n] != c {
+                if rest[n] == '\\' && c != '`' {
+                    n++
+                } else if rest[n] == '\n' && strings.IndexByte(lang.Multiline, c) < 0 {
+                    break
+                }
+                n++
+            }
+            n = min(n+1, len(rest))
+            if lang.isKey(rest[n:]) {
+                emit("a", rest[:n])
+            } else {
+                emit("s", rest[:n])
+            }
+            i += n
+            continue
+        }
+        if isIdentByte(c) {
+            n := 1
+            for n < len(rest) && (isIdentByte(rest[n]) || rest[n] == '.' && c >= '0' && c <= '9' ||
+                lang.Keys != "" && (rest[n] == '-' || rest[n] == '.')) {
+                n++
+            }
+            word := rest[:n]
+            switch {
+            case lang.isKey(rest[n:]):
+                emit("a", word)
+            case c >= '0' && c <= '9':
+                emit("n", word)
+            case lang.Keywords[word]:
+                emit("k", word)
+            default:
+                emit("", word)
+            }
+            i += n
+            continue
+        }
+        emit("", rest[:1])
+        i++
+    }
+    return append(lines, template.HTML(line.String()))
+}
+
+// Unified diff lines: file headers, hunk headers, insertions and deletions
+func diffLineClass(line string) string {
+    switch {
+    case strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index ") ||
+        strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "):
+        return "meta"
+    case strings.HasPrefix(line, "@@"):
+        return "hunk"
+    case strings.HasPrefix(line, "+"):
+        return "ins"
+    case strings.HasPrefix(line, "-"):
+        return "del"
+    }
+    return ""
+}
+
+// Whether the text following a token makes it a key in a data file
+func (lang *language) isKey(after string) bool {
+    return lang.Keys != "" && strings.HasPrefix(strings.TrimLeft(after, " \t"), lang.Keys)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+    for _, p := range prefixes {
+        if strings.HasPrefix(s, p) {
+            return true
+        }
+    }
+    return false
+}
+
+func isIdentByte(c byte) bool {
+    return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
@@ -0,0 +1,50 @@
+package mdserve
+
+import (
+    "net/http"
+)
+
+// Middleware wraps the Server's handler, e.g. for logging or extra auth
+type Middleware func(http.Handler) http.Handler
+
+// Hook points for embedders and plugins. Register them before serving;
+// hooks of the same kind run in registration order.
+type hooks struct {
+    middleware   []Middleware
+    onRequest    []func(w http.ResponseWriter, r *http.Request) bool
+    beforeRender []func(file string, src []byte) []byte
+    afterRender  []func(file string, html []byte) []byte
+    onIndex      []func(r *http.Request, entries []IndexEntry) []IndexEntry
+    onReload     []func() error
+}
+
+// Use wraps the Server in middleware; the first registered runs outermost
+func (s *Server) Use(mw ...Middleware) {
+    s.hooks.middleware = append(s.hooks.middleware, mw...)
+    var h http.Handler = http.HandlerFunc(s.serve)
+    for i := len(s.hooks.middleware) - 1; i >= 0; i-- {
+        h = s.hooks.middleware[i](h)
+    }
+    s.handler = h
+}
+
+// OnRequest runs fn after authentication and before routing. Returning
+// true means fn wrote the response and normal handling is skipped.
+func (s *Server) OnRequest(fn func(w http.ResponseWriter, r *http.Request) bool) {
+    s.hooks.onRequest = append(s.hooks.onRequest, fn)
+}
+
+// BeforeRender lets fn rewrite a document's markdown (without frontmatter)
+func (s *Server) BeforeRender(fn func(file string, src []byte) []byte) {
+    s.hooks.beforeRender = append(s.hooks.beforeRender, fn)
+}
+
+// AfterRender lets fn rewrite a document's rendered HTML
+func (s *Server) AfterRender(fn func(file string, html []byte) []byte) {
+    s.hooks.afterRender = append(s.hooks.afterRender, fn)
+}
+
+// OnIndex lets fn filter or reorder the entries of a generated index page
+func (s *Server) OnIndex(fn func(r *http.Request, entries []IndexEntry) []IndexEntry) {
+    s.hooks.onIndex = append(s.hooks.onIndex, fn)
+}
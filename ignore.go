@@ -0,0 +1,71 @@
+package mdserve
+
+import (
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// Directories left out of listings, search and watching unless
+// Config.Ignore keeps them with "!name": version control, dependencies
+// and build output
+var DefaultIgnore = []string{".git", "node_modules", "vendor", "dist", "build", "target", "_site", "__pycache__"}
+
+// The ignore patterns in effect: the defaults without those negated,
+// then the extra ones
+func ignorePatterns(extra []string) []string {
+    keep := map[string]bool{}
+    for _, p := range extra {
+        if strings.HasPrefix(p, "!") {
+            keep[p[1:]] = true
+        }
+    }
+    var patterns []string
+    for _, p := range DefaultIgnore {
+        if !keep[p] {
+            patterns = append(patterns, p)
+        }
+    }
+    for _, p := range extra {
+        if p != "" && !strings.HasPrefix(p, "!") {
+            patterns = append(patterns, p)
+        }
+    }
+    return patterns
+}
+
+// Whether a file or directory under root is left out of listings: by a
+// pattern, a glob on the name or on the path from root when it contains
+// "/", or by an ignore file in the tree
+func (s *Server) ignored(root, file string, dir bool) bool {
+    rel, err := filepath.Rel(root, file)
+    if err != nil || rel == "." {
+        return false
+    }
+    rel = filepath.ToSlash(rel)
+    for _, p := range s.ignore {
+        target := path.Base(rel)
+        if strings.Contains(p, "/") {
+            target = rel
+        }
+        if ok, _ := path.Match(strings.TrimPrefix(p, "/"), target); ok {
+            return true
+        }
+    }
+    return s.ignoreFiles.excludes(root, file, dir)
+}
+
+// The innermost served root a file is under, "" when none
+func (s *Server) rootOf(file string) string {
+    found := ""
+    for _, root := range s.Roots() {
+        rel, err := filepath.Rel(root, file)
+        if err != nil || rel == ".." || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+            continue
+        }
+        if found == "" || len(root) > len(found) {
+            found = root
+        }
+    }
+    return found
+}
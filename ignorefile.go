@@ -0,0 +1,221 @@
+package mdserve
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "sync"
+    "time"
+)
+
+// In-tree ignore files: .mdserveignore always, .gitignore with
+// Config.GitIgnore. Their rules apply to their directory and below.
+const mdserveIgnoreFile = ".mdserveignore"
+
+// How long a read ignore file is trusted before checking it again, so
+// listing a large tree doesn't stat every directory's files per document
+const ignoreRecheck = 2 * time.Second
+
+// One line of an ignore file, in .gitignore syntax
+type ignoreRule struct {
+    pattern *regexp.Regexp // Matches the path from the ignore file's directory
+    negate  bool           // !pattern: include again
+    dirOnly bool           // pattern/: directories only
+}
+
+// The rules of each directory's ignore files, keyed by directory
+type ignoreFiles struct {
+    git  bool
+    mu   sync.Mutex
+    dirs map[string]*ignoreDir
+}
+
+type ignoreDir struct {
+    checked time.Time
+    stamps  string // Modification times and sizes of the files read
+    rules   []ignoreRule
+}
+
+// Whether a file or directory under root is excluded by an ignore file in
+// root or a directory between root and it. As in git, nothing under an
+// excluded directory can be included again.
+func (c *ignoreFiles) excludes(root, file string, dir bool) bool {
+    if c == nil {
+        return false
+    }
+    rel, err := filepath.Rel(root, file)
+    if err != nil || rel == "." || rel == ".." || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+        return false
+    }
+    parts := strings.Split(filepath.ToSlash(rel), "/")
+    type layer struct {
+        base  string
+        rules []ignoreRule
+    }
+    var layers []layer
+    at := root
+    for i := range parts {
+        layers = append(layers, layer{strings.Join(parts[:i], "/"), c.rulesIn(at)})
+        target, isDir := strings.Join(parts[:i+1], "/"), dir || i < len(parts)-1
+        excluded := false
+        for _, l := range layers {
+            p := strings.TrimPrefix(target, l.base+"/")
+            for _, r := range l.rules {
+                if (!r.dirOnly || isDir) && r.pattern.MatchString(p) {
+                    excluded = !r.negate
+                }
+            }
+        }
+        if excluded {
+            return true
+        }
+        at = filepath.Join(at, parts[i])
+    }
+    return false
+}
+
+// The rules of a directory's ignore files, .gitignore first so
+// .mdserveignore can override it
+func (c *ignoreFiles) rulesIn(dir string) []ignoreRule {
+    c.mu.Lock()
+    d := c.dirs[dir]
+    c.mu.Unlock()
+    if d != nil && time.Since(d.checked) < ignoreRecheck {
+        return d.rules
+    }
+
+    names := []string{mdserveIgnoreFile}
+    if c.git {
+        names = []string{".gitignore", mdserveIgnoreFile}
+    }
+    var stamps strings.Builder
+    for _, name := range names {
+        if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+            fmt.Fprintf(&stamps, "%s %v %d", name, info.ModTime(), info.Size())
+        }
+        stamps.WriteByte(';')
+    }
+    if d == nil || d.stamps != stamps.String() {
+        var rules []ignoreRule
+        for _, name := range names {
+            if data, err := ioutil.ReadFile(filepath.Join(dir, name)); err == nil {
+                rules = append(rules, parseIgnoreFile(data)...)
+            }
+        }
+        d = &ignoreDir{stamps: stamps.String(), rules: rules}
+    } else {
+        d = &ignoreDir{stamps: d.stamps, rules: d.rules}
+    }
+    d.checked = time.Now()
+
+    c.mu.Lock()
+    if c.dirs == nil {
+        c.dirs = map[string]*ignoreDir{}
+    }
+    c.dirs[dir] = d
+    c.mu.Unlock()
+    return d.rules
+}
+
+// Read an ignore file again on next use, after it changed
+func (c *ignoreFiles) forget(dir string) {
+    c.mu.Lock()
+    delete(c.dirs, dir)
+    c.mu.Unlock()
+}
+
+// Read every ignore file again on next use
+func (c *ignoreFiles) forgetAll() {
+    c.mu.Lock()
+    c.dirs = nil
+    c.mu.Unlock()
+}
+
+// Whether a file name is that of an ignore file
+func isIgnoreFile(name string) bool {
+    return name == mdserveIgnoreFile || name == ".gitignore"
+}
+
+// Parse the lines of an ignore file: globs with * ? [...] and **, a
+// leading ! to include again, a trailing / for directories only. Patterns
+// with a / before their end are relative to the file's directory, others
+// match at any depth.
+func parseIgnoreFile(data []byte) []ignoreRule {
+    var rules []ignoreRule
+    for _, line := range bytes.Split(data, []byte("\n")) {
+        p := strings.TrimSuffix(string(line), "\r")
+        if !strings.HasSuffix(p, `\ `) {
+            p = strings.TrimRight(p, " ")
+        }
+        if p == "" || strings.HasPrefix(p, "#") {
+            continue
+        }
+        var r ignoreRule
+        if strings.HasPrefix(p, "!") {
+            r.negate, p = true, p[1:]
+        }
+        p = strings.TrimPrefix(p, `\`) // \# and \! start literally
+        if strings.HasSuffix(p, "/") {
+            r.dirOnly, p = true, strings.TrimRight(p, "/")
+        }
+        if p == "" {
+            continue
+        }
+        anchored := strings.Contains(p, "/")
+        p = strings.TrimPrefix(p, "/")
+        expr := globRegexp(p)
+        if !anchored {
+            expr = "(?:.*/)?" + expr
+        }
+        if re, err := regexp.Compile("^" + expr + "$"); err == nil {
+            r.pattern = re
+            rules = append(rules, r)
+        }
+    }
+    return rules
+}
+
+// Translate a gitignore glob into a regular expression
+func globRegexp(glob string) string {
+    var b strings.Builder
+    for i := 0; i < len(glob); i++ {
+        c := glob[i]
+        switch {
+        case strings.HasPrefix(glob[i:], "**/"):
+            b.WriteString("(?:.*/)?")
+            i += 2
+        case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+            b.WriteString("/.*")
+            i += 2
+        case strings.HasPrefix(glob[i:], "**"):
+            b.WriteString(".*")
+            i++
+        case c == '*':
+            b.WriteString("[^/]*")
+        case c == '?':
+            b.WriteString("[^/]")
+        case c == '[':
+            end := strings.IndexByte(glob[i+1:], ']')
+            if end < 0 {
+                b.WriteString(`\[`)
+                continue
+            }
+            class := glob[i+1 : i+1+end]
+            if strings.HasPrefix(class, "!") {
+                class = "^" + class[1:]
+            }
+            b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+            i += end + 1
+        case c == '\\' && i+1 < len(glob):
+            i++
+            b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+        default:
+            b.WriteString(regexp.QuoteMeta(string(c)))
+        }
+    }
+    return b.String()
+}
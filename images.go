@@ -0,0 +1,211 @@
+package mdserve
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "html"
+    "image"
+    "image/draw"
+    _ "image/gif"
+    "image/jpeg"
+    "image/png"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+const (
+    thumbnailWidth   = 1200      // Width of the thumbnails large images are shown as
+    thumbnailMinSize = 512 << 10 // Images above this many bytes get a thumbnail
+    maxImageWidth    = 4000
+    maxImagePixels   = 40 << 20 // Larger images are served as they are, not decoded
+)
+
+var errImageTooLarge = errors.New("image too large to resize")
+
+// Images that can be resized; GIFs are left alone to keep their animation
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+
+// Serve /img/<path>?w=800: the image scaled down to w pixels wide, from a
+// disk cache after the first request. Without w, or when the image is
+// already narrow enough, the original is served.
+func (s *Server) imageHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    m, file, _ := s.resolveRequest(st, strings.TrimPrefix(r.URL.Path, "/img"))
+    if m == nil || !imageExts[strings.ToLower(filepath.Ext(file))] {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    info, err := os.Stat(file)
+    if err != nil || info.IsDir() {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    width, err := strconv.Atoi(r.URL.Query().Get("w"))
+    if err != nil || width <= 0 {
+        serveAsset(w, r, file)
+        return
+    }
+    // Round widths up to a multiple of 100 so arbitrary values can't fill the cache
+    width = min((width+99)/100*100, maxImageWidth)
+
+    sum := sha256.Sum256([]byte(fmt.Sprintf("%s %d %d %d", file, info.Size(), info.ModTime().UnixNano(), width)))
+    cached := filepath.Join(s.thumbnailDir, hex.EncodeToString(sum[:])+filepath.Ext(file))
+    if _, err := os.Stat(cached); err != nil {
+        // Decoding takes a render slot, so resizing can't starve rendering
+        if err := s.acquireRender(); err != nil {
+            w.Header().Set("Retry-After", "5")
+            http.Error(w, "Busy rendering other documents, try again shortly", http.StatusServiceUnavailable)
+            return
+        }
+        data, err := thumbnail(file, width)
+        s.releaseRender()
+        if err != nil && err != errImageTooLarge {
+            http.Error(w, "Could not resize image", http.StatusInternalServerError)
+            return
+        }
+        if data == nil {
+            serveAsset(w, r, file)
+            return
+        }
+        if err := os.MkdirAll(s.thumbnailDir, 0755); err != nil || ioutil.WriteFile(cached, data, 0644) != nil {
+            http.ServeContent(w, r, filepath.Base(file), info.ModTime(), bytes.NewReader(data))
+            return
+        }
+    }
+    http.ServeFile(w, r, cached)
+}
+
+// The image in file scaled to width, or nil when it is no wider than that.
+// PNGs stay PNGs; everything else becomes JPEG. Images over maxImagePixels
+// are refused before decoding, as a small file can hold a huge image.
+func thumbnail(file string, width int) ([]byte, error) {
+    f, err := os.Open(file)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    cfg, _, err := image.DecodeConfig(f)
+    if err != nil {
+        return nil, err
+    }
+    if cfg.Width <= width {
+        return nil, nil
+    }
+    if cfg.Width*cfg.Height > maxImagePixels {
+        return nil, errImageTooLarge
+    }
+    if _, err := f.Seek(0, io.SeekStart); err != nil {
+        return nil, err
+    }
+    src, format, err := image.Decode(f)
+    if err != nil {
+        return nil, err
+    }
+    b := src.Bounds()
+    if b.Dx() <= width {
+        return nil, nil
+    }
+    dst := scaleDown(src, width, max(1, b.Dy()*width/b.Dx()))
+
+    var out bytes.Buffer
+    if format == "png" {
+        err = png.Encode(&out, dst)
+    } else {
+        err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 85})
+    }
+    return out.Bytes(), err
+}
+
+// Shrink an image by averaging the source pixels under each target pixel
+func scaleDown(src image.Image, width, height int) *image.RGBA {
+    b := src.Bounds()
+    rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+    draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+    dst := image.NewRGBA(image.Rect(0, 0, width, height))
+    for y := 0; y < height; y++ {
+        y0, y1 := y*b.Dy()/height, max((y+1)*b.Dy()/height, y*b.Dy()/height+1)
+        for x := 0; x < width; x++ {
+            x0, x1 := x*b.Dx()/width, max((x+1)*b.Dx()/width, x*b.Dx()/width+1)
+            var sum [4]int
+            for sy := y0; sy < y1; sy++ {
+                row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+                for i := 0; i < len(row); i += 4 {
+                    sum[0] += int(row[i])
+                    sum[1] += int(row[i+1])
+                    sum[2] += int(row[i+2])
+                    sum[3] += int(row[i+3])
+                }
+            }
+            n := (y1 - y0) * (x1 - x0)
+            px := dst.Pix[y*dst.Stride+x*4:]
+            for i := range sum {
+                px[i] = uint8(sum[i] / n)
+            }
+        }
+    }
+    return dst
+}
+
+// Widths offered in srcset, below the image's own
+var srcsetWidths = []int{400, 800, 1200, 1600}
+
+var imgSrc = regexp.MustCompile(`<img src="([^"]+)"`)
+
+// Make images load lazily and, for local ones, give them their size so the
+// page doesn't jump while they load, plus resized variants in srcset. Large
+// files get a thumbnail as src, with the original kept in data-full for
+// the lightbox.
+func (s *Server) processImages(page, file, urlFile string) string {
+    return imgSrc.ReplaceAllStringFunc(page, func(m string) string {
+        lazy := m + ` loading="lazy"`
+        src := html.UnescapeString(imgSrc.FindStringSubmatch(m)[1])
+        if strings.Contains(src, ":") || strings.HasPrefix(src, "/") || strings.ContainsAny(src, "?#") {
+            return lazy
+        }
+        rel, err := url.PathUnescape(src)
+        if err != nil {
+            return lazy
+        }
+        local := filepath.Join(filepath.Dir(file), filepath.FromSlash(rel))
+        f, err := os.Open(local)
+        if err != nil {
+            return lazy
+        }
+        defer f.Close()
+        cfg, _, err := image.DecodeConfig(f)
+        if err != nil {
+            return lazy
+        }
+        out := fmt.Sprintf(`%s width="%d" height="%d"`, lazy, cfg.Width, cfg.Height)
+        if !imageExts[strings.ToLower(path.Ext(rel))] || cfg.Width <= srcsetWidths[0] {
+            return out
+        }
+
+        resized := func(w int) string {
+            return fmt.Sprintf("%s/img/%s?w=%d", s.basePath, path.Join(path.Dir(urlFile), src), w)
+        }
+        var set []string
+        for _, w := range srcsetWidths {
+            if w < cfg.Width {
+                set = append(set, fmt.Sprintf("%s %dw", resized(w), w))
+            }
+        }
+        set = append(set, fmt.Sprintf("%s %dw", src, cfg.Width))
+        out += ` srcset="` + html.EscapeString(strings.Join(set, ", ")) + `" data-full="` + html.EscapeString(src) + `"`
+        if info, err := f.Stat(); err == nil && info.Size() >= thumbnailMinSize && cfg.Width > thumbnailWidth {
+            out = strings.Replace(out, m, `<img src="`+html.EscapeString(resized(thumbnailWidth))+`"`, 1)
+        }
+        return out
+    })
+}
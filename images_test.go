@@ -0,0 +1,75 @@
+package mdserve
+
+import (
+    "bytes"
+    "encoding/binary"
+    "hash/crc32"
+    "image"
+    "image/png"
+    "net/http"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// A PNG claiming to be width by height, with no pixel data
+func pngHeader(width, height int) []byte {
+    ihdr := make([]byte, 17)
+    copy(ihdr, "IHDR")
+    binary.BigEndian.PutUint32(ihdr[4:], uint32(width))
+    binary.BigEndian.PutUint32(ihdr[8:], uint32(height))
+    ihdr[12], ihdr[13] = 8, 6 // 8-bit RGBA
+    var b bytes.Buffer
+    b.WriteString("\x89PNG\r\n\x1a\n")
+    binary.Write(&b, binary.BigEndian, uint32(13))
+    b.Write(ihdr)
+    binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+    return b.Bytes()
+}
+
+func TestThumbnail(t *testing.T) {
+    var small bytes.Buffer
+    png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 300, 150)))
+    dir := t.TempDir()
+    tests := []struct {
+        name  string
+        data  []byte
+        width int
+        want  int // Thumbnail width, 0 for none
+        err   error
+    }{
+        {"scaled down", small.Bytes(), 100, 100, nil},
+        {"already narrow", small.Bytes(), 400, 0, nil},
+        {"too many pixels", pngHeader(1<<15, 1<<15), 100, 0, errImageTooLarge},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            file := filepath.Join(dir, tt.name+".png")
+            os.WriteFile(file, tt.data, 0644)
+            data, err := thumbnail(file, tt.width)
+            if err != tt.err {
+                t.Fatalf("got error %v, want %v", err, tt.err)
+            }
+            if tt.want == 0 {
+                if data != nil {
+                    t.Error("got a thumbnail, want none")
+                }
+                return
+            }
+            cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+            if err != nil || cfg.Width != tt.want {
+                t.Errorf("got width %d, %v; want %d", cfg.Width, err, tt.want)
+            }
+        })
+    }
+}
+
+// Images too large to resize are served as they are
+func TestImageHandlerTooLarge(t *testing.T) {
+    huge := string(pngHeader(1<<15, 1<<15))
+    s, _ := newTestServer(t, map[string]string{"huge.png": huge})
+    w := doRequest(s, "GET", "/img/huge.png?w=400", nil, true)
+    if w.Code != http.StatusOK || w.Body.String() != huge {
+        t.Errorf("got %d, want the original image", w.Code)
+    }
+}
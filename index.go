@@ -0,0 +1,91 @@
+package mdserve
+
+import (
+    "html/template"
+    "net/http"
+    "strings"
+)
+
+// One document listed on a generated index page
+type IndexEntry struct {
+    Path string // URL path without the leading slash
+    Name string // Path relative to the mount root
+    File string // Path on disk
+}
+
+// List a mount's documents when it has no index file of its own
+func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request, st *site, m *Mount) {
+    entries, err := s.indexEntries(r, m)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+    // Lite pages have no script to open folders, so they keep the flat list
+    var tree *treePage
+    if !s.lite {
+        root := directoryPage(m, entries, "", 0)
+        tree = &root
+    }
+    attachments, err := s.listAttachments(m)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+
+    // The mount's most viewed documents
+    var popular []pageViews
+    if s.stats {
+        if pages, err := s.popularPages(st, r); err == nil {
+            prefix := strings.TrimPrefix(m.Prefix, "/")
+            for _, p := range pages {
+                if len(popular) < 5 && (prefix == "" || strings.HasPrefix(p.Path, prefix+"/")) {
+                    popular = append(popular, p)
+                }
+            }
+        }
+    }
+
+    theme := s.themeFor(st, nil)
+
+    data := struct {
+        Base        string
+        Prefix      string
+        Theme       string
+        BaseCSS     template.CSS
+        ThemeCSS    template.CSS
+        Entries     []IndexEntry
+        Tree        *treePage // Top level of the collapsible tree, nil for a flat list
+        Truncated   bool
+        Attachments []Attachment
+        Popular     []pageViews
+        Data        map[string]interface{}
+    }{
+        Base:        s.basePath,
+        Prefix:      m.Prefix,
+        Theme:       theme,
+        BaseCSS:     template.CSS(baseCSS),
+        ThemeCSS:    template.CSS(Themes[theme]),
+        Entries:     entries,
+        Tree:        tree,
+        Truncated:   s.trees.isTruncated(m),
+        Attachments: attachments,
+        Popular:     popular,
+        Data:        s.pageData(r, m.Root),
+    }
+
+    s.executeTemplate(w, r, "index.html", data)
+}
+
+// Every document of a site, across its mounts, without the drafts r may
+// not see
+func (s *Server) siteDocs(st *site, r *http.Request) ([]IndexEntry, error) {
+    var all []IndexEntry
+    for _, m := range st.mounts {
+        entries, err := s.buildIndex(m)
+        if err != nil {
+            return nil, err
+        }
+        all = append(all, entries...)
+    }
+    return s.dropDrafts(r, all), nil
+}
@@ -0,0 +1,116 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "net/http"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Entries of a directory shown on an index page or returned by /api/tree
+// at once; "Show more" fetches the next ones
+const treePageSize = 200
+
+// A directory or document in the index tree
+type treeItem struct {
+    Name      string `json:"name"`
+    Path      string `json:"path"` // URL path without the leading slash
+    Dir       bool   `json:"dir,omitempty"`
+    Documents int    `json:"documents,omitempty"` // Below a directory, at any depth
+}
+
+// One page of a directory's entries
+type treePage struct {
+    Dir   string     `json:"dir"` // URL path without the leading slash
+    Items []treeItem `json:"items"`
+    Total int        `json:"total"`
+    Next  int        `json:"next,omitempty"` // Offset of the following page, 0 at the end
+}
+
+// The documents a mount's index lists for r: drafts it may not see and
+// whatever OnIndex hooks drop are left out
+func (s *Server) indexEntries(r *http.Request, m *Mount) ([]IndexEntry, error) {
+    entries, err := s.buildIndex(m)
+    if err != nil {
+        return nil, err
+    }
+    entries = s.dropDrafts(r, entries)
+    for _, fn := range s.hooks.onIndex {
+        entries = fn(r, entries)
+    }
+    return entries, nil
+}
+
+// The subdirectories and documents directly in dir, a path relative to
+// the mount root ("" for the root), directories first, from offset on
+func directoryPage(m *Mount, entries []IndexEntry, dir string, offset int) treePage {
+    prefix := ""
+    if dir != "" {
+        prefix = dir + "/"
+    }
+    var dirs, files []treeItem
+    counts := map[string]int{}
+    for _, e := range entries {
+        if !strings.HasPrefix(e.Name, prefix) {
+            continue
+        }
+        rest := e.Name[len(prefix):]
+        name, _, nested := strings.Cut(rest, "/")
+        if !nested {
+            files = append(files, treeItem{Name: name, Path: e.Path})
+            continue
+        }
+        if counts[name] == 0 {
+            dirs = append(dirs, treeItem{Name: name, Path: strings.TrimPrefix(path.Join(m.Prefix, prefix+name), "/"), Dir: true})
+        }
+        counts[name]++
+    }
+    sort.SliceStable(dirs, func(i, j int) bool { return dirs[i].Name < dirs[j].Name })
+    for i := range dirs {
+        dirs[i].Documents = counts[dirs[i].Name]
+    }
+
+    items := append(dirs, files...)
+    page := treePage{Dir: strings.TrimPrefix(path.Join(m.Prefix, dir), "/"), Total: len(items), Items: []treeItem{}}
+    if offset < len(items) {
+        end := min(len(items), offset+treePageSize)
+        page.Items = items[offset:end]
+        if end < len(items) {
+            page.Next = end
+        }
+    }
+    return page
+}
+
+// Find the mount of a directory's URL path, and the path relative to its
+// root
+func (st *site) mountForDir(urlPath string) (*Mount, string) {
+    urlPath = path.Clean("/" + urlPath)
+    for _, m := range st.mounts {
+        base := strings.TrimSuffix(m.Prefix, "/")
+        if urlPath == m.Prefix || strings.HasPrefix(urlPath, base+"/") {
+            return m, strings.TrimPrefix(strings.TrimPrefix(urlPath, base), "/")
+        }
+    }
+    return nil, ""
+}
+
+// /api/tree?dir=docs/guides&offset=200 returns one page of a directory's
+// subdirectories and documents for the collapsible index
+func (s *Server) treeHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    m, dir := st.mountForDir(r.URL.Query().Get("dir"))
+    if m == nil {
+        http.Error(w, "Directory not found", http.StatusNotFound)
+        return
+    }
+    entries, err := s.indexEntries(r, m)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+    offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(directoryPage(m, entries, dir, max(offset, 0)))
+}
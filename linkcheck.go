@@ -0,0 +1,259 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "fmt"
+    "html"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// A link that does not resolve
+type LinkProblem struct {
+    Path   string `json:"path"` // Document holding the link, URL path without the leading slash
+    Link   string `json:"link"`
+    Reason string `json:"reason"`
+}
+
+// What CheckLinks looks at besides links within the tree
+type LinkCheckOptions struct {
+    External    bool          // Also request http(s) links
+    Concurrency int           // External requests in flight, default 8
+    Timeout     time.Duration // Per external request, default 10s
+}
+
+var idAttr = regexp.MustCompile(`\sid="([^"]+)"`)
+
+// The ids and headings of rendered documents, kept until the file changes,
+// so links can be checked and outlines listed without rendering again
+type anchorIndex struct {
+    mu    sync.Mutex
+    files map[string]anchorEntry
+}
+
+type anchorEntry struct {
+    modTime time.Time
+    size    int64
+    ids     map[string]bool
+    toc     []tocEntry
+    title   string // From frontmatter, or else the first level-one heading
+}
+
+// The ids and headings of a rendered document
+func (s *Server) anchorsFor(file string) anchorEntry {
+    info, err := os.Stat(file)
+    if err != nil {
+        return anchorEntry{}
+    }
+    s.anchors.mu.Lock()
+    e, ok := s.anchors.files[file]
+    s.anchors.mu.Unlock()
+    if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+        return e
+    }
+    e = anchorEntry{modTime: info.ModTime(), size: info.Size(), ids: map[string]bool{}}
+    if page, err := s.renderFile(file); err == nil {
+        for _, m := range idAttr.FindAllSubmatch(page.HTML, -1) {
+            e.ids[string(m[1])] = true
+        }
+        e.toc = page.TOC
+        e.title = page.FrontMatter.Get("title")
+        for _, h := range page.TOC {
+            if e.title == "" && h.Level == 1 {
+                e.title = h.Text
+            }
+        }
+    }
+    s.anchors.mu.Lock()
+    if s.anchors.files == nil {
+        s.anchors.files = map[string]anchorEntry{}
+    }
+    s.anchors.files[file] = e
+    s.anchors.mu.Unlock()
+    return e
+}
+
+// The file on disk a link from file points at, which may not exist; ""
+// for links off the site
+func (s *Server) linkTarget(st *site, file string, u *url.URL) string {
+    switch {
+    case u.Scheme != "" || u.Host != "":
+        return ""
+    case strings.HasPrefix(u.Path, "/"):
+        _, target, _ := st.resolvePath(strings.TrimPrefix(u.Path, s.basePath))
+        return target
+    case u.Path != "":
+        return filepath.Join(filepath.Dir(file), filepath.FromSlash(u.Path))
+    }
+    return file
+}
+
+// Why a link from file within the site does not resolve, or "" when it
+// does or points elsewhere
+func (s *Server) linkProblem(st *site, file string, u *url.URL) string {
+    if u.Scheme != "" || u.Host != "" {
+        return ""
+    }
+    target := s.linkTarget(st, file, u)
+    info, err := os.Stat(target)
+    if target == "" || err != nil {
+        return "file not found"
+    }
+    if u.Fragment == "" || info.IsDir() || !s.isDocument(target) {
+        return ""
+    }
+    if !s.anchorsFor(target).ids[u.Fragment] {
+        return "no heading #" + u.Fragment
+    }
+    return ""
+}
+
+// The links within a site that do not resolve, sorted by document.
+// External links are collected into external, keyed by URL, when it is
+// not nil.
+func (s *Server) siteLinkProblems(st *site, r *http.Request, external map[string][]string) ([]LinkProblem, error) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        return nil, err
+    }
+    problems := []LinkProblem{}
+    for _, doc := range docs {
+        content, err := ioutil.ReadFile(doc.File)
+        if err != nil {
+            continue
+        }
+        _, body := parseFrontMatter(content)
+        for _, link := range findLinks(string(body)) {
+            u, err := url.Parse(link)
+            if err != nil {
+                problems = append(problems, LinkProblem{doc.Path, link, "malformed"})
+                continue
+            }
+            if u.Scheme == "http" || u.Scheme == "https" {
+                if external != nil && !containsString(external[link], doc.Path) {
+                    external[link] = append(external[link], doc.Path)
+                }
+                continue
+            }
+            if reason := s.linkProblem(st, doc.File, u); reason != "" {
+                problems = append(problems, LinkProblem{doc.Path, link, reason})
+            }
+        }
+    }
+    sortLinkProblems(problems)
+    return problems, nil
+}
+
+func sortLinkProblems(problems []LinkProblem) {
+    sort.SliceStable(problems, func(i, j int) bool {
+        if problems[i].Path != problems[j].Path {
+            return problems[i].Path < problems[j].Path
+        }
+        return problems[i].Link < problems[j].Link
+    })
+}
+
+// Check the links of every document served for the default host: relative
+// and site-absolute links must point at an existing file, and #fragments
+// at an id in the rendered target document. Problems are sorted by
+// document.
+func (s *Server) CheckLinks(opts LinkCheckOptions) ([]LinkProblem, error) {
+    var external map[string][]string // URL to the documents linking to it
+    if opts.External {
+        external = map[string][]string{}
+    }
+    problems, err := s.siteLinkProblems(s.defaultSite, nil, external)
+    if err != nil {
+        return nil, err
+    }
+    for link, reason := range checkURLs(external, opts) {
+        for _, p := range external[link] {
+            problems = append(problems, LinkProblem{p, link, reason})
+        }
+    }
+    sortLinkProblems(problems)
+    return problems, nil
+}
+
+// Serve /api/diagnostics/links: the links within the site that point at
+// missing files or headings
+func (s *Server) linkDiagnosticsHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    problems, err := s.siteLinkProblems(st, r, nil)
+    if err != nil {
+        http.Error(w, "Could not check links", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(problems)
+}
+
+var contentLink = regexp.MustCompile(`<a href="([^"]*)"`)
+
+// Give links in a rendered page that do not resolve a broken-link class
+// and the reason as their title, for -debug
+func (s *Server) markBrokenLinks(page string, st *site, file string) string {
+    return contentLink.ReplaceAllStringFunc(page, func(m string) string {
+        u, err := url.Parse(html.UnescapeString(contentLink.FindStringSubmatch(m)[1]))
+        if err != nil {
+            return m
+        }
+        if reason := s.linkProblem(st, file, u); reason != "" {
+            return m + ` class="broken-link" title="` + html.EscapeString(reason) + `"`
+        }
+        return m
+    })
+}
+
+// Request each URL, HEAD first and GET for servers that refuse HEAD,
+// returning why the failing ones failed
+func checkURLs(urls map[string][]string, opts LinkCheckOptions) map[string]string {
+    if opts.Concurrency <= 0 {
+        opts.Concurrency = 8
+    }
+    if opts.Timeout <= 0 {
+        opts.Timeout = 10 * time.Second
+    }
+    client := &http.Client{Timeout: opts.Timeout}
+    check := func(link string) string {
+        resp, err := client.Head(link)
+        if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusForbidden) {
+            resp.Body.Close()
+            resp, err = client.Get(link)
+        }
+        if err != nil {
+            return err.Error()
+        }
+        resp.Body.Close()
+        if resp.StatusCode >= 400 {
+            return fmt.Sprintf("HTTP %d", resp.StatusCode)
+        }
+        return ""
+    }
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    failed := map[string]string{}
+    sem := make(chan struct{}, opts.Concurrency)
+    for link := range urls {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(link string) {
+            defer func() { <-sem; wg.Done() }()
+            if reason := check(link); reason != "" {
+                mu.Lock()
+                failed[link] = reason
+                mu.Unlock()
+            }
+        }(link)
+    }
+    wg.Wait()
+    return failed
+}
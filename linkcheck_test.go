@@ -0,0 +1,100 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+var linkCheckFiles = map[string]string{
+    "index.md":       "# Home\n\n[Guide](guide/setup.md) [Section](guide/setup.md#install) [Missing](nope.md)\n\n[Bad anchor](guide/setup.md#nowhere) [Here](#home) [Not here](#away)\n",
+    "guide/setup.md": "# Setup\n\n## Install\n\n[Back](/index.md) [Gone](/gone.md) [Image](shot.png) [Mail](mailto:a@example.com)\n\n[ref]: ../missing.md\n\nSee [the ref][ref].\n",
+    "guide/shot.png": "png",
+    "code.md":        "`[Not a link](nope.md)`\n\n```\n[Also not](nope.md)\n```\n",
+}
+
+func TestCheckLinks(t *testing.T) {
+    s, _ := newTestServer(t, linkCheckFiles)
+    got, err := s.CheckLinks(LinkCheckOptions{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []LinkProblem{
+        {"guide/setup.md", "../missing.md", "file not found"},
+        {"guide/setup.md", "/gone.md", "file not found"},
+        {"index.md", "#away", "no heading #away"},
+        {"index.md", "guide/setup.md#nowhere", "no heading #nowhere"},
+        {"index.md", "nope.md", "file not found"},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got %+v, want %+v", got, want)
+    }
+}
+
+func TestCheckExternalLinks(t *testing.T) {
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/ok":
+        case "/get-only":
+            if r.Method == http.MethodHead {
+                w.WriteHeader(http.StatusMethodNotAllowed)
+            }
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    }))
+    defer ts.Close()
+    s, _ := newTestServer(t, map[string]string{
+        "a.md": "[OK](" + ts.URL + "/ok) [Head refused](" + ts.URL + "/get-only) [Missing](" + ts.URL + "/missing)\n",
+        "b.md": "[Missing again](" + ts.URL + "/missing)\n",
+    })
+
+    if got, _ := s.CheckLinks(LinkCheckOptions{}); len(got) != 0 {
+        t.Errorf("external links checked without External: %+v", got)
+    }
+    got, err := s.CheckLinks(LinkCheckOptions{External: true, Concurrency: 2})
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := []LinkProblem{
+        {"a.md", ts.URL + "/missing", "HTTP 404"},
+        {"b.md", ts.URL + "/missing", "HTTP 404"},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got %+v, want %+v", got, want)
+    }
+}
+
+func TestLinkDiagnostics(t *testing.T) {
+    s, _ := newTestServer(t, linkCheckFiles)
+    w := doRequest(s, "GET", "/api/diagnostics/links", nil, true)
+    if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+        t.Errorf("got Content-Type %q", ct)
+    }
+    var got []LinkProblem
+    if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+        t.Fatal(err)
+    }
+    if len(got) != 5 || got[0] != (LinkProblem{"guide/setup.md", "../missing.md", "file not found"}) {
+        t.Errorf("got %+v", got)
+    }
+
+    s, _ = newTestServer(t, map[string]string{"doc.md": "# Doc\n\n[OK](#doc) [Bad](#nowhere) [Gone](gone.md)\n"}, WithDebug())
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    for _, want := range []string{
+        `<a href="#doc">OK</a>`,
+        `<a href="#nowhere" class="broken-link" title="no heading #nowhere">Bad</a>`,
+        `<a href="gone.md" class="broken-link" title="file not found">Gone</a>`,
+    } {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing from %q", want, page)
+        }
+    }
+    s, _ = newTestServer(t, map[string]string{"doc.md": "[Gone](gone.md)\n"})
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); strings.Contains(page, `class="broken-link"`) {
+        t.Error("broken links marked without -debug")
+    }
+}
@@ -0,0 +1,56 @@
+package mdserve
+
+import (
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var markdownLink = regexp.MustCompile(`!?\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+var referenceLink = regexp.MustCompile(`(?m)^\s{0,3}\[[^\]]+\]:\s*<?(\S+?)>?(?:\s|$)`)
+
+// Link targets in a markdown body, skipping code
+func findLinks(body string) []string {
+    var links []string
+    mapProse(body, func(text string) string {
+        for _, m := range markdownLink.FindAllStringSubmatch(text, -1) {
+            links = append(links, m[1])
+        }
+        for _, m := range referenceLink.FindAllStringSubmatch(text, -1) {
+            links = append(links, m[1])
+        }
+        return text
+    })
+    return links
+}
+
+// Whether a link points outside the tree (has a scheme like https: or mailto:)
+func isExternalLink(target string) bool {
+    u, err := url.Parse(target)
+    return err == nil && u.Scheme != ""
+}
+
+// Relative links in a document whose target file does not exist
+func brokenLinks(file string, body string) []string {
+    var broken []string
+    for _, target := range findLinks(body) {
+        if isExternalLink(target) || strings.HasPrefix(target, "#") {
+            continue
+        }
+        u, err := url.Parse(target)
+        if err != nil {
+            broken = append(broken, target)
+            continue
+        }
+        p := u.Path
+        if p == "" || strings.HasPrefix(p, "/") {
+            continue // Site-absolute links depend on the mount layout
+        }
+        if _, err := os.Stat(filepath.Join(filepath.Dir(file), filepath.FromSlash(p))); err != nil {
+            broken = append(broken, target)
+        }
+    }
+    return broken
+}
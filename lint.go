@@ -0,0 +1,127 @@
+package mdserve
+
+import (
+    "fmt"
+    "io/ioutil"
+    "regexp"
+    "strings"
+)
+
+// A markdownlint-style rule
+type LintRule struct {
+    ID          string `json:"id"`   // markdownlint number, e.g. MD001
+    Name        string `json:"name"` // markdownlint alias, e.g. heading-increment
+    Description string `json:"description"`
+}
+
+// The rules Lint knows, all on by default
+var LintRules = []LintRule{
+    {"MD001", "heading-increment", "Heading levels should only increment by one level at a time"},
+    {"MD009", "no-trailing-spaces", "Trailing spaces"},
+    {"MD024", "no-duplicate-heading", "Multiple headings with the same content"},
+    {"MD034", "no-bare-urls", "Bare URL used"},
+}
+
+// One rule violation
+type LintIssue struct {
+    Path    string `json:"path"` // URL path without the leading slash
+    Line    int    `json:"line"`
+    Rule    string `json:"rule"` // ID/name, e.g. MD009/no-trailing-spaces
+    Message string `json:"message"`
+}
+
+var atxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+var bareURL = regexp.MustCompile(`https?://[^\s<>()\[\]]+`)
+var linkedURL = regexp.MustCompile(`\]\([^)]*\)|<[^>]*>`)
+
+// Lint checks every document served for the default host. Rules are
+// chosen as in a .markdownlint.json: each rule is on unless rules maps its
+// ID or name to false, or maps "default" to false and it to true.
+func (s *Server) Lint(rules map[string]bool) ([]LintIssue, error) {
+    enabled := map[string]bool{}
+    for _, r := range LintRules {
+        on, ok := rules["default"]
+        if !ok {
+            on = true
+        }
+        for _, key := range []string{r.ID, r.Name} {
+            if v, ok := rules[key]; ok {
+                on = v
+            }
+        }
+        enabled[r.ID] = on
+    }
+
+    docs, err := s.siteDocs(s.defaultSite, nil)
+    if err != nil {
+        return nil, err
+    }
+    var issues []LintIssue
+    for _, doc := range docs {
+        if !strings.HasSuffix(doc.File, ".md") {
+            continue
+        }
+        content, err := ioutil.ReadFile(doc.File)
+        if err != nil {
+            continue
+        }
+        issues = append(issues, lintMarkdown(doc.Path, string(content), enabled)...)
+    }
+    return issues, nil
+}
+
+// Apply the enabled rules to one document
+func lintMarkdown(docPath, content string, enabled map[string]bool) []LintIssue {
+    var issues []LintIssue
+    report := func(line int, id, format string, args ...interface{}) {
+        if !enabled[id] {
+            return
+        }
+        for _, r := range LintRules {
+            if r.ID == id {
+                issues = append(issues, LintIssue{docPath, line, r.ID + "/" + r.Name, fmt.Sprintf(format, args...)})
+            }
+        }
+    }
+
+    text := strings.ReplaceAll(content, "\r\n", "\n")
+    _, body := parseFrontMatter([]byte(text))
+    offset := strings.Count(text, "\n") - strings.Count(string(body), "\n") // Frontmatter lines
+
+    level := 0
+    headings := map[string]int{} // Line of each heading's first use
+    proseLines(string(body), func(i int, line string) {
+        n := offset + i
+
+        // Two trailing spaces are a line break
+        if rest := strings.TrimRight(line, " \t"); rest != line && strings.TrimSpace(line) != "" && line[len(rest):] != "  " {
+            report(n, "MD009", "Expected: 0 or 2 trailing spaces; Actual: %d", len(line)-len(rest))
+        }
+
+        if m := atxHeading.FindStringSubmatch(line); m != nil {
+            next := len(m[1])
+            if level > 0 && next > level+1 {
+                report(n, "MD001", "Expected: h%d; Actual: h%d", level+1, next)
+            }
+            level = next
+            if title := strings.TrimSpace(m[2]); title != "" {
+                if first, ok := headings[title]; ok {
+                    report(n, "MD024", "%q already used on line %d", title, first)
+                } else {
+                    headings[title] = n
+                }
+            }
+        }
+
+        if referenceLink.MatchString(line) {
+            return
+        }
+        mapOutsideCodeSpans(line, func(text string) string {
+            for _, u := range bareURL.FindAllString(linkedURL.ReplaceAllString(text, ""), -1) {
+                report(n, "MD034", "%s", u)
+            }
+            return text
+        })
+    })
+    return issues
+}
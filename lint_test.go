@@ -0,0 +1,77 @@
+package mdserve
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestLintMarkdown(t *testing.T) {
+    all := map[string]bool{"MD001": true, "MD009": true, "MD024": true, "MD034": true}
+    tests := []struct {
+        name    string
+        content string
+        want    []LintIssue
+    }{
+        {"clean", "# Title\n\n## Part\n\nText with a break  \nand [a link](https://example.com) <https://example.org>.\n", nil},
+        {"heading increment", "# Title\n\n### Deep\n\n## Back\n\n#### Deep again\n", []LintIssue{
+            {"doc.md", 3, "MD001/heading-increment", "Expected: h2; Actual: h3"},
+            {"doc.md", 7, "MD001/heading-increment", "Expected: h3; Actual: h4"},
+        }},
+        {"trailing spaces", "One \nTwo  \nThree   \n", []LintIssue{
+            {"doc.md", 1, "MD009/no-trailing-spaces", "Expected: 0 or 2 trailing spaces; Actual: 1"},
+            {"doc.md", 3, "MD009/no-trailing-spaces", "Expected: 0 or 2 trailing spaces; Actual: 3"},
+        }},
+        {"duplicate headings", "# Setup\n\n## Setup\n", []LintIssue{
+            {"doc.md", 3, "MD024/no-duplicate-heading", `"Setup" already used on line 1`},
+        }},
+        {"bare urls", "See https://example.com/a and `https://example.com/code`.\n\n[ref]: https://example.com/ref\n", []LintIssue{
+            {"doc.md", 1, "MD034/no-bare-urls", "https://example.com/a"},
+        }},
+        {"lines after frontmatter", "---\ntitle: T\n---\n# A\n\n### B\n", []LintIssue{
+            {"doc.md", 6, "MD001/heading-increment", "Expected: h2; Actual: h3"},
+        }},
+        {"code blocks skipped", "# A\n\n```\n### not a heading \nhttps://example.com\n```\n", nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := lintMarkdown("doc.md", tt.content, all); !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %+v, want %+v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestLintRuleSelection(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "doc.md":   "# A\n\n### B \n",
+        "data.txt": "# A\n\n### B \n",
+    })
+    tests := []struct {
+        name  string
+        rules map[string]bool
+        want  []string
+    }{
+        {"all by default", nil, []string{"MD009/no-trailing-spaces", "MD001/heading-increment"}},
+        {"off by id", map[string]bool{"MD009": false}, []string{"MD001/heading-increment"}},
+        {"off by name", map[string]bool{"heading-increment": false}, []string{"MD009/no-trailing-spaces"}},
+        {"only one", map[string]bool{"default": false, "no-trailing-spaces": true}, []string{"MD009/no-trailing-spaces"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            issues, err := s.Lint(tt.rules)
+            if err != nil {
+                t.Fatal(err)
+            }
+            var got []string
+            for _, issue := range issues {
+                if issue.Path != "doc.md" {
+                    t.Errorf("linted %s", issue.Path)
+                }
+                got = append(got, issue.Rule)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
@@ -1,242 +1,492 @@
-package main
+// Package mdserve serves a tree of markdown files as web pages, with
+// in-browser editing and transparent gpg encryption of sensitive notes.
+//
+// Embed it in another service with
+//
+//     docs := mdserve.New(
+//         mdserve.WithMounts(mdserve.Mount{Prefix: "/", Root: "./docs"}),
+//         mdserve.WithAuth("admin", "secret"),
+//         mdserve.WithBasePath("/docs"),
+//     )
+//     http.Handle("/docs/", http.StripPrefix("/docs", docs))
+package mdserve
 
 import (
-    "bufio"
     "fmt"
     "html/template"
-    "io/ioutil"
-    "log"
+    "net"
     "net/http"
     "os"
-    "os/exec"
-    "os/signal"
+    "path"
     "path/filepath"
+    "sort"
     "strings"
-    "syscall"
-    "github.com/gomarkdown/markdown"
+    "sync"
+    "sync/atomic"
+    "time"
 )
 
-var encryptionPassword string // Holds the password fetched from the file
-const adminUsername = "admin" // Admin username
+const adminUsername = "admin" // Default username for basic auth
 
-// Read the password from a file
-func readPasswordFromFile(filePath string) (string, error) {
-    file, err := os.Open(filePath)
-    if err != nil {
-        return "", fmt.Errorf("could not open password file: %v", err)
-    }
-    defer file.Close()
+// A documentation tree served under a URL prefix
+type Mount struct {
+    Prefix   string // URL prefix, e.g. "/team-a"
+    Root     string // Directory on disk, or a single document
+    Index    string // File served at the prefix itself, default index.md
+    ReadOnly bool   // Disable editing for this tree
 
-    scanner := bufio.NewScanner(file)
-    if scanner.Scan() {
-        return scanner.Text(), nil
-    }
-    return "", fmt.Errorf("password file is empty")
+    single bool // Root is a document: served at the prefix, with the files beside it but no others
 }
 
-// Decrypt all GPG files at startup
-func decryptAllGPGFiles() error {
-    err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
-        if strings.HasSuffix(path, ".gpg") {
-            outputFile := strings.TrimSuffix(path, ".gpg")
-            cmd := exec.Command("gpg", "--batch", "--yes", "--passphrase", encryptionPassword, 
-                                "-o", outputFile, "-d", path)
-            if err := cmd.Run(); err != nil {
-                return fmt.Errorf("Failed to decrypt %s: %v", path, err)
-            }
-            log.Printf("Decrypted: %s", path)
-        }
-        return nil
-    })
-    return err
+// A set of mounts answering for one virtual host, with its own theme and auth
+type Host struct {
+    Name     string // Host name matched against the Host header
+    Mounts   []Mount
+    Theme    string // Falls back to Config.Theme when empty
+    Username string // Default admin
+    Password string // Default Config.Password
+    Public   bool   // Serve without authentication
 }
 
+// Server serves markdown trees over HTTP
+type Server struct {
+    password    string
+    theme       string
+    basePath    string
+    tocPosition string
+    tocLevels   [2]int // Heading levels shown in the TOC, min and max
+    renderer    Renderer // nil for the default, built per document
+    markdown    markdownOptions
+    cache       *pageCache // nil when caching is off
+    lite        bool       // Minimal pages, no caches or background work
+    defaultSite *site
+    sites       map[string]*site // Keyed by lower-case host name
+
+    interactiveTables bool   // Every table sortable and filterable
+    numberSections    bool   // Number headings unless frontmatter says otherwise
+    thumbnailDir      string // Disk cache of resized images
+    cacheNamespace    string // Prefixes page cache keys when the cache is shared
+    cacheBackend      CacheBackend // Shared with other replicas, nil for none
+    cacheTTL          time.Duration
+    backendLogged     atomic.Int64 // When a backend failure was last logged, in Unix seconds
+    favicon           string // Custom icon file, "" for the built-in one
+    castPlayer        string // Directory of a self-hosted asciinema player, "" for jsDelivr
+    robots            string // Custom robots.txt file
+    stats             bool   // Count page views
+    analytics         string // Snippet added to every page head
+    drafts            bool   // Show drafts to everyone
+    previewToken      string // Shows drafts to requests carrying it
+    language          string // Fixed UI language, "" to negotiate
+    debug             bool   // Mark broken links in pages
+    statsMu           sync.Mutex
+    commentsMu        sync.Mutex
+
+    shortcodesMu sync.RWMutex
+    shortcodes   map[string]ShortcodeFunc
+    fences       map[string]fenceFunc // Keyed by code block language
+
+    formatsMu sync.RWMutex
+    formats   map[string]Renderer // Document formats besides markdown, keyed by extension
+
+    hooks   hooks
+    handler http.Handler // serve wrapped in middleware
+
+    templateDir   string
+    templatesMu   sync.Mutex
+    templates     map[string]*template.Template // Parsed, keyed by file name and language
+    funcs         template.FuncMap
+    data          map[string]interface{}
+    pageDataFuncs []PageDataFunc
+
+    filterCache filterCache
+    anchors     anchorIndex
+    searchIndex searchIndex
+    trees       treeCache
+    ignore      []string // Patterns left out of listings, see ignored
+    ignoreFiles *ignoreFiles
+    maxDepth    int // Directory levels listed per mount, 0 for all
+    maxFiles    int // Documents listed per mount, 0 for all
+
+    streamThreshold int64 // Markdown files larger than this are streamed, 0 never
+    maxFileSize     int64 // Documents larger than this aren't rendered, 0 for no limit
+    renderSlots     chan struct{} // One value per render running, nil for no limit
+    renderTimeout   time.Duration // Wait for a slot before giving up
+
+    dev bool // Re-read templates on every request, cache nothing and log requests
+
+    scanned     chan struct{} // Closed once Watch has indexed the files at startup
+    scannedOnce sync.Once
+    events      eventHub // Changes for /api/events
+
+    decryptMu sync.Mutex
+    decrypted []string // Plaintext written by DecryptAll or saved encrypted, removed by Cleanup
+
+    store       Store
+    writeMu     sync.Mutex // Serialises read-modify-write of documents
+}
 
-// Delete all Markdown files except README.md on exit
-func deleteAllMarkdownFiles() {
-    err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
-        if err != nil {
-            return err
-        }
+// A Host with its mounts ordered for lookup
+type site struct {
+    Host
+    mounts   []*Mount                // Longest prefix first
+    foldCase bool                    // Match missing paths to files differing only in case
+    ignores  *ignoreFiles            // Files the trees' ignore files keep private
+    document func(file string) bool // Whether a file is rendered as a document
+}
 
-        if strings.HasSuffix(path, ".md") && !strings.EqualFold(path, "README.md") {
-            if err := os.Remove(path); err != nil {
-                return fmt.Errorf("Failed to delete %s: %v", path, err)
-            }
-            log.Printf("Deleted: %s", path)
-        }
-        return nil
-    })
+// New creates a Server configured by opts
+func New(opts ...Option) *Server {
+    var cfg Config
+    for _, opt := range opts {
+        opt(&cfg)
+    }
 
+    s := &Server{
+        password:    cfg.Password,
+        theme:       cfg.Theme,
+        basePath:    strings.TrimSuffix(cfg.BasePath, "/"),
+        tocPosition: cfg.TOCPosition,
+        renderer:    cfg.Renderer,
+        sites:       map[string]*site{},
+        shortcodes:  map[string]ShortcodeFunc{},
+        formats:     map[string]Renderer{},
+        templateDir: cfg.TemplateDir,
+        templates:   map[string]*template.Template{},
+        funcs:       template.FuncMap{},
+        data:        cfg.Data,
+        store:       cfg.Store,
+        scanned:     make(chan struct{}),
+    }
+    if s.store == nil {
+        s.store = NewMemoryStore()
+    }
+    for name, fn := range cfg.Funcs {
+        s.funcs[name] = fn
+    }
+    if _, ok := Themes[s.theme]; !ok {
+        s.theme = "light"
+    }
+    if s.tocPosition != "right" && s.tocPosition != "none" {
+        s.tocPosition = "left"
+    }
+    s.numberSections = cfg.NumberSections
+    s.tocLevels = [2]int{1, 6}
+    if cfg.TOCMinLevel > 0 {
+        s.tocLevels[0] = cfg.TOCMinLevel
+    }
+    if cfg.TOCMaxLevel > 0 {
+        s.tocLevels[1] = cfg.TOCMaxLevel
+    }
+    s.markdown = markdownOptions{SmartPunctuation: cfg.SmartPunctuation, HardWraps: cfg.HardWraps, CommonMark: cfg.CommonMark}
+    s.lite = cfg.Lite
+    s.interactiveTables = cfg.InteractiveTables
+    s.favicon = cfg.Favicon
+    s.castPlayer = cfg.CastPlayer
+    s.robots = cfg.Robots
+    s.stats = cfg.Stats || cfg.Analytics.Counter
+    s.analytics = cfg.Analytics.snippet()
+    s.drafts = cfg.Drafts
+    s.previewToken = cfg.PreviewToken
+    s.debug = cfg.Debug
+    s.ignore = ignorePatterns(cfg.Ignore)
+    s.ignoreFiles = &ignoreFiles{git: cfg.GitIgnore}
+    s.maxDepth, s.maxFiles = cfg.MaxDepth, cfg.MaxFiles
+    s.streamThreshold = cfg.StreamThreshold
+    s.maxFileSize = cfg.MaxFileSize
+    if cfg.RenderWorkers > 0 {
+        s.renderSlots = make(chan struct{}, cfg.RenderWorkers)
+    }
+    s.renderTimeout = cfg.RenderTimeout
+    if s.renderTimeout <= 0 {
+        s.renderTimeout = 30 * time.Second
+    }
+    if _, ok := Messages[cfg.Language]; ok {
+        s.language = cfg.Language
+    }
+    s.dev = cfg.Dev
+    if cfg.SharedCache != nil && !s.lite && !s.dev {
+        s.cache = cfg.SharedCache.cache
+        s.cacheNamespace = fmt.Sprintf("%p\x00", s)
+    } else if cfg.CacheSize > 0 && !s.lite && !s.dev {
+        s.cache = newPageCache(cfg.CacheSize)
+    }
+    if !s.lite && !s.dev {
+        s.cacheBackend, s.cacheTTL = cfg.CacheBackend, cfg.CacheTTL
+    }
+    if s.lite {
+        s.tocPosition = "none"
+    }
+    cacheDir, err := os.UserCacheDir()
     if err != nil {
-        log.Printf("Error during markdown cleanup: %v", err)
-    } else {
-        log.Println("All markdown files (except README.md) deleted.")
+        cacheDir = os.TempDir()
     }
-}
-
+    s.thumbnailDir = filepath.Join(cacheDir, "mdserve", "thumbnails")
 
+    mounts := cfg.Mounts
+    if len(mounts) == 0 {
+        mounts = []Mount{{Prefix: "/", Root: "."}}
+    }
+    s.defaultSite = newSite(Host{Name: cfg.Name, Username: cfg.Username, Mounts: mounts, Public: cfg.Public})
+    for _, h := range cfg.Hosts {
+        site := newSite(h)
+        s.sites[strings.ToLower(h.Name)] = site
+    }
+    for _, st := range s.allSites() {
+        st.foldCase = cfg.CaseInsensitive
+        st.ignores = s.ignoreFiles
+        st.document = s.isDocument
+    }
 
-// Handle signals to ensure cleanup on exit
-func handleExit() {
-    c := make(chan os.Signal, 1)
-    signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-    go func() {
-        <-c
-        log.Println("Shutting down, cleaning up markdown files...")
-        deleteAllMarkdownFiles()
-        os.Exit(0)
-    }()
+    s.registerBuiltinShortcodes()
+    s.registerBuiltinFences()
+    s.registerBuiltinFormats()
+    s.registerPlantUML(cfg.PlantUML)
+    s.registerFilters(cfg.Filters)
+    s.handler = http.HandlerFunc(s.serve)
+    if s.dev {
+        s.Use(devMiddleware)
+    }
+    return s
 }
 
-// Basic authentication check
-func checkAuth(r *http.Request) bool {
-    username, password, ok := r.BasicAuth()
-    return ok && username == adminUsername && password == encryptionPassword
+func newSite(h Host) *site {
+    if h.Username == "" {
+        h.Username = adminUsername
+    }
+    st := &site{Host: h}
+    for i := range h.Mounts {
+        m := h.Mounts[i]
+        m.Prefix = path.Clean("/" + m.Prefix)
+        if m.Index == "" {
+            m.Index = "index.md"
+        }
+        if info, err := os.Stat(m.Root); err == nil && info.Mode().IsRegular() {
+            m.single = true
+        }
+        st.mounts = append(st.mounts, &m)
+    }
+    sort.SliceStable(st.mounts, func(i, j int) bool {
+        return len(st.mounts[i].Prefix) > len(st.mounts[j].Prefix)
+    })
+    return st
 }
 
-// Encrypt a file using GPG
-func encryptFile(file string) error {
-    cmd := exec.Command("gpg", "--batch", "--yes", "--passphrase", encryptionPassword, "-c", file)
-    if err := cmd.Run(); err != nil {
-        return fmt.Errorf("GPG encryption failed: %v", err)
+// Every site, the default one first
+func (s *Server) allSites() []*site {
+    list := []*site{s.defaultSite}
+    for _, st := range s.sites {
+        list = append(list, st)
     }
-    return nil
+    return list
 }
 
-// View handler with authentication
-func viewHandler(w http.ResponseWriter, r *http.Request) {
-    if !checkAuth(r) {
-        w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-        http.Error(w, "Unauthorized.", http.StatusUnauthorized)
-        return
+// Roots lists the directory, or document, of every mount across all hosts
+func (s *Server) Roots() []string {
+    var roots []string
+    for _, st := range s.allSites() {
+        for _, m := range st.mounts {
+            roots = append(roots, m.Root)
+        }
     }
+    return roots
+}
 
-    file := r.URL.Path[1:]
-    if file == "" {
-        file = "index.md"
+// Pick the site for the request's Host header
+func (s *Server) siteFor(r *http.Request) *site {
+    host := r.Host
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
     }
-
-    content, err := ioutil.ReadFile(file)
-    if err != nil {
-        http.Error(w, "File not found", http.StatusNotFound)
-        return
+    if st, ok := s.sites[strings.ToLower(host)]; ok {
+        return st
     }
+    return s.defaultSite
+}
 
-    htmlContent := markdown.ToHTML(content, nil, nil)
-    tmpl := `
-    <html>
-    <body>
-        <a href="/edit/{{.File}}">Edit this file</a>
-        <h1>Preview</h1>
-        <div>{{.HTMLContent}}</div>
-    </body>
-    </html>`
-
-    data := struct {
-        File        string
-        HTMLContent template.HTML
-    }{
-        File:        file,
-        HTMLContent: template.HTML(htmlContent),
+// Find the mount serving a URL path and the file it maps to.
+// Returns the file path on disk and its URL path without the leading slash;
+// no mount when an ignore file excludes the file.
+func (st *site) resolvePath(urlPath string) (*Mount, string, string) {
+    urlPath = path.Clean("/" + urlPath)
+    for _, m := range st.mounts {
+        base := strings.TrimSuffix(m.Prefix, "/")
+        if urlPath != m.Prefix && !strings.HasPrefix(urlPath, base+"/") {
+            continue
+        }
+        rel := strings.TrimPrefix(strings.TrimPrefix(urlPath, base), "/")
+        dir := m.Root
+        if m.single {
+            // The document under its own name, and the images and other
+            // files it links to
+            dir = filepath.Dir(m.Root)
+            if rel == "" {
+                rel = filepath.Base(m.Root)
+            }
+        } else if rel == "" {
+            rel = m.Index
+        }
+        file := filepath.Join(dir, filepath.FromSlash(rel))
+        info, err := os.Stat(file)
+        if os.IsNotExist(err) {
+            if found, ok := lookupPath(dir, rel, st.sameName); ok {
+                rel = found
+                file = filepath.Join(dir, filepath.FromSlash(rel))
+                info, err = os.Stat(file)
+            }
+        }
+        if m.single && file != filepath.Clean(m.Root) && st.document(file) {
+            return nil, "", ""
+        }
+        if st.ignores.excludes(m.Root, file, err == nil && info.IsDir()) {
+            return nil, "", ""
+        }
+        return m, file, strings.TrimPrefix(path.Join(m.Prefix, rel), "/")
     }
-
-    t, _ := template.New("view").Parse(tmpl)
-    t.Execute(w, data)
+    return nil, "", ""
 }
 
-// Edit handler with authentication
-func editHandler(w http.ResponseWriter, r *http.Request) {
-    if !checkAuth(r) {
-        w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-        http.Error(w, "Unauthorized.", http.StatusUnauthorized)
-        return
+// Resolve a requested URL path like resolvePath, with no mount also when
+// the file, or a directory above it, is hidden or ignored. Every handler
+// serving files by URL goes through here.
+func (s *Server) resolveRequest(st *site, urlPath string) (*Mount, string, string) {
+    m, file, urlFile := st.resolvePath(urlPath)
+    if m == nil || s.concealed(m, file) {
+        return nil, "", ""
     }
+    return m, file, urlFile
+}
 
-    file := r.URL.Path[len("/edit/"):]
-    if file == "" {
-        http.Error(w, "File not specified", http.StatusBadRequest)
-        return
+// Whether a path under a mount, existing or not, is hidden or ignored,
+// itself or any directory above it
+func (s *Server) concealed(m *Mount, file string) bool {
+    root := m.Root
+    if m.single {
+        root = filepath.Dir(m.Root)
     }
-
-    if r.Method == http.MethodPost {
-        newContent := r.FormValue("content")
-        err := ioutil.WriteFile(file, []byte(newContent), 0644)
-        if err != nil {
-            http.Error(w, "Could not save file", http.StatusInternalServerError)
-            return
+    rel, err := filepath.Rel(root, file)
+    if err != nil || rel == ".." || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+        return true
+    }
+    if rel == "." {
+        return false
+    }
+    parts := strings.Split(filepath.ToSlash(rel), "/")
+    for i, part := range parts {
+        p := filepath.Join(root, filepath.FromSlash(strings.Join(parts[:i+1], "/")))
+        dir := i < len(parts)-1
+        if !dir {
+            info, err := os.Stat(p)
+            dir = err == nil && info.IsDir()
         }
-
-        // Encrypt the file after saving
-        err = encryptFile(file)
-        if err != nil {
-            log.Printf("Encryption error: %v", err)
-            http.Error(w, "Encryption failed", http.StatusInternalServerError)
-            return
+        if strings.HasPrefix(part, ".") || s.ignored(root, p, dir) {
+            return true
         }
-
-        http.Redirect(w, r, "/"+file, http.StatusSeeOther)
-        return
-    }
-
-    content, err := ioutil.ReadFile(file)
-    if err != nil {
-        http.Error(w, "File not found", http.StatusNotFound)
-        return
     }
+    return false
+}
 
-    tmpl := `
-    <html>
-    <body>
-        <h1>Edit {{.File}}</h1>
-        <form method="POST" action="/edit/{{.File}}">
-            <textarea name="content" rows="20" cols="80">{{.RawContent}}</textarea><br>
-            <input type="submit" value="Save">
-        </form>
-        <a href="/{{.File}}">Cancel</a>
-    </body>
-    </html>`
+// Basic authentication check against the site's credentials
+func (s *Server) checkAuth(st *site, r *http.Request) bool {
+    return st.Public || s.checkCredentials(st, r)
+}
 
-    data := struct {
-        File       string
-        RawContent string
-    }{
-        File:       file,
-        RawContent: string(content),
+// Whether the request carries the site's credentials, public or not
+func (s *Server) checkCredentials(st *site, r *http.Request) bool {
+    expected := st.Password
+    if expected == "" {
+        expected = s.password
     }
+    username, password, ok := r.BasicAuth()
+    return ok && username == st.Username && password == expected
+}
 
-    t, _ := template.New("edit").Parse(tmpl)
-    t.Execute(w, data)
+// ServeHTTP gives the request an ID, see RequestID, and runs it through
+// any middleware registered with Use. A panic gets an error page.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    r, id := withRequestID(w, r)
+    ew := &errorWriter{ResponseWriter: w, id: id}
+    defer s.recoverPanic(ew, r)
+    s.handler.ServeHTTP(ew, r)
+    ew.finish(r)
 }
 
-func main() {
-    // Read password from file
-    var err error
-    encryptionPassword, err = readPasswordFromFile(".secret.key")
-    if err != nil {
-        log.Fatalf("Failed to read password: %v", err)
+// Route /edit/ to the editor, /img/ to resized images, /zip/ to archives,
+// built-in pages to their handlers and everything else to the viewer
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+    // Browsers fetch these on their own, often without credentials
+    switch r.URL.Path {
+    case "/favicon.ico":
+        s.faviconHandler(w, r)
+        return
+    case "/manifest.webmanifest":
+        s.manifestHandler(w, r)
+        return
+    case "/sw.js":
+        s.serviceWorkerHandler(w, r)
+        return
     }
 
-    // Decrypt all GPG files at startup
-    if err := decryptAllGPGFiles(); err != nil {
-        log.Fatalf("Failed to decrypt files: %v", err)
+    st := s.siteFor(r)
+    if r.URL.Path == "/robots.txt" {
+        s.robotsHandler(w, r, st)
+        return
+    }
+    if r.URL.Path == "/admin/reload" {
+        s.reloadHandler(w, r, st)
+        return
+    }
+    if !s.checkAuth(st, r) {
+        w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+        http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+        return
     }
 
-    // Handle graceful exit for cleanup
-    handleExit()
+    s.setPreviewCookie(w, r)
 
-    port := "8080"
-    if len(os.Args) > 1 {
-        port = os.Args[1]
+    for _, fn := range s.hooks.onRequest {
+        if fn(w, r) {
+            return
+        }
     }
 
-    http.HandleFunc("/", viewHandler)
-    http.HandleFunc("/edit/", editHandler)
-
-    fmt.Printf("Serving on http://localhost:%s\n", port)
-    log.Fatal(http.ListenAndServe(":"+port, nil))
+    switch {
+    case strings.HasPrefix(r.URL.Path, "/edit/"):
+        s.editHandler(w, r, st)
+    case r.URL.Path == "/tasks":
+        s.tasksHandler(w, r, st)
+    case strings.HasPrefix(r.URL.Path, "/comments/"):
+        s.commentsHandler(w, r, st)
+    case r.URL.Path == "/stats":
+        s.statsHandler(w, r, st)
+    case r.URL.Path == "/api/files":
+        s.filesHandler(w, r, st)
+    case r.URL.Path == "/api/tree":
+        s.treeHandler(w, r, st)
+    case r.URL.Path == "/api/outline":
+        s.outlineHandler(w, r, st)
+    case r.URL.Path == "/api/diagnostics/links":
+        s.linkDiagnosticsHandler(w, r, st)
+    case r.URL.Path == "/api/diagnostics/orphans":
+        s.orphansHandler(w, r, st)
+    case r.URL.Path == "/api/events":
+        s.eventsHandler(w, r, st)
+    case r.URL.Path == "/api/search":
+        s.searchAPIHandler(w, r, st)
+    case r.URL.Path == "/search":
+        s.searchHandler(w, r, st)
+    case r.URL.Path == "/opensearch.xml":
+        s.openSearchHandler(w, r)
+    case r.URL.Path == "/oembed":
+        s.oembedHandler(w, r, st)
+    case r.URL.Path == "/sitemap.xml":
+        s.sitemapHandler(w, r, st)
+    case s.castPlayer != "" && castPlayerFiles[r.URL.Path]:
+        s.castPlayerHandler(w, r)
+    case strings.HasPrefix(r.URL.Path, "/img/"):
+        s.imageHandler(w, r, st)
+    case r.URL.Path == "/zip" || strings.HasPrefix(r.URL.Path, "/zip/"):
+        s.zipHandler(w, r, st)
+    default:
+        s.viewHandler(w, r, st)
+    }
 }
-
@@ -0,0 +1,79 @@
+package mdserve
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// A server over a temporary tree holding files, with password "pw"
+func newTestServer(t *testing.T, files map[string]string, opts ...Option) (*Server, string) {
+    t.Helper()
+    root := t.TempDir()
+    for name, content := range files {
+        file := filepath.Join(root, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    opts = append([]Option{WithMounts(Mount{Prefix: "/", Root: root}), WithAuth("admin", "pw")}, opts...)
+    return New(opts...), root
+}
+
+// Send a request, with the server's credentials when auth is set
+func doRequest(s *Server, method, target string, form url.Values, auth bool) *httptest.ResponseRecorder {
+    var r *http.Request
+    if form != nil {
+        r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+        r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    } else {
+        r = httptest.NewRequest(method, target, nil)
+    }
+    if auth {
+        r.SetBasicAuth("admin", "pw")
+    }
+    w := httptest.NewRecorder()
+    s.ServeHTTP(w, r)
+    return w
+}
+
+func TestPublicSiteEditing(t *testing.T) {
+    s, root := newTestServer(t, map[string]string{"doc.md": "- [ ] task\n"}, WithPublic())
+    tests := []struct {
+        name   string
+        method string
+        form   url.Values
+        auth   bool
+        status int
+    }{
+        {"read anonymously", "GET", nil, false, http.StatusOK},
+        {"edit page anonymously", "GET", nil, false, http.StatusUnauthorized},
+        {"save anonymously", "POST", url.Values{"content": {"overwritten"}}, false, http.StatusUnauthorized},
+        {"toggle task anonymously", "POST", url.Values{"task": {"0"}, "checked": {"true"}}, false, http.StatusUnauthorized},
+        {"edit page signed in", "GET", nil, true, http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            target := "/edit/doc.md"
+            if tt.name == "read anonymously" {
+                target = "/doc.md"
+            }
+            if w := doRequest(s, tt.method, target, tt.form, tt.auth); w.Code != tt.status {
+                t.Errorf("%s %s: got %d, want %d", tt.method, target, w.Code, tt.status)
+            }
+        })
+    }
+    if content, _ := os.ReadFile(filepath.Join(root, "doc.md")); string(content) != "- [ ] task\n" {
+        t.Errorf("document changed to %q", content)
+    }
+    if body := doRequest(s, "GET", "/doc.md", nil, false).Body.String(); strings.Contains(body, "/edit/doc.md") {
+        t.Error("anonymous readers are offered the editor")
+    }
+}
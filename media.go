@@ -0,0 +1,35 @@
+package mdserve
+
+import (
+    "fmt"
+    "path"
+    "regexp"
+    "strings"
+)
+
+// A paragraph holding nothing but a link to a video or audio file
+var mediaLink = regexp.MustCompile(`(?i)<p><a href="([^"]+\.(mp4|webm|ogv|mov|mp3|m4a|ogg|oga|wav|flac))"[^>]*>([^<]*)</a></p>`)
+
+var videoExts = map[string]bool{"mp4": true, "webm": true, "ogv": true, "mov": true}
+
+// Turn standalone links to local video and audio files into native players.
+// The link stays inside as the fallback. Seeking works because assets are
+// served with Range support.
+func embedMedia(page string) string {
+    return mediaLink.ReplaceAllStringFunc(page, func(m string) string {
+        sub := mediaLink.FindStringSubmatch(m)
+        src, label := sub[1], sub[3]
+        if strings.Contains(src, "://") {
+            return m
+        }
+        if label == "" {
+            label = path.Base(src)
+        }
+        tag := "audio"
+        if videoExts[strings.ToLower(sub[2])] {
+            tag = "video"
+        }
+        return fmt.Sprintf(`<figure class="media"><%s controls preload="metadata" src="%s"><a href="%s">%s</a></%s><figcaption>%s</figcaption></figure>`+"\n",
+            tag, src, src, label, tag, label)
+    })
+}
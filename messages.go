@@ -0,0 +1,343 @@
+package mdserve
+
+import (
+    "fmt"
+    "html/template"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Messages holds translations of the page chrome by language code, keyed
+// by the English text. Pick one with Config.Language; otherwise each
+// request gets the best match for its Accept-Language header. Text
+// missing from a catalog stays English.
+var Messages = map[string]map[string]string{
+    "en": {},
+    "de": {
+        "Edit this file":                        "Diese Datei bearbeiten",
+        "Preview":                               "Vorschau",
+        "Draft: hidden from indexes and search": "Entwurf: nicht in Übersichten und Suche",
+        "Updated %s":                            "Geändert am %s",
+        "last commit by %s on %s":               "letzter Commit von %s am %s",
+        "Contents":                              "Inhalt",
+        "Languages":                             "Sprachen",
+        "Comments":                              "Kommentare",
+        "on":                                    "am",
+        "about":                                 "zu",
+        "Delete":                                "Löschen",
+        "No comments yet.":                      "Noch keine Kommentare.",
+        "Whole document":                        "Ganzes Dokument",
+        "Add a comment":                         "Kommentar hinzufügen",
+        "Comment":                               "Kommentieren",
+        "Index of %s":                           "Inhalt von %s",
+        "Search":                                "Suche",
+        "Download as zip":                       "Als Zip herunterladen",
+        "Popular":                               "Beliebt",
+        "%d views":                              "%d Aufrufe",
+        "All documents":                         "Alle Dokumente",
+        "No markdown files yet.":                "Noch keine Markdown-Dateien.",
+        "Attachments":                           "Anhänge",
+        "Download":                              "Herunterladen",
+        "Page views":                            "Seitenaufrufe",
+        "%d views of %d documents":              "%d Aufrufe von %d Dokumenten",
+        "Document":                              "Dokument",
+        "Views":                                 "Aufrufe",
+        "No views yet.":                         "Noch keine Aufrufe.",
+        "%d found":                              "%d gefunden",
+        "Edit %s":                               "%s bearbeiten",
+        "Save":                                  "Speichern",
+        "Cancel":                                "Abbrechen",
+        "Tasks":                                 "Aufgaben",
+        "%d open, %d done":                      "%d offen, %d erledigt",
+        "tag":                                   "Schlagwort",
+        "owner":                                 "Verantwortlich",
+        "clear filters":                         "Filter entfernen",
+        "Tags:":                                 "Schlagwörter:",
+        "Owners:":                               "Verantwortliche:",
+        "Owner":                                 "Verantwortlich",
+        "Tags":                                  "Schlagwörter",
+        "Progress":                              "Fortschritt",
+        "%d open":                               "%d offen",
+        "No task lists found.":                  "Keine Aufgabenlisten gefunden.",
+        "%d lines":                              "%d Zeilen",
+        "Raw":                                   "Rohdatei",
+        "Filter rows":                           "Zeilen filtern",
+        "Copy link to this section":             "Link zu diesem Abschnitt kopieren",
+        "Comment on this section":               "Diesen Abschnitt kommentieren",
+        "Copy":                                  "Kopieren",
+        "Copied":                                "Kopiert",
+        "Go to document":                        "Gehe zu Dokument",
+        "Could not save: ":                      "Speichern fehlgeschlagen: ",
+        "Skip to content":                       "Zum Inhalt springen",
+        "Subsections of":                        "Unterabschnitte von",
+        "Close":                                 "Schließen",
+        "Previous":                              "Zurück",
+        "Next":                                  "Weiter",
+        "Image":                                 "Bild",
+        "Reading settings":                      "Leseeinstellungen",
+        "Text size":                             "Schriftgröße",
+        "Line width":                            "Zeilenbreite",
+        "Font":                                  "Schrift",
+        "Smaller":                               "Kleiner",
+        "Reset":                                 "Zurücksetzen",
+        "Larger":                                "Größer",
+        "Narrow":                                "Schmal",
+        "Medium":                                "Mittel",
+        "Wide":                                  "Breit",
+        "Serif":                                 "Serif",
+        "Sans":                                  "Serifenlos",
+        "Mono":                                  "Monospace",
+        "Something went wrong":                  "Etwas ist schiefgelaufen",
+        "The server ran into an error showing this page. If it keeps happening, report it with request ID %s.": "Beim Anzeigen dieser Seite ist ein Serverfehler aufgetreten. Wenn das wiederholt passiert, melde ihn mit der Anfrage-ID %s.",
+        "This document is %s, more than the %s this server renders.": "Dieses Dokument ist %s groß, mehr als die %s, die dieser Server darstellt.",
+        "Download the raw file":                 "Rohdatei herunterladen",
+        "Show more":                             "Mehr anzeigen",
+        "Truncated: this tree is larger than the server's depth or file limit, so some documents are not listed.": "Gekürzt: dieser Baum übersteigt die Tiefen- oder Dateigrenze des Servers, daher fehlen einige Dokumente in der Liste.",
+        "Truncated: this tree is larger than the server's depth or file limit, so some documents are not searched.": "Gekürzt: dieser Baum übersteigt die Tiefen- oder Dateigrenze des Servers, daher werden einige Dokumente nicht durchsucht.",
+        "Site statistics":                       "Website-Statistik",
+        "%d documents, %d words":                "%d Dokumente, %d Wörter",
+        "%d broken links":                       "%d defekte Links",
+        "Largest documents":                     "Größte Dokumente",
+        "Size":                                  "Größe",
+        "Words":                                 "Wörter",
+        "Least recently updated":                "Am längsten nicht geändert",
+        "Updated":                               "Geändert",
+        "Without a title":                       "Ohne Titel",
+        "Broken links":                          "Defekte Links",
+    },
+    "fr": {
+        "Edit this file":                        "Modifier ce fichier",
+        "Preview":                               "Aperçu",
+        "Draft: hidden from indexes and search": "Brouillon : absent des index et de la recherche",
+        "Updated %s":                            "Modifié le %s",
+        "last commit by %s on %s":               "dernier commit de %s le %s",
+        "Contents":                              "Sommaire",
+        "Languages":                             "Langues",
+        "Comments":                              "Commentaires",
+        "on":                                    "le",
+        "about":                                 "sur",
+        "Delete":                                "Supprimer",
+        "No comments yet.":                      "Aucun commentaire.",
+        "Whole document":                        "Tout le document",
+        "Add a comment":                         "Ajouter un commentaire",
+        "Comment":                               "Commenter",
+        "Index of %s":                           "Index de %s",
+        "Search":                                "Rechercher",
+        "Download as zip":                       "Télécharger en zip",
+        "Popular":                               "Populaires",
+        "%d views":                              "%d vues",
+        "All documents":                         "Tous les documents",
+        "No markdown files yet.":                "Aucun fichier markdown.",
+        "Attachments":                           "Pièces jointes",
+        "Download":                              "Télécharger",
+        "Page views":                            "Pages vues",
+        "%d views of %d documents":              "%d vues de %d documents",
+        "Document":                              "Document",
+        "Views":                                 "Vues",
+        "No views yet.":                         "Aucune vue.",
+        "%d found":                              "%d trouvés",
+        "Edit %s":                               "Modifier %s",
+        "Save":                                  "Enregistrer",
+        "Cancel":                                "Annuler",
+        "Tasks":                                 "Tâches",
+        "%d open, %d done":                      "%d ouvertes, %d terminées",
+        "tag":                                   "étiquette",
+        "owner":                                 "responsable",
+        "clear filters":                         "effacer les filtres",
+        "Tags:":                                 "Étiquettes :",
+        "Owners:":                               "Responsables :",
+        "Owner":                                 "Responsable",
+        "Tags":                                  "Étiquettes",
+        "Progress":                              "Avancement",
+        "%d open":                               "%d ouvertes",
+        "No task lists found.":                  "Aucune liste de tâches.",
+        "%d lines":                              "%d lignes",
+        "Raw":                                   "Brut",
+        "Filter rows":                           "Filtrer les lignes",
+        "Copy link to this section":             "Copier le lien vers cette section",
+        "Comment on this section":               "Commenter cette section",
+        "Copy":                                  "Copier",
+        "Copied":                                "Copié",
+        "Go to document":                        "Aller au document",
+        "Could not save: ":                      "Échec de l'enregistrement : ",
+        "Skip to content":                       "Aller au contenu",
+        "Subsections of":                        "Sous-sections de",
+        "Close":                                 "Fermer",
+        "Previous":                              "Précédent",
+        "Next":                                  "Suivant",
+        "Image":                                 "Image",
+        "Reading settings":                      "Réglages de lecture",
+        "Text size":                             "Taille du texte",
+        "Line width":                            "Largeur des lignes",
+        "Font":                                  "Police",
+        "Smaller":                               "Plus petit",
+        "Reset":                                 "Réinitialiser",
+        "Larger":                                "Plus grand",
+        "Narrow":                                "Étroite",
+        "Medium":                                "Moyenne",
+        "Wide":                                  "Large",
+        "Serif":                                 "Serif",
+        "Sans":                                  "Sans serif",
+        "Mono":                                  "Chasse fixe",
+        "Something went wrong":                  "Une erreur s'est produite",
+        "The server ran into an error showing this page. If it keeps happening, report it with request ID %s.": "Le serveur a rencontré une erreur en affichant cette page. Si cela se reproduit, signalez-la avec l'identifiant de requête %s.",
+        "This document is %s, more than the %s this server renders.": "Ce document fait %s, plus que les %s que ce serveur affiche.",
+        "Download the raw file":                 "Télécharger le fichier brut",
+        "Show more":                             "Afficher plus",
+        "Truncated: this tree is larger than the server's depth or file limit, so some documents are not listed.": "Tronqué : cette arborescence dépasse la limite de profondeur ou de fichiers du serveur, certains documents ne sont pas listés.",
+        "Truncated: this tree is larger than the server's depth or file limit, so some documents are not searched.": "Tronqué : cette arborescence dépasse la limite de profondeur ou de fichiers du serveur, certains documents ne sont pas cherchés.",
+        "Site statistics":                       "Statistiques du site",
+        "%d documents, %d words":                "%d documents, %d mots",
+        "%d broken links":                       "%d liens cassés",
+        "Largest documents":                     "Documents les plus longs",
+        "Size":                                  "Taille",
+        "Words":                                 "Mots",
+        "Least recently updated":                "Les moins récemment modifiés",
+        "Updated":                               "Modifié",
+        "Without a title":                       "Sans titre",
+        "Broken links":                          "Liens cassés",
+    },
+    "es": {
+        "Edit this file":                        "Editar este archivo",
+        "Preview":                               "Vista previa",
+        "Draft: hidden from indexes and search": "Borrador: oculto en índices y búsqueda",
+        "Updated %s":                            "Actualizado el %s",
+        "last commit by %s on %s":               "último commit de %s el %s",
+        "Contents":                              "Contenido",
+        "Languages":                             "Idiomas",
+        "Comments":                              "Comentarios",
+        "on":                                    "el",
+        "about":                                 "sobre",
+        "Delete":                                "Eliminar",
+        "No comments yet.":                      "Todavía no hay comentarios.",
+        "Whole document":                        "Todo el documento",
+        "Add a comment":                         "Añadir un comentario",
+        "Comment":                               "Comentar",
+        "Index of %s":                           "Índice de %s",
+        "Search":                                "Buscar",
+        "Download as zip":                       "Descargar como zip",
+        "Popular":                               "Populares",
+        "%d views":                              "%d visitas",
+        "All documents":                         "Todos los documentos",
+        "No markdown files yet.":                "Todavía no hay archivos markdown.",
+        "Attachments":                           "Adjuntos",
+        "Download":                              "Descargar",
+        "Page views":                            "Visitas",
+        "%d views of %d documents":              "%d visitas de %d documentos",
+        "Document":                              "Documento",
+        "Views":                                 "Visitas",
+        "No views yet.":                         "Todavía no hay visitas.",
+        "%d found":                              "%d encontrados",
+        "Edit %s":                               "Editar %s",
+        "Save":                                  "Guardar",
+        "Cancel":                                "Cancelar",
+        "Tasks":                                 "Tareas",
+        "%d open, %d done":                      "%d abiertas, %d hechas",
+        "tag":                                   "etiqueta",
+        "owner":                                 "responsable",
+        "clear filters":                         "quitar filtros",
+        "Tags:":                                 "Etiquetas:",
+        "Owners:":                               "Responsables:",
+        "Owner":                                 "Responsable",
+        "Tags":                                  "Etiquetas",
+        "Progress":                              "Progreso",
+        "%d open":                               "%d abiertas",
+        "No task lists found.":                  "No se encontraron listas de tareas.",
+        "%d lines":                              "%d líneas",
+        "Raw":                                   "Sin formato",
+        "Filter rows":                           "Filtrar filas",
+        "Copy link to this section":             "Copiar enlace a esta sección",
+        "Comment on this section":               "Comentar esta sección",
+        "Copy":                                  "Copiar",
+        "Copied":                                "Copiado",
+        "Go to document":                        "Ir al documento",
+        "Could not save: ":                      "No se pudo guardar: ",
+        "Skip to content":                       "Saltar al contenido",
+        "Subsections of":                        "Subsecciones de",
+        "Close":                                 "Cerrar",
+        "Previous":                              "Anterior",
+        "Next":                                  "Siguiente",
+        "Image":                                 "Imagen",
+        "Reading settings":                      "Ajustes de lectura",
+        "Text size":                             "Tamaño del texto",
+        "Line width":                            "Ancho de línea",
+        "Font":                                  "Fuente",
+        "Smaller":                               "Más pequeño",
+        "Reset":                                 "Restablecer",
+        "Larger":                                "Más grande",
+        "Narrow":                                "Estrecho",
+        "Medium":                                "Medio",
+        "Wide":                                  "Ancho",
+        "Serif":                                 "Con serifa",
+        "Sans":                                  "Sin serifa",
+        "Mono":                                  "Monoespaciada",
+        "Something went wrong":                  "Algo salió mal",
+        "The server ran into an error showing this page. If it keeps happening, report it with request ID %s.": "El servidor tuvo un error al mostrar esta página. Si vuelve a ocurrir, infórmalo con el ID de solicitud %s.",
+        "This document is %s, more than the %s this server renders.": "Este documento ocupa %s, más que los %s que muestra este servidor.",
+        "Download the raw file":                 "Descargar el archivo original",
+        "Show more":                             "Mostrar más",
+        "Truncated: this tree is larger than the server's depth or file limit, so some documents are not listed.": "Truncado: este árbol supera el límite de profundidad o de archivos del servidor, así que algunos documentos no aparecen.",
+        "Truncated: this tree is larger than the server's depth or file limit, so some documents are not searched.": "Truncado: este árbol supera el límite de profundidad o de archivos del servidor, así que algunos documentos no se buscan.",
+        "Site statistics":                       "Estadísticas del sitio",
+        "%d documents, %d words":                "%d documentos, %d palabras",
+        "%d broken links":                       "%d enlaces rotos",
+        "Largest documents":                     "Documentos más grandes",
+        "Size":                                  "Tamaño",
+        "Words":                                 "Palabras",
+        "Least recently updated":                "Menos actualizados",
+        "Updated":                               "Actualizado",
+        "Without a title":                       "Sin título",
+        "Broken links":                          "Enlaces rotos",
+    },
+}
+
+// The language for a request: Config.Language when set, otherwise the
+// most preferred one in Accept-Language that has a catalog
+func (s *Server) languageFor(r *http.Request) string {
+    if s.language != "" {
+        return s.language
+    }
+    type pref struct {
+        lang string
+        q    float64
+    }
+    var prefs []pref
+    for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+        tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+        q := 1.0
+        if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+            q, _ = strconv.ParseFloat(v, 64)
+        }
+        // de-CH falls back to de
+        base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+        if _, ok := Messages[base]; ok && q > 0 {
+            prefs = append(prefs, pref{base, q})
+        }
+    }
+    if len(prefs) == 0 {
+        return "en"
+    }
+    sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+    return prefs[0].lang
+}
+
+// Template functions for a language: t translates a message, formatting
+// it with any arguments; lang is the language code
+func translator(lang string) template.FuncMap {
+    catalog := Messages[lang]
+    return template.FuncMap{
+        "t": func(text string, args ...interface{}) string {
+            if translated, ok := catalog[text]; ok {
+                text = translated
+            }
+            if len(args) > 0 {
+                return fmt.Sprintf(text, args...)
+            }
+            return text
+        },
+        "lang":     func() string { return lang },
+        "messages": func() map[string]string { return catalog },
+    }
+}
@@ -0,0 +1,89 @@
+package mdserve
+
+import (
+    "net/http/httptest"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+func TestLanguageFor(t *testing.T) {
+    tests := []struct {
+        header string
+        opts   []Option
+        want   string
+    }{
+        {"", nil, "en"},
+        {"de", nil, "de"},
+        {"de-CH,de;q=0.9", nil, "de"},
+        {"ja, fr;q=0.5", nil, "fr"},
+        {"en;q=0.4, es;q=0.8", nil, "es"},
+        {"fr;q=0, de;q=0.1", nil, "de"},
+        {"xx", nil, "en"},
+        {"de", []Option{WithLanguage("fr")}, "fr"},
+    }
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "/", nil)
+        r.Header.Set("Accept-Language", tt.header)
+        if got := New(tt.opts...).languageFor(r); got != tt.want {
+            t.Errorf("languageFor(%q) = %q, want %q", tt.header, got, tt.want)
+        }
+    }
+}
+
+func TestTranslatedPages(t *testing.T) {
+    files := map[string]string{"doc.md": "# Doc\n\n## Part\n"}
+    tests := []struct {
+        name   string
+        header string
+        opts   []Option
+        want   []string
+        vary   bool
+    }{
+        {"english", "", nil, []string{`<html lang="en">`, `aria-label="Contents"`, "<h2>Comments</h2>", "var uiMessages = {};"}, true},
+        {"german", "de-DE", nil, []string{`<html lang="de">`, `aria-label="Inhalt"`, "<h2>Kommentare</h2>", `"Copy":"Kopieren"`}, true},
+        {"configured", "de", []Option{WithLanguage("es")}, []string{`<html lang="es">`, "<h2>Comentarios</h2>"}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, files, tt.opts...)
+            r := httptest.NewRequest("GET", "/doc.md", nil)
+            r.SetBasicAuth("admin", "pw")
+            r.Header.Set("Accept-Language", tt.header)
+            w := httptest.NewRecorder()
+            s.ServeHTTP(w, r)
+            for _, want := range tt.want {
+                if !strings.Contains(w.Body.String(), want) {
+                    t.Errorf("%q missing from %q", want, w.Body.String())
+                }
+            }
+            if vary := strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Language"); vary != tt.vary {
+                t.Errorf("Vary: Accept-Language is %v, want %v", vary, tt.vary)
+            }
+        })
+    }
+}
+
+// Every message of the built-in templates and page script is in every
+// catalog
+func TestMessageCatalogs(t *testing.T) {
+    var msgs []string
+    for _, src := range builtinTemplates {
+        for _, m := range regexp.MustCompile(`\{\{t "((?:[^"\\]|\\.)*)"`).FindAllStringSubmatch(src, -1) {
+            msgs = append(msgs, m[1])
+        }
+    }
+    for _, m := range regexp.MustCompile(`uiText\('([^']*)'\)`).FindAllStringSubmatch(baseJS, -1) {
+        msgs = append(msgs, m[1])
+    }
+    for lang, catalog := range Messages {
+        if lang == "en" {
+            continue
+        }
+        for _, msg := range msgs {
+            if _, ok := catalog[msg]; !ok {
+                t.Errorf("%s: no translation of %q", lang, msg)
+            }
+        }
+    }
+}
@@ -0,0 +1,83 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "fmt"
+    "html"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+var firstParagraph = regexp.MustCompile(`(?s)<p>(.*?)</p>`)
+
+// Serve /oembed?url=<document url>, the oEmbed description of a document
+// with a title, author and preview card for wikis and chat tools to embed.
+// Only the JSON format is offered.
+func (s *Server) oembedHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    q := r.URL.Query()
+    if f := q.Get("format"); f != "" && f != "json" {
+        http.Error(w, "Only the json format is supported", http.StatusNotImplemented)
+        return
+    }
+    target, err := url.Parse(q.Get("url"))
+    if err != nil || target.Path == "" {
+        http.Error(w, "Missing or invalid url", http.StatusBadRequest)
+        return
+    }
+    urlPath := strings.TrimPrefix(target.Path, s.basePath)
+    m, file, urlFile := s.resolveRequest(st, urlPath)
+    if m == nil || !s.isDocument(file) || !s.showDrafts(r) && isDraft(file) {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    page, err := s.renderFile(file)
+    if err != nil {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+
+    title := page.FrontMatter.Get("title")
+    if title == "" && len(page.TOC) > 0 {
+        title = page.TOC[0].Text
+    }
+    summary := page.FrontMatter.Get("description")
+    if summary == "" {
+        if p := firstParagraph.FindSubmatch(page.HTML); p != nil {
+            summary = html.UnescapeString(htmlTag.ReplaceAllString(string(p[1]), ""))
+        }
+    }
+    if runes := []rune(summary); len(runes) > 200 {
+        summary = strings.TrimSpace(string(runes[:200])) + "…"
+    }
+
+    width := 600
+    if mw, err := strconv.Atoi(q.Get("maxwidth")); err == nil && mw > 0 {
+        width = min(width, mw)
+    }
+    link := s.siteURL(r) + (&url.URL{Path: "/" + urlFile}).EscapedPath()
+    card := fmt.Sprintf(`<blockquote class="mdserve-embed" style="max-width:%dpx"><a href="%s"><strong>%s</strong></a>`,
+        width, html.EscapeString(link), html.EscapeString(title))
+    if summary != "" {
+        card += "<p>" + html.EscapeString(summary) + "</p>"
+    }
+    card += "</blockquote>"
+
+    resp := map[string]interface{}{
+        "version":       "1.0",
+        "type":          "rich",
+        "provider_name": "mdserve",
+        "provider_url":  s.siteURL(r) + "/",
+        "title":         title,
+        "html":          card,
+        "width":         width,
+        "height":        nil,
+    }
+    if author := page.FrontMatter.Get("author"); author != "" {
+        resp["author_name"] = author
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
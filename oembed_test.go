@@ -0,0 +1,60 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestOEmbed(t *testing.T) {
+    long := strings.Repeat("word ", 60)
+    s, _ := newTestServer(t, map[string]string{
+        "guide.md": "---\nauthor: Ann\n---\n# Getting started\n\nInstall it <em>first</em> & run it.\n",
+        "desc.md":  "---\ntitle: Described\ndescription: Short summary.\n---\nBody text.\n",
+        "long.md":  "# Long\n\n" + long + "\n",
+        "data.csv": "a,b\n",
+    })
+    tests := []struct {
+        name   string
+        target string
+        status int
+        want   map[string]interface{}
+        html   string // In the card
+    }{
+        {"heading and first paragraph", "/oembed?url=http://example.com/guide.md", http.StatusOK, map[string]interface{}{"type": "rich", "version": "1.0", "title": "Getting started", "author_name": "Ann", "width": 600.0, "provider_url": "http://example.com/"}, `<a href="http://example.com/guide.md"><strong>Getting started</strong></a><p>Install it first &amp; run it.</p>`},
+        {"frontmatter", "/oembed?url=/desc.md&maxwidth=300", http.StatusOK, map[string]interface{}{"title": "Described", "width": 300.0}, `style="max-width:300px"`},
+        {"summary cut", "/oembed?url=/long.md", http.StatusOK, map[string]interface{}{"title": "Long"}, "word…</p>"},
+        {"not a document", "/oembed?url=/data.csv", http.StatusNotFound, nil, ""},
+        {"missing", "/oembed?url=/nope.md", http.StatusNotFound, nil, ""},
+        {"no url", "/oembed", http.StatusBadRequest, nil, ""},
+        {"xml", "/oembed?url=/guide.md&format=xml", http.StatusNotImplemented, nil, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := doRequest(s, "GET", tt.target, nil, true)
+            if w.Code != tt.status {
+                t.Fatalf("got %d, want %d", w.Code, tt.status)
+            }
+            if tt.status != http.StatusOK {
+                return
+            }
+            var got map[string]interface{}
+            if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+                t.Fatal(err)
+            }
+            for key, want := range tt.want {
+                if got[key] != want {
+                    t.Errorf("%s: got %v, want %v", key, got[key], want)
+                }
+            }
+            if card, _ := got["html"].(string); !strings.Contains(card, tt.html) {
+                t.Errorf("%q missing from %q", tt.html, card)
+            }
+        })
+    }
+
+    if page := doRequest(s, "GET", "/guide.md", nil, true).Body.String(); !strings.Contains(page, `<link rel="alternate" type="application/json+oembed" href="/oembed?url=/guide.md">`) {
+        t.Error("page does not link its oEmbed description")
+    }
+}
@@ -0,0 +1,120 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// A document readers can't get to by following links
+type Orphan struct {
+    Path   string `json:"path"` // URL path without the leading slash
+    Title  string `json:"title"`
+    Reason string `json:"reason"`
+}
+
+// The documents of a site that no other document links to, or that can't
+// be reached by following links from a mount's index page. Mounts without
+// an index file get the generated listing, which reaches everything, so
+// only the first rule applies to them.
+func (s *Server) siteOrphans(st *site, r *http.Request) ([]Orphan, error) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        return nil, err
+    }
+    type doc struct {
+        entry   IndexEntry
+        title   string
+        targets []string // Files linked to
+    }
+    byFile := map[string]*doc{}
+    var list []*doc
+    for _, e := range docs {
+        content, err := ioutil.ReadFile(e.File)
+        if err != nil {
+            continue
+        }
+        fm, body := parseFrontMatter(content)
+        d := &doc{entry: e, title: documentTitle(fm, string(body))}
+        for _, link := range findLinks(string(body)) {
+            u, err := url.Parse(link)
+            if err != nil {
+                continue
+            }
+            if target := s.linkTarget(st, e.File, u); target != "" && target != e.File {
+                d.targets = append(d.targets, filepath.Clean(target))
+            }
+        }
+        byFile[filepath.Clean(e.File)] = d
+        list = append(list, d)
+    }
+
+    linked := map[string]bool{}
+    for _, d := range list {
+        for _, t := range d.targets {
+            linked[t] = true
+        }
+    }
+    // Follow links from the index pages
+    indexes := map[string]bool{}
+    var queue []string
+    for _, m := range st.mounts {
+        index := filepath.Clean(filepath.Join(m.Root, filepath.FromSlash(m.Index)))
+        if _, err := os.Stat(index); err == nil {
+            indexes[index] = true
+            queue = append(queue, index)
+            continue
+        }
+        for _, d := range list {
+            if rel, err := filepath.Rel(m.Root, d.entry.File); err == nil && !strings.HasPrefix(rel, "..") {
+                queue = append(queue, filepath.Clean(d.entry.File))
+            }
+        }
+    }
+    reached := map[string]bool{}
+    for len(queue) > 0 {
+        file := queue[0]
+        queue = queue[1:]
+        if reached[file] {
+            continue
+        }
+        reached[file] = true
+        if d := byFile[file]; d != nil {
+            queue = append(queue, d.targets...)
+        }
+    }
+
+    orphans := []Orphan{}
+    for _, d := range list {
+        file := filepath.Clean(d.entry.File)
+        switch {
+        case indexes[file]:
+        case !linked[file]:
+            orphans = append(orphans, Orphan{d.entry.Path, d.title, "no other document links here"})
+        case !reached[file]:
+            orphans = append(orphans, Orphan{d.entry.Path, d.title, "not reachable from an index page"})
+        }
+    }
+    return orphans, nil
+}
+
+// Orphans lists the documents of the default host that readers can't
+// reach by following links
+func (s *Server) Orphans() ([]Orphan, error) {
+    return s.siteOrphans(s.defaultSite, nil)
+}
+
+// Serve /api/diagnostics/orphans
+func (s *Server) orphansHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    orphans, err := s.siteOrphans(st, r)
+    if err != nil {
+        http.Error(w, "Could not list documents", http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(orphans)
+}
@@ -0,0 +1,62 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+func TestOrphans(t *testing.T) {
+    tests := []struct {
+        name  string
+        files map[string]string
+        want  []Orphan
+    }{
+        {
+            "with an index",
+            map[string]string{
+                "index.md":    "# Home\n\n[A](a.md)\n",
+                "a.md":        "# A\n\n[B](sub/b.md#part)\n",
+                "sub/b.md":    "# B\n\n[Home](/index.md)\n",
+                "island1.md":  "# Island one\n\n[Two](island2.md)\n",
+                "island2.md":  "# Island two\n\n[One](island1.md)\n",
+                "lonely.md":   "# Lonely\n\n[Self](#lonely)\n",
+                "untitled.md": "Nothing links here.\n",
+            },
+            []Orphan{
+                {"island1.md", "Island one", "not reachable from an index page"},
+                {"island2.md", "Island two", "not reachable from an index page"},
+                {"lonely.md", "Lonely", "no other document links here"},
+                {"untitled.md", "", "no other document links here"},
+            },
+        },
+        {
+            "generated listing",
+            map[string]string{
+                "island1.md": "# Island one\n\n[Two](island2.md)\n",
+                "island2.md": "# Island two\n\n[One](island1.md)\n",
+                "lonely.md":  "# Lonely\n",
+            },
+            []Orphan{{"lonely.md", "Lonely", "no other document links here"}},
+        },
+        {"none", map[string]string{"index.md": "[A](a.md)\n", "a.md": "A\n"}, []Orphan{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, tt.files)
+            got, err := s.Orphans()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("got %+v, want %+v", got, tt.want)
+            }
+
+            var served []Orphan
+            w := doRequest(s, "GET", "/api/diagnostics/orphans", nil, true)
+            if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil || !reflect.DeepEqual(served, tt.want) {
+                t.Errorf("served %q", w.Body.String())
+            }
+        })
+    }
+}
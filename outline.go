@@ -0,0 +1,60 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "net/http"
+)
+
+// A heading with the headings below it, in /api/outline
+type outlineHeading struct {
+    Level    int               `json:"level"`
+    ID       string            `json:"id"`
+    Text     string            `json:"text"`
+    Children []*outlineHeading `json:"children"`
+}
+
+// One document in /api/outline
+type documentOutline struct {
+    Path     string            `json:"path"` // URL path without the leading slash
+    Title    string            `json:"title"`
+    Headings []*outlineHeading `json:"headings"`
+}
+
+// Nest a flat list of headings by level. A heading that skips levels
+// becomes the child of the nearest shallower one.
+func outlineTree(toc []tocEntry) []*outlineHeading {
+    roots := []*outlineHeading{}
+    var stack []*outlineHeading
+    for _, e := range toc {
+        h := &outlineHeading{Level: e.Level, ID: e.ID, Text: e.Text, Children: []*outlineHeading{}}
+        for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+            stack = stack[:len(stack)-1]
+        }
+        if len(stack) == 0 {
+            roots = append(roots, h)
+        } else {
+            parent := stack[len(stack)-1]
+            parent.Children = append(parent.Children, h)
+        }
+        stack = append(stack, h)
+    }
+    return roots
+}
+
+// Serve /api/outline, the heading tree of every document of the site.
+// Headings come from the rendered documents and are kept until the file
+// changes.
+func (s *Server) outlineHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+    outline := []documentOutline{}
+    for _, doc := range docs {
+        e := s.anchorsFor(doc.File)
+        outline = append(outline, documentOutline{Path: doc.Path, Title: e.title, Headings: outlineTree(e.toc)})
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(outline)
+}
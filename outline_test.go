@@ -0,0 +1,89 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// Headings as id(children...)
+func outlineShape(hs []*outlineHeading) string {
+    var parts []string
+    for _, h := range hs {
+        part := h.ID
+        if len(h.Children) > 0 {
+            part += "(" + outlineShape(h.Children) + ")"
+        }
+        parts = append(parts, part)
+    }
+    return strings.Join(parts, " ")
+}
+
+func TestOutlineTree(t *testing.T) {
+    tests := []struct {
+        name   string
+        levels []int
+        want   string
+    }{
+        {"flat", []int{2, 2}, "a b"},
+        {"nested", []int{1, 2, 3, 2, 1}, "a(b(c) d) e"},
+        {"skipped level", []int{1, 3, 2}, "a(b c)"},
+        {"starts deep", []int{3, 1, 2}, "a b(c)"},
+        {"none", nil, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var toc []tocEntry
+            for i, level := range tt.levels {
+                toc = append(toc, tocEntry{Level: level, ID: string(rune('a' + i))})
+            }
+            if got := outlineShape(outlineTree(toc)); got != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestOutlineAPI(t *testing.T) {
+    s, root := newTestServer(t, map[string]string{
+        "guide.md": "---\ntitle: The guide\n---\n# Intro\n\n## Setup\n\n### Linux\n\n## Use\n",
+        "plain.md": "No headings.\n",
+        "draft.md": "---\ndraft: true\n---\n# Draft\n",
+    })
+    get := func() []documentOutline {
+        t.Helper()
+        w := doRequest(s, "GET", "/api/outline", nil, true)
+        if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+            t.Errorf("got Content-Type %q", ct)
+        }
+        var outline []documentOutline
+        if err := json.Unmarshal(w.Body.Bytes(), &outline); err != nil {
+            t.Fatal(err)
+        }
+        return outline
+    }
+
+    outline := get()
+    if len(outline) != 2 {
+        t.Fatalf("got %+v", outline)
+    }
+    if d := outline[0]; d.Path != "guide.md" || d.Title != "The guide" || outlineShape(d.Headings) != "intro(setup(linux) use)" {
+        t.Errorf("got %+v", d)
+    }
+    if d := outline[1]; d.Path != "plain.md" || d.Title != "" || d.Headings == nil || len(d.Headings) != 0 {
+        t.Errorf("got %+v", d)
+    }
+    if h := outline[0].Headings[0].Children[0]; h.Level != 2 || h.Text != "Setup" {
+        t.Errorf("got %+v", h)
+    }
+
+    // A changed document is read again
+    if err := os.WriteFile(filepath.Join(root, "plain.md"), []byte("# Now titled\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if d := get()[1]; d.Title != "Now titled" || outlineShape(d.Headings) != "now-titled" {
+        t.Errorf("got %+v after the change", d)
+    }
+}
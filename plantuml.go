@@ -0,0 +1,114 @@
+package mdserve
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// PlantUML says how ```plantuml blocks are turned into SVG: by a PlantUML
+// server, or by a local command such as ["java", "-jar", "plantuml.jar"].
+// Without either, the blocks stay code blocks.
+type PlantUML struct {
+    Server   string   // e.g. "https://www.plantuml.com/plantuml"
+    Command  []string // Run with -tsvg -pipe when Server is empty
+    CacheDir string   // Generated SVGs; default the user cache dir
+}
+
+func (s *Server) registerPlantUML(p PlantUML) {
+    if p.Server == "" && len(p.Command) == 0 {
+        return
+    }
+    if p.CacheDir == "" {
+        dir, err := os.UserCacheDir()
+        if err != nil {
+            dir = os.TempDir()
+        }
+        p.CacheDir = filepath.Join(dir, "mdserve", "plantuml")
+    }
+    render := func(opts []string, body string) (string, error) {
+        svg, err := p.svg(body)
+        if err != nil {
+            return "", err
+        }
+        return `<figure class="diagram">` + svg + "</figure>\n", nil
+    }
+    s.fences["plantuml"] = render
+    s.fences["puml"] = render
+}
+
+// The SVG for a diagram, from the disk cache when it was rendered before
+func (p PlantUML) svg(source string) (string, error) {
+    source = strings.TrimSpace(source)
+    if !strings.HasPrefix(source, "@start") {
+        source = "@startuml\n" + source + "\n@enduml"
+    }
+    sum := sha256.Sum256([]byte(source))
+    cached := filepath.Join(p.CacheDir, hex.EncodeToString(sum[:])+".svg")
+    if svg, err := ioutil.ReadFile(cached); err == nil {
+        return string(svg), nil
+    }
+
+    var out []byte
+    var err error
+    if p.Server != "" {
+        out, err = p.fetch(source)
+    } else {
+        out, err = p.run(source)
+    }
+    if err != nil {
+        return "", err
+    }
+    // Drop the XML prolog so the SVG can sit inline in the page
+    if i := bytes.Index(out, []byte("<svg")); i > 0 {
+        out = out[i:]
+    }
+    if err := os.MkdirAll(p.CacheDir, 0755); err == nil {
+        ioutil.WriteFile(cached, out, 0644)
+    }
+    return string(out), nil
+}
+
+// Ask the PlantUML server, passing the source hex-encoded in the URL
+func (p PlantUML) fetch(source string) ([]byte, error) {
+    client := &http.Client{Timeout: 20 * time.Second}
+    resp, err := client.Get(strings.TrimSuffix(p.Server, "/") + "/svg/~h" + hex.EncodeToString([]byte(source)))
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    svg, err := ioutil.ReadAll(resp.Body)
+    if err != nil {
+        return nil, err
+    }
+    // The server draws syntax errors into the SVG and flags them with a 400
+    if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+        return nil, fmt.Errorf("PlantUML server: %s", resp.Status)
+    }
+    return svg, nil
+}
+
+// Render with the local PlantUML command
+func (p PlantUML) run(source string) ([]byte, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+    args := append(append([]string{}, p.Command[1:]...), "-tsvg", "-pipe")
+    cmd := exec.CommandContext(ctx, p.Command[0], args...)
+    cmd.Stdin = strings.NewReader(source)
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    out, err := cmd.Output()
+    if err != nil && len(out) == 0 {
+        return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+    }
+    return out, nil
+}
@@ -0,0 +1,48 @@
+package mdserve
+
+import (
+    "encoding/hex"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestPlantUMLServer(t *testing.T) {
+    requests := 0
+    uml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        requests++
+        source, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/svg/~h"))
+        if err != nil || string(source) != "@startuml\nA -> B\n@enduml" {
+            t.Errorf("got %s", r.URL.Path)
+        }
+        w.Write([]byte(`<?xml version="1.0"?><svg id="diagram"></svg>`))
+    }))
+    defer uml.Close()
+    s := New(WithPlantUML(PlantUML{Server: uml.URL, CacheDir: t.TempDir()}))
+
+    src := []byte("```plantuml\nA -> B\n```\n")
+    for i := 0; i < 2; i++ {
+        page := string(s.renderMarkdown(src, s.markdown))
+        if !strings.Contains(page, `<figure class="diagram"><svg id="diagram"></svg></figure>`) || strings.Contains(page, "<?xml") {
+            t.Errorf("got %q", page)
+        }
+    }
+    if requests != 1 {
+        t.Errorf("asked the server %d times, want once and then the cache", requests)
+    }
+}
+
+func TestPlantUMLCommand(t *testing.T) {
+    s := New(WithPlantUML(PlantUML{Command: []string{"sh", "-c", `grep -q '^@startuml' && echo '<svg id="local"></svg>'`}, CacheDir: t.TempDir()}))
+    if page := string(s.renderMarkdown([]byte("```puml\nA -> B\n```\n"), s.markdown)); !strings.Contains(page, `<svg id="local"></svg>`) {
+        t.Errorf("got %q", page)
+    }
+}
+
+func TestPlantUMLOff(t *testing.T) {
+    s := New()
+    if page := string(s.renderMarkdown([]byte("```plantuml\nA -> B\n```\n"), s.markdown)); !strings.Contains(page, "<code") || strings.Contains(page, "diagram") {
+        t.Errorf("got %q", page)
+    }
+}
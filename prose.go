@@ -0,0 +1,146 @@
+package mdserve
+
+import (
+    "html/template"
+    "regexp"
+    "strings"
+)
+
+// Apply fn to the prose of a markdown document, leaving fenced code blocks
+// and inline code spans untouched
+func mapProse(src string, fn func(text string) string) string {
+    lines := strings.SplitAfter(src, "\n")
+    fence := "" // Opening fence while inside a code block
+    for i, line := range lines {
+        trimmed := strings.TrimLeft(line, " \t")
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+                fence = ""
+            }
+            continue
+        }
+        if marker := fenceMarker(trimmed); marker != "" {
+            fence = marker
+            continue
+        }
+        lines[i] = mapOutsideCodeSpans(line, fn)
+    }
+    return strings.Join(lines, "")
+}
+
+// Call fn with each line of a markdown document outside fenced code
+// blocks, numbered from 1
+func proseLines(src string, fn func(n int, line string)) {
+    fence := ""
+    for i, line := range strings.Split(src, "\n") {
+        trimmed := strings.TrimLeft(line, " \t")
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+                fence = ""
+            }
+            continue
+        }
+        if marker := fenceMarker(trimmed); marker != "" {
+            fence = marker
+            continue
+        }
+        fn(i+1, line)
+    }
+}
+
+// Byte ranges of a markdown document taken by fenced code blocks and
+// inline code spans, in order
+func codeRanges(src string) [][2]int {
+    var ranges [][2]int
+    fence, fenceStart := "", 0
+    offset := 0
+    for _, line := range strings.SplitAfter(src, "\n") {
+        start := offset
+        offset += len(line)
+        trimmed := strings.TrimLeft(line, " \t")
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+                ranges = append(ranges, [2]int{fenceStart, offset})
+                fence = ""
+            }
+            continue
+        }
+        if marker := fenceMarker(trimmed); marker != "" {
+            fence, fenceStart = marker, start
+            continue
+        }
+        for rest, at := line, start; rest != ""; {
+            open := strings.Index(rest, "`")
+            if open < 0 {
+                break
+            }
+            n := len(rest[open:]) - len(strings.TrimLeft(rest[open:], "`"))
+            end := strings.Index(rest[open+n:], rest[open:open+n])
+            if end < 0 {
+                break
+            }
+            ranges = append(ranges, [2]int{at + open, at + open + n + end + n})
+            at += open + n + end + n
+            rest = rest[open+n+end+n:]
+        }
+    }
+    if fence != "" {
+        ranges = append(ranges, [2]int{fenceStart, len(src)}) // Unclosed, runs to the end
+    }
+    return ranges
+}
+
+// Whether a byte offset falls in one of ranges
+func inRanges(at int, ranges [][2]int) bool {
+    for _, r := range ranges {
+        if at >= r[0] && at < r[1] {
+            return true
+        }
+    }
+    return false
+}
+
+// The ``` or ~~~ run opening a fenced code block, or ""
+func fenceMarker(line string) string {
+    for _, c := range []string{"`", "~"} {
+        n := len(line) - len(strings.TrimLeft(line, c))
+        if n >= 3 {
+            return strings.Repeat(c, n)
+        }
+    }
+    return ""
+}
+
+// Apply fn to the parts of a line outside `code spans`
+func mapOutsideCodeSpans(line string, fn func(text string) string) string {
+    var out strings.Builder
+    for line != "" {
+        start := strings.Index(line, "`")
+        if start < 0 {
+            out.WriteString(fn(line))
+            break
+        }
+        n := len(line[start:]) - len(strings.TrimLeft(line[start:], "`"))
+        ticks := line[start : start+n]
+        end := strings.Index(line[start+n:], ticks)
+        if end < 0 {
+            out.WriteString(fn(line))
+            break
+        }
+        out.WriteString(fn(line[:start]))
+        out.WriteString(line[start : start+n+end+n])
+        line = line[start+n+end+n:]
+    }
+    return out.String()
+}
+
+var keyRef = regexp.MustCompile(`\[\[([^\[\]\n]{1,30})\]\]`)
+
+// Turn [[Ctrl]]+[[C]] into <kbd> elements
+func expandKeys(src string) string {
+    return mapProse(src, func(text string) string {
+        return keyRef.ReplaceAllStringFunc(text, func(m string) string {
+            return "<kbd>" + template.HTMLEscapeString(m[2:len(m)-2]) + "</kbd>"
+        })
+    })
+}
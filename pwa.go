@@ -0,0 +1,61 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "net/http"
+)
+
+// Service worker: pages come from the network when it's there, and from
+// the cache of previously visited pages when it isn't. Editing, archives
+// and partial downloads always go to the network.
+const serviceWorker = `var CACHE = 'mdserve-v1';
+var scope = self.registration.scope;
+self.addEventListener('install', function (e) {
+    e.waitUntil(caches.open(CACHE).then(function (c) { return c.add(scope); }).catch(function () {}));
+    self.skipWaiting();
+});
+self.addEventListener('activate', function (e) {
+    e.waitUntil(caches.keys().then(function (keys) {
+        return Promise.all(keys.filter(function (k) { return k !== CACHE; }).map(function (k) { return caches.delete(k); }));
+    }).then(function () { return self.clients.claim(); }));
+});
+self.addEventListener('fetch', function (e) {
+    var req = e.request, url = new URL(req.url);
+    if (req.method !== 'GET' || url.origin !== location.origin || req.headers.has('Range') ||
+        url.pathname.indexOf('/edit/') >= 0 || url.pathname.indexOf('/zip/') >= 0) return;
+    e.respondWith(fetch(req).then(function (resp) {
+        var size = +resp.headers.get('Content-Length');
+        if (resp.ok && resp.status === 200 && size < 5e6) {
+            var copy = resp.clone();
+            caches.open(CACHE).then(function (c) { c.put(req, copy); });
+        }
+        return resp;
+    }).catch(function () {
+        return caches.match(req).then(function (hit) {
+            return hit || new Response('Offline, and this page was not opened before.', {status: 503, headers: {'Content-Type': 'text/plain'}});
+        });
+    }));
+});
+`
+
+// Serve /sw.js, scoped to everything under the base path
+func (s *Server) serviceWorkerHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "text/javascript")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Write([]byte(serviceWorker))
+}
+
+// Serve /manifest.webmanifest so the docs can be installed as an app
+func (s *Server) manifestHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/manifest+json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "name":       "mdserve",
+        "short_name": "mdserve",
+        "start_url":  s.basePath + "/",
+        "scope":      s.basePath + "/",
+        "display":    "standalone",
+        "icons": []map[string]string{
+            {"src": s.basePath + "/favicon.ico", "sizes": "32x32", "type": "image/x-icon"},
+        },
+    })
+}
@@ -0,0 +1,60 @@
+package mdserve
+
+import (
+    "html/template"
+    "log"
+    "net/http"
+    "runtime/debug"
+)
+
+// Turn a handler panic into a logged stack and an error page, unless the
+// response had already started, in which case it can only be cut short
+func (s *Server) recoverPanic(w *errorWriter, r *http.Request) {
+    v := recover()
+    if v == nil {
+        return
+    }
+    if v == http.ErrAbortHandler {
+        panic(v)
+    }
+    stack := debug.Stack()
+    log.Printf("Request %s: panic serving %s %s: %v\n%s", w.id, r.Method, r.URL.RequestURI(), v, stack)
+    if w.status != 0 {
+        panic(http.ErrAbortHandler)
+    }
+    s.panicPage(w.ResponseWriter, r, w.id, v, stack)
+}
+
+// The 500 page, with the panic and stack in development mode
+func (s *Server) panicPage(w http.ResponseWriter, r *http.Request, id string, v interface{}, stack []byte) {
+    defer func() {
+        // A broken error template must not hide the original error
+        if recover() != nil {
+            http.Error(w, "Internal server error\nRequest ID: "+id, http.StatusInternalServerError)
+        }
+    }()
+    theme := s.themeFor(s.siteFor(r), nil)
+    data := struct {
+        Base      string
+        Theme     string
+        BaseCSS   template.CSS
+        ThemeCSS  template.CSS
+        RequestID string
+        Panic     interface{} // nil outside development mode
+        Stack     string
+        Data      map[string]interface{}
+    }{
+        Base:      s.basePath,
+        Theme:     theme,
+        BaseCSS:   template.CSS(baseCSS),
+        ThemeCSS:  template.CSS(Themes[theme]),
+        RequestID: id,
+    }
+    if s.dev {
+        data.Panic, data.Stack = v, string(stack)
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Cache-Control", "no-store")
+    w.WriteHeader(http.StatusInternalServerError)
+    s.executeTemplate(w, r, "error.html", data)
+}
@@ -0,0 +1,139 @@
+package mdserve
+
+import (
+    "bufio"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// A rule from a _redirects file: "from to [status]"
+type redirectRule struct {
+    From, To string
+    Status   int
+}
+
+// Read the _redirects file at a mount's root. Paths are relative to the
+// mount; a from ending in "/*" matches everything below it, and ":splat"
+// in to stands for the matched rest.
+func readRedirects(m *Mount) []redirectRule {
+    f, err := os.Open(filepath.Join(m.Root, "_redirects"))
+    if err != nil {
+        return nil
+    }
+    defer f.Close()
+    var rules []redirectRule
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        fields := strings.Fields(scanner.Text())
+        if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+            continue
+        }
+        rule := redirectRule{From: fields[0], To: fields[1], Status: http.StatusMovedPermanently}
+        if len(fields) > 2 {
+            if code, err := strconv.Atoi(strings.TrimSuffix(fields[2], "!")); err == nil && code >= 300 && code < 400 {
+                rule.Status = code
+            }
+        }
+        rules = append(rules, rule)
+    }
+    return rules
+}
+
+// A mount's _redirects rules and the aliases its documents declare
+type mountLinks struct {
+    rules   []redirectRule
+    aliases map[string]string // Alias URL path to the document's path
+}
+
+// Read a mount's redirects and aliases. Aliases are site paths, or
+// relative to the document declaring them; drafts declare none unless
+// the server shows them.
+func (s *Server) readMountLinks(m *Mount) (*mountLinks, error) {
+    entries, err := s.buildIndex(m)
+    if err != nil {
+        return nil, err
+    }
+    links := &mountLinks{rules: readRedirects(m), aliases: map[string]string{}}
+    for _, doc := range s.dropDrafts(nil, entries) {
+        for _, alias := range readFrontMatter(doc.File)["aliases"] {
+            if !strings.HasPrefix(alias, "/") {
+                alias = path.Join("/", path.Dir(doc.Path), alias)
+            }
+            if alias = path.Clean(alias); links.aliases[alias] == "" {
+                links.aliases[alias] = doc.Path
+            }
+        }
+    }
+    return links, nil
+}
+
+// A mount's redirects and aliases, kept with its listed tree so they are
+// read again when the tree is, or when the watcher sees a document change
+func (s *Server) mountLinksFor(m *Mount) (*mountLinks, error) {
+    if s.lite || s.dev {
+        return s.readMountLinks(m)
+    }
+    if _, err := s.buildIndex(m); err != nil {
+        return nil, err
+    }
+    t := s.trees.tree(m)
+    if t == nil {
+        return s.readMountLinks(m)
+    }
+    t.linksMu.Lock()
+    defer t.linksMu.Unlock()
+    if t.links == nil {
+        links, err := s.readMountLinks(m)
+        if err != nil {
+            return nil, err
+        }
+        t.links = links
+    }
+    return t.links, nil
+}
+
+// Where a missing URL path has moved to, from the _redirects files of the
+// site's mounts or the aliases frontmatter of its documents
+func (s *Server) redirectFor(st *site, urlPath string) (string, int) {
+    urlPath = path.Clean("/" + urlPath)
+    var links []*mountLinks
+    for _, m := range st.mounts {
+        l, err := s.mountLinksFor(m)
+        if err != nil {
+            l = &mountLinks{rules: readRedirects(m)} // Without aliases
+        }
+        links = append(links, l)
+    }
+    for i, m := range st.mounts {
+        base := strings.TrimSuffix(m.Prefix, "/")
+        if urlPath != m.Prefix && !strings.HasPrefix(urlPath, base+"/") {
+            continue
+        }
+        rel := "/" + strings.TrimPrefix(strings.TrimPrefix(urlPath, base), "/")
+        for _, rule := range links[i].rules {
+            to := rule.To
+            if prefix, ok := strings.CutSuffix(rule.From, "/*"); ok {
+                if !strings.HasPrefix(rel, prefix+"/") {
+                    continue
+                }
+                to = strings.ReplaceAll(to, ":splat", strings.TrimPrefix(rel, prefix+"/"))
+            } else if path.Clean("/"+rule.From) != rel {
+                continue
+            }
+            if strings.Contains(to, "://") {
+                return to, rule.Status
+            }
+            return s.basePath + path.Join(base, "/"+to), rule.Status
+        }
+    }
+    for _, l := range links {
+        if doc, ok := l.aliases[urlPath]; ok {
+            return s.basePath + "/" + doc, http.StatusMovedPermanently
+        }
+    }
+    return "", 0
+}
@@ -0,0 +1,56 @@
+package mdserve
+
+import (
+    "net/http"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestRedirects(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "_redirects":      "# Moved pages\n/old.md /new.md\n/temp.md /new.md 302\n/blog/* /posts/:splat\n/away https://example.com/\n",
+        "new.md":          "# New",
+        "guides/setup.md": "---\naliases: [install.md, /start.md]\n---\n# Setup",
+        "hidden/draft.md": "---\ndraft: true\naliases: [/secret.md]\n---\n# Draft",
+    })
+    tests := []struct {
+        path     string
+        location string // "" for no redirect
+        status   int
+    }{
+        {"/old.md", "/new.md", http.StatusMovedPermanently},
+        {"/temp.md", "/new.md", http.StatusFound},
+        {"/blog/2024/hello.md", "/posts/2024/hello.md", http.StatusMovedPermanently},
+        {"/away", "https://example.com/", http.StatusMovedPermanently},
+        {"/guides/install.md", "/guides/setup.md", http.StatusMovedPermanently},
+        {"/start.md", "/guides/setup.md", http.StatusMovedPermanently},
+        {"/secret.md", "", http.StatusNotFound},
+        {"/missing.md", "", http.StatusNotFound},
+        {"/new.md", "", http.StatusOK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.path, func(t *testing.T) {
+            w := doRequest(s, "GET", tt.path, nil, true)
+            if w.Code != tt.status || w.Header().Get("Location") != tt.location {
+                t.Errorf("got %d %q, want %d %q", w.Code, w.Header().Get("Location"), tt.status, tt.location)
+            }
+        })
+    }
+}
+
+// Aliases are read once per listed tree, and again after a change
+func TestRedirectsCached(t *testing.T) {
+    s, root := newTestServer(t, map[string]string{"doc.md": "---\naliases: [/a.md]\n---\n"})
+    if w := doRequest(s, "GET", "/a.md", nil, true); w.Code != http.StatusMovedPermanently {
+        t.Fatalf("got %d, want a redirect", w.Code)
+    }
+    os.WriteFile(filepath.Join(root, "doc.md"), []byte("---\naliases: [/b.md]\n---\n"), 0644)
+    if w := doRequest(s, "GET", "/b.md", nil, true); w.Code != http.StatusNotFound {
+        t.Errorf("aliases read again before the tree changed: %d", w.Code)
+    }
+    s.trees.forgetLinks()
+    if w := doRequest(s, "GET", "/b.md", nil, true); w.Code != http.StatusMovedPermanently {
+        t.Errorf("got %d after the change, want a redirect", w.Code)
+    }
+}
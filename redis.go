@@ -0,0 +1,238 @@
+package mdserve
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+const (
+    redisTimeout   = 2 * time.Second // For connecting and for each command
+    redisRetry     = 5 * time.Second // Commands fail at once this long after Redis can't be reached
+    redisIdleConns = 16
+)
+
+// OpenRedisCache connects to Redis at url, redis://[[user]:password@]host[:port][/db],
+// for a CacheBackend shared by every replica pointed at it
+func OpenRedisCache(rawURL string) (CacheBackend, error) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid Redis URL: %v", err)
+    }
+    if u.Scheme != "redis" || u.Host == "" {
+        return nil, fmt.Errorf("invalid Redis URL %q, want redis://host:port/db", rawURL)
+    }
+    c := &redisCache{addr: u.Host, idle: make(chan *redisConn, redisIdleConns)}
+    if u.Port() == "" {
+        c.addr = net.JoinHostPort(u.Hostname(), "6379")
+    }
+    if u.User != nil {
+        c.password, _ = u.User.Password()
+        c.user = u.User.Username()
+        if c.password == "" {
+            c.user, c.password = "", c.user // redis://secret@host
+        }
+    }
+    if db := strings.Trim(u.Path, "/"); db != "" {
+        if c.db, err = strconv.Atoi(db); err != nil {
+            return nil, fmt.Errorf("invalid Redis database %q", db)
+        }
+    }
+    if _, err := c.do("PING"); err != nil {
+        return nil, fmt.Errorf("could not reach Redis at %s: %v", c.addr, err)
+    }
+    return c, nil
+}
+
+type redisCache struct {
+    addr     string
+    user     string
+    password string
+    db       int
+    idle     chan *redisConn
+
+    mu        sync.Mutex
+    downUntil time.Time // Set when Redis can't be reached, so requests don't each wait for it
+}
+
+type redisConn struct {
+    conn net.Conn
+    r    *bufio.Reader
+}
+
+// An error reply from Redis; the connection is still usable
+type redisError string
+
+func (e redisError) Error() string {
+    return "redis: " + string(e)
+}
+
+var errRedisDown = errors.New("redis: not reachable, retrying shortly")
+
+func (c *redisCache) Get(key string) ([]byte, error) {
+    v, err := c.do("GET", key)
+    if err != nil {
+        return nil, err
+    }
+    value, _ := v.([]byte)
+    return value, nil
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) error {
+    args := []interface{}{"SET", key, value}
+    if ttl > 0 {
+        args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+    }
+    _, err := c.do(args...)
+    return err
+}
+
+func (c *redisCache) Close() error {
+    for {
+        select {
+        case rc := <-c.idle:
+            rc.conn.Close()
+        default:
+            return nil
+        }
+    }
+}
+
+// Run a command on an idle connection or a new one, returning the reply:
+// a string, int64, []byte, []interface{} or nil
+func (c *redisCache) do(args ...interface{}) (interface{}, error) {
+    rc, err := c.get()
+    if err != nil {
+        return nil, err
+    }
+    reply, err := rc.command(args...)
+    var replyErr redisError
+    if err != nil && !errors.As(err, &replyErr) {
+        rc.conn.Close() // The connection may be out of step now
+        return nil, err
+    }
+    select {
+    case c.idle <- rc:
+    default:
+        rc.conn.Close()
+    }
+    return reply, err
+}
+
+// An idle connection, or a new one authenticated and on the database
+func (c *redisCache) get() (*redisConn, error) {
+    select {
+    case rc := <-c.idle:
+        return rc, nil
+    default:
+    }
+    c.mu.Lock()
+    down := time.Now().Before(c.downUntil)
+    c.mu.Unlock()
+    if down {
+        return nil, errRedisDown
+    }
+    conn, err := net.DialTimeout("tcp", c.addr, redisTimeout)
+    if err != nil {
+        c.mu.Lock()
+        c.downUntil = time.Now().Add(redisRetry)
+        c.mu.Unlock()
+        return nil, err
+    }
+    rc := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+    var setup [][]interface{}
+    if c.user != "" {
+        setup = append(setup, []interface{}{"AUTH", c.user, c.password})
+    } else if c.password != "" {
+        setup = append(setup, []interface{}{"AUTH", c.password})
+    }
+    if c.db != 0 {
+        setup = append(setup, []interface{}{"SELECT", strconv.Itoa(c.db)})
+    }
+    for _, args := range setup {
+        if _, err := rc.command(args...); err != nil {
+            conn.Close()
+            return nil, err
+        }
+    }
+    return rc, nil
+}
+
+// Send a command as an array of bulk strings and read its reply
+func (rc *redisConn) command(args ...interface{}) (interface{}, error) {
+    rc.conn.SetDeadline(time.Now().Add(redisTimeout))
+    w := bufio.NewWriter(rc.conn)
+    fmt.Fprintf(w, "*%d\r\n", len(args))
+    for _, arg := range args {
+        var b []byte
+        switch v := arg.(type) {
+        case string:
+            b = []byte(v)
+        case []byte:
+            b = v
+        }
+        fmt.Fprintf(w, "$%d\r\n", len(b))
+        w.Write(b)
+        w.WriteString("\r\n")
+    }
+    if err := w.Flush(); err != nil {
+        return nil, err
+    }
+    return rc.reply()
+}
+
+// Read one reply in the Redis protocol (RESP)
+func (rc *redisConn) reply() (interface{}, error) {
+    line, err := rc.r.ReadString('\n')
+    if err != nil {
+        return nil, err
+    }
+    if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+        return nil, fmt.Errorf("redis: malformed reply %q", line)
+    }
+    kind, rest := line[0], line[1:len(line)-2]
+    switch kind {
+    case '+':
+        return rest, nil
+    case '-':
+        return nil, redisError(rest)
+    case ':':
+        return strconv.ParseInt(rest, 10, 64)
+    case '$':
+        n, err := strconv.Atoi(rest)
+        if err != nil {
+            return nil, fmt.Errorf("redis: malformed reply %q", line)
+        }
+        if n < 0 {
+            return nil, nil // Missing
+        }
+        buf := make([]byte, n+2)
+        if _, err := io.ReadFull(rc.r, buf); err != nil {
+            return nil, err
+        }
+        return buf[:n], nil
+    case '*':
+        n, err := strconv.Atoi(rest)
+        if err != nil {
+            return nil, fmt.Errorf("redis: malformed reply %q", line)
+        }
+        if n < 0 {
+            return nil, nil
+        }
+        items := make([]interface{}, n)
+        for i := range items {
+            if items[i], err = rc.reply(); err != nil {
+                return nil, err
+            }
+        }
+        return items, nil
+    }
+    return nil, fmt.Errorf("redis: malformed reply %q", line)
+}
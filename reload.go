@@ -0,0 +1,54 @@
+package mdserve
+
+import (
+    "fmt"
+    "html/template"
+    "log"
+    "net/http"
+)
+
+// OnReload runs fn when the server is told to reload, such as to read a
+// config file again. Its error is reported to whoever asked.
+func (s *Server) OnReload(fn func() error) {
+    s.hooks.onReload = append(s.hooks.onReload, fn)
+}
+
+// Reload drops what the server keeps from files, templates, rendered
+// pages, listed trees and ignore files, so the next requests read them
+// again, then runs the OnReload hooks
+func (s *Server) Reload() error {
+    s.templatesMu.Lock()
+    s.templates = map[string]*template.Template{}
+    s.templatesMu.Unlock()
+    s.cache.clear()
+    s.trees.invalidate()
+    s.ignoreFiles.forgetAll()
+    for _, fn := range s.hooks.onReload {
+        if err := fn(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// POST /admin/reload calls Reload. It needs credentials even on public
+// hosts.
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    if !s.checkCredentials(st, r) {
+        w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+        http.Error(w, "Unauthorized.", http.StatusUnauthorized)
+        return
+    }
+    if r.Method != http.MethodPost {
+        w.Header().Set("Allow", http.MethodPost)
+        http.Error(w, "Use POST to reload", http.StatusMethodNotAllowed)
+        return
+    }
+    if err := s.Reload(); err != nil {
+        log.Printf("Reload failed: %v", err)
+        http.Error(w, fmt.Sprintf("Reload failed: %v", err), http.StatusInternalServerError)
+        return
+    }
+    log.Printf("Reloaded on request from %s", r.RemoteAddr)
+    fmt.Fprintln(w, "Reloaded")
+}
@@ -0,0 +1,198 @@
+package mdserve
+
+import (
+    "errors"
+    "io/ioutil"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/gomarkdown/markdown"
+    "github.com/gomarkdown/markdown/html"
+    "github.com/gomarkdown/markdown/parser"
+)
+
+// Renderer converts markdown to HTML
+type Renderer interface {
+    Render(src []byte) []byte
+}
+
+// RendererFunc adapts a plain function to a Renderer
+type RendererFunc func(src []byte) []byte
+
+func (f RendererFunc) Render(src []byte) []byte {
+    return f(src)
+}
+
+// Settings of the default renderer, site-wide from Config and per
+// document from frontmatter
+type markdownOptions struct {
+    SmartPunctuation bool // Curly quotes, dashes and fractions
+    HardWraps        bool // Every newline in a paragraph is a line break
+    CommonMark       bool // Plain CommonMark, no extensions or preprocessing
+}
+
+// The options for a document: the server's, with frontmatter overrides
+func (s *Server) markdownOptionsFor(fm frontMatter) markdownOptions {
+    opts := s.markdown
+    if v, err := strconv.ParseBool(fm.Get("smart_punctuation")); err == nil {
+        opts.SmartPunctuation = v
+    }
+    if v, err := strconv.ParseBool(fm.Get("hard_wraps")); err == nil {
+        opts.HardWraps = v
+    }
+    return opts
+}
+
+// Parser extensions that only implement CommonMark itself
+const commonMarkExtensions = parser.FencedCode | parser.NoEmptyLineBeforeBlock | parser.SpaceHeadings | parser.BackslashLineBreak
+
+// The default renderer: gomarkdown with common extensions and heading ids,
+// or just CommonMark
+func defaultRenderer(opts markdownOptions) Renderer {
+    return RendererFunc(func(src []byte) []byte {
+        if opts.CommonMark {
+            p := parser.NewWithExtensions(commonMarkExtensions)
+            return markdown.ToHTML(src, p, html.NewRenderer(html.RendererOptions{}))
+        }
+        extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.Footnotes | parser.DefinitionLists
+        if opts.HardWraps {
+            extensions |= parser.HardLineBreak
+        }
+        p := parser.NewWithExtensions(extensions)
+        flags := html.FootnoteReturnLinks
+        if opts.SmartPunctuation {
+            flags |= html.Smartypants | html.SmartypantsFractions | html.SmartypantsDashes | html.SmartypantsLatexDashes
+        }
+        r := html.NewRenderer(html.RendererOptions{Flags: flags})
+        return markdown.ToHTML(src, p, r)
+    })
+}
+
+// Render markdown to HTML, expanding shortcodes, CSV and other fences, alerts,
+// admonitions, abbreviations, table attributes and [[key]] references. Opts
+// apply unless a custom Renderer is configured; shortcodes render their
+// content with the site-wide options. In CommonMark mode the body goes to
+// the renderer untouched.
+func (s *Server) renderMarkdown(body []byte, opts markdownOptions) []byte {
+    renderer := s.renderer
+    if renderer == nil {
+        renderer = defaultRenderer(opts)
+    }
+    if opts.CommonMark {
+        return renderer.Render(body)
+    }
+    src, abbrs := extractAbbreviations(string(body))
+    src, placeholders := s.expandShortcodes(src)
+    src = s.expandFences(src, placeholders)
+    src = s.expandAlerts(src, placeholders, opts)
+    src = s.expandAdmonitions(src, placeholders, opts)
+    src = expandKeys(markTables(src))
+    out := string(renderer.Render([]byte(src)))
+    // Placeholders can hold others, such as a shortcode in an alert, so
+    // substitute until none is left
+    for pass := 0; pass <= len(placeholders); pass++ {
+        replaced := false
+        for key, html := range placeholders {
+            if strings.Contains(out, key) {
+                out = strings.ReplaceAll(out, "<p>"+key+"</p>", html)
+                out = strings.ReplaceAll(out, key, html)
+                replaced = true
+            }
+        }
+        if !replaced {
+            break
+        }
+    }
+    out = embedMedia(embedCasts(s.applyTableClasses(linkFootnotes(out))))
+    return []byte(applyAbbreviations(out, abbrs))
+}
+
+// A markdown file ready to be placed in the page template
+type renderedPage struct {
+    FrontMatter frontMatter
+    HTML        []byte
+    TOC         []tocEntry
+}
+
+// Returned for documents over Config.MaxFileSize, which are not rendered
+var errTooLarge = errors.New("document too large to render")
+
+// Returned when no render slot frees up within Config.RenderTimeout
+var errBusy = errors.New("too many documents rendering")
+
+// Wait for one of the Config.RenderWorkers render slots; release it with
+// releaseRender
+func (s *Server) acquireRender() error {
+    if s.renderSlots == nil {
+        return nil
+    }
+    select {
+    case s.renderSlots <- struct{}{}:
+        return nil
+    default:
+    }
+    timer := time.NewTimer(s.renderTimeout)
+    defer timer.Stop()
+    select {
+    case s.renderSlots <- struct{}{}:
+        return nil
+    case <-timer.C:
+        return errBusy
+    }
+}
+
+func (s *Server) releaseRender() {
+    if s.renderSlots != nil {
+        <-s.renderSlots
+    }
+}
+
+// Read and render a markdown file, using the page cache when enabled
+func (s *Server) renderFile(file string) (*renderedPage, error) {
+    info, err := os.Stat(file)
+    if err != nil {
+        return nil, err
+    }
+    if s.maxFileSize > 0 && info.Size() > s.maxFileSize {
+        return nil, errTooLarge
+    }
+    if page := s.cache.get(s.cacheKey(file), info.ModTime(), info.Size()); page != nil {
+        return page, nil
+    }
+    if page := new(renderedPage); s.fromBackend("page", file, info, page) {
+        s.cache.put(s.cacheKey(file), info.ModTime(), info.Size(), page)
+        return page, nil
+    }
+    if err := s.acquireRender(); err != nil {
+        return nil, err
+    }
+    defer s.releaseRender()
+
+    content, err := ioutil.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    fm, body := parseFrontMatter(content)
+    if s.formatFor(file) == nil && !s.markdownOptionsFor(fm).CommonMark {
+        body = markTasks(body)
+    }
+    for _, fn := range s.hooks.beforeRender {
+        body = fn(file, body)
+    }
+    var rendered []byte
+    if format := s.formatFor(file); format != nil {
+        rendered = taskCheckboxes(format.Render(body))
+    } else if opts := s.markdownOptionsFor(fm); opts.CommonMark {
+        rendered = s.renderMarkdown(body, opts)
+    } else {
+        rendered = taskCheckboxes(s.renderMarkdown(body, opts))
+    }
+    for _, fn := range s.hooks.afterRender {
+        rendered = fn(file, rendered)
+    }
+    page := &renderedPage{FrontMatter: fm, HTML: rendered, TOC: extractTOC(rendered)}
+    s.cache.put(s.cacheKey(file), info.ModTime(), info.Size(), page)
+    s.toBackend("page", file, info, page)
+    return page, nil
+}
@@ -0,0 +1,108 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestSmartPunctuation(t *testing.T) {
+    const body = "\"Quoted\" it's -- and --- 1/2 `\"code\" --`\n"
+    const smart = "<p>&ldquo;Quoted&rdquo; it&rsquo;s &ndash; and &mdash; <sup>1</sup>&frasl;<sub>2</sub> <code>&quot;code&quot; --</code></p>"
+    const plain = "<p>&quot;Quoted&quot; it's -- and --- 1/2 <code>&quot;code&quot; --</code></p>"
+    tests := []struct {
+        name  string
+        fm    string
+        opts  []Option
+        smart bool
+    }{
+        {"off by default", "", nil, false},
+        {"server option", "", []Option{WithSmartPunctuation()}, true},
+        {"frontmatter on", "smart_punctuation: true\n", nil, true},
+        {"frontmatter off", "smart_punctuation: false\n", []Option{WithSmartPunctuation()}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            src := body
+            if tt.fm != "" {
+                src = "---\n" + tt.fm + "---\n" + body
+            }
+            s, _ := newTestServer(t, map[string]string{"doc.md": src}, tt.opts...)
+            page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+            want := plain
+            if tt.smart {
+                want = smart
+            }
+            if !strings.Contains(page, want) {
+                t.Errorf("%q missing from %q", want, page)
+            }
+        })
+    }
+
+    // Alerts and admonitions render their bodies with the same options
+    s := New(WithSmartPunctuation())
+    for _, src := range []string{"> [!NOTE]\n> \"Hi\" -- there\n", "!!! note\n    \"Hi\" -- there\n"} {
+        if page := string(s.renderMarkdown([]byte(src), s.markdown)); !strings.Contains(page, "&ldquo;Hi&rdquo; &ndash; there") {
+            t.Errorf("plain punctuation in %q", page)
+        }
+    }
+}
+
+func TestHardWraps(t *testing.T) {
+    const body = "One\ntwo\n\n```\nx\ny\n```\n"
+    tests := []struct {
+        name    string
+        fm      string
+        opts    []Option
+        wrapped bool
+    }{
+        {"off by default", "", nil, false},
+        {"server option", "", []Option{WithHardWraps()}, true},
+        {"frontmatter on", "hard_wraps: true\n", nil, true},
+        {"frontmatter off", "hard_wraps: false\n", []Option{WithHardWraps()}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            src := body
+            if tt.fm != "" {
+                src = "---\n" + tt.fm + "---\n" + body
+            }
+            s, _ := newTestServer(t, map[string]string{"doc.md": src}, tt.opts...)
+            page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+            want := "<p>One\ntwo</p>"
+            if tt.wrapped {
+                want = "<p>One<br>\ntwo</p>"
+            }
+            if !strings.Contains(page, want) {
+                t.Errorf("%q missing from %q", want, page)
+            }
+            if !strings.Contains(page, "<pre><code>x\ny\n</code></pre>") {
+                t.Errorf("code block changed in %q", page)
+            }
+        })
+    }
+}
+
+func TestCommonMark(t *testing.T) {
+    src := "# Title\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n> [!NOTE]\n> Hi\n\n{{< kbd \"Ctrl\" >}}\n\n- [ ] task\n\nText[^1] -- \"quoted\".\n\n[^1]: Note.\n"
+    s, _ := newTestServer(t, map[string]string{"doc.md": src}, WithCommonMark(), WithSmartPunctuation())
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    _, page, _ = strings.Cut(page, `<div class="content"`) // After the styles
+    want := []string{
+        "<h1>Title</h1>",
+        "<p>| a | b |\n|---|---|\n| 1 | 2 |</p>",
+        "<blockquote>\n<p>[!NOTE]\nHi</p>\n</blockquote>",
+        "<p>{{&lt; kbd &quot;Ctrl&quot; &gt;}}</p>",
+        "<li>[ ] task</li>",
+        "-- &quot;quoted&quot;.</p>",
+    }
+    for _, w := range want {
+        if !strings.Contains(page, w) {
+            t.Errorf("%q missing from %q", w, page)
+        }
+    }
+    for _, unwanted := range []string{"<table>", "alert-note", "<kbd>", `type="checkbox"`, "footnote", "&ldquo;"} {
+        if strings.Contains(page, unwanted) {
+            t.Errorf("%q in %q", unwanted, page)
+        }
+    }
+}
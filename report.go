@@ -0,0 +1,73 @@
+package mdserve
+
+import (
+    "io/ioutil"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// DocInfo describes one document for reporting
+type DocInfo struct {
+    Path        string    `json:"path"` // URL path without the leading slash
+    Title       string    `json:"title"`
+    Tags        []string  `json:"tags"`
+    Owner       string    `json:"owner"`
+    Words       int       `json:"words"`
+    ModTime     time.Time `json:"mtime"`
+    Views       int       `json:"views"` // From the Store, see Config.Stats
+    BrokenLinks []string  `json:"broken_links"`
+}
+
+// Report describes every document served for the default host
+func (s *Server) Report() ([]DocInfo, error) {
+    docs, err := s.siteDocs(s.defaultSite, nil)
+    if err != nil {
+        return nil, err
+    }
+    var infos []DocInfo
+    for _, doc := range docs {
+        info, err := os.Stat(doc.File)
+        if err != nil {
+            continue
+        }
+        content, err := ioutil.ReadFile(doc.File)
+        if err != nil {
+            continue
+        }
+        fm, body := parseFrontMatter(content)
+        views, err := s.store.Get(viewsBucket, viewsKey(s.defaultSite, doc.Path))
+        if err != nil {
+            return nil, err
+        }
+        n, _ := strconv.Atoi(string(views))
+        infos = append(infos, DocInfo{
+            Path:        doc.Path,
+            Title:       documentTitle(fm, string(body)),
+            Tags:        fm["tags"],
+            Owner:       fm.Get("owner"),
+            Words:       len(strings.Fields(string(body))),
+            ModTime:     info.ModTime(),
+            Views:       n,
+            BrokenLinks: brokenLinks(doc.File, string(body)),
+        })
+    }
+    return infos, nil
+}
+
+// The frontmatter title, or else the first level-one heading (or AsciiDoc
+// document title)
+func documentTitle(fm frontMatter, body string) string {
+    if title := fm.Get("title"); title != "" {
+        return title
+    }
+    title := ""
+    mapProse(body, func(text string) string {
+        if title == "" && (strings.HasPrefix(text, "# ") || strings.HasPrefix(text, "= ")) {
+            title = strings.TrimSpace(strings.Trim(text[2:], "#=\n "))
+        }
+        return text
+    })
+    return title
+}
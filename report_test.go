@@ -0,0 +1,51 @@
+package mdserve
+
+import (
+    "testing"
+)
+
+func TestReportViews(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "a.md":      "# A",
+        "docs/b.md": "# B",
+        "c.md":      "# C",
+    }, WithStats())
+    for _, p := range []string{"/a.md", "/docs/b.md", "/docs/b.md"} {
+        doRequest(s, "GET", p, nil, true)
+    }
+    docs, err := s.Report()
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := map[string]int{"a.md": 1, "docs/b.md": 2, "c.md": 0}
+    for _, d := range docs {
+        if d.Views != want[d.Path] {
+            t.Errorf("%s: got %d views, want %d", d.Path, d.Views, want[d.Path])
+        }
+        delete(want, d.Path)
+    }
+    if len(want) > 0 {
+        t.Errorf("missing from the report: %v", want)
+    }
+}
+
+func TestReportDrafts(t *testing.T) {
+    files := map[string]string{"a.md": "# A", "draft.md": "---\ndraft: true\n---\n# Draft"}
+    tests := []struct {
+        name string
+        opts []Option
+        want int
+    }{
+        {"drafts left out", nil, 1},
+        {"drafts included", []Option{WithDrafts()}, 2},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, files, tt.opts...)
+            docs, err := s.Report()
+            if err != nil || len(docs) != tt.want {
+                t.Errorf("got %d documents, %v; want %d", len(docs), err, tt.want)
+            }
+        })
+    }
+}
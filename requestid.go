@@ -0,0 +1,84 @@
+package mdserve
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "net/http"
+    "regexp"
+    "strings"
+)
+
+// Request IDs a proxy in front may already have assigned
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// RequestID returns the ID the server gave a request, "" outside of one.
+// It is sent in the X-Request-ID header, appended to error messages and
+// logged with server errors and in the access log, so a reader reporting
+// an error can be matched to the logs.
+func RequestID(r *http.Request) string {
+    id, _ := r.Context().Value(requestIDKey{}).(string)
+    return id
+}
+
+// Tag a request with an ID, keeping the X-Request-ID a proxy set
+func withRequestID(w http.ResponseWriter, r *http.Request) (*http.Request, string) {
+    id := r.Header.Get("X-Request-ID")
+    if !validRequestID.MatchString(id) {
+        b := make([]byte, 8)
+        rand.Read(b)
+        id = hex.EncodeToString(b)
+    }
+    w.Header().Set("X-Request-ID", id)
+    return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)), id
+}
+
+// Adds the request ID to plain-text error responses, such as those from
+// http.Error, and logs server errors with it
+type errorWriter struct {
+    http.ResponseWriter
+    id      string
+    status  int
+    message strings.Builder // Of a plain-text error, for the log
+}
+
+func (w *errorWriter) WriteHeader(status int) {
+    if w.status == 0 {
+        w.status = status
+    }
+    w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+    if w.status == 0 {
+        w.status = http.StatusOK
+    }
+    if w.plainError() && w.message.Len() < 200 {
+        w.message.Write(p)
+    }
+    return w.ResponseWriter.Write(p)
+}
+
+func (w *errorWriter) Flush() {
+    if f, ok := w.ResponseWriter.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+func (w *errorWriter) plainError() bool {
+    return w.status >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain")
+}
+
+// Finish the response once the handler is done
+func (w *errorWriter) finish(r *http.Request) {
+    if w.plainError() && r.Method != http.MethodHead {
+        fmt.Fprintf(w.ResponseWriter, "Request ID: %s\n", w.id)
+    }
+    if w.status >= 500 {
+        log.Printf("Request %s: %s %s: %d %s", w.id, r.Method, r.URL.RequestURI(), w.status, strings.TrimSpace(w.message.String()))
+    }
+}
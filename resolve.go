@@ -0,0 +1,48 @@
+package mdserve
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "golang.org/x/text/unicode/norm"
+)
+
+// Whether a file name matches a path component from a URL. Accented names
+// match in either Unicode form: macOS writes "é" decomposed (NFD) while
+// links are usually typed composed (NFC). Case is ignored with foldCase.
+func (st *site) sameName(name, part string) bool {
+    name, part = norm.NFC.String(name), norm.NFC.String(part)
+    if st.foldCase {
+        return strings.EqualFold(name, part)
+    }
+    return name == part
+}
+
+// Find the file under root whose slash-separated path matches rel
+// component by component under same, where each component has exactly one
+// match. Returns the path as it is on disk.
+func lookupPath(root, rel string, same func(a, b string) bool) (string, bool) {
+    dir := root
+    var found []string
+    for _, part := range strings.Split(rel, "/") {
+        entries, err := os.ReadDir(dir)
+        if err != nil {
+            return "", false
+        }
+        match := ""
+        for _, e := range entries {
+            if same(e.Name(), part) {
+                if match != "" {
+                    return "", false
+                }
+                match = e.Name()
+            }
+        }
+        if match == "" {
+            return "", false
+        }
+        found = append(found, match)
+        dir = filepath.Join(dir, match)
+    }
+    return strings.Join(found, "/"), true
+}
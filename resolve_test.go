@@ -0,0 +1,78 @@
+package mdserve
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestCaseInsensitivePaths(t *testing.T) {
+    files := map[string]string{
+        "Guide/README.md": "# Guide readme\n",
+        "notes.md":        "# Lower notes\n",
+        "Notes.md":        "# Upper notes\n",
+    }
+    tests := []struct {
+        name   string
+        target string
+        fold   bool
+        status int
+        want   string
+    }{
+        {"exact", "/Guide/README.md", true, http.StatusOK, "Guide readme"},
+        {"different case", "/guide/readme.MD", true, http.StatusOK, "Guide readme"},
+        {"off by default", "/guide/readme.MD", false, http.StatusNotFound, ""},
+        {"exact among several", "/Notes.md", true, http.StatusOK, "Upper notes"},
+        {"several matches", "/NOTES.md", true, http.StatusNotFound, ""},
+        {"no match", "/guide/other.md", true, http.StatusNotFound, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var opts []Option
+            if tt.fold {
+                opts = append(opts, WithCaseInsensitivePaths())
+            }
+            s, _ := newTestServer(t, files, opts...)
+            w := doRequest(s, "GET", tt.target, nil, true)
+            if w.Code != tt.status {
+                t.Fatalf("got %d, want %d", w.Code, tt.status)
+            }
+            if !strings.Contains(w.Body.String(), tt.want) {
+                t.Errorf("%q missing from %q", tt.want, w.Body.String())
+            }
+        })
+    }
+}
+
+func TestUnicodeNormalizedPaths(t *testing.T) {
+    const nfc, nfd = "caf\u00e9", "cafe\u0301"
+    tests := []struct {
+        name   string
+        file   string // On disk
+        target string
+        fold   bool
+        status int
+    }{
+        {"composed link, decomposed file", nfd + ".md", "/" + nfc + ".md", false, http.StatusOK},
+        {"decomposed link, composed file", nfc + ".md", "/" + nfd + ".md", false, http.StatusOK},
+        {"escaped link", nfd + "/menu.md", "/caf%C3%A9/menu.md", false, http.StatusOK},
+        {"case and form", nfd + ".md", "/CAFÉ.md", true, http.StatusOK},
+        {"case without folding", nfd + ".md", "/CAFÉ.md", false, http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var opts []Option
+            if tt.fold {
+                opts = append(opts, WithCaseInsensitivePaths())
+            }
+            s, _ := newTestServer(t, map[string]string{tt.file: "# Menu\n"}, opts...)
+            w := doRequest(s, "GET", tt.target, nil, true)
+            if w.Code != tt.status {
+                t.Fatalf("got %d, want %d", w.Code, tt.status)
+            }
+            if tt.status == http.StatusOK && !strings.Contains(w.Body.String(), `<h1 id="menu">Menu</h1>`) {
+                t.Errorf("wrong page %q", w.Body.String())
+            }
+        })
+    }
+}
@@ -0,0 +1,269 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "encoding/xml"
+    "html/template"
+    "math"
+    "net/http"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// A document matching a search
+type searchResult struct {
+    Path    string        `json:"path"` // URL path without the leading slash
+    Name    string        `json:"name"`
+    Title   string        `json:"title"`
+    Score   float64       `json:"score"`
+    Anchor  string        `json:"anchor"`  // Heading id of the best matching section, "" for the top
+    Heading string        `json:"heading"` // Its heading text
+    Snippet template.HTML `json:"snippet"` // Text around the first match, terms in <mark>
+    Matches []string      `json:"matches"` // Distinct words that matched, lower-case
+}
+
+// BM25 parameters: how fast repeated terms stop adding to the score, and
+// how much long documents are penalised
+const bm25K1, bm25B = 1.2, 0.75
+
+// Occurrences of words starting with term, so "instal" finds "install"
+func prefixCount(terms map[string]int, term string, matched map[string]bool) int {
+    n := 0
+    for word, count := range terms {
+        if strings.HasPrefix(word, term) {
+            n += count
+            if matched != nil {
+                matched[word] = true
+            }
+        }
+    }
+    return n
+}
+
+// Documents of a site containing every word of query, ranked by BM25 with
+// matches in the title counting extra. Words match at their start, so a
+// partly typed word still finds documents.
+func (s *Server) search(st *site, r *http.Request, query string) ([]searchResult, error) {
+    terms := searchTokens(query)
+    if len(terms) == 0 {
+        return nil, nil
+    }
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        return nil, err
+    }
+
+    type candidate struct {
+        entry IndexEntry
+        doc   *searchDoc
+        tf    []int // Per term
+    }
+    var candidates []candidate
+    df := make([]int, len(terms)) // Documents containing each term
+    total, length := 0, 0
+    listed := map[string]bool{}
+    for _, entry := range docs {
+        listed[entry.File] = true
+        d := s.searchDocFor(entry.File)
+        if d == nil {
+            continue
+        }
+        total++
+        length += d.Length
+        titleTerms := map[string]int{}
+        for _, t := range searchTokens(d.Title) {
+            titleTerms[t]++
+        }
+        tf := make([]int, len(terms))
+        all := true
+        for i, term := range terms {
+            for _, sec := range d.Sections {
+                tf[i] += prefixCount(sec.Terms, term, nil)
+            }
+            tf[i] += 5 * prefixCount(titleTerms, term, nil)
+            if tf[i] > 0 {
+                df[i]++
+            } else {
+                all = false
+            }
+        }
+        if all {
+            candidates = append(candidates, candidate{entry, d, tf})
+        }
+    }
+    s.dropRemovedSearchDocs(listed)
+    if len(candidates) == 0 {
+        return nil, nil
+    }
+    avg := float64(length) / float64(total)
+
+    var results []searchResult
+    for _, c := range candidates {
+        res := searchResult{Path: c.entry.Path, Name: c.entry.Name, Title: c.doc.Title}
+        norm := bm25K1 * (1 - bm25B + bm25B*float64(c.doc.Length)/math.Max(avg, 1))
+        for i, tf := range c.tf {
+            idf := math.Log(1 + (float64(total-df[i])+0.5)/(float64(df[i])+0.5))
+            res.Score += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+        }
+
+        // The section where the terms occur most
+        best, bestHits := -1, 0
+        matched := map[string]bool{}
+        for j, sec := range c.doc.Sections {
+            hits := 0
+            for _, term := range terms {
+                hits += prefixCount(sec.Terms, term, matched)
+            }
+            if hits > bestHits {
+                best, bestHits = j, hits
+            }
+        }
+        if best >= 0 {
+            sec := c.doc.Sections[best]
+            res.Anchor, res.Heading = sec.ID, sec.Heading
+            res.Snippet = searchSnippet(sec.Text, terms)
+        }
+        for word := range matched {
+            res.Matches = append(res.Matches, word)
+        }
+        sort.Strings(res.Matches)
+        results = append(results, res)
+    }
+    sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+    return results, nil
+}
+
+// Characters of context shown around the first match
+const snippetLength = 200
+
+// The part of text around the first word starting with one of terms, or
+// its start, with the matching words marked
+func searchSnippet(text string, terms []string) template.HTML {
+    quoted := make([]string, len(terms))
+    for i, t := range terms {
+        quoted[i] = regexp.QuoteMeta(t)
+    }
+    match := regexp.MustCompile(`(?i)(?:^|[^\pL\pN])((?:` + strings.Join(quoted, "|") + `)[\pL\pN]*)`)
+    first := match.FindStringSubmatchIndex(text)
+    if first == nil {
+        first = []int{0, 0, 0, 0} // Matched in the heading: show the start
+    }
+    start := 0
+    if first[2] > snippetLength/3 {
+        start = first[2] - snippetLength/3
+        if i := strings.IndexByte(text[start:first[2]], ' '); i >= 0 {
+            start += i + 1
+        }
+    }
+    end := len(text)
+    if end-start > snippetLength {
+        end = start + snippetLength
+        if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+            end = start + i
+        }
+        for !utf8.RuneStart(text[end]) {
+            end--
+        }
+    }
+
+    var out strings.Builder
+    if start > 0 {
+        out.WriteString("… ")
+    }
+    part, last := text[start:end], 0
+    for _, m := range match.FindAllStringSubmatchIndex(part, -1) {
+        out.WriteString(template.HTMLEscapeString(part[last:m[2]]))
+        out.WriteString("<mark>" + template.HTMLEscapeString(part[m[2]:m[3]]) + "</mark>")
+        last = m[3]
+    }
+    out.WriteString(template.HTMLEscapeString(part[last:]))
+    if end < len(text) {
+        out.WriteString(" …")
+    }
+    return template.HTML(out.String())
+}
+
+// Serve /api/search?q=: the ranked results as JSON, at most ?limit=
+// (default 20) of them
+func (s *Server) searchAPIHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    results, err := s.search(st, r, r.URL.Query().Get("q"))
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+    limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+    if err != nil || limit <= 0 {
+        limit = 20
+    }
+    if results == nil {
+        results = []searchResult{}
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(results[:min(len(results), limit)])
+}
+
+// Search a site's documents with ?q=
+func (s *Server) searchHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    query := r.URL.Query().Get("q")
+    results, err := s.search(st, r, query)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+
+    theme := s.themeFor(st, nil)
+    data := struct {
+        Base     string
+        Theme    string
+        BaseCSS  template.CSS
+        ThemeCSS template.CSS
+        Query    string
+        Results  []searchResult
+        Partial  bool // Some documents weren't listed, see MaxFiles
+        Data     map[string]interface{}
+    }{
+        Base:     s.basePath,
+        Theme:    theme,
+        BaseCSS:  template.CSS(baseCSS),
+        ThemeCSS: template.CSS(Themes[theme]),
+        Query:    query,
+        Results:  results,
+        Data:     s.pageData(r, ""),
+    }
+    for _, m := range st.mounts {
+        data.Partial = data.Partial || s.trees.isTruncated(m)
+    }
+
+    s.executeTemplate(w, r, "search.html", data)
+}
+
+// Serve /opensearch.xml so browsers can offer the site as a search engine
+func (s *Server) openSearchHandler(w http.ResponseWriter, r *http.Request) {
+    type urlTemplate struct {
+        Type     string `xml:"type,attr"`
+        Method   string `xml:"method,attr"`
+        Template string `xml:"template,attr"`
+    }
+    desc := struct {
+        XMLName       xml.Name    `xml:"OpenSearchDescription"`
+        XMLNS         string      `xml:"xmlns,attr"`
+        ShortName     string      `xml:"ShortName"`
+        Description   string      `xml:"Description"`
+        InputEncoding string      `xml:"InputEncoding"`
+        Image         string      `xml:"Image"`
+        URL           urlTemplate `xml:"Url"`
+    }{
+        XMLNS:         "http://a9.com/-/spec/opensearch/1.1/",
+        ShortName:     r.Host,
+        Description:   "Search the documents on " + r.Host,
+        InputEncoding: "UTF-8",
+        Image:         s.siteURL(r) + "/favicon.ico",
+        URL:           urlTemplate{Type: "text/html", Method: "get", Template: s.siteURL(r) + "/search?q={searchTerms}"},
+    }
+    w.Header().Set("Content-Type", "application/opensearchdescription+xml")
+    w.Write([]byte(xml.Header))
+    xml.NewEncoder(w).Encode(desc)
+}
@@ -0,0 +1,57 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestSearchPage(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "install.md": "---\ntitle: Installing\n---\n# Setup\n\nRun the installer.\n\n## Upgrades\n\nUpgrade with the installer too.\n",
+        "usage.md":   "# Usage\n\nStart the server.\n",
+        "draft.md":   "---\ndraft: true\n---\nThe installer again.\n",
+    })
+    tests := []struct {
+        name    string
+        query   string
+        want    []string
+        notWant []string
+    }{
+        {"found", "installer", []string{"<p>1 found</p>", `<a href="/install.md#setup">Installing &rsaquo; Setup</a> <small>install.md</small>`, "Run the <mark>installer</mark>."}, []string{"usage.md", "draft.md"}},
+        {"partly typed", "insta", []string{"<p>1 found</p>", "<mark>installer</mark>"}, nil},
+        {"every word", "upgrade installer", []string{"<p>1 found</p>", `href="/install.md#upgrades"`}, nil},
+        {"nothing", "zebra", []string{"<p>0 found</p>"}, []string{"<li>"}},
+        {"no query", "", []string{`<input type="search" name="q" value="" autofocus>`}, []string{"found"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            page := doRequest(s, "GET", "/search?q="+strings.ReplaceAll(tt.query, " ", "+"), nil, true).Body.String()
+            for _, want := range tt.want {
+                if !strings.Contains(page, want) {
+                    t.Errorf("%q missing from %q", want, page)
+                }
+            }
+            for _, unwanted := range tt.notWant {
+                if strings.Contains(page, unwanted) {
+                    t.Errorf("%q in %q", unwanted, page)
+                }
+            }
+        })
+    }
+}
+
+func TestOpenSearch(t *testing.T) {
+    s, _ := newTestServer(t, nil)
+    w := doRequest(s, "GET", "/opensearch.xml", nil, true)
+    if ct := w.Header().Get("Content-Type"); ct != "application/opensearchdescription+xml" {
+        t.Errorf("got Content-Type %q", ct)
+    }
+    for _, want := range []string{"<ShortName>example.com</ShortName>", `<Url type="text/html" method="get" template="http://example.com/search?q={searchTerms}">`} {
+        if !strings.Contains(w.Body.String(), want) {
+            t.Errorf("%q missing from %q", want, w.Body.String())
+        }
+    }
+    if page := doRequest(s, "GET", "/search", nil, true).Body.String(); !strings.Contains(page, `<link rel="search" type="application/opensearchdescription+xml" href="/opensearch.xml"`) {
+        t.Error("pages don't link the OpenSearch descriptor")
+    }
+}
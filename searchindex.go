@@ -0,0 +1,191 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "html"
+    "log"
+    "os"
+    "regexp"
+    "strings"
+    "sync"
+    "time"
+)
+
+// A document prepared for searching
+type searchDoc struct {
+    ModTime  time.Time
+    Size     int64
+    Title    string
+    Length   int // Words in the document
+    Sections []searchSection
+}
+
+// The text from one heading to the next; the part before the first
+// heading has no ID
+type searchSection struct {
+    ID      string
+    Heading string
+    Text    string
+    Terms   map[string]int // Occurrences of each word, heading included
+}
+
+// Documents prepared for searching, keyed by file, kept until the file
+// changes
+type searchIndex struct {
+    mu   sync.Mutex
+    docs map[string]*searchDoc
+}
+
+var searchWord = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// The lower-case words of a text
+func searchTokens(text string) []string {
+    return searchWord.FindAllString(strings.ToLower(text), -1)
+}
+
+// Bucket of prepared documents, keyed by file, so the index survives
+// restarts when the Store is on disk
+const searchBucket = "search"
+
+// A document from the search index, rendering it when it is new or has
+// changed; nil when it can't be read
+func (s *Server) searchDocFor(file string) *searchDoc {
+    info, err := os.Stat(file)
+    if err != nil {
+        if os.IsNotExist(err) {
+            s.forgetSearchFile(file)
+        }
+        return nil
+    }
+    current := func(d *searchDoc) bool {
+        return d != nil && d.ModTime.Equal(info.ModTime()) && d.Size == info.Size()
+    }
+    s.searchIndex.mu.Lock()
+    d := s.searchIndex.docs[file]
+    s.searchIndex.mu.Unlock()
+    if current(d) {
+        return d
+    }
+    if s.persistSearch(file) {
+        if data, err := s.store.Get(searchBucket, file); err == nil && data != nil {
+            d = nil
+            if json.Unmarshal(data, &d) == nil && current(d) {
+                s.rememberSearchDoc(file, d)
+                return d
+            }
+        }
+    }
+    if d = new(searchDoc); s.fromBackend("search", file, info, d) {
+        s.rememberSearchDoc(file, d)
+        return d
+    }
+    return s.indexSearchFile(file)
+}
+
+// Prepare a document for searching and store it, replacing what was there
+func (s *Server) indexSearchFile(file string) *searchDoc {
+    info, err := os.Stat(file)
+    if err != nil {
+        return nil
+    }
+    page, err := s.renderFile(file)
+    if err != nil {
+        return nil
+    }
+    d := newSearchDoc(page)
+    d.ModTime, d.Size = info.ModTime(), info.Size()
+    s.rememberSearchDoc(file, d)
+    s.toBackend("search", file, info, d)
+    if !s.persistSearch(file) {
+        return d
+    }
+    if data, err := json.Marshal(d); err == nil {
+        if err := s.store.Put(searchBucket, file, data); err != nil {
+            log.Printf("Search index: %v", err)
+        }
+    }
+    return d
+}
+
+// Whether a document's index entry goes in the Store: not in lite mode
+// nor when that is in memory anyway, nor for documents decrypted from
+// .gpg files, whose text must not reach the disk
+func (s *Server) persistSearch(file string) bool {
+    if _, ok := s.store.(*memoryStore); ok || s.lite {
+        return false
+    }
+    return !decrypted(file)
+}
+
+// Keep a prepared document for the next search; lite mode prepares
+// them again instead
+func (s *Server) rememberSearchDoc(file string, d *searchDoc) {
+    if s.lite {
+        return
+    }
+    s.searchIndex.mu.Lock()
+    defer s.searchIndex.mu.Unlock()
+    if s.searchIndex.docs == nil {
+        s.searchIndex.docs = map[string]*searchDoc{}
+    }
+    s.searchIndex.docs[file] = d
+}
+
+// Drop a deleted document from the index
+func (s *Server) forgetSearchFile(file string) {
+    s.searchIndex.mu.Lock()
+    delete(s.searchIndex.docs, file)
+    s.searchIndex.mu.Unlock()
+    if err := s.store.Delete(searchBucket, file); err != nil {
+        log.Printf("Search index: %v", err)
+    }
+}
+
+// Drop the prepared documents whose files are gone, looking only at those
+// not in listed, as the rest were just found; without a watcher nothing
+// else notices
+func (s *Server) dropRemovedSearchDocs(listed map[string]bool) {
+    s.searchIndex.mu.Lock()
+    var files []string
+    for file := range s.searchIndex.docs {
+        if !listed[file] {
+            files = append(files, file)
+        }
+    }
+    s.searchIndex.mu.Unlock()
+    for _, file := range files {
+        if _, err := os.Stat(file); os.IsNotExist(err) {
+            s.forgetSearchFile(file)
+        }
+    }
+}
+
+// Split a rendered page into sections at its headings
+func newSearchDoc(page *renderedPage) *searchDoc {
+    d := &searchDoc{Title: page.FrontMatter.Get("title")}
+    content := string(page.HTML)
+    plain := func(h string) string {
+        return strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(h, " "))), " ")
+    }
+    add := func(id, heading, body string) {
+        sec := searchSection{ID: id, Heading: heading, Text: plain(body), Terms: map[string]int{}}
+        for _, t := range searchTokens(heading + " " + sec.Text) {
+            sec.Terms[t]++
+            d.Length++
+        }
+        if sec.Heading != "" || sec.Text != "" {
+            d.Sections = append(d.Sections, sec)
+        }
+    }
+    id, heading, start := "", "", 0
+    for _, m := range headingTag.FindAllStringSubmatchIndex(content, -1) {
+        add(id, heading, content[start:m[0]])
+        level, text := content[m[2]:m[3]], plain(content[m[6]:m[7]])
+        if d.Title == "" && level == "1" {
+            d.Title = text
+        }
+        id, heading, start = content[m[4]:m[5]], text, m[1]
+    }
+    add(id, heading, content[start:])
+    return d
+}
@@ -0,0 +1,48 @@
+package mdserve
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// The documents prepared for searching, by file
+func indexedFiles(s *Server) []string {
+    s.searchIndex.mu.Lock()
+    defer s.searchIndex.mu.Unlock()
+    var files []string
+    for file := range s.searchIndex.docs {
+        files = append(files, file)
+    }
+    return files
+}
+
+func TestSearchIndexKept(t *testing.T) {
+    files := map[string]string{"a.md": "# Apples\n", "b.md": "# Apricots\n"}
+    s, root := newTestServer(t, files)
+    if body := doRequest(s, "GET", "/api/search?q=ap", nil, true).Body.String(); !strings.Contains(body, "Apricots") {
+        t.Fatalf("no results: %s", body)
+    }
+    if got := indexedFiles(s); len(got) != 2 {
+        t.Fatalf("indexed %q", got)
+    }
+
+    if err := os.Remove(filepath.Join(root, "b.md")); err != nil {
+        t.Fatal(err)
+    }
+    doRequest(s, "GET", "/api/search?q=ap", nil, true)
+    if got := indexedFiles(s); len(got) != 1 || filepath.Base(got[0]) != "a.md" {
+        t.Errorf("indexed %q after b.md was removed", got)
+    }
+}
+
+func TestSearchIndexLite(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{"a.md": "# Apples\n"}, WithLite())
+    if body := doRequest(s, "GET", "/api/search?q=apples", nil, true).Body.String(); !strings.Contains(body, "Apples") {
+        t.Fatalf("no results: %s", body)
+    }
+    if got := indexedFiles(s); len(got) != 0 {
+        t.Errorf("indexed %q in lite mode", got)
+    }
+}
@@ -0,0 +1,262 @@
+package mdserve
+
+import (
+    "fmt"
+    "html/template"
+    "log"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync/atomic"
+)
+
+// Renders a shortcode from its arguments and the raw markdown between the
+// opening and closing tags ("" for self-closing use)
+type ShortcodeFunc func(args map[string]string, inner string) (template.HTML, error)
+
+// RegisterShortcode makes {{< name key="value" >}} available in documents,
+// replacing any existing shortcode with the same name
+func (s *Server) RegisterShortcode(name string, fn ShortcodeFunc) {
+    s.shortcodesMu.Lock()
+    defer s.shortcodesMu.Unlock()
+    s.shortcodes[name] = fn
+}
+
+func (s *Server) lookupShortcode(name string) ShortcodeFunc {
+    s.shortcodesMu.RLock()
+    defer s.shortcodesMu.RUnlock()
+    return s.shortcodes[name]
+}
+
+var shortcodeTag = regexp.MustCompile(`\{\{<\s*(/?)([\w-]+)(.*?)\s*>\}\}`)
+var shortcodeArg = regexp.MustCompile(`([\w-]+)="([^"]*)"|"([^"]*)"|(\S+)`)
+
+// Parse `key="value"` pairs; bare and quoted positional values are keyed "0", "1", ...
+func parseShortcodeArgs(s string) map[string]string {
+    args := map[string]string{}
+    pos := 0
+    for _, m := range shortcodeArg.FindAllStringSubmatch(s, -1) {
+        switch {
+        case m[1] != "":
+            args[m[1]] = m[2]
+        case m[4] != "":
+            args[strconv.Itoa(pos)] = m[4]
+            pos++
+        default:
+            args[strconv.Itoa(pos)] = m[3]
+            pos++
+        }
+    }
+    return args
+}
+
+// One shortcode found in a document
+type shortcodeCall struct {
+    Name       string
+    Args       map[string]string
+    Inner      string
+    Start, End int // Byte range of the whole call including closing tag
+}
+
+// Find top-level shortcode calls, pairing each opening tag with its closing
+// tag. Tags in code blocks and spans are left out.
+func findShortcodes(src string) []shortcodeCall {
+    var calls []shortcodeCall
+    code := codeRanges(src)
+    var tags [][]int
+    for _, t := range shortcodeTag.FindAllStringSubmatchIndex(src, -1) {
+        if !inRanges(t[0], code) {
+            tags = append(tags, t)
+        }
+    }
+    for i := 0; i < len(tags); i++ {
+        t := tags[i]
+        if src[t[2]:t[3]] == "/" {
+            continue // Stray closing tag
+        }
+        call := shortcodeCall{
+            Name:  src[t[4]:t[5]],
+            Args:  parseShortcodeArgs(src[t[6]:t[7]]),
+            Start: t[0],
+            End:   t[1],
+        }
+        depth := 0
+        for j := i + 1; j < len(tags); j++ {
+            u := tags[j]
+            if src[u[4]:u[5]] != call.Name {
+                continue
+            }
+            if src[u[2]:u[3]] == "" {
+                depth++
+            } else if depth > 0 {
+                depth--
+            } else {
+                call.Inner = strings.Trim(src[t[1]:u[0]], "\n")
+                call.End = u[1]
+                for i+1 < len(tags) && tags[i+1][0] < call.End {
+                    i++
+                }
+                break
+            }
+        }
+        calls = append(calls, call)
+    }
+    return calls
+}
+
+// Replace known shortcodes with placeholders, returning their rendered
+// HTML. Those in code blocks and spans stay as written, so the syntax can
+// be documented.
+func (s *Server) expandShortcodes(src string) (string, map[string]string) {
+    html := map[string]string{}
+    var out strings.Builder
+    last := 0
+    for _, call := range findShortcodes(src) {
+        fn := s.lookupShortcode(call.Name)
+        if fn == nil {
+            continue
+        }
+        rendered, err := fn(call.Args, call.Inner)
+        if err != nil {
+            log.Printf("Shortcode %s: %v", call.Name, err)
+            rendered = template.HTML(`<p class="sc-error">` + template.HTMLEscapeString(call.Name+": "+err.Error()) + `</p>`)
+        }
+        key := fmt.Sprintf("MDSERVESHORTCODE%dX", len(html))
+        html[key] = string(rendered)
+        out.WriteString(src[last:call.Start])
+        out.WriteString(key)
+        last = call.End
+    }
+    out.WriteString(src[last:])
+    return out.String(), html
+}
+
+var tabGroups int64 // Gives each tabs shortcode unique input names
+
+// Built-in shortcodes, registered on every new Server
+func (s *Server) registerBuiltinShortcodes() {
+    s.RegisterShortcode("youtube", func(args map[string]string, inner string) (template.HTML, error) {
+        id := args["id"]
+        if id == "" {
+            id = args["0"]
+        }
+        if id == "" {
+            return "", fmt.Errorf("missing video id")
+        }
+        return template.HTML(`<div class="sc-video"><iframe src="https://www.youtube-nocookie.com/embed/` +
+            template.HTMLEscapeString(id) + `" allowfullscreen loading="lazy"></iframe></div>`), nil
+    })
+
+    s.RegisterShortcode("figure", func(args map[string]string, inner string) (template.HTML, error) {
+        src := args["src"]
+        if src == "" {
+            return "", fmt.Errorf("missing src")
+        }
+        caption := args["caption"]
+        if caption == "" && inner != "" {
+            caption = inner
+        }
+        out := `<figure class="sc-figure"><img src="` + template.HTMLEscapeString(src) +
+            `" alt="` + template.HTMLEscapeString(args["alt"]) + `">`
+        if caption != "" {
+            out += `<figcaption>` + strings.TrimSpace(string(s.renderMarkdown([]byte(caption), s.markdown))) + `</figcaption>`
+        }
+        return template.HTML(out + `</figure>`), nil
+    })
+
+    // {{< tabs >}}{{< tab "Go" >}}...{{< /tab >}}{{< /tabs >}}
+    s.RegisterShortcode("tabs", func(args map[string]string, inner string) (template.HTML, error) {
+        group := atomic.AddInt64(&tabGroups, 1)
+        var out strings.Builder
+        out.WriteString(`<div class="sc-tabs">`)
+        i := 0
+        for _, tab := range findShortcodes(inner) {
+            if tab.Name != "tab" {
+                continue
+            }
+            title := tab.Args["title"]
+            if title == "" {
+                title = tab.Args["0"]
+            }
+            id := fmt.Sprintf("tabs-%d-%d", group, i)
+            checked := ""
+            if i == 0 {
+                checked = " checked"
+            }
+            fmt.Fprintf(&out, `<input type="radio" name="tabs-%d" id="%s"%s><label for="%s">%s</label><div class="sc-tab">%s</div>`,
+                group, id, checked, id, template.HTMLEscapeString(title), s.renderMarkdown([]byte(tab.Inner), s.markdown))
+            i++
+        }
+        out.WriteString(`</div>`)
+        return template.HTML(out.String()), nil
+    })
+
+    // {{< columns >}}left<--->right{{< /columns >}}
+    s.RegisterShortcode("columns", func(args map[string]string, inner string) (template.HTML, error) {
+        var out strings.Builder
+        out.WriteString(`<div class="sc-columns">`)
+        for _, col := range strings.Split(inner, "<--->") {
+            out.WriteString(`<div>`)
+            out.Write(s.renderMarkdown([]byte(strings.Trim(col, "\n")), s.markdown))
+            out.WriteString(`</div>`)
+        }
+        out.WriteString(`</div>`)
+        return template.HTML(out.String()), nil
+    })
+
+    // {{< kbd "Ctrl+Shift+P" >}}
+    s.RegisterShortcode("kbd", func(args map[string]string, inner string) (template.HTML, error) {
+        keys := args["0"]
+        if keys == "" {
+            return "", fmt.Errorf("missing keys")
+        }
+        var parts []string
+        for _, key := range strings.Split(keys, "+") {
+            parts = append(parts, "<kbd>"+template.HTMLEscapeString(strings.TrimSpace(key))+"</kbd>")
+        }
+        return template.HTML(`<span class="sc-keys">` + strings.Join(parts, "+") + `</span>`), nil
+    })
+
+    // {{< menu "File > Save As" >}}
+    s.RegisterShortcode("menu", func(args map[string]string, inner string) (template.HTML, error) {
+        items := args["0"]
+        if items == "" {
+            return "", fmt.Errorf("missing menu path")
+        }
+        var parts []string
+        for _, item := range strings.Split(items, ">") {
+            parts = append(parts, "<span>"+template.HTMLEscapeString(strings.TrimSpace(item))+"</span>")
+        }
+        return template.HTML(`<span class="sc-menu">` + strings.Join(parts, ` <span class="sc-menu-sep">▸</span> `) + `</span>`), nil
+    })
+
+    // {{< steps >}}{{< step "Install" >}}...{{< /step >}}{{< /steps >}}
+    s.RegisterShortcode("steps", func(args map[string]string, inner string) (template.HTML, error) {
+        var steps []shortcodeCall
+        for _, step := range findShortcodes(inner) {
+            if step.Name == "step" {
+                steps = append(steps, step)
+            }
+        }
+        if len(steps) == 0 {
+            return "", fmt.Errorf("no step blocks inside steps")
+        }
+        var out strings.Builder
+        fmt.Fprintf(&out, `<div class="sc-steps"><div class="sc-steps-progress"><progress value="0" max="%d"></progress> <span>0/%d done</span></div>`,
+            len(steps), len(steps))
+        for i, step := range steps {
+            title := step.Args["title"]
+            if title == "" {
+                title = step.Args["0"]
+            }
+            open := ""
+            if i == 0 {
+                open = " open"
+            }
+            fmt.Fprintf(&out, `<details class="sc-step"%s><summary><span class="sc-step-num">%d</span> %s</summary><div class="sc-step-body">%s<label><input type="checkbox" class="sc-step-done"> Done</label></div></details>`,
+                open, i+1, template.HTMLEscapeString(title), s.renderMarkdown([]byte(step.Inner), s.markdown))
+        }
+        out.WriteString(`</div>`)
+        return template.HTML(out.String()), nil
+    })
+}
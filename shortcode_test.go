@@ -0,0 +1,46 @@
+package mdserve
+
+import (
+    "html/template"
+    "strings"
+    "testing"
+)
+
+func TestShortcodesInCode(t *testing.T) {
+    s := New()
+    s.RegisterShortcode("hi", func(args map[string]string, inner string) (template.HTML, error) {
+        return template.HTML("<b>hi " + args["0"] + inner + "</b>"), nil
+    })
+    tests := []struct {
+        name string
+        src  string
+        want int // Shortcodes expanded
+    }{
+        {"outside code", "Say {{< hi there >}}.", 1},
+        {"fenced code", "```\n{{< hi there >}}\n```\n", 0},
+        {"tilde fence", "~~~ md\n{{< hi there >}}\n~~~\n", 0},
+        {"unclosed fence", "```\n{{< hi there >}}\n", 0},
+        {"inline code", "Write `{{< hi there >}}` to greet.", 0},
+        {"double backticks", "Write ``{{< hi `x` >}}`` to greet.", 0},
+        {"after code", "`code` then {{< hi there >}}", 1},
+        {"after fence", "```\n{{< hi >}}\n```\n\n{{< hi there >}}\n", 1},
+        {"closing tag in code", "{{< hi >}}x `{{< /hi >}}` y{{< /hi >}}", 1},
+        {"opening tag in code", "`{{< hi >}}` and {{< hi there >}}{{< /hi >}}", 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            out, html := s.expandShortcodes(tt.src)
+            if len(html) != tt.want {
+                t.Errorf("%q expanded %d shortcodes, want %d: %q", tt.src, len(html), tt.want, out)
+            }
+            if tt.want == 0 && out != tt.src {
+                t.Errorf("code changed to %q", out)
+            }
+        })
+    }
+
+    out, html := s.expandShortcodes("{{< hi >}}x `{{< /hi >}}` y{{< /hi >}}")
+    if out != "MDSERVESHORTCODE0X" || !strings.Contains(html["MDSERVESHORTCODE0X"], "x `{{< /hi >}}` y") {
+        t.Errorf("closing tag in code ended the shortcode: %q %q", out, html)
+    }
+}
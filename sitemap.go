@@ -0,0 +1,78 @@
+package mdserve
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "net/url"
+    "os"
+    "time"
+)
+
+// Serve /robots.txt: the configured file, or by default an invitation to
+// crawl public sites and a request to stay out of password-protected ones
+func (s *Server) robotsHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    if s.robots != "" {
+        data, err := ioutil.ReadFile(s.robots)
+        if err != nil {
+            log.Printf("robots.txt: %v", err)
+            http.Error(w, "File not found", http.StatusNotFound)
+            return
+        }
+        w.Write(data)
+        return
+    }
+    if !st.Public {
+        fmt.Fprint(w, "User-agent: *\nDisallow: /\n")
+        return
+    }
+    fmt.Fprintf(w, "User-agent: *\nAllow: /\nDisallow: %s/edit/\n\nSitemap: %s/sitemap.xml\n", s.basePath, s.siteURL(r))
+}
+
+type sitemapURL struct {
+    Loc     string `xml:"loc"`
+    LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Serve /sitemap.xml listing every document of the site with its
+// modification date
+func (s *Server) sitemapHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+    set := struct {
+        XMLName xml.Name     `xml:"urlset"`
+        XMLNS   string       `xml:"xmlns,attr"`
+        URLs    []sitemapURL `xml:"url"`
+    }{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+    base := s.siteURL(r)
+    for _, doc := range docs {
+        u := sitemapURL{Loc: base + (&url.URL{Path: "/" + doc.Path}).EscapedPath()}
+        if info, err := os.Stat(doc.File); err == nil {
+            u.LastMod = info.ModTime().UTC().Format(time.RFC3339)
+        }
+        set.URLs = append(set.URLs, u)
+    }
+
+    w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+    w.Write([]byte(xml.Header))
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    enc.Encode(set)
+}
+
+// The absolute URL of the site root as the client sees it, without the
+// trailing slash
+func (s *Server) siteURL(r *http.Request) string {
+    scheme := "http"
+    if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+        scheme = "https"
+    }
+    return scheme + "://" + r.Host + s.basePath
+}
@@ -0,0 +1,55 @@
+package mdserve
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestRobots(t *testing.T) {
+    custom := filepath.Join(t.TempDir(), "robots.txt")
+    os.WriteFile(custom, []byte("User-agent: *\nDisallow: /private/\n"), 0644)
+    tests := []struct {
+        name string
+        opts []Option
+        want string
+    }{
+        {"private", nil, "User-agent: *\nDisallow: /\n"},
+        {"public", []Option{WithPublic()}, "User-agent: *\nAllow: /\nDisallow: /edit/\n\nSitemap: http://example.com/sitemap.xml\n"},
+        {"public behind a proxy path", []Option{WithPublic(), WithBasePath("/docs")}, "User-agent: *\nAllow: /\nDisallow: /docs/edit/\n\nSitemap: http://example.com/docs/sitemap.xml\n"},
+        {"configured", []Option{WithRobots(custom)}, "User-agent: *\nDisallow: /private/\n"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, nil, tt.opts...)
+            if got := doRequest(s, "GET", "/robots.txt", nil, false).Body.String(); got != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestSitemap(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "index.md":      "# Home\n",
+        "guides/a b.md": "# A B\n",
+        "draft.md":      "---\ndraft: true\n---\n",
+        "image.png":     "png",
+    }, WithPublic())
+    w := doRequest(s, "GET", "/sitemap.xml", nil, false)
+    if ct := w.Header().Get("Content-Type"); ct != "application/xml; charset=utf-8" {
+        t.Errorf("got Content-Type %q", ct)
+    }
+    body := w.Body.String()
+    for _, want := range []string{`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`, "<loc>http://example.com/index.md</loc>", "<loc>http://example.com/guides/a%20b.md</loc>", "<lastmod>"} {
+        if !strings.Contains(body, want) {
+            t.Errorf("%q missing from %q", want, body)
+        }
+    }
+    for _, unwanted := range []string{"draft.md", "image.png"} {
+        if strings.Contains(body, unwanted) {
+            t.Errorf("%q in %q", unwanted, body)
+        }
+    }
+}
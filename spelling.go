@@ -0,0 +1,164 @@
+package mdserve
+
+import (
+    "bufio"
+    _ "embed"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+// Words CheckSpelling accepts, lower-case
+type Dictionary map[string]bool
+
+//go:embed words.txt
+var defaultWords string
+
+// DefaultDictionary holds the English words built into mdserve
+func DefaultDictionary() Dictionary {
+    d := Dictionary{}
+    d.read(strings.NewReader(defaultWords))
+    return d
+}
+
+// Load adds the words of a file, one per line; # starts a comment
+func (d Dictionary) Load(file string) error {
+    f, err := os.Open(file)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    return d.read(f)
+}
+
+func (d Dictionary) read(r io.Reader) error {
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        line, _, _ := strings.Cut(sc.Text(), "#")
+        if word := strings.TrimSpace(line); word != "" {
+            d[strings.ToLower(word)] = true
+        }
+    }
+    return sc.Err()
+}
+
+// Whether the dictionary has a word, in any case and with or without 's
+func (d Dictionary) has(word string) bool {
+    lower := strings.ToLower(word)
+    return d[lower] || d[strings.TrimSuffix(lower, "'s")]
+}
+
+// Up to three dictionary words one edit away
+func (d Dictionary) suggest(word string) []string {
+    word = strings.ToLower(word)
+    var found []string
+    seen := map[string]bool{}
+    try := func(w string) {
+        if d[w] && !seen[w] && len(found) < 3 {
+            seen[w] = true
+            found = append(found, w)
+        }
+    }
+    r := []rune(word)
+    for i := 0; i <= len(r); i++ {
+        if i < len(r) {
+            try(string(r[:i]) + string(r[i+1:])) // Deletion
+        }
+        if i+1 < len(r) {
+            try(string(r[:i]) + string(r[i+1]) + string(r[i]) + string(r[i+2:])) // Transposition
+        }
+        for c := 'a'; c <= 'z'; c++ {
+            if i < len(r) {
+                try(string(r[:i]) + string(c) + string(r[i+1:])) // Substitution
+            }
+            try(string(r[:i]) + string(c) + string(r[i:])) // Insertion
+        }
+    }
+    return found
+}
+
+// A word not in the dictionary
+type SpellingIssue struct {
+    Path        string   `json:"path"` // URL path without the leading slash
+    Line        int      `json:"line"`
+    Word        string   `json:"word"`
+    Suggestions []string `json:"suggestions"`
+}
+
+var (
+    spellWord = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+    // Prose that isn't words: link targets, URLs, addresses, tags, paths
+    spellSkip = regexp.MustCompile(`\]\([^)]*\)|<[^>]*>|\S+://\S+|\S+@\S+\.\S+|\S*[/\\]\S*|\{\{<.*?>\}\}|\[\[[^\]]*\]\]|\{[^}]*\}`)
+)
+
+// CheckSpelling reports the words in the prose of each document of the
+// default host that dict, such as DefaultDictionary(), lacks. Code
+// blocks, code spans, links targets and URLs are skipped, as are words
+// with capitals inside, like camelCase identifiers and acronyms. Each
+// mount's .spelling file, one word per line, extends dict for the whole
+// check.
+func (s *Server) CheckSpelling(dict Dictionary) ([]SpellingIssue, error) {
+    words := Dictionary{}
+    for w := range dict {
+        words[w] = true
+    }
+    for _, m := range s.defaultSite.mounts {
+        if err := words.Load(filepath.Join(m.Root, ".spelling")); err != nil && !os.IsNotExist(err) {
+            return nil, err
+        }
+    }
+
+    docs, err := s.siteDocs(s.defaultSite, nil)
+    if err != nil {
+        return nil, err
+    }
+    var issues []SpellingIssue
+    suggestions := map[string][]string{}
+    for _, doc := range docs {
+        if !strings.HasSuffix(doc.File, ".md") {
+            continue
+        }
+        content, err := ioutil.ReadFile(doc.File)
+        if err != nil {
+            continue
+        }
+        text := strings.ReplaceAll(string(content), "\r\n", "\n")
+        _, body := parseFrontMatter([]byte(text))
+        offset := strings.Count(text, "\n") - strings.Count(string(body), "\n")
+
+        proseLines(string(body), func(i int, line string) {
+            if referenceLink.MatchString(line) {
+                return
+            }
+            mapOutsideCodeSpans(line, func(text string) string {
+                for _, word := range spellWord.FindAllString(spellSkip.ReplaceAllString(text, " "), -1) {
+                    if len([]rune(word)) < 2 || innerCapital(word) || words.has(strings.ReplaceAll(word, "’", "'")) {
+                        continue
+                    }
+                    lower := strings.ToLower(word)
+                    if _, ok := suggestions[lower]; !ok {
+                        suggestions[lower] = words.suggest(lower)
+                    }
+                    issues = append(issues, SpellingIssue{doc.Path, offset + i, word, suggestions[lower]})
+                }
+                return text
+            })
+        })
+    }
+    return issues, nil
+}
+
+// Whether a word has a capital letter after its first, as in iPhone,
+// camelCase or HTTP
+func innerCapital(word string) bool {
+    for i, r := range word {
+        if i > 0 && unicode.IsUpper(r) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,27 @@
+package mdserve
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestCheckSpelling(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{
+        "doc.md":    "# Headings\n\nThe documnet renders footnotes, tables and links.\n\n```\nnot chekced\n```\n\nA `codd` span, a [link](http://exampel.com) and mdserve.\n",
+        ".spelling": "mdserve\n",
+    })
+    issues, err := s.CheckSpelling(DefaultDictionary())
+    if err != nil {
+        t.Fatal(err)
+    }
+    var words []string
+    for _, i := range issues {
+        words = append(words, i.Word)
+    }
+    if want := []string{"documnet"}; !reflect.DeepEqual(words, want) {
+        t.Errorf("got %q, want %q", words, want)
+    }
+    if len(issues) == 1 && (issues[0].Line != 3 || !reflect.DeepEqual(issues[0].Suggestions, []string{"document"})) {
+        t.Errorf("got %+v", issues[0])
+    }
+}
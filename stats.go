@@ -0,0 +1,116 @@
+package mdserve
+
+import (
+    "html/template"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Bucket of view counts, keyed by host name and URL path
+const viewsBucket = "views"
+
+// A document with its view count
+type pageViews struct {
+    Path  string
+    Title string
+    Views int
+}
+
+func viewsKey(st *site, urlFile string) string {
+    return strings.ToLower(st.Name) + "/" + urlFile
+}
+
+// Count a view of a document
+func (s *Server) countView(st *site, urlFile string) {
+    s.statsMu.Lock()
+    defer s.statsMu.Unlock()
+    key := viewsKey(st, urlFile)
+    value, err := s.store.Get(viewsBucket, key)
+    if err != nil {
+        log.Printf("Stats: %v", err)
+        return
+    }
+    n, _ := strconv.Atoi(string(value))
+    if err := s.store.Put(viewsBucket, key, []byte(strconv.Itoa(n+1))); err != nil {
+        log.Printf("Stats: %v", err)
+    }
+}
+
+// The site's viewed documents, most viewed first
+func (s *Server) popularPages(st *site, r *http.Request) ([]pageViews, error) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        return nil, err
+    }
+    var pages []pageViews
+    for _, doc := range docs {
+        value, err := s.store.Get(viewsBucket, viewsKey(st, doc.Path))
+        if err != nil {
+            return nil, err
+        }
+        n, _ := strconv.Atoi(string(value))
+        if n == 0 {
+            continue
+        }
+        p := pageViews{Path: doc.Path, Title: doc.Name, Views: n}
+        if content, err := ioutil.ReadFile(doc.File); err == nil {
+            fm, body := parseFrontMatter(content)
+            if title := documentTitle(fm, string(body)); title != "" {
+                p.Title = title
+            }
+        }
+        pages = append(pages, p)
+    }
+    sort.SliceStable(pages, func(i, j int) bool { return pages[i].Views > pages[j].Views })
+    return pages, nil
+}
+
+// Serve /stats: an overview of the site's documents for maintainers and,
+// with stats on, the view count of every document
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    health, err := s.contentHealth(st, r)
+    if err != nil {
+        http.Error(w, "Could not read documents", http.StatusInternalServerError)
+        return
+    }
+    var pages []pageViews
+    if s.stats {
+        if pages, err = s.popularPages(st, r); err != nil {
+            http.Error(w, "Could not read statistics", http.StatusInternalServerError)
+            return
+        }
+    }
+    total := 0
+    for _, p := range pages {
+        total += p.Views
+    }
+
+    theme := s.themeFor(st, nil)
+    data := struct {
+        Base     string
+        Theme    string
+        BaseCSS  template.CSS
+        ThemeCSS template.CSS
+        Health   *contentHealth
+        Counting bool // Whether views are counted
+        Pages    []pageViews
+        Total    int
+        Data     map[string]interface{}
+    }{
+        Base:     s.basePath,
+        Theme:    theme,
+        BaseCSS:  template.CSS(baseCSS),
+        ThemeCSS: template.CSS(Themes[theme]),
+        Health:   health,
+        Counting: s.stats,
+        Pages:    pages,
+        Total:    total,
+        Data:     s.pageData(r, ""),
+    }
+
+    s.executeTemplate(w, r, "stats.html", data)
+}
@@ -0,0 +1,70 @@
+package mdserve
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestPageViews(t *testing.T) {
+    files := map[string]string{
+        "a.md":       "# Apples\n",
+        "b.md":       "# Bananas\n",
+        "unread.md":  "# Unread\n",
+        "guide/c.md": "# Cherries\n",
+    }
+    s, _ := newTestServer(t, files, WithStats())
+    for _, target := range []string{"/a.md", "/b.md", "/b.md", "/b.md", "/a.md", "/guide/c.md", "/missing.md"} {
+        doRequest(s, "GET", target, nil, true)
+    }
+
+    page := doRequest(s, "GET", "/stats", nil, true).Body.String()
+    want := []string{
+        "<p>6 views of 3 documents</p>",
+        `<tr><td><a href="/b.md">Bananas</a></td><td>3</td></tr>`,
+        `<tr><td><a href="/a.md">Apples</a></td><td>2</td></tr>`,
+        `<tr><td><a href="/guide/c.md">Cherries</a></td><td>1</td></tr>`,
+    }
+    rest := page
+    for _, w := range want {
+        i := strings.Index(rest, w)
+        if i < 0 {
+            t.Fatalf("%q missing, or out of order, in %q", w, page)
+        }
+        rest = rest[i+len(w):]
+    }
+    if _, views, _ := strings.Cut(page, "<h2>Page views</h2>"); strings.Contains(views, "Unread") || strings.Contains(views, "missing.md") {
+        t.Errorf("unviewed document listed in %q", views)
+    }
+
+    index := doRequest(s, "GET", "/", nil, true).Body.String()
+    if want := `<li><a href="/b.md">Bananas</a> <small>3 views</small></li>`; !strings.Contains(index, want) {
+        t.Errorf("%q missing from %q", want, index)
+    }
+
+    // Each mount's index lists its own popular pages
+    docs, notes := t.TempDir(), t.TempDir()
+    for _, f := range []string{filepath.Join(docs, "d.md"), filepath.Join(notes, "n.md")} {
+        if err := os.WriteFile(f, []byte("# "+filepath.Base(f)+"\n"), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    s = New(WithMounts(Mount{Prefix: "/docs", Root: docs}, Mount{Prefix: "/notes", Root: notes}), WithAuth("admin", "pw"), WithStats())
+    doRequest(s, "GET", "/docs/d.md", nil, true)
+    doRequest(s, "GET", "/notes/n.md", nil, true)
+    index = doRequest(s, "GET", "/notes/", nil, true).Body.String()
+    if !strings.Contains(index, `<li><a href="/notes/n.md">n.md</a> <small>1 views</small></li>`) || strings.Contains(index, `href="/docs/d.md">d.md</a> <small>`) {
+        t.Errorf("wrong popular pages in %q", index)
+    }
+
+    // Off by default
+    s, _ = newTestServer(t, files)
+    doRequest(s, "GET", "/a.md", nil, true)
+    if page := doRequest(s, "GET", "/stats", nil, true).Body.String(); strings.Contains(page, "views of") {
+        t.Errorf("views counted in %q", page)
+    }
+    if index := doRequest(s, "GET", "/", nil, true).Body.String(); strings.Contains(index, "Popular") {
+        t.Errorf("popular pages in %q", index)
+    }
+}
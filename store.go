@@ -0,0 +1,144 @@
+package mdserve
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+    bolt "go.etcd.io/bbolt"
+)
+
+// Store persists server-side state such as view counts, annotations,
+// bookmarks, share tokens and sessions. Keys live in named buckets.
+// Implementations must be safe for concurrent use.
+type Store interface {
+    Get(bucket, key string) ([]byte, error) // nil, nil when missing
+    Put(bucket, key string, value []byte) error
+    Delete(bucket, key string) error
+    Keys(bucket string) ([]string, error) // Sorted
+    Close() error
+}
+
+// NewMemoryStore keeps state in memory; it is lost on restart
+func NewMemoryStore() Store {
+    return &memoryStore{buckets: map[string]map[string][]byte{}}
+}
+
+type memoryStore struct {
+    mu      sync.RWMutex
+    buckets map[string]map[string][]byte
+}
+
+func (m *memoryStore) Get(bucket, key string) ([]byte, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    value, ok := m.buckets[bucket][key]
+    if !ok {
+        return nil, nil
+    }
+    return append([]byte(nil), value...), nil
+}
+
+func (m *memoryStore) Put(bucket, key string, value []byte) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.buckets[bucket] == nil {
+        m.buckets[bucket] = map[string][]byte{}
+    }
+    m.buckets[bucket][key] = append([]byte(nil), value...)
+    return nil
+}
+
+func (m *memoryStore) Delete(bucket, key string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.buckets[bucket], key)
+    return nil
+}
+
+func (m *memoryStore) Keys(bucket string) ([]string, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    keys := make([]string, 0, len(m.buckets[bucket]))
+    for k := range m.buckets[bucket] {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys, nil
+}
+
+func (m *memoryStore) Close() error {
+    return nil
+}
+
+// OpenBoltStore opens (creating if needed) a bolt database file
+func OpenBoltStore(file string) (Store, error) {
+    db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+    if err != nil {
+        return nil, fmt.Errorf("could not open state file %s: %v", file, err)
+    }
+    return &boltStore{db: db}, nil
+}
+
+type boltStore struct {
+    db *bolt.DB
+}
+
+func (b *boltStore) Get(bucket, key string) ([]byte, error) {
+    var value []byte
+    err := b.db.View(func(tx *bolt.Tx) error {
+        bk := tx.Bucket([]byte(bucket))
+        if bk == nil {
+            return nil
+        }
+        if v := bk.Get([]byte(key)); v != nil {
+            value = append([]byte(nil), v...)
+        }
+        return nil
+    })
+    return value, err
+}
+
+func (b *boltStore) Put(bucket, key string, value []byte) error {
+    return b.db.Update(func(tx *bolt.Tx) error {
+        bk, err := tx.CreateBucketIfNotExists([]byte(bucket))
+        if err != nil {
+            return err
+        }
+        return bk.Put([]byte(key), value)
+    })
+}
+
+func (b *boltStore) Delete(bucket, key string) error {
+    return b.db.Update(func(tx *bolt.Tx) error {
+        bk := tx.Bucket([]byte(bucket))
+        if bk == nil {
+            return nil
+        }
+        return bk.Delete([]byte(key))
+    })
+}
+
+func (b *boltStore) Keys(bucket string) ([]string, error) {
+    var keys []string
+    err := b.db.View(func(tx *bolt.Tx) error {
+        bk := tx.Bucket([]byte(bucket))
+        if bk == nil {
+            return nil
+        }
+        return bk.ForEach(func(k, v []byte) error {
+            keys = append(keys, string(k))
+            return nil
+        })
+    })
+    return keys, err
+}
+
+func (b *boltStore) Close() error {
+    return b.db.Close()
+}
+
+// Store returns the Server's state store
+func (s *Server) Store() Store {
+    return s.store
+}
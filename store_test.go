@@ -0,0 +1,59 @@
+package mdserve
+
+import (
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestStores(t *testing.T) {
+    file := filepath.Join(t.TempDir(), "state.db")
+    tests := []struct {
+        name string
+        open func() (Store, error)
+    }{
+        {"memory", func() (Store, error) { return NewMemoryStore(), nil }},
+        {"bolt", func() (Store, error) { return OpenBoltStore(file) }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            st, err := tt.open()
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer st.Close()
+            if v, err := st.Get("views", "a"); v != nil || err != nil {
+                t.Errorf("missing key: got %q, %v", v, err)
+            }
+            if keys, err := st.Keys("nothing"); len(keys) != 0 || err != nil {
+                t.Errorf("missing bucket: got %q, %v", keys, err)
+            }
+            value := []byte("1")
+            for _, key := range []string{"b", "a", "c"} {
+                if err := st.Put("views", key, value); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            value[0] = '2' // The store keeps its own copy
+            if v, _ := st.Get("views", "a"); string(v) != "1" {
+                t.Errorf("got %q, want 1", v)
+            }
+            if err := st.Delete("views", "c"); err != nil {
+                t.Fatal(err)
+            }
+            if keys, _ := st.Keys("views"); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+                t.Errorf("got keys %q", keys)
+            }
+        })
+    }
+
+    // The bolt file keeps what was put
+    st, err := OpenBoltStore(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer st.Close()
+    if v, _ := st.Get("views", "b"); string(v) != "1" {
+        t.Errorf("reopened: got %q, want 1", v)
+    }
+}
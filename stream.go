@@ -0,0 +1,178 @@
+package mdserve
+
+import (
+    "bytes"
+    "fmt"
+    "html/template"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "regexp"
+    "strings"
+)
+
+// Markdown rendered and sent at once while streaming a large document.
+// Chunks end before a heading, so blocks are never cut in two. Small
+// chunks also render faster in total, as the parser slows down more than
+// linearly on long inputs.
+const streamChunkSize = 16 << 10
+
+// Stands in for the content while the page template runs, to split the
+// page around it
+const streamMarker = "<!--mdserve:content-->"
+
+var headingID = regexp.MustCompile(`(<h[1-6] id=")([^"]+)"`)
+
+// Whether a document is large enough to stream rather than render whole
+func (s *Server) streamed(file string) bool {
+    if s.streamThreshold <= 0 || !strings.HasSuffix(file, ".md") || s.formatFor(file) != nil {
+        return false
+    }
+    info, err := os.Stat(file)
+    return err == nil && info.Size() > s.streamThreshold
+}
+
+// A table of contents for the browser to fill in from the headings once
+// the whole page has arrived
+func deferredTOC(fm frontMatter, min, max int) template.HTML {
+    min, max = tocLevelsFor(fm, min, max)
+    return template.HTML(fmt.Sprintf(`<ul data-deferred data-min="%d" data-max="%d"></ul>`, min, max))
+}
+
+// Execute a page template whose content is streamMarker, sending what
+// comes before the marker at once, then the content as write produces it,
+// then the rest
+func (s *Server) streamTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}, write func(io.Writer)) {
+    t, err := s.loadTemplate(name, s.languageFor(r))
+    if err != nil {
+        log.Printf("Template error: %v", err)
+        http.Error(w, "Template error", http.StatusInternalServerError)
+        return
+    }
+    if s.language == "" {
+        w.Header().Add("Vary", "Accept-Language")
+    }
+    var buf bytes.Buffer
+    if err := t.Execute(&buf, data); err != nil {
+        log.Printf("Template %s: %v", name, err)
+    }
+    head, tail, _ := strings.Cut(string(s.injectAnalytics(buf.Bytes())), streamMarker)
+    io.WriteString(w, head)
+    write(&flushWriter{w})
+    io.WriteString(w, tail)
+}
+
+// Sends what is written right away
+type flushWriter struct {
+    w http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+    n, err := f.w.Write(p)
+    if flusher, ok := f.w.(http.Flusher); ok {
+        flusher.Flush()
+    }
+    return n, err
+}
+
+// The headings of a body about to be streamed, for the TOC, section
+// numbers and comments, found by rendering its ATX heading lines on their
+// own. They get the IDs the chunks will give them; setext headings are
+// left out.
+func (s *Server) streamedHeadings(fm frontMatter, body []byte) []tocEntry {
+    var headings []string
+    proseLines(string(body), func(n int, line string) {
+        if atxHeading.MatchString(line) {
+            headings = append(headings, strings.TrimSpace(line))
+        }
+    })
+    return extractTOC(s.renderMarkdown([]byte(strings.Join(headings, "\n\n")), s.markdownOptionsFor(fm)))
+}
+
+// Render a markdown body chunk by chunk to w, or whole when it has
+// footnotes. Link reference and abbreviation definitions are repeated in
+// every chunk so they resolve anywhere; heading IDs are kept unique
+// across chunks and numbered from numbers, see sectionNumbers, unless it
+// is nil.
+func (s *Server) streamMarkdown(w io.Writer, st *site, file, urlFile string, fm frontMatter, body []byte, numbers map[string]string) {
+    opts := s.markdownOptionsFor(fm)
+    if !opts.CommonMark {
+        body = markTasks(body)
+    }
+    for _, fn := range s.hooks.beforeRender {
+        body = fn(file, body)
+    }
+    src := string(body)
+
+    // Split before headings once a chunk is big enough. Footnotes are
+    // numbered and listed across the whole document, so one that has them
+    // is rendered in one piece.
+    var definitions []string
+    var cuts []int
+    footnotes := false
+    offsets := []int{0}
+    for _, line := range strings.SplitAfter(src, "\n") {
+        offsets = append(offsets, offsets[len(offsets)-1]+len(line))
+    }
+    last := 0
+    proseLines(src, func(n int, line string) {
+        if referenceLink.MatchString(line) && strings.HasPrefix(strings.TrimSpace(line), "[^") {
+            footnotes = true
+        } else if referenceLink.MatchString(line) || abbrDef.MatchString(line) {
+            definitions = append(definitions, strings.TrimSpace(line))
+        }
+        if at := offsets[n-1]; atxHeading.MatchString(line) && at-last >= streamChunkSize {
+            cuts = append(cuts, at)
+            last = at
+        }
+    })
+    if footnotes {
+        cuts = nil
+    }
+    cuts = append(cuts, len(src))
+    extra := ""
+    if len(definitions) > 0 {
+        extra = "\n\n" + strings.Join(definitions, "\n") + "\n"
+    }
+
+    seen := map[string]bool{}
+    start := 0
+    for _, end := range cuts {
+        chunk := []byte(src[start:end] + extra)
+        start = end
+        var out []byte
+        if opts.CommonMark {
+            out = s.renderMarkdown(chunk, opts)
+        } else {
+            out = taskCheckboxes(s.renderMarkdown(chunk, opts))
+        }
+        for _, fn := range s.hooks.afterRender {
+            out = fn(file, out)
+        }
+        html := uniqueHeadingIDs(string(out), seen)
+        if numbers != nil {
+            html = numberHeadings(html, numbers)
+        }
+        if s.debug {
+            html = s.markBrokenLinks(html, st, file)
+        }
+        if _, err := io.WriteString(w, s.processImages(html, file, urlFile)); err != nil {
+            return // The reader went away
+        }
+    }
+}
+
+// Rename heading IDs already used by earlier chunks the way the renderer
+// does within one: intro, intro-1, intro-2
+func uniqueHeadingIDs(html string, seen map[string]bool) string {
+    return headingID.ReplaceAllStringFunc(html, func(tag string) string {
+        m := headingID.FindStringSubmatch(tag)
+        id := m[2]
+        for n := 1; seen[id]; n++ {
+            id = fmt.Sprintf("%s-%d", m[2], n)
+        }
+        seen[id] = true
+        return m[1] + id + `"`
+    })
+}
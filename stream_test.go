@@ -0,0 +1,79 @@
+package mdserve
+
+import (
+    "regexp"
+    "strings"
+    "testing"
+)
+
+var (
+    sectionNumber = regexp.MustCompile(`<span class="section-number">([^<]+)</span>`)
+    sectionOption = regexp.MustCompile(`<option value="([^"]+)">([^<]+)</option>`)
+)
+
+// A streamed page gets the same section numbers and commentable sections
+// as the page rendered whole
+func TestStreamedSections(t *testing.T) {
+    doc := strings.Join([]string{
+        "---",
+        "number_sections: true",
+        "---",
+        "# Guide",
+        "## Install",
+        "```",
+        "# not a heading",
+        "```",
+        "## Use",
+        "### Options {#opts}",
+        "#### Deep",
+        "## Install",
+    }, "\n")
+    tests := []struct {
+        name string
+        opts []Option
+    }{
+        {"whole", nil},
+        {"streamed", []Option{WithStreaming(1)}},
+    }
+    want := map[string][]string{}
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, map[string]string{"guide.md": doc}, tt.opts...)
+            page := doRequest(s, "GET", "/guide.md", nil, true).Body.String()
+            got := map[string][]string{}
+            for _, m := range sectionNumber.FindAllStringSubmatch(page, -1) {
+                got["numbers"] = append(got["numbers"], m[1])
+            }
+            for _, m := range sectionOption.FindAllStringSubmatch(page, -1) {
+                got["sections"] = append(got["sections"], m[1]+" "+m[2])
+            }
+            if strings.Join(got["numbers"], " ") != "1. 2. 2.1 2.1.1 3." {
+                t.Errorf("got section numbers %q", got["numbers"])
+            }
+            if len(got["sections"]) != 6 {
+                t.Errorf("got commentable sections %q, want 6", got["sections"])
+            }
+            if tt.name == "whole" {
+                want = got
+            } else if strings.Join(got["sections"], "|") != strings.Join(want["sections"], "|") {
+                t.Errorf("got sections %q, want %q as when rendered whole", got["sections"], want["sections"])
+            }
+        })
+    }
+}
+
+// Footnotes resolve in a streamed document, wherever their definitions are
+func TestStreamedFootnotes(t *testing.T) {
+    filler := strings.Repeat("Some text to fill the section.\n\n", 1000)
+    doc := "# One\n\nA claim[^a].\n\n" + filler + "# Two\n\n" + filler + "[^a]: The source.\n"
+    s, _ := newTestServer(t, map[string]string{"long.md": doc}, WithStreaming(1))
+    page := doRequest(s, "GET", "/long.md", nil, true).Body.String()
+    for _, want := range []string{`href="#fn:a"`, `<li id="fn:a">The source.`} {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing", want)
+        }
+    }
+    if strings.Contains(page, "[^a]") {
+        t.Error("footnote left as text")
+    }
+}
@@ -0,0 +1,48 @@
+package mdserve
+
+import (
+    "regexp"
+    "strings"
+)
+
+var (
+    tableAttrs  = regexp.MustCompile(`^\{((?:\s*\.[\w-]+)+)\s*\}\s*$`)
+    tableMarker = regexp.MustCompile(`<!-- mdserve-table ([\w -]+) -->\s*<table>`)
+)
+
+// Turn a "{.sortable .filterable}" line directly above a table into a
+// marker comment that applyTableClasses picks up after rendering
+func markTables(src string) string {
+    lines := strings.SplitAfter(src, "\n")
+    fence := ""
+    for i, line := range lines {
+        trimmed := strings.TrimLeft(line, " \t")
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) {
+                fence = ""
+            }
+            continue
+        }
+        if fence = fenceMarker(trimmed); fence != "" {
+            continue
+        }
+        m := tableAttrs.FindStringSubmatch(line)
+        if m == nil || i+1 >= len(lines) || !strings.HasPrefix(strings.TrimSpace(lines[i+1]), "|") {
+            continue
+        }
+        classes := strings.Fields(strings.ReplaceAll(m[1], ".", " "))
+        lines[i] = "<!-- mdserve-table " + strings.Join(classes, " ") + " -->\n\n"
+    }
+    return strings.Join(lines, "")
+}
+
+// Give marked tables their classes, and every table both classes when
+// interactive tables are on for the whole server
+func (s *Server) applyTableClasses(page string) string {
+    page = tableMarker.ReplaceAllString(page, `<table class="$1">`)
+    if s.interactiveTables {
+        page = strings.ReplaceAll(page, "<table>", `<table class="sortable filterable">`)
+        page = strings.ReplaceAll(page, `<table class="csv-table">`, `<table class="csv-table sortable filterable">`)
+    }
+    return page
+}
@@ -0,0 +1,37 @@
+package mdserve
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestTableClasses(t *testing.T) {
+    table := "| Tool | Stars |\n|------|-------|\n| mdserve | 120 |\n"
+    tests := []struct {
+        name    string
+        src     string
+        opts    []Option
+        want    string
+        notWant string
+    }{
+        {"plain", table, nil, "<table>", "sortable"},
+        {"both", "{.sortable .filterable}\n" + table, nil, `<table class="sortable filterable">`, "{.sortable"},
+        {"sortable only", "{.sortable}\n" + table, nil, `<table class="sortable">`, "filterable"},
+        {"not above a table", "{.sortable}\n\nText.\n", nil, "{.sortable}", "<table"},
+        {"in fenced code", "```\n{.sortable}\n" + table + "```\n", nil, "{.sortable}", `class="sortable"`},
+        {"every table", table, []Option{WithInteractiveTables()}, `<table class="sortable filterable">`, "<table>"},
+        {"every csv table", "```csv\na,b\n1,2\n```\n", []Option{WithInteractiveTables()}, `<table class="csv-table sortable filterable">`, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := New(tt.opts...)
+            page := string(s.renderMarkdown([]byte(tt.src), s.markdown))
+            if !strings.Contains(page, tt.want) {
+                t.Errorf("%q missing from %q", tt.want, page)
+            }
+            if tt.notWant != "" && strings.Contains(page, tt.notWant) {
+                t.Errorf("%q in %q", tt.notWant, page)
+            }
+        })
+    }
+}
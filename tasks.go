@@ -0,0 +1,323 @@
+package mdserve
+
+import (
+    "errors"
+    "fmt"
+    "html/template"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+var taskItem = regexp.MustCompile(`^(?:\s*>)*\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+var listItem = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])(?:\s|$)`)
+var quotePrefix = regexp.MustCompile(`^(?:[ \t]*>[ ]?)+`)
+
+// Tags a task list item in the markdown with its index among taskLines,
+// so its checkbox toggles that very line. Private use characters, which
+// the renderer passes through as they are.
+const taskMarkStart, taskMarkEnd = "\uE000", "\uE001"
+
+var taskMark = regexp.MustCompile(taskMarkStart + `(\d+)` + taskMarkEnd)
+
+// Task list progress of one document
+type taskSummary struct {
+    Path  string
+    Name  string
+    Title string
+    Owner string
+    Tags  []string
+    Done  int
+    Total int
+    Open  []string // Text of unchecked items
+}
+
+// Percentage of completed items
+func (t taskSummary) Percent() int {
+    if t.Total == 0 {
+        return 0
+    }
+    return t.Done * 100 / t.Total
+}
+
+// Count GFM task list items outside code blocks
+func scanTasks(body string) (done int, open []string) {
+    lines := strings.Split(body, "\n")
+    for _, i := range taskLines(lines) {
+        m := taskItem.FindStringSubmatch(lines[i])
+        if m[1] == " " {
+            open = append(open, strings.TrimSpace(m[2]))
+        } else {
+            done++
+        }
+    }
+    return done, open
+}
+
+// Indexes of the task list items among the lines of a markdown body,
+// blockquoted ones included, skipping fenced and indented code blocks
+func taskLines(lines []string) []int {
+    var items []int
+    fence := ""
+    code, inList, prevBlank := false, false, true
+    for i, line := range lines {
+        content := quotePrefix.ReplaceAllString(line, "")
+        trimmed := strings.TrimLeft(content, " \t")
+        blank := strings.TrimSpace(content) == ""
+        if fence != "" {
+            if strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+                fence = ""
+            }
+            continue
+        }
+        if fence = fenceMarker(trimmed); fence != "" {
+            continue
+        }
+        // Indented code starts after a blank line outside a list and runs
+        // while lines stay indented
+        indent := indentWidth(content)
+        if code && (blank || indent >= 4) {
+            continue
+        }
+        code = false
+        if indent >= 4 && prevBlank && !inList && !blank {
+            code = true
+            continue
+        }
+        if listItem.MatchString(content) {
+            inList = true
+        } else if !blank && indent == 0 {
+            inList = false
+        }
+        prevBlank = blank
+        if taskItem.MatchString(line) {
+            items = append(items, i)
+        }
+    }
+    return items
+}
+
+// Leading whitespace in columns, tabs counting 4
+func indentWidth(line string) int {
+    n := 0
+    for _, c := range line {
+        switch c {
+        case ' ':
+            n++
+        case '\t':
+            n += 4 - n%4
+        default:
+            return n
+        }
+    }
+    return n
+}
+
+// Tag the task list items of a markdown body for taskCheckboxes
+func markTasks(body []byte) []byte {
+    lines := strings.Split(string(body), "\n")
+    for n, i := range taskLines(lines) {
+        m := taskItem.FindStringSubmatchIndex(lines[i])
+        lines[i] = lines[i][:m[4]] + taskMarkStart + strconv.Itoa(n) + taskMarkEnd + lines[i][m[4]:]
+    }
+    return []byte(strings.Join(lines, "\n"))
+}
+
+// Lines of content before the markdown body, taken by frontmatter
+func frontMatterLines(content string) int {
+    text := strings.ReplaceAll(content, "\r\n", "\n")
+    _, body := parseFrontMatter([]byte(text))
+    return strings.Count(text[:len(text)-len(body)], "\n")
+}
+
+var errTaskChanged = errors.New("task list changed since the page was loaded")
+
+// Check or uncheck the index-th task list item of a document, counted
+// as markTasks does
+func setTask(content string, index int, checked bool) (string, error) {
+    lines := strings.Split(content, "\n")
+    skip := frontMatterLines(content)
+    items := taskLines(lines[skip:])
+    if index < 0 || index >= len(items) {
+        return "", errTaskChanged
+    }
+    line := lines[skip+items[index]]
+    m := taskItem.FindStringSubmatchIndex(line)
+    if (line[m[2]] != ' ') == checked {
+        return "", errTaskChanged
+    }
+    box := " "
+    if checked {
+        box = "x"
+    }
+    lines[skip+items[index]] = line[:m[2]] + box + line[m[3]:]
+    return strings.Join(lines, "\n"), nil
+}
+
+var taskBox = regexp.MustCompile(`<li>(<p>)?\[([ xX])\] (?:` + taskMarkStart + `(\d+)` + taskMarkEnd + `)?`)
+
+// Turn rendered "[ ]" list items into checkboxes. Those tagged by
+// markTasks carry their item's index; others, such as in documents of
+// other formats, can't be toggled. They start disabled; the page script
+// enables them on editable pages.
+func taskCheckboxes(page []byte) []byte {
+    page = taskBox.ReplaceAllFunc(page, func(item []byte) []byte {
+        m := taskBox.FindSubmatch(item)
+        checked := ""
+        if m[2][0] != ' ' {
+            checked = " checked"
+        }
+        index := ""
+        if m[3] != nil {
+            index = fmt.Sprintf(` data-task="%s"`, m[3])
+        }
+        return []byte(fmt.Sprintf(`<li class="task-item">%s<input type="checkbox" class="task-checkbox"%s%s disabled> `, m[1], index, checked))
+    })
+    // Tags the renderer kept as text, such as in code
+    return taskMark.ReplaceAll(page, nil)
+}
+
+// Save a checkbox toggle from a rendered page back to the markdown file
+func (s *Server) taskHandler(w http.ResponseWriter, r *http.Request, file string) {
+    index, err := strconv.Atoi(r.FormValue("task"))
+    if err != nil {
+        http.Error(w, "Invalid task", http.StatusBadRequest)
+        return
+    }
+    checked := r.FormValue("checked") == "true"
+    if s.formatFor(file) != nil {
+        http.Error(w, "Tasks can only be toggled in markdown documents", http.StatusBadRequest)
+        return
+    }
+
+    s.writeMu.Lock()
+    defer s.writeMu.Unlock()
+    content, err := ioutil.ReadFile(file)
+    if err != nil {
+        http.Error(w, "File not found", http.StatusNotFound)
+        return
+    }
+    updated, err := setTask(string(content), index, checked)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusConflict)
+        return
+    }
+    if err := ioutil.WriteFile(file, []byte(updated), 0644); err != nil {
+        http.Error(w, "Could not save file", http.StatusInternalServerError)
+        return
+    }
+    if err := s.encryptSaved(file); err != nil {
+        log.Printf("Encryption error: %v", err)
+        http.Error(w, "Encryption failed", http.StatusInternalServerError)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// Aggregate task lists across a site's documents, filtered by ?tag= and ?owner=
+func (s *Server) tasksHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    docs, err := s.siteDocs(st, r)
+    if err != nil {
+        http.Error(w, "Could not list files", http.StatusInternalServerError)
+        return
+    }
+
+    tagFilter := r.URL.Query().Get("tag")
+    ownerFilter := r.URL.Query().Get("owner")
+    tagSet := map[string]bool{}
+    ownerSet := map[string]bool{}
+    var summaries []taskSummary
+    var done, total int
+    for _, doc := range docs {
+        content, err := ioutil.ReadFile(doc.File)
+        if err != nil {
+            continue
+        }
+        fm, body := parseFrontMatter(content)
+        d, open := scanTasks(string(body))
+        if d+len(open) == 0 {
+            continue
+        }
+        t := taskSummary{
+            Path:  doc.Path,
+            Name:  doc.Name,
+            Title: fm.Get("title"),
+            Owner: fm.Get("owner"),
+            Tags:  fm["tags"],
+            Done:  d,
+            Total: d + len(open),
+            Open:  open,
+        }
+        for _, tag := range t.Tags {
+            tagSet[tag] = true
+        }
+        if t.Owner != "" {
+            ownerSet[t.Owner] = true
+        }
+        if ownerFilter != "" && t.Owner != ownerFilter {
+            continue
+        }
+        if tagFilter != "" && !containsString(t.Tags, tagFilter) {
+            continue
+        }
+        summaries = append(summaries, t)
+        done += t.Done
+        total += t.Total
+    }
+    sort.SliceStable(summaries, func(i, j int) bool {
+        return summaries[i].Total-summaries[i].Done > summaries[j].Total-summaries[j].Done
+    })
+
+    theme := s.themeFor(st, nil)
+    data := struct {
+        Base     string
+        Theme    string
+        BaseCSS  template.CSS
+        ThemeCSS template.CSS
+        Tag      string
+        Owner    string
+        Tags     []string
+        Owners   []string
+        Docs     []taskSummary
+        Done     int
+        Open     int
+        Data     map[string]interface{}
+    }{
+        Base:     s.basePath,
+        Theme:    theme,
+        BaseCSS:  template.CSS(baseCSS),
+        ThemeCSS: template.CSS(Themes[theme]),
+        Tag:      tagFilter,
+        Owner:    ownerFilter,
+        Tags:     sortedKeys(tagSet),
+        Owners:   sortedKeys(ownerSet),
+        Docs:     summaries,
+        Done:     done,
+        Open:     total - done,
+        Data:     s.pageData(r, ""),
+    }
+
+    s.executeTemplate(w, r, "tasks.html", data)
+}
+
+func containsString(list []string, s string) bool {
+    for _, item := range list {
+        if item == s {
+            return true
+        }
+    }
+    return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+    keys := make([]string, 0, len(set))
+    for k := range set {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,127 @@
+package mdserve
+
+import (
+    "net/url"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestSetTask(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+        index   int
+        want    string // "" when the toggle is refused
+    }{
+        {"plain list", "- [ ] a\n- [ ] b\n", 1, "- [ ] a\n- [x] b\n"},
+        {"blockquoted item counts", "- [ ] a\n> - [ ] quoted\n- [ ] b\n", 1, "- [ ] a\n> - [x] quoted\n- [ ] b\n"},
+        {"after blockquote", "- [ ] a\n> - [ ] quoted\n- [ ] b\n", 2, "- [ ] a\n> - [ ] quoted\n- [x] b\n"},
+        {"fenced code skipped", "```\n- [ ] code\n```\n- [ ] a\n", 0, "```\n- [ ] code\n```\n- [x] a\n"},
+        {"fence in blockquote skipped", "> ```\n> - [ ] code\n> ```\n- [ ] a\n", 0, "> ```\n> - [ ] code\n> ```\n- [x] a\n"},
+        {"indented code skipped", "Text\n\n    - [ ] code\n\n- [ ] a\n", 0, "Text\n\n    - [ ] code\n\n- [x] a\n"},
+        {"nested item is not code", "- [ ] a\n\n    - [ ] nested\n", 1, "- [ ] a\n\n    - [x] nested\n"},
+        {"frontmatter skipped", "---\ntodo:\n- [ ] no\n---\n- [ ] a\n", 0, "---\ntodo:\n- [ ] no\n---\n- [x] a\n"},
+        {"windows line endings", "- [ ] a\r\n- [ ] b\r\n", 1, "- [ ] a\r\n- [x] b\r\n"},
+        {"ordered list", "1. [ ] a\n2) [ ] b\n", 1, "1. [ ] a\n2) [x] b\n"},
+        {"out of range", "- [ ] a\n", 1, ""},
+        {"already checked", "- [x] a\n", 0, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := setTask(tt.content, tt.index, true)
+            if tt.want == "" {
+                if err == nil {
+                    t.Errorf("got %q, want an error", got)
+                }
+                return
+            }
+            if err != nil || got != tt.want {
+                t.Errorf("got %q, %v; want %q", got, err, tt.want)
+            }
+        })
+    }
+}
+
+var renderedTask = regexp.MustCompile(`data-task="(\d+)"[^>]*> ([^<\n]*)`)
+
+// Every rendered checkbox must toggle the source line it shows
+func TestRenderedTasksMatchSource(t *testing.T) {
+    doc := strings.Join([]string{
+        "---",
+        "title: Tasks",
+        "---",
+        "- [ ] first",
+        "> - [ ] lazy, part of the item above",
+        "",
+        "> - [ ] quoted",
+        "",
+        "```",
+        "- [ ] fenced",
+        "```",
+        "",
+        "Text",
+        "",
+        "    - [ ] indented code",
+        "",
+        "> [!NOTE]",
+        "> - [ ] in alert",
+        "",
+        "- [x] done",
+        "    - [ ] nested",
+        "- [ ] last",
+    }, "\n")
+    s, root := newTestServer(t, map[string]string{"todo.md": doc})
+    page := doRequest(s, "GET", "/todo.md", nil, true).Body.String()
+    if strings.Contains(page, taskMarkStart) {
+        t.Error("task tags left in the page")
+    }
+    if !strings.Contains(page, "- [ ] indented code") || !strings.Contains(page, "- [ ] fenced") {
+        t.Error("code blocks changed")
+    }
+    boxes := renderedTask.FindAllStringSubmatch(page, -1)
+    if len(boxes) != 6 {
+        t.Fatalf("got %d checkboxes, want 6", len(boxes))
+    }
+    for _, box := range boxes {
+        content, _ := os.ReadFile(filepath.Join(root, "todo.md"))
+        text := strings.TrimSpace(box[2])
+        checked := !strings.Contains(box[0], "checked")
+        w := doRequest(s, "POST", "/edit/todo.md", url.Values{"task": {box[1]}, "checked": {map[bool]string{true: "true", false: "false"}[checked]}}, true)
+        if w.Code >= 300 && w.Code != 500 { // 500: gpg could not encrypt, the write happened
+            t.Fatalf("toggling %q: %d %s", text, w.Code, w.Body)
+        }
+        updated, _ := os.ReadFile(filepath.Join(root, "todo.md"))
+        before, after := strings.Split(string(content), "\n"), strings.Split(string(updated), "\n")
+        for i := range before {
+            if before[i] != after[i] && !strings.HasSuffix(after[i], text) {
+                t.Errorf("toggling %q changed line %q", text, after[i])
+            }
+        }
+    }
+}
+
+// Saving the whole document waits for a task toggle under way
+func TestSaveWaitsForTaskToggle(t *testing.T) {
+    t.Setenv("GNUPGHOME", t.TempDir())
+    s, root := newTestServer(t, map[string]string{"todo.md": "- [ ] a\n"})
+    file := filepath.Join(root, "todo.md")
+    s.writeMu.Lock() // As a toggle does between reading and writing
+    saved := make(chan struct{})
+    go func() {
+        doRequest(s, "POST", "/edit/todo.md", url.Values{"content": {"- [ ] b\n"}}, true)
+        close(saved)
+    }()
+    time.Sleep(50 * time.Millisecond)
+    if content, _ := os.ReadFile(file); string(content) != "- [ ] a\n" {
+        t.Errorf("saved %q during the toggle", content)
+    }
+    s.writeMu.Unlock()
+    <-saved
+    if content, _ := os.ReadFile(file); string(content) != "- [ ] b\n" {
+        t.Errorf("got %q after the toggle, want the saved content", content)
+    }
+}
@@ -0,0 +1,415 @@
+package mdserve
+
+import (
+    "bytes"
+    "fmt"
+    "html/template"
+    "io/ioutil"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+)
+
+// Built-in page templates. A file with the same name in Config.TemplateDir
+// replaces the built-in one; it receives the same data.
+var builtinTemplates = map[string]string{
+    "view.html":   viewTemplate,
+    "index.html":  indexTemplate,
+    "edit.html":   editTemplate,
+    "tasks.html":  tasksTemplate,
+    "code.html":   codeTemplate,
+    "search.html": searchTemplate,
+    "stats.html":  statsTemplate,
+    "large.html":  largeTemplate,
+    "error.html":  errorTemplate,
+}
+
+const viewTemplate = `<html lang="{{lang}}">
+<head>
+    {{with .Title}}<title>{{.}}</title>{{end}}
+    <link rel="alternate" type="application/json+oembed" href="{{.Base}}/oembed?url={{.Base}}/{{.File}}">
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <link rel="manifest" href="{{.Base}}/manifest.webmanifest">
+    <link rel="search" type="application/opensearchdescription+xml" href="{{.Base}}/opensearch.xml" title="Docs">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+    {{range .CSS}}<link rel="stylesheet" href="{{.}}">
+    {{end}}
+    {{range .Languages}}{{if not .Current}}<link rel="alternate" hreflang="{{.Lang}}" href="{{$.Base}}/{{.Path}}">
+    {{end}}{{end}}
+</head>
+<body class="theme-{{.Theme}}{{if .TOC}} has-toc{{end}}">
+    <a class="skip-link" href="#main">{{t "Skip to content"}}</a>
+    <div class="layout toc-{{.TOCPosition}}" dir="{{.Dir}}">
+    {{if .TOC}}<nav class="toc" aria-label="{{t "Contents"}}">{{.TOC}}</nav>{{end}}
+    <main id="main" tabindex="-1"{{if .Live}} data-live="{{.Base}}/api/events?path={{urlquery .File}}"{{end}}>
+    {{with .Languages}}<nav class="translations" aria-label="{{t "Languages"}}">{{range .}}
+        {{if .Current}}<strong lang="{{.Lang}}">{{.Name}}</strong>{{else}}<a href="{{$.Base}}/{{.Path}}" hreflang="{{.Lang}}" lang="{{.Lang}}">{{.Name}}</a>{{end}}
+    {{end}}</nav>{{end}}
+    {{if .Editable}}<a href="{{.Base}}/edit/{{.File}}">{{t "Edit this file"}}</a>{{end}}
+    <h1>{{t "Preview"}}</h1>
+    {{if .Draft}}<p class="draft-banner">{{t "Draft: hidden from indexes and search"}}</p>{{end}}
+    <p class="page-meta">{{t "Updated %s" (.Modified.Format "2 Jan 2006 15:04")}}{{with .Commit}} &middot; {{t "last commit by %s on %s" .Author (.Date.Format "2 Jan 2006")}}{{end}}</p>
+    <div class="content"{{with .Lang}} lang="{{.}}"{{end}}{{if .Editable}} data-edit="{{.Base}}/edit/{{.File}}"{{end}}>{{.HTMLContent}}</div>
+    {{if .Commentable}}<section class="comments" id="comments">
+    <h2>{{t "Comments"}}</h2>
+    {{range .Comments}}<div class="comment" id="comment-{{.ID}}">
+        <p class="comment-meta"><strong>{{.Author}}</strong> {{t "on"}} {{.Time.Format "2 Jan 2006 15:04"}}{{with .Heading}} &middot; {{t "about"}} <a href="#{{.}}">{{or (index $.Sections .) .}}</a>{{end}}</p>
+        <p class="comment-text">{{.Text}}</p>
+        <form method="post" action="{{$.Base}}/comments/{{$.File}}"><button name="delete" value="{{.ID}}">{{t "Delete"}}</button></form>
+    </div>
+    {{else}}<p>{{t "No comments yet."}}</p>
+    {{end}}
+    <form method="post" action="{{.Base}}/comments/{{.File}}" id="comment-form">
+        <select name="heading"><option value="">{{t "Whole document"}}</option>
+        {{range .Headings}}<option value="{{.ID}}">{{.Text}}</option>
+        {{end}}</select>
+        <textarea name="text" rows="3" required placeholder="{{t "Add a comment"}}"></textarea>
+        <button>{{t "Comment"}}</button>
+    </form>
+    </section>{{end}}
+    </main>
+    </div>
+    {{with .BaseJS}}<script>var uiMessages = {{messages}};</script>
+    <script>{{.}}</script>{{end}}
+</body>
+</html>
+`
+
+const indexTemplate = `<html lang="{{lang}}">
+<head>
+    <title>{{t "Index of %s" .Prefix}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <link rel="manifest" href="{{.Base}}/manifest.webmanifest">
+    <link rel="search" type="application/opensearchdescription+xml" href="{{.Base}}/opensearch.xml" title="Docs">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{t "Index of %s" .Prefix}}</h1>
+    <form action="{{.Base}}/search"><input type="search" name="q" placeholder="{{t "Search"}}"></form>
+    <a href="{{.Base}}/zip{{.Prefix}}">{{t "Download as zip"}}</a>
+    {{with .Popular}}<h2>{{t "Popular"}}</h2>
+    <ol>
+    {{range .}}<li><a href="{{$.Base}}/{{.Path}}">{{.Title}}</a> <small>{{t "%d views" .Views}}</small></li>
+    {{end}}
+    </ol>
+    <h2>{{t "All documents"}}</h2>{{end}}
+    {{if .Truncated}}<p class="truncated-banner">{{t "Truncated: this tree is larger than the server's depth or file limit, so some documents are not listed."}}</p>{{end}}
+    {{with .Tree}}<ul class="tree">
+    {{range .Items}}<li>{{if .Dir}}<details data-dir="{{.Path}}"><summary>{{.Name}}/ <small>{{.Documents}}</small></summary><ul></ul></details>{{else}}<a href="{{$.Base}}/{{.Path}}">{{.Name}}</a>{{end}}</li>
+    {{else}}<li>{{t "No markdown files yet."}}</li>
+    {{end}}
+    {{if .Next}}<li><button class="tree-more" data-dir="{{.Dir}}" data-next="{{.Next}}">{{t "Show more"}}</button></li>{{end}}
+    </ul>{{else}}<ul>
+    {{range .Entries}}<li><a href="{{$.Base}}/{{.Path}}">{{.Name}}</a></li>
+    {{else}}<li>{{t "No markdown files yet."}}</li>
+    {{end}}
+    </ul>{{end}}
+    {{with .Attachments}}<h2>{{t "Attachments"}}</h2>
+    <ul class="attachments">
+    {{range .}}<li>{{.Icon}} <a href="{{$.Base}}/{{.Path}}">{{.Name}}</a> <a href="{{$.Base}}/{{.Path}}?download" title="{{t "Download"}}">⬇</a></li>
+    {{end}}
+    </ul>{{end}}
+    {{if .Tree}}<script>
+    // Folders load their contents when first opened, a page at a time
+    (function() {
+        var base = {{.Base}};
+        function item(it) {
+            var li = document.createElement('li');
+            if (it.dir) {
+                var d = document.createElement('details'), s = document.createElement('summary'), n = document.createElement('small');
+                d.dataset.dir = it.path;
+                s.textContent = it.name + '/ ';
+                n.textContent = it.documents;
+                s.appendChild(n);
+                d.appendChild(s);
+                d.appendChild(document.createElement('ul'));
+                li.appendChild(d);
+            } else {
+                var a = document.createElement('a');
+                a.href = base + '/' + it.path;
+                a.textContent = it.name;
+                li.appendChild(a);
+            }
+            return li;
+        }
+        function more(dir, next) {
+            var li = document.createElement('li'), b = document.createElement('button');
+            b.className = 'tree-more';
+            b.dataset.dir = dir;
+            b.dataset.next = next;
+            b.textContent = {{t "Show more"}};
+            li.appendChild(b);
+            return li;
+        }
+        function load(ul, dir, offset) {
+            fetch(base + '/api/tree?dir=' + encodeURIComponent(dir) + '&offset=' + offset)
+                .then(function(r) { return r.json(); })
+                .then(function(page) {
+                    page.items.forEach(function(it) { ul.appendChild(item(it)); });
+                    if (page.next) { ul.appendChild(more(dir, page.next)); }
+                });
+        }
+        document.addEventListener('toggle', function(e) {
+            var d = e.target;
+            if (d.open && d.dataset.dir !== undefined && !d.dataset.loaded) {
+                d.dataset.loaded = '1';
+                load(d.querySelector('ul'), d.dataset.dir, 0);
+            }
+        }, true);
+        document.addEventListener('click', function(e) {
+            if (e.target.classList.contains('tree-more')) {
+                var li = e.target.parentNode, ul = li.parentNode;
+                ul.removeChild(li);
+                load(ul, e.target.dataset.dir, e.target.dataset.next);
+            }
+        });
+    })();
+    </script>{{end}}
+</body>
+</html>
+`
+
+const statsTemplate = `<html lang="{{lang}}">
+<head>
+    <title>{{t "Site statistics"}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <link rel="manifest" href="{{.Base}}/manifest.webmanifest">
+    <link rel="search" type="application/opensearchdescription+xml" href="{{.Base}}/opensearch.xml" title="Docs">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{t "Site statistics"}}</h1>
+    {{with .Health}}<p>{{t "%d documents, %d words" .Documents .Words}} &middot; {{t "%d broken links" .BrokenLinks}}</p>
+    <h2>{{t "Largest documents"}}</h2>
+    <table>
+        <tr><th>{{t "Document"}}</th><th>{{t "Size"}}</th><th>{{t "Words"}}</th></tr>
+        {{range .Largest}}<tr><td><a href="{{$.Base}}/{{.Path}}">{{or .Title .Name}}</a></td><td>{{.SizeText}}</td><td>{{.Words}}</td></tr>
+        {{end}}
+    </table>
+    <h2>{{t "Least recently updated"}}</h2>
+    <table>
+        <tr><th>{{t "Document"}}</th><th>{{t "Updated"}}</th></tr>
+        {{range .Stalest}}<tr><td><a href="{{$.Base}}/{{.Path}}">{{or .Title .Name}}</a></td><td>{{.Updated.Format "2 Jan 2006"}}</td></tr>
+        {{end}}
+    </table>
+    {{with .Untitled}}<h2>{{t "Without a title"}}</h2>
+    <ul>{{range .}}<li><a href="{{$.Base}}/{{.Path}}">{{.Name}}</a></li>{{end}}</ul>{{end}}
+    {{with .Broken}}<h2>{{t "Broken links"}}</h2>
+    <table>
+        <tr><th>{{t "Document"}}</th><th>{{t "Broken links"}}</th></tr>
+        {{range .}}<tr><td><a href="{{$.Base}}/{{.Path}}">{{or .Title .Name}}</a></td><td>{{.BrokenLinks}}</td></tr>
+        {{end}}
+    </table>{{end}}{{end}}
+    {{if .Counting}}<h2>{{t "Page views"}}</h2>
+    <p>{{t "%d views of %d documents" .Total (len .Pages)}}</p>
+    <table>
+        <tr><th>{{t "Document"}}</th><th>{{t "Views"}}</th></tr>
+        {{range .Pages}}<tr><td><a href="{{$.Base}}/{{.Path}}">{{.Title}}</a></td><td>{{.Views}}</td></tr>
+        {{else}}<tr><td colspan="2">{{t "No views yet."}}</td></tr>
+        {{end}}
+    </table>{{end}}
+</body>
+</html>
+`
+
+const searchTemplate = `<html lang="{{lang}}">
+<head>
+    <title>{{t "Search"}}{{with .Query}}: {{.}}{{end}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <link rel="manifest" href="{{.Base}}/manifest.webmanifest">
+    <link rel="search" type="application/opensearchdescription+xml" href="{{.Base}}/opensearch.xml" title="Docs">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{t "Search"}}</h1>
+    <form action="{{.Base}}/search"><input type="search" name="q" value="{{.Query}}" autofocus></form>
+    {{if .Query}}<p>{{t "%d found" (len .Results)}}</p>
+    {{if .Partial}}<p class="truncated-banner">{{t "Truncated: this tree is larger than the server's depth or file limit, so some documents are not searched."}}</p>{{end}}
+    <ul>
+    {{range .Results}}<li><a href="{{$.Base}}/{{.Path}}{{with .Anchor}}#{{.}}{{end}}">{{or .Title .Name}}{{with .Heading}} &rsaquo; {{.}}{{end}}</a> <small>{{.Name}}</small>
+        {{with .Snippet}}<p class="search-snippet">{{.}}</p>{{end}}</li>
+    {{end}}
+    </ul>{{end}}
+</body>
+</html>
+`
+
+const largeTemplate = `<html lang="{{lang}}">
+<head>
+    <title>{{.Name}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{.Name}}</h1>
+    <p class="truncated-banner">{{t "This document is %s, more than the %s this server renders." .Size .Limit}}</p>
+    <p><a href="{{.Base}}/{{.File}}?download">{{t "Download the raw file"}}</a></p>
+</body>
+</html>
+`
+
+const errorTemplate = `<html lang="{{lang}}">
+<head>
+    <title>{{t "Something went wrong"}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{t "Something went wrong"}}</h1>
+    <p>{{t "The server ran into an error showing this page. If it keeps happening, report it with request ID %s." .RequestID}}</p>
+    {{with .Panic}}<pre>{{.}}
+
+{{$.Stack}}</pre>{{end}}
+</body>
+</html>
+`
+
+const editTemplate = `<html lang="{{lang}}">
+<body>
+    <h1>{{t "Edit %s" .File}}</h1>
+    <form method="POST" action="{{.Base}}/edit/{{.File}}">
+        <textarea name="content" rows="20" cols="80">{{.RawContent}}</textarea><br>
+        <input type="submit" value="{{t "Save"}}">
+    </form>
+    <a href="{{.Base}}/{{.File}}">{{t "Cancel"}}</a>
+</body>
+</html>
+`
+
+const tasksTemplate = `<html lang="{{lang}}">
+<head>
+    <title>{{t "Tasks"}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <link rel="manifest" href="{{.Base}}/manifest.webmanifest">
+    <link rel="search" type="application/opensearchdescription+xml" href="{{.Base}}/opensearch.xml" title="Docs">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{t "Tasks"}}</h1>
+    <p>{{t "%d open, %d done" .Open .Done}}{{with .Tag}} &middot; {{t "tag"}} <b>{{.}}</b>{{end}}{{with .Owner}} &middot; {{t "owner"}} <b>{{.}}</b>{{end}}
+    {{if or .Tag .Owner}}&middot; <a href="{{.Base}}/tasks">{{t "clear filters"}}</a>{{end}}</p>
+    {{if .Tags}}<p>{{t "Tags:"}} {{range .Tags}}<a href="?tag={{.}}">{{.}}</a> {{end}}</p>{{end}}
+    {{if .Owners}}<p>{{t "Owners:"}} {{range .Owners}}<a href="?owner={{.}}">{{.}}</a> {{end}}</p>{{end}}
+    <table class="tasks">
+        <tr><th>{{t "Document"}}</th><th>{{t "Owner"}}</th><th>{{t "Tags"}}</th><th>{{t "Progress"}}</th></tr>
+        {{range .Docs}}<tr>
+            <td><a href="{{$.Base}}/{{.Path}}">{{or .Title .Name}}</a>
+            {{if .Open}}<details><summary>{{t "%d open" (len .Open)}}</summary><ul>{{range .Open}}<li>{{.}}</li>{{end}}</ul></details>{{end}}</td>
+            <td>{{.Owner}}</td>
+            <td>{{range .Tags}}{{.}} {{end}}</td>
+            <td><progress value="{{.Done}}" max="{{.Total}}"></progress> {{.Done}}/{{.Total}} ({{.Percent}}%)</td>
+        </tr>
+        {{else}}<tr><td colspan="4">{{t "No task lists found."}}</td></tr>
+        {{end}}
+    </table>
+</body>
+</html>
+`
+
+const codeTemplate = `{{define "line"}}<span class="line" id="L{{.Number}}"><a class="line-number" href="#L{{.Number}}">{{.Number}}</a><code>{{.HTML}}</code></span>{{end}}
+{{define "lines"}}{{range .}}{{if .Children}}<details open><summary>{{template "line" .}}</summary>{{template "lines" .Children}}</details>
+{{else}}{{template "line" .}}
+{{end}}{{end}}{{end}}<html lang="{{lang}}">
+<head>
+    <title>{{.File}}</title>
+    <link rel="icon" href="{{.Base}}/favicon.ico">
+    <link rel="manifest" href="{{.Base}}/manifest.webmanifest">
+    <link rel="search" type="application/opensearchdescription+xml" href="{{.Base}}/opensearch.xml" title="Docs">
+    <style>{{.BaseCSS}}
+    {{.ThemeCSS}}</style>
+</head>
+<body class="theme-{{.Theme}}">
+    <h1>{{.File}}</h1>
+    <p>{{.Language}} &middot; {{t "%d lines" .Count}} &middot; <a href="{{.Base}}/{{.File}}?raw">{{t "Raw"}}</a> &middot; <a href="{{.Base}}/{{.File}}?download">{{t "Download"}}</a></p>
+    <div class="code">
+    {{template "lines" .Lines}}
+    </div>
+</body>
+</html>
+`
+
+// Produces extra values for a page, available to templates as .Data
+type PageDataFunc func(r *http.Request, file string) map[string]interface{}
+
+// AddFuncs makes extra functions available to page templates
+func (s *Server) AddFuncs(funcs template.FuncMap) {
+    s.templatesMu.Lock()
+    defer s.templatesMu.Unlock()
+    for name, fn := range funcs {
+        s.funcs[name] = fn
+    }
+    s.templates = map[string]*template.Template{}
+}
+
+// AddPageData registers fn to add per-page values to .Data; later
+// registrations win on conflicting keys
+func (s *Server) AddPageData(fn PageDataFunc) {
+    s.pageDataFuncs = append(s.pageDataFuncs, fn)
+}
+
+// Static site data merged with the per-page data functions
+func (s *Server) pageData(r *http.Request, file string) map[string]interface{} {
+    data := map[string]interface{}{}
+    for k, v := range s.data {
+        data[k] = v
+    }
+    for _, fn := range s.pageDataFuncs {
+        for k, v := range fn(r, file) {
+            data[k] = v
+        }
+    }
+    return data
+}
+
+// Parse a page template for a language, preferring an override from the
+// template directory
+func (s *Server) loadTemplate(name, lang string) (*template.Template, error) {
+    s.templatesMu.Lock()
+    defer s.templatesMu.Unlock()
+    key := name + "/" + lang
+    if t, ok := s.templates[key]; ok && !s.dev {
+        return t, nil
+    }
+
+    text := builtinTemplates[name]
+    if s.templateDir != "" {
+        content, err := ioutil.ReadFile(filepath.Join(s.templateDir, name))
+        if err == nil {
+            text = string(content)
+        } else if !os.IsNotExist(err) {
+            return nil, err
+        }
+    }
+    t, err := template.New(name).Funcs(translator(lang)).Funcs(s.funcs).Parse(text)
+    if err != nil {
+        return nil, fmt.Errorf("template %s: %v", name, err)
+    }
+    s.templates[key] = t
+    return t, nil
+}
+
+// Render a page template to the response, in the request's language
+func (s *Server) executeTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+    t, err := s.loadTemplate(name, s.languageFor(r))
+    if err != nil {
+        log.Printf("Template error: %v", err)
+        http.Error(w, "Template error", http.StatusInternalServerError)
+        return
+    }
+    if s.language == "" {
+        w.Header().Add("Vary", "Accept-Language")
+    }
+    var buf bytes.Buffer
+    if err := t.Execute(&buf, data); err != nil {
+        log.Printf("Template %s: %v", name, err)
+    }
+    w.Write(s.injectAnalytics(buf.Bytes()))
+}
@@ -0,0 +1,94 @@
+package mdserve
+
+import (
+    "net"
+    "net/http"
+    "sort"
+    "strings"
+)
+
+// A Tenant is a separately configured Server answering for a host name, a
+// path prefix under it, or both. A Server for a prefix needs it as its
+// BasePath.
+type Tenant struct {
+    Host   string // Host header matched, "" for any host
+    Prefix string // URL path prefix such as "/team-a", "" for the whole host
+    Server *Server
+}
+
+// Tenants routes each request to the Server of the tenant matching its
+// host and path: tenants for the host before those for any host, longer
+// prefixes before shorter ones. Requests no tenant matches go to fallback.
+type Tenants struct {
+    list     []Tenant
+    fallback http.Handler
+}
+
+// NewTenants routes requests to tenants, and the others to fallback (a
+// 404 when nil)
+func NewTenants(fallback http.Handler, tenants ...Tenant) *Tenants {
+    t := &Tenants{fallback: fallback}
+    for _, tn := range tenants {
+        tn.Host = strings.ToLower(tn.Host)
+        tn.Prefix = strings.TrimSuffix(tn.Prefix, "/")
+        t.list = append(t.list, tn)
+    }
+    sort.SliceStable(t.list, func(i, j int) bool {
+        a, b := t.list[i], t.list[j]
+        if (a.Host == "") != (b.Host == "") {
+            return a.Host != ""
+        }
+        return len(a.Prefix) > len(b.Prefix)
+    })
+    if t.fallback == nil {
+        t.fallback = http.NotFoundHandler()
+    }
+    return t
+}
+
+func (t *Tenants) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    host := r.Host
+    if h, _, err := net.SplitHostPort(host); err == nil {
+        host = h
+    }
+    host = strings.ToLower(host)
+    for _, tn := range t.list {
+        if tn.Host != "" && tn.Host != host {
+            continue
+        }
+        if tn.Prefix == "" {
+            tn.Server.ServeHTTP(w, r)
+            return
+        }
+        if r.URL.Path == tn.Prefix {
+            // Relative links need the trailing slash
+            http.Redirect(w, r, tn.Prefix+"/", http.StatusMovedPermanently)
+            return
+        }
+        if strings.HasPrefix(r.URL.Path, tn.Prefix+"/") {
+            http.StripPrefix(tn.Prefix, tn.Server).ServeHTTP(w, r)
+            return
+        }
+    }
+    t.fallback.ServeHTTP(w, r)
+}
+
+// A SharedCache holds rendered pages for several Servers, so together they
+// keep within one memory budget. Give it to each with WithSharedCache.
+type SharedCache struct {
+    cache *pageCache
+}
+
+// NewSharedCache makes a cache of size rendered pages
+func NewSharedCache(size int) *SharedCache {
+    return &SharedCache{cache: newPageCache(size)}
+}
+
+// Page cache keys of a Server sharing its cache, so servers rendering the
+// same file differently keep their own pages
+func (s *Server) cacheKey(file string) string {
+    if s.cacheNamespace == "" {
+        return file
+    }
+    return s.cacheNamespace + file
+}
@@ -0,0 +1,664 @@
+package mdserve
+
+import (
+    "encoding/json"
+    "html/template"
+)
+
+// Themes holds the page stylesheets selectable with Config.Theme or
+// "theme:" frontmatter
+var Themes = map[string]string{
+    "light": `body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #222; background: #fff; }
+        a { color: #0645ad; }
+        pre, code { background: #f4f4f4; }
+        pre { padding: 0.8em; overflow-x: auto; }
+        @media (prefers-contrast: more) { body { color: #000; } a { color: #0000c8; text-decoration: underline; } }`,
+    "dark": `body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #ddd; background: #1e1e1e; }
+        a { color: #8ab4f8; }
+        pre, code { background: #2d2d2d; }
+        pre { padding: 0.8em; overflow-x: auto; }
+        .footnote-preview { background: #2d2d2d; color: #ddd; }
+        .tok-k { color: #c678dd; } .tok-s { color: #98c379; } .tok-n { color: #d19a66; } .tok-a { color: #61afef; }
+        @media (prefers-contrast: more) { body { color: #fff; background: #000; } a { color: #9cf; text-decoration: underline; } }`,
+    "slides": `body { font-family: sans-serif; margin: 0; color: #222; background: #fafafa; font-size: 1.6em; }
+        h1, h2 { page-break-before: always; min-height: 2em; border-top: 2px solid #ccc; padding-top: 1em; }
+        body > div { max-width: 40em; margin: 0 auto; }`,
+    "contrast": `body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #000; background: #fff; line-height: 1.5; }
+        a { color: #0000c8; text-decoration: underline; } a:visited { color: #551a8b; }
+        pre, code { color: #000; background: #fff; border: 1px solid #000; }
+        pre { padding: 0.8em; overflow-x: auto; } pre code { border: 0; }
+        .page-meta, small, figcaption, .line-number, .code-block .line::before, .toc-toggle, .heading-anchor, .heading-comment { opacity: 1 !important; }
+        .tok-k { color: #6f1d9b; } .tok-s { color: #1d5e20; } .tok-c { color: #3b3b3b; } .tok-n { color: #7a4100; } .tok-a { color: #0b4a9e; }
+        .alert, .comment, .sc-step, kbd { border-color: #000; }
+        :focus-visible { outline: 3px solid #000; }`,
+    "plain": ``,
+}
+
+// Pick a page's theme: frontmatter first, then the host's, then the default
+func (s *Server) themeFor(st *site, fm frontMatter) string {
+    for _, theme := range []string{fm.Get("theme"), st.Theme} {
+        if _, ok := Themes[theme]; ok {
+            return theme
+        }
+    }
+    return s.theme
+}
+
+// Styles shared by every theme
+const baseCSS = `.sc-video { position: relative; padding-bottom: 56.25%; height: 0; }
+        .sc-video iframe { position: absolute; width: 100%; height: 100%; border: 0; }
+        .sc-figure img { max-width: 100%; }
+        .sc-figure figcaption { font-size: 0.9em; opacity: 0.8; }
+        .sc-tabs { display: flex; flex-wrap: wrap; }
+        .sc-tabs > input { display: none; }
+        .sc-tabs > label { padding: 0.4em 1em; cursor: pointer; border-bottom: 2px solid transparent; }
+        .sc-tabs > input:checked + label { border-bottom-color: currentColor; font-weight: bold; }
+        .sc-tabs > .sc-tab { order: 1; width: 100%; display: none; }
+        .sc-tabs > input:checked + label + .sc-tab { display: block; }
+        .sc-columns { display: flex; gap: 1.5em; }
+        .sc-columns > div { flex: 1; min-width: 0; }
+        .sc-error { color: #b00; }
+        .alert { border-inline-start: 4px solid; padding: 0.2em 1em; margin: 1em 0; border-start-end-radius: 4px; border-end-end-radius: 4px; background: #8881; }
+        .alert-title { font-weight: bold; margin: 0.4em 0; }
+        .alert-note { border-color: #0969da; } .alert-note .alert-title { color: #0969da; }
+        .alert-tip { border-color: #1a7f37; } .alert-tip .alert-title { color: #1a7f37; }
+        .alert-important { border-color: #8250df; } .alert-important .alert-title { color: #8250df; }
+        .alert-warning { border-color: #9a6700; } .alert-warning .alert-title { color: #9a6700; }
+        .alert-caution { border-color: #cf222e; } .alert-caution .alert-title { color: #cf222e; }
+        details.alert > summary { cursor: pointer; }
+        .footnote-ref { position: relative; }
+        .footnote-preview { display: none; position: absolute; bottom: 1.4em; left: -1em; z-index: 10; width: max-content; max-width: 22em;
+            padding: 0.5em 0.8em; font-size: 0.8rem; font-weight: normal; line-height: 1.4; color: #222; background: #fff; border: 1px solid #8886; border-radius: 6px; box-shadow: 0 2px 8px #0003; }
+        .footnote-ref:hover .footnote-preview, .footnote-ref:focus-within .footnote-preview { display: block; }
+        .footnote-return { text-decoration: none; }
+        dt { font-weight: bold; margin-top: 0.6em; }
+        dd { margin-inline-start: 1.5em; }
+        .task-item { list-style: none; }
+        .task-checkbox { margin: 0 0.4em 0 -1.4em; }
+        table.sortable th { cursor: pointer; user-select: none; }
+        table.sortable th[aria-sort=ascending]::after { content: " ▲"; }
+        table.sortable th[aria-sort=descending]::after { content: " ▼"; }
+        .table-filter { display: block; margin: 1em 0 0.3em; padding: 0.3em 0.5em; }
+        .chart { margin: 1em 0; max-width: 40em; }
+        .cast-player { margin: 1em 0; }
+        .media { margin: 1em 0; } .media video { max-width: 100%; } .media audio { width: 100%; max-width: 40em; }
+        .media figcaption { font-size: 0.9em; opacity: 0.8; }
+        .code { font-size: 0.9em; }
+        .code .line { display: flex; min-height: 1.3em; }
+        .code .line:target { background: #ff03; }
+        .code code { flex: 1; white-space: pre-wrap; background: none; }
+        .code .line-number { min-width: 3.5em; padding-right: 1em; text-align: right; user-select: none; opacity: 0.5; color: inherit; text-decoration: none; }
+        .code .line:has(.tok-ins) { background: #2da44e22; } .code .line:has(.tok-del) { background: #cf222e22; }
+        .tok-ins { color: #1a7f37; } .tok-del { color: #cf222e; } .tok-hunk { color: #8250df; } .tok-meta { font-weight: bold; }
+        .code details > summary { list-style: none; cursor: pointer; }
+        .code details > summary::-webkit-details-marker { display: none; }
+        .code details > summary .line-number::before { content: "▾ "; }
+        .code details:not([open]) > summary .line-number::before { content: "▸ "; }
+        .code details:not([open]) > summary code::after { content: " …"; opacity: 0.5; }
+        .tok-k { color: #a626a4; font-weight: 600; } .tok-s { color: #50a14f; } .tok-c { color: #5c6370; font-style: italic; } .tok-n { color: #c18401; } .tok-a { color: #4078f2; }
+        main img { max-width: 100%; height: auto; }
+        img.zoomable { cursor: zoom-in; }
+        .lightbox { position: fixed; inset: 0; z-index: 100; display: flex; align-items: center; justify-content: center; overflow: hidden; background: #000d; }
+        .lightbox[hidden] { display: none; }
+        .lightbox img { max-width: 95vw; max-height: 92vh; cursor: grab; user-select: none; }
+        .lightbox button { position: absolute; padding: 0.2em 0.5em; font-size: 2em; color: #fff; background: none; border: 0; cursor: pointer; opacity: 0.7; }
+        .lightbox button:hover { opacity: 1; }
+        .lightbox .lightbox-close { top: 0.2em; right: 0.4em; }
+        .lightbox .lightbox-prev { left: 0.4em; } .lightbox .lightbox-next { right: 0.4em; }
+        .lightbox-caption { position: absolute; bottom: 0.8em; color: #fff; font-size: 0.9em; }
+        abbr[title] { text-decoration: underline dotted; cursor: help; }
+        kbd { display: inline-block; padding: 0.1em 0.4em; font: 0.85em monospace; border: 1px solid #8888; border-bottom-width: 2px; border-radius: 4px; background: #8881; }
+        .sc-menu { font-weight: 600; white-space: nowrap; }
+        .sc-menu-sep { opacity: 0.6; font-weight: normal; }
+        .sc-steps-progress { margin: 0.5em 0; }
+        .sc-steps-progress progress { width: 60%; vertical-align: middle; }
+        .sc-step { border: 1px solid #8884; border-radius: 6px; margin: 0.6em 0; padding: 0.4em 0.8em; }
+        .sc-step summary { cursor: pointer; font-weight: bold; }
+        .sc-step-num { display: inline-block; width: 1.6em; height: 1.6em; line-height: 1.6em; text-align: center; border-radius: 50%; background: #8884; margin-inline-end: 0.4em; }
+        .sc-step.done .sc-step-num { background: #2a2; color: #fff; }
+        .section-number { opacity: 0.6; }
+        .content pre { position: relative; }
+        .copy-code { position: absolute; top: 0.4em; right: 0.4em; padding: 0.1em 0.5em; font-size: 0.8em; cursor: pointer; opacity: 0; transition: opacity 0.2s; }
+        .content pre:hover .copy-code, .copy-code:focus { opacity: 0.8; }
+        .code-block { margin: 1em 0; } .code-block pre { margin: 0; }
+        .code-block .line { display: block; min-height: 1.2em; }
+        .code-block .line.hl { background: #ff03; }
+        .code-block.linenos code { counter-reset: line var(--linenostart); }
+        .code-block.linenos .line::before { counter-increment: line; content: counter(line); display: inline-block; width: 2.5em; margin-right: 1em; text-align: right; opacity: 0.5; user-select: none; }
+        .palette { position: fixed; inset: 0; z-index: 90; display: flex; justify-content: center; align-items: flex-start; padding-top: 12vh; background: #0006; }
+        .palette[hidden] { display: none; }
+        .palette-box { width: min(40em, 92vw); color: #222; background: #fff; border-radius: 8px; box-shadow: 0 8px 30px #0005; overflow: hidden; }
+        .palette input { box-sizing: border-box; width: 100%; padding: 0.8em 1em; font-size: 1.1em; border: 0; border-bottom: 1px solid #8884; outline: none; }
+        .palette ul { list-style: none; margin: 0; padding: 0; max-height: 50vh; overflow-y: auto; }
+        .palette li { padding: 0.4em 1em; cursor: pointer; } .palette li small { opacity: 0.6; margin-left: 0.5em; }
+        .palette li.selected { background: #0969da22; }
+        .code-title { padding: 0.3em 0.8em; font: 0.85em monospace; background: #8882; border-radius: 4px 4px 0 0; }
+        .heading-anchor { margin-inline-start: 0.3em; font-size: 0.8em; text-decoration: none; opacity: 0; transition: opacity 0.2s; }
+        :is(h1, h2, h3, h4, h5, h6):hover > .heading-anchor, .heading-anchor:focus { opacity: 0.6; }
+        .heading-anchor.copied::after { content: " copied"; font-size: 0.7em; }
+        .content :is(h1, h2, h3, h4, h5, h6):target { animation: heading-flash 2s ease-out; }
+        @keyframes heading-flash { from { background: #ff06; } to { background: transparent; } }
+        .draft-banner, .truncated-banner { padding: 0.3em 0.8em; border: 1px dashed #bf8700; border-radius: 4px; color: #9a6700; background: #fff8c5; }
+        .translations { float: inline-end; font-size: 0.9em; } .translations > * { margin-inline-start: 0.5em; }
+        .page-meta { font-size: 0.85em; opacity: 0.7; margin-top: -0.5em; }
+        .comments { margin-top: 3em; border-top: 1px solid #8884; }
+        .comment { margin: 1em 0; padding: 0.5em 1em; border-inline-start: 3px solid #0969da88; }
+        .comment-meta { font-size: 0.85em; opacity: 0.8; margin: 0; }
+        .comment-text { white-space: pre-wrap; }
+        .comment form { display: inline; } .comment button { font-size: 0.8em; }
+        #comment-form { display: flex; flex-direction: column; gap: 0.5em; max-width: 40em; }
+        .heading-comment { margin-inline-start: 0.3em; font-size: 0.7em; border: 0; background: none; cursor: pointer; opacity: 0; }
+        .search-snippet { margin: 0.2em 0 0.8em; font-size: 0.9em; }
+        .tree, .tree ul { list-style: none; padding-inline-start: 1.2em; } .tree summary { cursor: pointer; } .tree small { opacity: 0.6; }
+        a.broken-link { text-decoration: underline dotted #d00; text-underline-offset: 0.2em; cursor: help; }
+        :is(h1, h2, h3, h4, h5, h6):hover > .heading-comment, .heading-comment:focus { opacity: 0.6; }
+        body.has-toc { max-width: 68em; }
+        .layout { display: flex; gap: 2em; align-items: flex-start; }
+        .layout > main { flex: 1; min-width: 0; }
+        .toc { flex: 0 0 14em; position: sticky; top: 1em; max-height: 95vh; overflow-y: auto; font-size: 0.9em; }
+        .toc ul { list-style: none; padding-inline-start: 1em; margin: 0; }
+        .toc > ul { padding-inline-start: 1.2em; }
+        .toc-right .toc { order: 2; }
+        .toc.spy li > ul { display: none; } .toc.spy li:is(.open, .expanded) > ul { display: block; }
+        .toc-toggle { width: 1.2em; margin-inline-start: -1.2em; padding: 0; border: 0; background: none; color: inherit; cursor: pointer; font-size: 0.8em; opacity: 0.6; }
+        .toc-toggle::before { content: "▸"; } .toc-toggle[aria-expanded=true]::before { content: "▾"; }
+        .toc:not(.spy) .toc-toggle { display: none; }
+        .skip-link { position: absolute; left: -999em; top: 0.5em; z-index: 200; padding: 0.4em 0.8em; background: #fff; color: #0645ad; border: 2px solid; }
+        .skip-link:focus { left: 0.5em; }
+        .reader-toggle { position: fixed; top: 0.5em; inset-inline-end: 0.5em; z-index: 50; padding: 0.2em 0.5em; font: bold 1em serif; cursor: pointer; }
+        .reader-settings { position: fixed; top: 2.5em; inset-inline-end: 0.5em; z-index: 50; padding: 0.5em 0.8em; font: 0.85em sans-serif; color: #222; background: #fff; border: 1px solid #8886; border-radius: 6px; box-shadow: 0 4px 16px #0003; }
+        .reader-settings div { display: flex; align-items: center; gap: 0.3em; margin: 0.3em 0; } .reader-settings span { flex: 1; margin-inline-end: 0.5em; }
+        .reader-settings button[aria-pressed=true] { font-weight: bold; outline: 1px solid; }
+        :focus-visible { outline: 2px solid #0969da; outline-offset: 2px; }
+        @media (prefers-contrast: more) {
+            .page-meta, small, figcaption, .line-number, .code-block .line::before, .toc-toggle { opacity: 1 !important; }
+            .tok-c { color: inherit; }
+            .alert, .comment, .sc-step, kbd, .palette-box, .reader-settings { border-color: currentColor; }
+        }
+        @media (prefers-reduced-motion: reduce) { *, *::before, *::after { animation: none !important; transition: none !important; scroll-behavior: auto !important; } }
+        .toc a.active { font-weight: bold; }
+        table.tasks { border-collapse: collapse; width: 100%; }
+        table.tasks td, table.tasks th { border-bottom: 1px solid #8884; padding: 0.3em 0.5em; text-align: left; vertical-align: top; }
+        [dir=rtl] table.tasks td, [dir=rtl] table.tasks th { text-align: right; }
+        [dir=rtl] pre, [dir=rtl] .code-block { direction: ltr; text-align: left; }`
+
+// The script for rendered pages, none in lite mode
+func (s *Server) pageJS() template.JS {
+    if s.lite {
+        return ""
+    }
+    player, _ := json.Marshal(s.castPlayerURL())
+    return template.JS("var castPlayer = " + string(player) + ";" + baseJS)
+}
+
+// Script shared by every page. The page defines uiMessages, the
+// translations of its strings; pageJS adds castPlayer, where the asciinema
+// player is loaded from.
+const baseJS = `
+function uiText(text) {
+    return (window.uiMessages || {})[text] || text;
+}
+document.querySelectorAll('.sc-steps').forEach(function (group) {
+    var steps = group.querySelectorAll('.sc-step');
+    var bar = group.querySelector('progress');
+    var label = group.querySelector('.sc-steps-progress span');
+    group.addEventListener('change', function (e) {
+        if (!e.target.classList.contains('sc-step-done')) return;
+        var step = e.target.closest('.sc-step');
+        step.classList.toggle('done', e.target.checked);
+        if (e.target.checked) {
+            step.open = false;
+            var next = step.nextElementSibling;
+            if (next && next.classList.contains('sc-step')) next.open = true;
+        }
+        var done = group.querySelectorAll('.sc-step.done').length;
+        bar.value = done;
+        label.textContent = done + '/' + steps.length + ' done';
+    });
+});
+document.querySelectorAll('table.sortable').forEach(function (table) {
+    var body = table.tBodies[0];
+    table.querySelectorAll('thead th').forEach(function (th, col) {
+        th.addEventListener('click', function () {
+            var asc = th.getAttribute('aria-sort') !== 'ascending';
+            table.querySelectorAll('thead th').forEach(function (h) { h.removeAttribute('aria-sort'); });
+            th.setAttribute('aria-sort', asc ? 'ascending' : 'descending');
+            var rows = Array.prototype.slice.call(body.rows);
+            rows.sort(function (a, b) {
+                var x = a.cells[col] ? a.cells[col].textContent.trim() : '';
+                var y = b.cells[col] ? b.cells[col].textContent.trim() : '';
+                var nx = parseFloat(x.replace(/,/g, '')), ny = parseFloat(y.replace(/,/g, ''));
+                var c = !isNaN(nx) && !isNaN(ny) ? nx - ny : x.localeCompare(y, undefined, {numeric: true});
+                return asc ? c : -c;
+            });
+            rows.forEach(function (row) { body.appendChild(row); });
+        });
+    });
+});
+document.querySelectorAll('table.filterable').forEach(function (table) {
+    var input = document.createElement('input');
+    input.type = 'search';
+    input.placeholder = uiText('Filter rows');
+    input.className = 'table-filter';
+    table.parentNode.insertBefore(input, table);
+    input.addEventListener('input', function () {
+        var q = input.value.toLowerCase();
+        Array.prototype.forEach.call(table.tBodies[0].rows, function (row) {
+            row.hidden = q !== '' && row.textContent.toLowerCase().indexOf(q) < 0;
+        });
+    });
+});
+var casts = document.querySelectorAll('.cast-player');
+if (casts.length) {
+    // Fetched without cookies or credentials when it comes from elsewhere
+    var css = document.createElement('link');
+    css.rel = 'stylesheet';
+    css.crossOrigin = 'anonymous';
+    css.href = castPlayer + '.css';
+    document.head.appendChild(css);
+    var js = document.createElement('script');
+    js.crossOrigin = 'anonymous';
+    js.src = castPlayer + '.min.js';
+    js.onload = function () {
+        casts.forEach(function (el) {
+            var src = el.querySelector('a').href;
+            var opts = JSON.parse(el.dataset.opts || 'null') || {};
+            el.textContent = '';
+            AsciinemaPlayer.create(src, el, opts);
+        });
+    };
+    document.head.appendChild(js);
+}
+var images = Array.prototype.filter.call(document.querySelectorAll('main img'), function (img) {
+    return !img.closest('a');
+});
+if (images.length) {
+    var lightbox = document.createElement('div');
+    lightbox.className = 'lightbox';
+    lightbox.hidden = true;
+    lightbox.setAttribute('role', 'dialog');
+    lightbox.setAttribute('aria-modal', 'true');
+    lightbox.innerHTML = '<img alt=""><button class="lightbox-close">×</button>' +
+        '<button class="lightbox-prev">‹</button><button class="lightbox-next">›</button>' +
+        '<span class="lightbox-caption"></span>';
+    [['close', 'Close'], ['prev', 'Previous'], ['next', 'Next']].forEach(function (b) {
+        var button = lightbox.querySelector('.lightbox-' + b[0]);
+        button.title = uiText(b[1]);
+        button.setAttribute('aria-label', uiText(b[1]));
+    });
+    document.body.appendChild(lightbox);
+    var view = lightbox.querySelector('img'), current = 0, zoom = 1, x = 0, y = 0, drag = null, opener = null;
+    // Give focus back to the image the lightbox was opened from
+    var hide = function () {
+        lightbox.hidden = true;
+        if (opener) opener.focus();
+    };
+    var place = function () { view.style.transform = 'translate(' + x + 'px,' + y + 'px) scale(' + zoom + ')'; };
+    var show = function (i) {
+        current = (i + images.length) % images.length;
+        view.src = images[current].dataset.full || images[current].currentSrc || images[current].src;
+        lightbox.querySelector('.lightbox-caption').textContent = images[current].alt;
+        zoom = 1; x = 0; y = 0; place();
+        lightbox.setAttribute('aria-label', images[current].alt || uiText('Image'));
+        if (lightbox.hidden) opener = document.activeElement;
+        lightbox.hidden = false;
+        lightbox.querySelector('.lightbox-close').focus();
+    };
+    lightbox.querySelector('.lightbox-prev').hidden = lightbox.querySelector('.lightbox-next').hidden = images.length < 2;
+    images.forEach(function (img, i) {
+        img.classList.add('zoomable');
+        img.tabIndex = 0;
+        img.setAttribute('role', 'button');
+        img.addEventListener('click', function () { show(i); });
+        img.addEventListener('keydown', function (e) {
+            if (e.key === 'Enter' || e.key === ' ') {
+                e.preventDefault();
+                show(i);
+            }
+        });
+    });
+    lightbox.addEventListener('click', function (e) {
+        if (e.target === lightbox || e.target.classList.contains('lightbox-close')) hide();
+        else if (e.target.classList.contains('lightbox-prev')) show(current - 1);
+        else if (e.target.classList.contains('lightbox-next')) show(current + 1);
+    });
+    document.addEventListener('keydown', function (e) {
+        if (lightbox.hidden) return;
+        if (e.key === 'Escape') hide();
+        else if (e.key === 'ArrowLeft' && images.length > 1) show(current - 1);
+        else if (e.key === 'ArrowRight' && images.length > 1) show(current + 1);
+    });
+    lightbox.addEventListener('wheel', function (e) {
+        e.preventDefault();
+        zoom = Math.min(8, Math.max(1, zoom * (e.deltaY < 0 ? 1.2 : 1 / 1.2)));
+        if (zoom === 1) { x = 0; y = 0; }
+        place();
+    }, {passive: false});
+    view.addEventListener('dblclick', function () {
+        zoom = zoom === 1 ? 2 : 1; x = 0; y = 0; place();
+    });
+    view.addEventListener('pointerdown', function (e) {
+        e.preventDefault();
+        drag = {x: e.clientX - x, y: e.clientY - y};
+        view.setPointerCapture(e.pointerId);
+    });
+    view.addEventListener('pointermove', function (e) {
+        if (!drag) return;
+        x = e.clientX - drag.x; y = e.clientY - drag.y; place();
+    });
+    view.addEventListener('pointerup', function () { drag = null; });
+}
+// Streamed pages leave their contents to be listed here, once every
+// heading has arrived
+var deferredTOC = document.querySelector('nav.toc ul[data-deferred]');
+if (deferredTOC) {
+    var tocMin = +deferredTOC.dataset.min, tocMax = +deferredTOC.dataset.max;
+    var tocLists = [], tocLevels = [], tocItems = [];
+    document.querySelectorAll('.content :is(h1, h2, h3, h4, h5, h6)[id]').forEach(function (h) {
+        var level = +h.tagName.charAt(1);
+        if (level < tocMin || level > tocMax) return;
+        while (tocLevels.length && tocLevels[tocLevels.length - 1] > level) {
+            tocLevels.pop(); tocLists.pop(); tocItems.pop();
+        }
+        if (!tocLevels.length || tocLevels[tocLevels.length - 1] < level) {
+            var ul = document.createElement('ul');
+            (tocItems.length ? tocItems[tocItems.length - 1] : deferredTOC.parentNode).appendChild(ul);
+            tocLevels.push(level); tocLists.push(ul); tocItems.push(null);
+        }
+        var li = document.createElement('li'), a = document.createElement('a');
+        a.href = '#' + h.id;
+        a.textContent = h.textContent;
+        li.appendChild(a);
+        tocLists[tocLists.length - 1].appendChild(li);
+        tocItems[tocItems.length - 1] = li;
+    });
+    deferredTOC.remove();
+}
+document.querySelectorAll('.content :is(h1, h2, h3, h4, h5, h6)[id]').forEach(function (h) {
+    var a = document.createElement('a');
+    a.className = 'heading-anchor';
+    a.href = '#' + encodeURIComponent(h.id);
+    a.title = uiText('Copy link to this section');
+    a.setAttribute('aria-label', a.title);
+    a.textContent = '🔗';
+    a.addEventListener('click', function () {
+        var url = location.href.split('#')[0] + a.getAttribute('href');
+        if (navigator.clipboard) navigator.clipboard.writeText(url).then(function () {
+            a.classList.add('copied');
+            setTimeout(function () { a.classList.remove('copied'); }, 1500);
+        });
+    });
+    h.appendChild(a);
+});
+var commentForm = document.getElementById('comment-form');
+if (commentForm) document.querySelectorAll('.content :is(h1, h2, h3, h4, h5, h6)[id]').forEach(function (h) {
+    var button = document.createElement('button');
+    button.className = 'heading-comment';
+    button.type = 'button';
+    button.title = uiText('Comment on this section');
+    button.setAttribute('aria-label', button.title + ': ' + h.textContent);
+    button.textContent = '💬';
+    button.addEventListener('click', function () {
+        commentForm.heading.value = h.id;
+        commentForm.text.focus();
+        commentForm.scrollIntoView({behavior: 'smooth', block: 'center'});
+    });
+    h.appendChild(button);
+});
+document.querySelectorAll('.content pre > code').forEach(function (code) {
+    var button = document.createElement('button');
+    button.className = 'copy-code';
+    button.type = 'button';
+    button.textContent = uiText('Copy');
+    button.addEventListener('click', function () {
+        navigator.clipboard.writeText(code.innerText.replace(/\n$/, '')).then(function () {
+            button.textContent = uiText('Copied');
+            setTimeout(function () { button.textContent = uiText('Copy'); }, 1500);
+        });
+    });
+    code.parentNode.appendChild(button);
+});
+var toc = document.querySelector('nav.toc');
+if (toc && toc.querySelector('a[href^="#"]')) {
+    var links = Array.prototype.slice.call(toc.querySelectorAll('a[href^="#"]'));
+    var targets = links.map(function (a) { return document.getElementById(decodeURIComponent(a.hash.slice(1))); });
+    // Branches follow the reader, and a button beside each opens or closes
+    // it by hand, with the mouse or the keyboard
+    var toggles = [];
+    toc.querySelectorAll('li').forEach(function (li, n) {
+        var sub = li.querySelector(':scope > ul'), link = li.querySelector(':scope > a');
+        if (!sub || !link) return;
+        sub.id = sub.id || 'toc-branch-' + n;
+        var button = document.createElement('button');
+        button.type = 'button';
+        button.className = 'toc-toggle';
+        button.setAttribute('aria-controls', sub.id);
+        button.setAttribute('aria-label', uiText('Subsections of') + ' ' + link.textContent);
+        button.addEventListener('click', function () {
+            var open = button.getAttribute('aria-expanded') === 'true';
+            li.classList.toggle('expanded', !open);
+            if (open) li.classList.remove('open');
+            button.setAttribute('aria-expanded', !open);
+        });
+        li.insertBefore(button, link);
+        toggles.push(button);
+    });
+    var spy = function () {
+        var i = 0;
+        targets.forEach(function (h, j) { if (h && h.getBoundingClientRect().top <= 80) i = j; });
+        toc.querySelectorAll('.active, .open').forEach(function (el) { el.classList.remove('active', 'open'); });
+        toc.querySelectorAll('[aria-current]').forEach(function (el) { el.removeAttribute('aria-current'); });
+        links[i].classList.add('active');
+        links[i].setAttribute('aria-current', 'location');
+        for (var li = links[i].parentNode; li && li !== toc; li = li.parentNode) {
+            if (li.tagName === 'LI') li.classList.add('open');
+        }
+        toggles.forEach(function (button) {
+            var li = button.parentNode;
+            button.setAttribute('aria-expanded', li.classList.contains('open') || li.classList.contains('expanded'));
+        });
+        var top = links[i].offsetTop;
+        if (top < toc.scrollTop || top > toc.scrollTop + toc.clientHeight - 20) toc.scrollTop = top - toc.clientHeight / 2;
+    };
+    var pending = false;
+    window.addEventListener('scroll', function () {
+        if (pending) return;
+        pending = true;
+        requestAnimationFrame(function () { pending = false; spy(); });
+    }, {passive: true});
+    toc.classList.add('spy');
+    spy();
+}
+var main = document.querySelector('main#main');
+if (main) {
+    // Reading settings, kept per browser
+    var fonts = {serif: 'Georgia, "Times New Roman", serif', sans: 'system-ui, "Segoe UI", Helvetica, Arial, sans-serif', mono: 'ui-monospace, Menlo, Consolas, monospace'};
+    var widths = {narrow: 35, medium: 50, wide: 70};
+    var reader = {};
+    try { reader = JSON.parse(localStorage.getItem('mdserve-reader')) || {}; } catch (e) {}
+    var applyReader = function () {
+        main.style.fontSize = reader.size ? reader.size + '%' : '';
+        main.style.fontFamily = fonts[reader.font] || '';
+        document.body.style.maxWidth = widths[reader.width] ? widths[reader.width] + (document.body.classList.contains('has-toc') ? 18 : 0) + 'em' : '';
+        settings.querySelectorAll('[data-set]').forEach(function (b) {
+            var kv = b.dataset.set.split('=');
+            b.setAttribute('aria-pressed', reader[kv[0]] === kv[1]);
+        });
+        try { localStorage.setItem('mdserve-reader', JSON.stringify(reader)); } catch (e) {}
+    };
+    var toggle = document.createElement('button'), settings = document.createElement('div');
+    toggle.type = 'button';
+    toggle.className = 'reader-toggle';
+    toggle.textContent = 'Aa';
+    toggle.title = uiText('Reading settings');
+    toggle.setAttribute('aria-label', toggle.title);
+    toggle.setAttribute('aria-expanded', 'false');
+    toggle.setAttribute('aria-controls', 'reader-settings');
+    settings.id = 'reader-settings';
+    settings.className = 'reader-settings';
+    settings.hidden = true;
+    settings.setAttribute('role', 'group');
+    settings.setAttribute('aria-label', toggle.title);
+    var row = function (label, buttons) {
+        var div = document.createElement('div'), span = document.createElement('span');
+        span.textContent = uiText(label);
+        div.appendChild(span);
+        buttons.forEach(function (b) {
+            var button = document.createElement('button');
+            button.type = 'button';
+            button.textContent = uiText(b[0]);
+            if (b[1].indexOf('=') > 0) button.dataset.set = b[1];
+            else button.dataset.size = b[1];
+            div.appendChild(button);
+        });
+        settings.appendChild(div);
+    };
+    row('Text size', [['Smaller', '-10'], ['Reset', '0'], ['Larger', '10']]);
+    row('Line width', [['Narrow', 'width=narrow'], ['Medium', 'width=medium'], ['Wide', 'width=wide']]);
+    row('Font', [['Serif', 'font=serif'], ['Sans', 'font=sans'], ['Mono', 'font=mono']]);
+    settings.addEventListener('click', function (e) {
+        var b = e.target.closest('button');
+        if (!b) return;
+        if (b.dataset.size) {
+            reader.size = b.dataset.size === '0' ? 0 : Math.min(200, Math.max(70, (reader.size || 100) + Number(b.dataset.size)));
+        } else {
+            var kv = b.dataset.set.split('=');
+            reader[kv[0]] = reader[kv[0]] === kv[1] ? '' : kv[1];
+        }
+        applyReader();
+    });
+    var showSettings = function (show) {
+        settings.hidden = !show;
+        toggle.setAttribute('aria-expanded', show);
+        if (show) settings.querySelector('button').focus();
+    };
+    toggle.addEventListener('click', function () { showSettings(settings.hidden); });
+    settings.addEventListener('keydown', function (e) {
+        if (e.key === 'Escape') {
+            showSettings(false);
+            toggle.focus();
+        }
+    });
+    document.body.appendChild(toggle);
+    document.body.appendChild(settings);
+    applyReader();
+}
+var manifest = document.querySelector('link[rel=manifest]');
+if (manifest && 'serviceWorker' in navigator) {
+    navigator.serviceWorker.register(manifest.href.replace(/manifest\.webmanifest$/, 'sw.js'));
+}
+if (manifest) {
+    var palette = document.createElement('div'), files = null, matches = [], selected = 0;
+    palette.className = 'palette';
+    palette.hidden = true;
+    palette.setAttribute('role', 'dialog');
+    palette.setAttribute('aria-modal', 'true');
+    palette.innerHTML = '<div class="palette-box"><input type="text" role="combobox" aria-controls="palette-list" aria-expanded="true" aria-autocomplete="list">' +
+        '<ul id="palette-list" role="listbox"></ul></div>';
+    document.body.appendChild(palette);
+    var query = palette.querySelector('input'), list = palette.querySelector('ul'), opener = null;
+    query.placeholder = uiText('Go to document');
+    palette.setAttribute('aria-label', query.placeholder);
+    query.setAttribute('aria-label', query.placeholder);
+    var closePalette = function () {
+        palette.hidden = true;
+        if (opener) opener.focus();
+    };
+    var root = manifest.href.replace(/manifest\.webmanifest$/, '');
+    // Characters of q in order, scoring consecutive runs and word starts higher
+    var fuzzy = function (q, text) {
+        text = text.toLowerCase();
+        var score = 0, run = 0, at = 0;
+        for (var i = 0; i < q.length; i++) {
+            var j = text.indexOf(q[i], at);
+            if (j < 0) return -1;
+            run = j === at ? run + 1 : 1;
+            score += run + (j === 0 || /[\s\/._-]/.test(text[j - 1]) ? 3 : 0);
+            at = j + 1;
+        }
+        return score - text.length / 100;
+    };
+    var draw = function () {
+        list.innerHTML = '';
+        matches.slice(0, 50).forEach(function (f, i) {
+            var li = document.createElement('li');
+            li.textContent = f.title || f.name;
+            var small = document.createElement('small');
+            small.textContent = f.name;
+            li.appendChild(small);
+            li.id = 'palette-item-' + i;
+            li.setAttribute('role', 'option');
+            li.setAttribute('aria-selected', i === selected);
+            if (i === selected) li.className = 'selected';
+            li.addEventListener('click', function () { location.href = root + f.path; });
+            list.appendChild(li);
+        });
+        var current = list.querySelector('.selected');
+        if (current) current.scrollIntoView({block: 'nearest'});
+        query.setAttribute('aria-activedescendant', current ? current.id : '');
+    };
+    var update = function () {
+        var q = query.value.toLowerCase().replace(/\s+/g, '');
+        matches = (files || []).map(function (f) {
+            return {f: f, score: q ? Math.max(fuzzy(q, f.title || ''), fuzzy(q, f.name)) : 0};
+        }).filter(function (m) { return m.score >= 0; }).sort(function (a, b) { return b.score - a.score; }).map(function (m) { return m.f; });
+        selected = 0;
+        draw();
+    };
+    var openPalette = function () {
+        if (palette.hidden) opener = document.activeElement;
+        palette.hidden = false;
+        query.value = '';
+        query.focus();
+        if (files) return update();
+        fetch(root + 'api/files').then(function (resp) { return resp.json(); }).then(function (data) { files = data; update(); });
+    };
+    document.addEventListener('keydown', function (e) {
+        if ((e.ctrlKey || e.metaKey) && e.key.toLowerCase() === 'p') {
+            e.preventDefault();
+            openPalette();
+        } else if (!palette.hidden && e.key === 'Escape') {
+            closePalette();
+        }
+    });
+    query.addEventListener('input', update);
+    query.addEventListener('keydown', function (e) {
+        if (e.key === 'ArrowDown' || e.key === 'ArrowUp') {
+            e.preventDefault();
+            selected = Math.max(0, Math.min(Math.min(matches.length, 50) - 1, selected + (e.key === 'ArrowDown' ? 1 : -1)));
+            draw();
+        } else if (e.key === 'Enter' && matches[selected]) {
+            location.href = root + matches[selected].path;
+        }
+    });
+    palette.addEventListener('click', function (e) { if (e.target === palette) closePalette(); });
+}
+var editable = document.querySelector('[data-edit]');
+if (editable) editable.querySelectorAll('.task-checkbox[data-task]').forEach(function (box) {
+    box.disabled = false;
+    box.addEventListener('change', function () {
+        var form = new URLSearchParams({task: box.dataset.task, checked: box.checked});
+        fetch(editable.dataset.edit, {method: 'POST', body: form}).then(function (resp) {
+            if (resp.ok) return;
+            box.checked = !box.checked;
+            resp.text().then(function (msg) { alert(uiText('Could not save: ') + msg); });
+        });
+    });
+});
+// Reload the page once its file changes on disk, unless a comment is
+// being written; the browser reconnects to the feed by itself
+var live = document.querySelector('[data-live]');
+if (live && window.EventSource) {
+    var reloadSoon;
+    var reloadPage = function () {
+        clearTimeout(reloadSoon);
+        reloadSoon = setTimeout(function () {
+            var typing = Array.prototype.some.call(document.querySelectorAll('textarea'), function (t) { return t.value; });
+            if (!typing) location.reload();
+        }, 300); // Editors often write a file in several steps
+    };
+    var feed = new EventSource(live.dataset.live);
+    feed.addEventListener('created', reloadPage);
+    feed.addEventListener('changed', reloadPage);
+}
+`
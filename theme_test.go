@@ -0,0 +1,140 @@
+package mdserve
+
+import (
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// The permalink anchors are added in the browser to the headings with an
+// id inside the content, so check those ids are there and unique
+func TestHeadingAnchors(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{"doc.md": "# Café & more\n\n## Setup\n\n## Setup\n\n## Custom {#mine}\n"})
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    want := []string{
+        `<div class="content" data-edit="/edit/doc.md"><h1 id="café-more">Café &amp; more</h1>`,
+        `<h2 id="setup">Setup</h2>`,
+        `<h2 id="setup-1">Setup</h2>`,
+        `<h2 id="mine">Custom</h2>`,
+        "document.querySelectorAll('.content :is(h1, h2, h3, h4, h5, h6)[id]')",
+        "a.className = 'heading-anchor';",
+        ".heading-anchor.copied::after",
+    }
+    for _, w := range want {
+        if !strings.Contains(page, w) {
+            t.Errorf("%q missing from %q", w, page)
+        }
+    }
+}
+
+// Keyboard and screen reader support: what the server renders, and the
+// roles and labels the script gives the widgets it builds
+func TestAccessiblePages(t *testing.T) {
+    s, _ := newTestServer(t, map[string]string{"doc.md": "# Doc\n\n## Part\n\n![A cat](cat.png)\n"})
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    want := []string{
+        `<a class="skip-link" href="#main">Skip to content</a>`,
+        `<main id="main" tabindex="-1"`,
+        `<nav class="toc" aria-label="Contents">`,
+        ".skip-link:focus { left: 0.5em; }",
+        ":focus-visible { outline: 2px solid",
+        "@media (prefers-reduced-motion: reduce)",
+        "lightbox.setAttribute('role', 'dialog');",
+        "button.setAttribute('aria-controls', sub.id);",
+        "links[i].setAttribute('aria-current', 'location');",
+        `role="combobox" aria-controls="palette-list"`,
+    }
+    for _, w := range want {
+        if !strings.Contains(page, w) {
+            t.Errorf("%q missing from %q", w, page)
+        }
+    }
+
+    s, _ = newTestServer(t, map[string]string{"doc.md": "# Doc\n"}, WithLanguage("de"))
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); !strings.Contains(page, `<a class="skip-link" href="#main">Zum Inhalt springen</a>`) {
+        t.Errorf("skip link not translated in %q", page)
+    }
+}
+
+// The reading settings are built by the page script, with their labels
+// from the page's catalog
+func TestReadingSettings(t *testing.T) {
+    files := map[string]string{"doc.md": "# Doc\n"}
+    s, _ := newTestServer(t, files, WithLanguage("de"))
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    for _, want := range []string{
+        "var main = document.querySelector('main#main');",
+        "localStorage.getItem('mdserve-reader')",
+        "row('Text size', [['Smaller', '-10'], ['Reset', '0'], ['Larger', '10']]);",
+        `"Text size":"Schriftgröße"`,
+        ".reader-settings button[aria-pressed=true]",
+    } {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing from %q", want, page)
+        }
+    }
+
+    s, _ = newTestServer(t, files, WithLite())
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); strings.Contains(page, "mdserve-reader") {
+        t.Error("reading settings in lite mode")
+    }
+}
+
+// WCAG contrast ratio of a #rrggbb or #rgb colour against white
+func contrastOnWhite(hex string) float64 {
+    hex = strings.TrimPrefix(hex, "#")
+    if len(hex) == 3 {
+        hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+    }
+    lum := 0.0
+    for i, weight := range []float64{0.2126, 0.7152, 0.0722} {
+        v, _ := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+        c := float64(v) / 255
+        if c <= 0.03928 {
+            c /= 12.92
+        } else {
+            c = math.Pow((c+0.055)/1.055, 2.4)
+        }
+        lum += weight * c
+    }
+    return 1.05 / (lum + 0.05)
+}
+
+func TestContrastTheme(t *testing.T) {
+    // Every text colour of the theme meets WCAG AAA on its white page
+    colors := regexp.MustCompile(`[^-]color: (#[0-9a-f]{3,6})\b`).FindAllStringSubmatch(Themes["contrast"], -1)
+    if len(colors) < 5 {
+        t.Fatalf("only found colours %q", colors)
+    }
+    for _, m := range colors {
+        if ratio := contrastOnWhite(m[1]); ratio < 7 {
+            t.Errorf("%s has a contrast ratio of %.1f", m[1], ratio)
+        }
+    }
+
+    tests := []struct {
+        name  string
+        src   string
+        opts  []Option
+        theme string
+    }{
+        {"server default", "# Doc\n", []Option{WithTheme("contrast")}, "contrast"},
+        {"frontmatter", "---\ntheme: contrast\n---\n# Doc\n", nil, "contrast"},
+        {"light", "# Doc\n", nil, "light"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, map[string]string{"doc.md": tt.src}, tt.opts...)
+            page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+            if !strings.Contains(page, `<body class="theme-`+tt.theme) {
+                t.Errorf("not in the %s theme: %q", tt.theme, page)
+            }
+            // Other themes follow the reader's contrast preference
+            if !strings.Contains(page, "@media (prefers-contrast: more)") {
+                t.Error("prefers-contrast not honoured")
+            }
+        })
+    }
+}
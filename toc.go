@@ -0,0 +1,157 @@
+package mdserve
+
+import (
+    "fmt"
+    "html"
+    "html/template"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// One heading of a rendered page
+type tocEntry struct {
+    Level int
+    ID    string
+    Text  string
+}
+
+var headingTag = regexp.MustCompile(`(?s)<h([1-6]) id="([^"]+)"[^>]*>(.*?)</h[1-6]>`)
+var htmlTag = regexp.MustCompile(`<[^>]+>`)
+
+// Collect the headings that carry an id from rendered HTML
+func extractTOC(page []byte) []tocEntry {
+    var toc []tocEntry
+    for _, m := range headingTag.FindAllSubmatch(page, -1) {
+        text := html.UnescapeString(htmlTag.ReplaceAllString(string(m[3]), ""))
+        toc = append(toc, tocEntry{Level: int(m[1][0] - '0'), ID: string(m[2]), Text: strings.TrimSpace(text)})
+    }
+    return toc
+}
+
+// Where a document's TOC goes: the toc frontmatter key ("false", "none",
+// "left" or "right") overrides the server's position, except in lite mode
+func (s *Server) tocPositionFor(fm frontMatter) string {
+    switch v := fm.Get("toc"); {
+    case v == "false" || v == "none":
+        return "none"
+    case (v == "left" || v == "right") && !s.lite:
+        return v
+    }
+    return s.tocPosition
+}
+
+// The TOC levels from min to max, each bound overridable by the
+// toc_min_level and toc_max_level frontmatter keys
+func tocLevelsFor(fm frontMatter, min, max int) (int, int) {
+    if n, err := strconv.Atoi(fm.Get("toc_min_level")); err == nil {
+        min = n
+    }
+    if n, err := strconv.Atoi(fm.Get("toc_max_level")); err == nil {
+        max = n
+    }
+    return min, max
+}
+
+// The entries with levels from min to max, see tocLevelsFor
+func filterTOC(toc []tocEntry, fm frontMatter, min, max int) []tocEntry {
+    min, max = tocLevelsFor(fm, min, max)
+    var out []tocEntry
+    for _, e := range toc {
+        if e.Level >= min && e.Level <= max {
+            out = append(out, e)
+        }
+    }
+    return out
+}
+
+// Number headings 1., 1.1, 1.2.3 and so on, in the page and its TOC alike
+func numberSections(page string, toc []tocEntry) (string, []tocEntry) {
+    numbers := sectionNumbers(toc)
+    numbered := append([]tocEntry{}, toc...)
+    for i, e := range toc {
+        if num, ok := numbers[e.ID]; ok {
+            numbered[i].Text = num + " " + e.Text
+        }
+    }
+    return numberHeadings(page, numbers), numbered
+}
+
+// The section number of each heading by id. A lone h1 opening the page is
+// its title and stays unnumbered.
+func sectionNumbers(toc []tocEntry) map[string]string {
+    start := 0
+    if len(toc) > 0 && toc[0].Level == 1 {
+        start = 1
+        for _, e := range toc[1:] {
+            if e.Level == 1 {
+                start = 0
+            }
+        }
+    }
+    numbers := map[string]string{}
+    var stack []struct{ level, count int } // Open sections, outermost first
+    for i := start; i < len(toc); i++ {
+        e := toc[i]
+        // A heading after a deeper one that skipped its level, like h3
+        // after h4, continues that one's count
+        next := 1
+        for len(stack) > 0 && stack[len(stack)-1].level > e.Level {
+            next = stack[len(stack)-1].count + 1
+            stack = stack[:len(stack)-1]
+        }
+        if len(stack) > 0 && stack[len(stack)-1].level == e.Level {
+            stack[len(stack)-1].count++
+        } else {
+            stack = append(stack, struct{ level, count int }{e.Level, next})
+        }
+        var parts []string
+        for _, sec := range stack {
+            parts = append(parts, strconv.Itoa(sec.count))
+        }
+        num := strings.Join(parts, ".")
+        if len(parts) == 1 {
+            num += "."
+        }
+        numbers[e.ID] = num
+    }
+    return numbers
+}
+
+// Put section numbers, see sectionNumbers, in front of the headings
+func numberHeadings(page string, numbers map[string]string) string {
+    return headingTag.ReplaceAllStringFunc(page, func(m string) string {
+        num, ok := numbers[headingTag.FindStringSubmatch(m)[2]]
+        if !ok {
+            return m
+        }
+        i := strings.IndexByte(m, '>') + 1
+        return m[:i] + `<span class="section-number">` + num + "</span> " + m[i:]
+    })
+}
+
+// Render headings as nested lists following their levels
+func renderTOC(toc []tocEntry) template.HTML {
+    if len(toc) == 0 {
+        return ""
+    }
+    var out strings.Builder
+    var stack []int // Levels of the open lists
+    for _, e := range toc {
+        for len(stack) > 0 && stack[len(stack)-1] > e.Level {
+            out.WriteString("</li></ul>")
+            stack = stack[:len(stack)-1]
+        }
+        if len(stack) == 0 || stack[len(stack)-1] < e.Level {
+            out.WriteString("<ul>")
+            stack = append(stack, e.Level)
+        } else {
+            out.WriteString("</li>")
+        }
+        fmt.Fprintf(&out, `<li><a href="#%s">%s</a>`, template.HTMLEscapeString(e.ID), template.HTMLEscapeString(e.Text))
+    }
+    for range stack {
+        out.WriteString("</li></ul>")
+    }
+    return template.HTML(out.String())
+}
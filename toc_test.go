@@ -0,0 +1,179 @@
+package mdserve
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+// The scroll spy runs in the browser, so check what it works from: nested
+// TOC links whose targets are in the page, and the script and styles
+func TestTOCScrollSpy(t *testing.T) {
+    files := map[string]string{"doc.md": "# Title\n\n## One\n\n### One A\n\n## Two\n"}
+    s, _ := newTestServer(t, files)
+    page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+    if want := `<nav class="toc" aria-label="Contents"><ul><li><a href="#title">Title</a><ul><li><a href="#one">One</a><ul><li><a href="#one-a">One A</a></li></ul></li><li><a href="#two">Two</a></li></ul></li></ul></nav>`; !strings.Contains(page, want) {
+        t.Errorf("%q missing from %q", want, page)
+    }
+    for _, id := range strings.Fields(tocLinks(page)) {
+        if !strings.Contains(page, `id="`+id+`"`) {
+            t.Errorf("no heading for TOC link #%s", id)
+        }
+    }
+    for _, want := range []string{"toc.classList.add('spy')", ".toc.spy li > ul { display: none; }", ".toc a.active { font-weight: bold; }"} {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing", want)
+        }
+    }
+
+    s, _ = newTestServer(t, files, WithLite())
+    if page := doRequest(s, "GET", "/doc.md", nil, true).Body.String(); strings.Contains(page, "toc.classList") {
+        t.Error("script in lite mode")
+    }
+}
+
+// The TOC's link targets, in order
+func tocLinks(page string) string {
+    nav := regexp.MustCompile(`<nav class="toc".*?</nav>`).FindString(page)
+    var ids []string
+    for _, m := range regexp.MustCompile(`href="#([^"]+)"`).FindAllStringSubmatch(nav, -1) {
+        ids = append(ids, m[1])
+    }
+    return strings.Join(ids, " ")
+}
+
+func TestTOCLevels(t *testing.T) {
+    const body = "# Title\n\n## One\n\n### One A\n\n#### Deep\n\n## Two\n"
+    tests := []struct {
+        name string
+        fm   string
+        opts []Option
+        want string
+    }{
+        {"all by default", "", nil, "title one one-a deep two"},
+        {"server levels", "", []Option{WithTOCLevels(2, 3)}, "one one-a two"},
+        {"frontmatter levels", "toc_min_level: 2\ntoc_max_level: 2\n", nil, "one two"},
+        {"frontmatter overrides one bound", "toc_max_level: 4\n", []Option{WithTOCLevels(2, 3)}, "one one-a deep two"},
+        {"no levels left", "toc_min_level: 5\n", nil, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            src := body
+            if tt.fm != "" {
+                src = "---\n" + tt.fm + "---\n" + body
+            }
+            s, _ := newTestServer(t, map[string]string{"doc.md": src}, tt.opts...)
+            page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+            if got := tocLinks(page); got != tt.want {
+                t.Errorf("got TOC %q, want %q", got, tt.want)
+            }
+            if !strings.Contains(page, `<h4 id="deep">`) {
+                t.Error("heading left out of the page")
+            }
+        })
+    }
+}
+
+func TestTOCPositionFrontMatter(t *testing.T) {
+    tests := []struct {
+        name string
+        toc  string // The toc key
+        opts []Option
+        want string // The layout's class, "" without a TOC
+    }{
+        {"server default", "", nil, "toc-left"},
+        {"moved right", "right", nil, "toc-right"},
+        {"moved left", "left", []Option{WithTOCPosition("right")}, "toc-left"},
+        {"turned off", "false", nil, ""},
+        {"none", "none", nil, ""},
+        {"shown where the server has none", "right", []Option{WithTOCPosition("none")}, "toc-right"},
+        {"unknown value", "top", []Option{WithTOCPosition("right")}, "toc-right"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            src := "# Title\n\n## Part\n"
+            if tt.toc != "" {
+                src = "---\ntoc: " + tt.toc + "\n---\n" + src
+            }
+            s, _ := newTestServer(t, map[string]string{"doc.md": src}, tt.opts...)
+            page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+            if tt.want == "" {
+                if strings.Contains(page, `<nav class="toc"`) {
+                    t.Errorf("TOC in %q", page)
+                }
+                return
+            }
+            if !strings.Contains(page, `<div class="layout `+tt.want+`"`) || tocLinks(page) != "title part" {
+                t.Errorf("no %s TOC in %q", tt.want, page)
+            }
+        })
+    }
+}
+
+func TestSectionNumbers(t *testing.T) {
+    tests := []struct {
+        name   string
+        levels []int
+        want   string // Each heading's number, "-" when unnumbered
+    }{
+        {"title stays unnumbered", []int{1, 2, 3, 3, 2}, "- 1. 1.1 1.2 2."},
+        {"several h1s", []int{1, 2, 1}, "1. 1.1 2."},
+        {"no title", []int{2, 2, 3}, "1. 2. 2.1"},
+        {"skipped level", []int{1, 2, 4, 3}, "- 1. 1.1 1.2"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var toc []tocEntry
+            for i, level := range tt.levels {
+                toc = append(toc, tocEntry{Level: level, ID: strconv.Itoa(i)})
+            }
+            numbers := sectionNumbers(toc)
+            var got []string
+            for _, e := range toc {
+                if num, ok := numbers[e.ID]; ok {
+                    got = append(got, num)
+                } else {
+                    got = append(got, "-")
+                }
+            }
+            if strings.Join(got, " ") != tt.want {
+                t.Errorf("got %q, want %q", strings.Join(got, " "), tt.want)
+            }
+        })
+    }
+}
+
+func TestNumberedPage(t *testing.T) {
+    const body = "# Title\n\n## Intro\n\n### Scope\n"
+    tests := []struct {
+        name     string
+        fm       string
+        opts     []Option
+        numbered bool
+    }{
+        {"off by default", "", nil, false},
+        {"server option", "", []Option{WithSectionNumbers()}, true},
+        {"frontmatter on", "number_sections: true\n", nil, true},
+        {"frontmatter off", "number_sections: false\n", []Option{WithSectionNumbers()}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            src := body
+            if tt.fm != "" {
+                src = "---\n" + tt.fm + "---\n" + body
+            }
+            s, _ := newTestServer(t, map[string]string{"doc.md": src}, tt.opts...)
+            page := doRequest(s, "GET", "/doc.md", nil, true).Body.String()
+            want := []string{`<h1 id="title">Title</h1>`, `<h2 id="intro"><span class="section-number">1.</span> Intro</h2>`, `<h3 id="scope"><span class="section-number">1.1</span> Scope</h3>`, `<a href="#intro">1. Intro</a>`, `<a href="#scope">1.1 Scope</a>`}
+            for _, w := range want[1:] {
+                if strings.Contains(page, w) != tt.numbered {
+                    t.Errorf("%q: got %v, want %v", w, !tt.numbered, tt.numbered)
+                }
+            }
+            if !strings.Contains(page, want[0]) {
+                t.Errorf("title numbered in %q", page)
+            }
+        })
+    }
+}
@@ -0,0 +1,98 @@
+package mdserve
+
+import (
+    "os"
+    "path"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// Languages recognised in translated file names and directories, with
+// the names the switcher shows them by
+var languageNames = map[string]string{
+    "ar": "العربية", "bg": "Български", "ca": "Català", "cs": "Čeština", "da": "Dansk",
+    "de": "Deutsch", "el": "Ελληνικά", "en": "English", "es": "Español", "et": "Eesti",
+    "fa": "فارسی", "fi": "Suomi", "fr": "Français", "he": "עברית", "hi": "हिन्दी",
+    "hu": "Magyar", "id": "Bahasa Indonesia", "it": "Italiano", "ja": "日本語", "ko": "한국어",
+    "lt": "Lietuvių", "lv": "Latviešu", "nb": "Norsk bokmål", "nl": "Nederlands", "pl": "Polski",
+    "pt": "Português", "ro": "Română", "ru": "Русский", "sk": "Slovenčina", "sl": "Slovenščina",
+    "sv": "Svenska", "th": "ไทย", "tr": "Türkçe", "uk": "Українська", "vi": "Tiếng Việt",
+    "zh": "中文",
+}
+
+// One language version of a document
+type translation struct {
+    Lang    string
+    Name    string
+    Path    string // URL path without the leading slash
+    Current bool
+}
+
+// The language versions of a document, found either as page.de.md next to
+// page.md or as the same path under sibling language directories (en/,
+// de/, ...) at the mount root. A page.md without a suffix is in the
+// language of its lang frontmatter, or English. Nil when there is only the
+// one version.
+func (s *Server) translations(m *Mount, file, urlFile string) []translation {
+    rel, err := filepath.Rel(m.Root, file)
+    if err != nil {
+        return nil
+    }
+    rel = filepath.ToSlash(rel)
+    found := map[string]string{} // Language to path relative to the mount
+
+    // Suffixes: page.md, page.de.md, page.ja.md
+    dir, base := path.Split(rel)
+    ext := path.Ext(base)
+    stem := strings.TrimSuffix(base, ext)
+    if lang := strings.TrimPrefix(path.Ext(stem), "."); languageNames[lang] != "" {
+        stem = strings.TrimSuffix(stem, "."+lang)
+    }
+    entries, _ := os.ReadDir(filepath.Join(m.Root, filepath.FromSlash(dir)))
+    for _, e := range entries {
+        name := e.Name()
+        if e.IsDir() || !strings.HasPrefix(name, stem+".") || !strings.HasSuffix(name, ext) {
+            continue
+        }
+        lang := strings.TrimSuffix(strings.TrimPrefix(name, stem+"."), ext)
+        if name == stem+ext {
+            lang = readFrontMatter(filepath.Join(m.Root, filepath.FromSlash(dir+name))).Get("lang")
+            if languageNames[lang] == "" {
+                lang = "en"
+            }
+        }
+        if languageNames[lang] != "" {
+            found[lang] = dir + name
+        }
+    }
+
+    // Trees: en/guide/setup.md, de/guide/setup.md
+    if top, rest, ok := strings.Cut(rel, "/"); ok && languageNames[top] != "" && len(found) < 2 {
+        found = map[string]string{}
+        dirs, _ := os.ReadDir(m.Root)
+        for _, d := range dirs {
+            if !d.IsDir() || languageNames[d.Name()] == "" {
+                continue
+            }
+            if _, err := os.Stat(filepath.Join(m.Root, d.Name(), filepath.FromSlash(rest))); err == nil {
+                found[d.Name()] = d.Name() + "/" + rest
+            }
+        }
+    }
+
+    if len(found) < 2 {
+        return nil
+    }
+    var list []translation
+    for lang, p := range found {
+        list = append(list, translation{
+            Lang:    lang,
+            Name:    languageNames[lang],
+            Path:    strings.TrimPrefix(path.Join(m.Prefix, p), "/"),
+            Current: p == rel,
+        })
+    }
+    sort.Slice(list, func(i, j int) bool { return list[i].Lang < list[j].Lang })
+    return list
+}
@@ -0,0 +1,58 @@
+package mdserve
+
+import (
+    "regexp"
+    "strings"
+    "testing"
+)
+
+// The switcher's languages in order, the current one starred
+func languageSwitcher(page string) string {
+    nav := regexp.MustCompile(`(?s)<nav class="translations".*?</nav>`).FindString(page)
+    var langs []string
+    for _, m := range regexp.MustCompile(`<(strong|a href="[^"]*" hreflang="[^"]*") lang="([^"]+)"`).FindAllStringSubmatch(nav, -1) {
+        if m[1] == "strong" {
+            m[2] += "*"
+        }
+        langs = append(langs, m[2])
+    }
+    return strings.Join(langs, " ")
+}
+
+func TestTranslations(t *testing.T) {
+    tests := []struct {
+        name   string
+        files  map[string]string
+        target string
+        opts   []Option
+        want   string
+    }{
+        {"suffixes", map[string]string{"page.md": "A\n", "page.de.md": "B\n", "page.fr.md": "C\n"}, "/page.de.md", nil, "de* en fr"},
+        {"unsuffixed in English", map[string]string{"page.md": "A\n", "page.de.md": "B\n"}, "/page.md", nil, "de en*"},
+        {"unsuffixed with lang", map[string]string{"page.md": "---\nlang: fr\n---\nA\n", "page.de.md": "B\n"}, "/page.md", nil, "de fr*"},
+        {"not a language", map[string]string{"page.md": "A\n", "page.v2.md": "B\n"}, "/page.md", nil, ""},
+        {"other documents", map[string]string{"page.md": "A\n", "pages.de.md": "B\n"}, "/page.md", nil, ""},
+        {"trees", map[string]string{"en/guide/setup.md": "A\n", "de/guide/setup.md": "B\n", "fr/guide/other.md": "C\n"}, "/en/guide/setup.md", nil, "de en*"},
+        {"lite mode", map[string]string{"page.md": "A\n", "page.de.md": "B\n"}, "/page.md", []Option{WithLite()}, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, tt.files, tt.opts...)
+            page := doRequest(s, "GET", tt.target, nil, true).Body.String()
+            if got := languageSwitcher(page); got != tt.want {
+                t.Errorf("got languages %q, want %q", got, tt.want)
+            }
+        })
+    }
+
+    s, _ := newTestServer(t, map[string]string{"page.md": "A\n", "page.de.md": "B\n"})
+    page := doRequest(s, "GET", "/page.de.md", nil, true).Body.String()
+    for _, want := range []string{`<link rel="alternate" hreflang="en" href="/page.md">`, `<div class="content" lang="de"`, `<a href="/page.md" hreflang="en" lang="en">English</a>`} {
+        if !strings.Contains(page, want) {
+            t.Errorf("%q missing from %q", want, page)
+        }
+    }
+    if strings.Contains(page, `hreflang="de" href=`) {
+        t.Error("the page is its own alternate")
+    }
+}
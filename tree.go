@@ -0,0 +1,220 @@
+package mdserve
+
+import (
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+    "log"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// How long a listed tree is reused. Watched trees are listed again as
+// soon as something in them is added or removed; the longer limit covers
+// changes the watcher misses, such as those made by other machines on a
+// network filesystem.
+const (
+    treeTTL        = 10 * time.Second
+    treeWatchedTTL = time.Minute
+)
+
+// Directories read at once while listing a tree
+const treeScanners = 16
+
+// The documents of each mount, listed once and reused until they may
+// have changed
+type treeCache struct {
+    mu        sync.Mutex
+    watched   bool
+    trees     map[*Mount]*cachedTree
+    truncated map[*Mount]bool // Whether the last listing hit a limit
+}
+
+type cachedTree struct {
+    entries []IndexEntry
+    listed  time.Time
+    once    sync.Once // Lists the tree; requests arriving meanwhile wait for it
+    err     error
+
+    linksMu sync.Mutex
+    links   *mountLinks // Redirects and aliases, read on the first 404
+}
+
+// The mount's documents in file system walk order, from the cache when it
+// is fresh. Callers may change the slice they get.
+func (s *Server) buildIndex(m *Mount) ([]IndexEntry, error) {
+    if s.lite || s.dev {
+        return s.scanTree(m)
+    }
+    c := &s.trees
+    c.mu.Lock()
+    t := c.trees[m]
+    ttl := treeTTL
+    if c.watched {
+        ttl = treeWatchedTTL
+    }
+    if t == nil || t.err != nil || (!t.listed.IsZero() && time.Since(t.listed) > ttl) {
+        t = &cachedTree{}
+        if c.trees == nil {
+            c.trees = map[*Mount]*cachedTree{}
+        }
+        c.trees[m] = t
+    }
+    c.mu.Unlock()
+
+    t.once.Do(func() {
+        t.entries, t.err = s.scanTree(m)
+        t.listed = time.Now()
+    })
+    if t.err != nil {
+        return nil, t.err
+    }
+    return append([]IndexEntry(nil), t.entries...), nil
+}
+
+// Forget every listed tree, so the next request lists them again
+func (c *treeCache) invalidate() {
+    c.mu.Lock()
+    c.trees = nil
+    c.mu.Unlock()
+}
+
+// The listed tree of a mount, nil when there is none yet
+func (c *treeCache) tree(m *Mount) *cachedTree {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.trees[m]
+}
+
+// Forget the redirects and aliases read from the listed trees, when a
+// document or _redirects file changed without the trees changing
+func (c *treeCache) forgetLinks() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for _, t := range c.trees {
+        t.linksMu.Lock()
+        t.links = nil
+        t.linksMu.Unlock()
+    }
+}
+
+// Whether a mount's last listing stopped at Config.MaxDepth or MaxFiles
+func (c *treeCache) isTruncated(m *Mount) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.truncated[m]
+}
+
+// Record whether a listing was cut short, logging when it first is
+func (c *treeCache) setTruncated(m *Mount, truncated bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if truncated && !c.truncated[m] {
+        log.Printf("Listing of %s truncated at the depth or file limit", m.Root)
+    }
+    if c.truncated == nil {
+        c.truncated = map[*Mount]bool{}
+    }
+    c.truncated[m] = truncated
+}
+
+func (c *treeCache) isWatched() bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.watched
+}
+
+func (c *treeCache) setWatched(watched bool) {
+    c.mu.Lock()
+    c.watched = watched
+    c.mu.Unlock()
+}
+
+// List the documents under a mount, reading directories concurrently,
+// which matters on network filesystems where each read waits for a round
+// trip. Entries come in the order filepath.Walk would give them. Reading
+// stops at the depth and file limits, so a server pointed at a home
+// directory by mistake stays responsive.
+func (s *Server) scanTree(m *Mount) ([]IndexEntry, error) {
+    info, err := os.Lstat(m.Root)
+    if err != nil {
+        return nil, err
+    }
+    if !info.IsDir() {
+        if s.isDocument(m.Root) {
+            return []IndexEntry{{Path: strings.TrimPrefix(path.Join(m.Prefix, "."), "/"), Name: ".", File: m.Root}}, nil
+        }
+        return nil, nil
+    }
+
+    sem := make(chan struct{}, treeScanners)
+    var found atomic.Int64
+    var truncated atomic.Bool
+    var scan func(dir, rel string, depth int) ([]IndexEntry, error)
+    scan = func(dir, rel string, depth int) ([]IndexEntry, error) {
+        if s.maxFiles > 0 && found.Load() >= int64(s.maxFiles) {
+            truncated.Store(true)
+            return nil, nil
+        }
+        sem <- struct{}{}
+        list, err := os.ReadDir(dir)
+        <-sem
+        if err != nil {
+            return nil, err
+        }
+
+        // Each entry's documents, filled in by a goroutine per directory
+        parts := make([][]IndexEntry, len(list))
+        errs := make([]error, len(list))
+        var wg sync.WaitGroup
+        for i, e := range list {
+            file := filepath.Join(dir, e.Name())
+            name := path.Join(rel, e.Name())
+            if s.ignored(m.Root, file, e.IsDir()) {
+                continue
+            }
+            if e.IsDir() {
+                if s.maxDepth > 0 && depth+1 >= s.maxDepth {
+                    truncated.Store(true)
+                    continue
+                }
+                wg.Add(1)
+                go func(i int) {
+                    defer wg.Done()
+                    parts[i], errs[i] = scan(file, name, depth+1)
+                }(i)
+                continue
+            }
+            if s.isDocument(file) {
+                found.Add(1)
+                parts[i] = []IndexEntry{{
+                    Path: strings.TrimPrefix(path.Join(m.Prefix, name), "/"),
+                    Name: name,
+                    File: file,
+                }}
+            }
+        }
+        wg.Wait()
+
+        var entries []IndexEntry
+        for i := range list {
+            if errs[i] != nil {
+                return nil, errs[i]
+            }
+            entries = append(entries, parts[i]...)
+        }
+        return entries, nil
+    }
+    entries, err := scan(m.Root, "", 0)
+    if err != nil {
+        return nil, err
+    }
+    if s.maxFiles > 0 && len(entries) > s.maxFiles {
+        entries = entries[:s.maxFiles]
+        truncated.Store(true)
+    }
+    s.trees.setTruncated(m, truncated.Load())
+    return entries, nil
+}
@@ -0,0 +1,164 @@
+package mdserve
+
+import (
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "github.com/fsnotify/fsnotify"
+)
+
+// Watch keeps the search index current as documents change, so searches
+// only render what changed since the index was stored, and lets index
+// pages reuse the listed trees until files are added or removed. Changes
+// go out to the clients of /api/events.
+// Documents that changed while the server was down are indexed in the
+// background first. Close the returned watcher to stop.
+func (s *Server) Watch() (io.Closer, error) {
+    w, err := fsnotify.NewWatcher()
+    if err != nil {
+        return nil, err
+    }
+    for _, st := range s.allSites() {
+        for _, m := range st.mounts {
+            // fsnotify watches directories, so a single document, such as
+            // piped standard input, is watched through the one holding it
+            // and fileChanged leaves out the files beside it
+            if m.single {
+                err = w.Add(filepath.Dir(m.Root))
+            } else {
+                err = s.watchTree(w, m.Root, m.Root)
+            }
+            if err != nil {
+                w.Close()
+                return nil, err
+            }
+        }
+    }
+
+    s.trees.setWatched(true)
+    go s.refreshSearchIndex()
+
+    go func() {
+        for {
+            select {
+            case ev, ok := <-w.Events:
+                if !ok {
+                    return
+                }
+                s.fileChanged(w, ev)
+            case err, ok := <-w.Errors:
+                if !ok {
+                    return
+                }
+                log.Printf("Watch: %v", err)
+                s.trees.invalidate() // Events may have been lost
+            }
+        }
+    }()
+    return &treeWatcher{w, s}, nil
+}
+
+type treeWatcher struct {
+    *fsnotify.Watcher
+    s *Server
+}
+
+func (w *treeWatcher) Close() error {
+    w.s.trees.setWatched(false)
+    w.s.events.closeAll()
+    return w.Watcher.Close()
+}
+
+// Scanned returns a channel that is closed once Watch has brought the
+// search index up to date with the files at startup, so the server can
+// report itself ready, such as to systemd
+func (s *Server) Scanned() <-chan struct{} {
+    return s.scanned
+}
+
+// Bring the stored index up to date with the files, dropping documents
+// that are gone. Servers can share a Store, so entries outside this
+// server's roots are left alone.
+func (s *Server) refreshSearchIndex() {
+    defer s.scannedOnce.Do(func() { close(s.scanned) })
+    seen := map[string]bool{}
+    for _, st := range s.allSites() {
+        docs, err := s.siteDocs(st, nil)
+        if err != nil {
+            log.Printf("Search index: %v", err)
+            return
+        }
+        for _, doc := range docs {
+            s.searchDocFor(doc.File)
+            seen[doc.File] = true
+        }
+    }
+    keys, err := s.store.Keys(searchBucket)
+    if err != nil {
+        log.Printf("Search index: %v", err)
+        return
+    }
+    for _, file := range keys {
+        if !seen[file] && s.rootOf(file) != "" {
+            s.forgetSearchFile(file)
+        }
+    }
+    s.events.publish("scanned", "")
+}
+
+// Watch a directory under root and those below it, leaving out hidden and
+// ignored ones
+func (s *Server) watchTree(w *fsnotify.Watcher, root, dir string) error {
+    return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+        if err != nil || !info.IsDir() {
+            return nil
+        }
+        if hidden(dir, file, info) || s.ignored(root, file, true) {
+            return filepath.SkipDir
+        }
+        return w.Add(file)
+    })
+}
+
+// Update the search index and listed trees for one file system event, and
+// tell the clients of /api/events
+func (s *Server) fileChanged(w *fsnotify.Watcher, ev fsnotify.Event) {
+    if s.rootOf(ev.Name) == "" {
+        return // Beside a single document
+    }
+    if ev.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+        s.trees.invalidate()
+    }
+    if filepath.Base(ev.Name) == "_redirects" || s.isDocument(ev.Name) {
+        s.trees.forgetLinks()
+    }
+    if isIgnoreFile(filepath.Base(ev.Name)) {
+        s.ignoreFiles.forget(filepath.Dir(ev.Name))
+        s.trees.invalidate()
+    }
+    info, err := os.Stat(ev.Name)
+    switch {
+    case err != nil:
+        // Removed or renamed away; fsnotify drops watches of removed directories
+        s.forgetSearchFile(ev.Name)
+        if root := s.rootOf(ev.Name); s.isDocument(ev.Name) && !strings.HasPrefix(filepath.Base(ev.Name), ".") && !s.ignored(root, ev.Name, false) {
+            s.events.publish("removed", ev.Name)
+        }
+    case info.IsDir():
+        if ev.Op&fsnotify.Create != 0 {
+            if err := s.watchTree(w, s.rootOf(ev.Name), ev.Name); err != nil {
+                log.Printf("Watch: %v", err)
+            }
+        }
+    case ev.Op&(fsnotify.Create|fsnotify.Write) != 0 && s.isDocument(ev.Name) && !hidden("", ev.Name, info):
+        if ev.Op&fsnotify.Create != 0 {
+            s.events.publish("created", ev.Name)
+        } else {
+            s.events.publish("changed", ev.Name)
+        }
+        s.indexSearchFile(ev.Name)
+        s.events.publish("indexed", ev.Name)
+    }
+}
@@ -0,0 +1,68 @@
+package mdserve
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// Tenants sharing a Store keep each other's search entries
+func TestRefreshSearchIndexSharedStore(t *testing.T) {
+    store, err := OpenBoltStore(filepath.Join(t.TempDir(), "state.db"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer store.Close()
+    a, rootA := newTestServer(t, map[string]string{"a.md": "# A\n"}, WithStore(store))
+    b, rootB := newTestServer(t, map[string]string{"b.md": "# B\n"}, WithStore(store))
+    a.refreshSearchIndex()
+    b.refreshSearchIndex()
+    a.refreshSearchIndex()
+    for _, file := range []string{filepath.Join(rootA, "a.md"), filepath.Join(rootB, "b.md")} {
+        if data, err := store.Get(searchBucket, file); err != nil || data == nil {
+            t.Errorf("%s: entry gone (%v)", file, err)
+        }
+    }
+}
+
+// A single document is watched through its directory, leaving out the
+// files beside it
+func TestWatchSingleDocument(t *testing.T) {
+    dir := t.TempDir()
+    doc, other := filepath.Join(dir, "doc.md"), filepath.Join(dir, "other.md")
+    for _, file := range []string{doc, other} {
+        if err := os.WriteFile(file, []byte("# Hi\n"), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    s := New(WithMounts(Mount{Prefix: "/", Root: doc}))
+    w, err := s.Watch()
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer w.Close()
+    events, _ := s.events.subscribe(0)
+    defer s.events.unsubscribe(events)
+
+    if err := os.WriteFile(other, []byte("# Other\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(doc, []byte("# Changed\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    timeout := time.After(5 * time.Second)
+    for {
+        select {
+        case ev := <-events:
+            if ev.File == other {
+                t.Fatalf("%s event for the file beside the document", ev.Type)
+            }
+            if ev.Type == "changed" && ev.File == doc {
+                return
+            }
+        case <-timeout:
+            t.Fatal("no changed event for the document")
+        }
+    }
+}
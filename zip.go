@@ -0,0 +1,94 @@
+package mdserve
+
+import (
+    "archive/zip"
+    "io"
+    "log"
+    "mime"
+    "net/http"
+    "os"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// Stream /zip/<dir> as a zip archive of the directory's documents and
+// assets. Hidden files and directories, ignored ones, drafts the reader
+// may not see, and the .gpg files the plaintext was decrypted from, are
+// left out, as they are from listings.
+func (s *Server) zipHandler(w http.ResponseWriter, r *http.Request, st *site) {
+    m, dir, urlDir := s.resolveRequest(st, strings.TrimPrefix(r.URL.Path, "/zip"))
+    if m == nil {
+        http.Error(w, "Directory not found", http.StatusNotFound)
+        return
+    }
+    // A mount prefix resolves to its index file; archive the root instead
+    if urlDir == strings.TrimPrefix(path.Join(m.Prefix, m.Index), "/") {
+        dir, urlDir = m.Root, strings.TrimPrefix(m.Prefix, "/")
+    }
+    if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+        http.Error(w, "Directory not found", http.StatusNotFound)
+        return
+    }
+    drafts := s.showDrafts(r)
+
+    name := path.Base("/" + urlDir)
+    if name == "/" {
+        abs, _ := filepath.Abs(dir)
+        name = filepath.Base(abs)
+    }
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+
+    archive := zip.NewWriter(w)
+    err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+        if hidden(dir, file, info) || s.ignored(m.Root, file, info.IsDir()) {
+            if info.IsDir() {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+        if info.IsDir() || !info.Mode().IsRegular() || strings.HasSuffix(file, ".gpg") {
+            return nil
+        }
+        if !drafts && s.isDocument(file) && isDraft(file) {
+            return nil
+        }
+        rel, err := filepath.Rel(dir, file)
+        if err != nil {
+            return err
+        }
+        header, err := zip.FileInfoHeader(info)
+        if err != nil {
+            return err
+        }
+        header.Name = path.Join(name, filepath.ToSlash(rel))
+        header.Method = zip.Deflate
+        out, err := archive.CreateHeader(header)
+        if err != nil {
+            return err
+        }
+        f, err := os.Open(file)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        _, err = io.Copy(out, f)
+        return err
+    })
+    if err == nil {
+        err = archive.Close()
+    }
+    // Headers are gone by now, so all that's left is to log it
+    if err != nil {
+        log.Printf("Zip %s: %v", dir, err)
+    }
+}
+
+// Whether a file or directory under root is hidden, like .git or .secret.key
+func hidden(root, file string, info os.FileInfo) bool {
+    return strings.HasPrefix(info.Name(), ".") && file != root
+}
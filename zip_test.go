@@ -0,0 +1,63 @@
+package mdserve
+
+import (
+    "archive/zip"
+    "bytes"
+    "net/http"
+    "sort"
+    "strings"
+    "testing"
+)
+
+func TestZipLeavesOutWhatListingsDo(t *testing.T) {
+    files := map[string]string{
+        "a.md":                "# A",
+        "sub/b.md":            "# B",
+        "sub/image.png":       "png",
+        "draft.md":            "---\ndraft: true\n---\n# Draft",
+        ".secret.md":          "# Hidden",
+        "node_modules/x/x.js": "// Ignored by default",
+        "notes.tmp":           "Ignored with -ignore",
+        "private/c.md":        "# Ignored by .mdserveignore",
+        ".mdserveignore":      "private/\n",
+        "secret.md.gpg":       "encrypted",
+    }
+    tests := []struct {
+        name   string
+        target string
+        opts   []Option
+        want   string // Archived files, "" for a 404
+    }{
+        {"whole tree", "/zip/", nil, "a.md sub/b.md sub/image.png"},
+        {"with drafts", "/zip/", []Option{WithDrafts()}, "a.md draft.md sub/b.md sub/image.png"},
+        {"subdirectory", "/zip/sub", nil, "b.md image.png"},
+        {"ignored directory", "/zip/node_modules", nil, ""},
+        {"directory in an ignored one", "/zip/node_modules/x", nil, ""},
+        {"directory of an ignore file", "/zip/private", nil, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, _ := newTestServer(t, files, append([]Option{WithIgnore("*.tmp")}, tt.opts...)...)
+            w := doRequest(s, "GET", tt.target, nil, true)
+            if tt.want == "" {
+                if w.Code != http.StatusNotFound {
+                    t.Errorf("got %d, want 404", w.Code)
+                }
+                return
+            }
+            z, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+            if err != nil {
+                t.Fatalf("%d: %v", w.Code, err)
+            }
+            var got []string
+            for _, f := range z.File {
+                _, name, _ := strings.Cut(f.Name, "/")
+                got = append(got, name)
+            }
+            sort.Strings(got)
+            if strings.Join(got, " ") != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}